@@ -2,8 +2,22 @@ package v1alpha1
 
 // IndexImageMetadataRegistry is the field index for the registry of an image.
 const (
-	IndexImageMetadataRegistry = "imageMetadata.registry"
-	IndexImageMetadataDigest   = "imageMetadata.digest"
+	IndexImageMetadataRegistry   = "imageMetadata.registry"
+	IndexImageMetadataRepository = "imageMetadata.repository"
+	IndexImageMetadataTag        = "imageMetadata.tag"
+	IndexImageMetadataPlatform   = "imageMetadata.platform"
+	IndexImageMetadataDigest     = "imageMetadata.digest"
+)
+
+type BaseImageSource string
+
+// Enumeration of the ways a base image can be detected
+const (
+	// BaseImageSourceAnnotations identifies a base image declared by the
+	// org.opencontainers.image.base.name and org.opencontainers.image.base.digest annotations
+	BaseImageSourceAnnotations BaseImageSource = "Annotations"
+	// BaseImageSourceLayers identifies a base image whose layers are the first layers of the image
+	BaseImageSourceLayers BaseImageSource = "Layers"
 )
 
 // ImageMetadata contains the metadata details of an image.
@@ -20,6 +34,22 @@ type ImageMetadata struct {
 	Platform string `json:"platform" protobuf:"bytes,5,req,name=platform"`
 	// Digest specifies the sha256 digest of the image.
 	Digest string `json:"digest" protobuf:"bytes,6,req,name=digest"`
+	// BaseImage specifies the image the image was built from, when it could be detected.
+	BaseImage *BaseImage `json:"baseImage,omitempty" protobuf:"bytes,7,opt,name=baseImage"`
+}
+
+// BaseImage contains the reference of the image another image was built from.
+type BaseImage struct {
+	// RegistryURI specifies the URI of the registry where the base image is stored. Example: "index.docker.io".
+	RegistryURI string `json:"registryURI" protobuf:"bytes,1,req,name=registryURI"`
+	// Repository specifies the repository path of the base image. Example: "library/alpine".
+	Repository string `json:"repository" protobuf:"bytes,2,req,name=repository"`
+	// Tag specifies the tag of the base image, when known. Example: "3.20".
+	Tag string `json:"tag,omitempty" protobuf:"bytes,3,opt,name=tag"`
+	// Digest specifies the sha256 digest of the base image, when known.
+	Digest string `json:"digest,omitempty" protobuf:"bytes,4,opt,name=digest"`
+	// Source specifies how the base image was detected. Either "Annotations" or "Layers".
+	Source BaseImageSource `json:"source" protobuf:"bytes,5,req,name=source,casttype=BaseImageSource"`
 }
 
 type ImageMetadataAccessor interface {
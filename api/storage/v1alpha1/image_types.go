@@ -42,6 +42,9 @@ type ImageLayer struct {
 	Digest string `json:"digest" protobuf:"bytes,2,req,name=digest"`
 	// diffID is the Hash of the uncompressed layer
 	DiffID string `json:"diffID" protobuf:"bytes,3,req,name=diffID"`
+	// baseImage tells if the layer belongs to the base image,
+	// rather than being created by the image build
+	BaseImage bool `json:"baseImage,omitempty" protobuf:"varint,4,opt,name=baseImage"`
 }
 
 func (i *Image) GetImageMetadata() ImageMetadata {
@@ -53,6 +53,104 @@ type Report struct {
 
 	// Results per target (e.g., layer, package type)
 	Results []Result `json:"results" protobuf:"bytes,2,rep,name=results"`
+
+	// Scanner is the engine that produced the report (e.g., "trivy", "grype")
+	Scanner string `json:"scanner,omitempty" protobuf:"bytes,3,opt,name=scanner"`
+
+	// Database identifies the vulnerability database used by the scanner
+	Database *VulnerabilityDatabase `json:"database,omitempty" protobuf:"bytes,4,opt,name=database"`
+
+	// Layers groups the vulnerabilities by the image layer introducing them
+	Layers []LayerReport `json:"layers,omitempty" protobuf:"bytes,5,rep,name=layers"`
+
+	// BaseImageUpgrades lists the newer tags of the base image repository, with the CVEs they fix
+	BaseImageUpgrades []BaseImageUpgrade `json:"baseImageUpgrades,omitempty" protobuf:"bytes,6,rep,name=baseImageUpgrades"`
+
+	// Delta contains the changes since the previous scan of the image
+	// (empty on the first scan)
+	Delta *ReportDelta `json:"delta,omitempty" protobuf:"bytes,7,opt,name=delta"`
+
+	// ScannedAt is when the image was last scanned
+	ScannedAt *metav1.Time `json:"scannedAt,omitempty" protobuf:"bytes,8,opt,name=scannedAt"`
+}
+
+// ReportDelta contains the changes of the vulnerabilities between two successive scans of an image.
+// Suppressed vulnerabilities are ignored.
+type ReportDelta struct {
+	// PreviousScanJobUID is the UID of the ScanJob of the previous scan
+	PreviousScanJobUID string `json:"previousScanJobUID,omitempty" protobuf:"bytes,1,opt,name=previousScanJobUID"`
+
+	// Introduced lists the vulnerabilities not found by the previous scan
+	Introduced []VulnerabilityChange `json:"introduced" protobuf:"bytes,2,rep,name=introduced"`
+
+	// Fixed lists the vulnerabilities found by the previous scan only
+	Fixed []VulnerabilityChange `json:"fixed" protobuf:"bytes,3,rep,name=fixed"`
+
+	// SeverityChanges lists the vulnerabilities whose severity changed since the previous scan
+	SeverityChanges []VulnerabilityChange `json:"severityChanges" protobuf:"bytes,4,rep,name=severityChanges"`
+}
+
+// VulnerabilityChange identifies a vulnerability of a package that changed between two scans.
+type VulnerabilityChange struct {
+	// CVE identifier
+	CVE string `json:"cve" protobuf:"bytes,1,req,name=cve"`
+
+	// PURL (Package URL) of the vulnerable package
+	PURL string `json:"purl" protobuf:"bytes,2,req,name=purl"`
+
+	// Severity rating of the vulnerability
+	Severity string `json:"severity" protobuf:"bytes,3,req,name=severity"`
+
+	// PreviousSeverity is the severity rating of the vulnerability in the previous scan
+	// (set for severity changes only)
+	PreviousSeverity string `json:"previousSeverity,omitempty" protobuf:"bytes,4,opt,name=previousSeverity"`
+}
+
+// BaseImageUpgrade contains the CVEs fixed by moving to a newer tag of the base image repository.
+type BaseImageUpgrade struct {
+	// Tag of the base image repository
+	Tag string `json:"tag" protobuf:"bytes,1,req,name=tag"`
+
+	// Digest of the image the tag points to
+	Digest string `json:"digest" protobuf:"bytes,2,req,name=digest"`
+
+	// FixedCVEs are the CVEs of the base image layers not affecting the tag
+	FixedCVEs []string `json:"fixedCVEs" protobuf:"bytes,3,rep,name=fixedCVEs"`
+}
+
+// LayerReport contains the vulnerabilities introduced by an image layer.
+type LayerReport struct {
+	// DiffID is the Hash of the uncompressed layer
+	DiffID string `json:"diffID" protobuf:"bytes,1,req,name=diffID"`
+
+	// Digest is the Hash of the compressed layer
+	Digest string `json:"digest,omitempty" protobuf:"bytes,2,opt,name=digest"`
+
+	// Command is the command that led to the creation of the layer
+	Command string `json:"command,omitempty" protobuf:"bytes,3,opt,name=command"`
+
+	// BaseImage tells if the layer belongs to the base image.
+	// Vulnerabilities of base image layers are fixed by updating the base image,
+	// the other ones by changing the image build.
+	BaseImage bool `json:"baseImage" protobuf:"varint,4,req,name=baseImage"`
+
+	// Summary of the vulnerabilities introduced by the layer
+	Summary Summary `json:"summary" protobuf:"bytes,5,req,name=summary"`
+
+	// CVEs introduced by the layer, excluding the suppressed ones
+	CVEs []string `json:"cves" protobuf:"bytes,6,rep,name=cves"`
+}
+
+// VulnerabilityDatabase identifies a version of a vulnerability database.
+type VulnerabilityDatabase struct {
+	// Version is the schema version of the database
+	Version string `json:"version" protobuf:"bytes,1,req,name=version"`
+
+	// UpdatedAt is the time the database was built
+	UpdatedAt *metav1.Time `json:"updatedAt,omitempty" protobuf:"bytes,2,opt,name=updatedAt"`
+
+	// Digest of the database OCI artifact, when downloaded from a registry
+	Digest string `json:"digest,omitempty" protobuf:"bytes,3,opt,name=digest"`
 }
 
 // Summary provides a high-level overview of the vulnerabilities found.
@@ -162,6 +260,9 @@ type Vulnerability struct {
 
 	// VEXStatus information
 	VEXStatus *VEXStatus `json:"vexStatus,omitempty" protobuf:"bytes,14,opt,name=vexStatus"`
+
+	// FirstSeen is when the vulnerability was found in the image for the first time
+	FirstSeen *metav1.Time `json:"firstSeen,omitempty" protobuf:"bytes,15,opt,name=firstSeen"`
 }
 
 func (v *VulnerabilityReport) GetImageMetadata() ImageMetadata {
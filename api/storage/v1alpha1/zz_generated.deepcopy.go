@@ -9,6 +9,43 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BaseImage) DeepCopyInto(out *BaseImage) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaseImage.
+func (in *BaseImage) DeepCopy() *BaseImage {
+	if in == nil {
+		return nil
+	}
+	out := new(BaseImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BaseImageUpgrade) DeepCopyInto(out *BaseImageUpgrade) {
+	*out = *in
+	if in.FixedCVEs != nil {
+		in, out := &in.FixedCVEs, &out.FixedCVEs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaseImageUpgrade.
+func (in *BaseImageUpgrade) DeepCopy() *BaseImageUpgrade {
+	if in == nil {
+		return nil
+	}
+	out := new(BaseImageUpgrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CVSS) DeepCopyInto(out *CVSS) {
 	*out = *in
@@ -30,7 +67,7 @@ func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.ImageMetadata = in.ImageMetadata
+	in.ImageMetadata.DeepCopyInto(&out.ImageMetadata)
 	if in.Layers != nil {
 		in, out := &in.Layers, &out.Layers
 		*out = make([]ImageLayer, len(*in))
@@ -109,6 +146,11 @@ func (in *ImageList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageMetadata) DeepCopyInto(out *ImageMetadata) {
 	*out = *in
+	if in.BaseImage != nil {
+		in, out := &in.BaseImage, &out.BaseImage
+		*out = new(BaseImage)
+		**out = **in
+	}
 	return
 }
 
@@ -122,6 +164,28 @@ func (in *ImageMetadata) DeepCopy() *ImageMetadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LayerReport) DeepCopyInto(out *LayerReport) {
+	*out = *in
+	out.Summary = in.Summary
+	if in.CVEs != nil {
+		in, out := &in.CVEs, &out.CVEs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LayerReport.
+func (in *LayerReport) DeepCopy() *LayerReport {
+	if in == nil {
+		return nil
+	}
+	out := new(LayerReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Report) DeepCopyInto(out *Report) {
 	*out = *in
@@ -133,6 +197,34 @@ func (in *Report) DeepCopyInto(out *Report) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Database != nil {
+		in, out := &in.Database, &out.Database
+		*out = new(VulnerabilityDatabase)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Layers != nil {
+		in, out := &in.Layers, &out.Layers
+		*out = make([]LayerReport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BaseImageUpgrades != nil {
+		in, out := &in.BaseImageUpgrades, &out.BaseImageUpgrades
+		*out = make([]BaseImageUpgrade, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Delta != nil {
+		in, out := &in.Delta, &out.Delta
+		*out = new(ReportDelta)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScannedAt != nil {
+		in, out := &in.ScannedAt, &out.ScannedAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -146,6 +238,37 @@ func (in *Report) DeepCopy() *Report {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReportDelta) DeepCopyInto(out *ReportDelta) {
+	*out = *in
+	if in.Introduced != nil {
+		in, out := &in.Introduced, &out.Introduced
+		*out = make([]VulnerabilityChange, len(*in))
+		copy(*out, *in)
+	}
+	if in.Fixed != nil {
+		in, out := &in.Fixed, &out.Fixed
+		*out = make([]VulnerabilityChange, len(*in))
+		copy(*out, *in)
+	}
+	if in.SeverityChanges != nil {
+		in, out := &in.SeverityChanges, &out.SeverityChanges
+		*out = make([]VulnerabilityChange, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReportDelta.
+func (in *ReportDelta) DeepCopy() *ReportDelta {
+	if in == nil {
+		return nil
+	}
+	out := new(ReportDelta)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Result) DeepCopyInto(out *Result) {
 	*out = *in
@@ -174,7 +297,7 @@ func (in *SBOM) DeepCopyInto(out *SBOM) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.ImageMetadata = in.ImageMetadata
+	in.ImageMetadata.DeepCopyInto(&out.ImageMetadata)
 	in.SPDX.DeepCopyInto(&out.SPDX)
 	return
 }
@@ -287,6 +410,10 @@ func (in *Vulnerability) DeepCopyInto(out *Vulnerability) {
 		*out = new(VEXStatus)
 		**out = **in
 	}
+	if in.FirstSeen != nil {
+		in, out := &in.FirstSeen, &out.FirstSeen
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -300,12 +427,48 @@ func (in *Vulnerability) DeepCopy() *Vulnerability {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VulnerabilityChange) DeepCopyInto(out *VulnerabilityChange) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VulnerabilityChange.
+func (in *VulnerabilityChange) DeepCopy() *VulnerabilityChange {
+	if in == nil {
+		return nil
+	}
+	out := new(VulnerabilityChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VulnerabilityDatabase) DeepCopyInto(out *VulnerabilityDatabase) {
+	*out = *in
+	if in.UpdatedAt != nil {
+		in, out := &in.UpdatedAt, &out.UpdatedAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VulnerabilityDatabase.
+func (in *VulnerabilityDatabase) DeepCopy() *VulnerabilityDatabase {
+	if in == nil {
+		return nil
+	}
+	out := new(VulnerabilityDatabase)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VulnerabilityReport) DeepCopyInto(out *VulnerabilityReport) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.ImageMetadata = in.ImageMetadata
+	in.ImageMetadata.DeepCopyInto(&out.ImageMetadata)
 	in.Report.DeepCopyInto(&out.Report)
 	return
 }
@@ -13,6 +13,18 @@ const (
 	CatalogTypeOCIDistribution = "OCIDistribution"
 )
 
+const (
+	// CredentialProviderAWS obtains the credentials of Amazon ECR registries
+	// using IAM roles for service accounts (IRSA) or EKS Pod Identity.
+	CredentialProviderAWS = "AWS"
+	// CredentialProviderGCP obtains the credentials of Google Container Registry
+	// and Artifact Registry using GKE workload identity.
+	CredentialProviderGCP = "GCP"
+	// CredentialProviderAzure obtains the credentials of Azure Container Registry
+	// using AKS workload identity.
+	CredentialProviderAzure = "Azure"
+)
+
 // RegistrySpec defines the desired state of Registry
 type RegistrySpec struct {
 	// URI is the URI of the container registry
@@ -24,9 +36,27 @@ type RegistrySpec struct {
 	Repositories []string `json:"repositories,omitempty"`
 	// AuthSecret is the name of the secret in the same namespace that contains the credentials to access the registry.
 	AuthSecret string `json:"authSecret,omitempty"`
+	// CredentialProvider is the cloud provider used to obtain short-lived credentials to access the registry,
+	// using the workload identity of the worker.
+	// It cannot be used together with AuthSecret.
+	// +kubebuilder:validation:Enum=AWS;GCP;Azure
+	// +optional
+	CredentialProvider string `json:"credentialProvider,omitempty"`
 	// ScanInterval is the interval at which the registry is scanned.
-	// If not set, automatic scanning is disabled.
+	// If neither ScanInterval nor Schedule are set, automatic scanning is disabled.
 	ScanInterval *metav1.Duration `json:"scanInterval,omitempty"`
+	// Schedule is a cron expression defining when the registry is scanned, e.g. "0 2 * * *".
+	// It cannot be used together with ScanInterval.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+	// TimeZone is the IANA time zone used to evaluate Schedule and BlackoutWindows, e.g. "Europe/Berlin".
+	// If not set, UTC is used.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// BlackoutWindows are the periods of time during which automatic scans are not started.
+	// A scan that becomes due during a blackout window is started once the window ends.
+	// +optional
+	BlackoutWindows []BlackoutWindow `json:"blackoutWindows,omitempty"`
 	// CABundle is the CA bundle to use when connecting to the registry.
 	CABundle string `json:"caBundle,omitempty"`
 	// Insecure allows insecure connections to the registry when set to true.
@@ -34,12 +64,82 @@ type RegistrySpec struct {
 	// Platforms allows to specify the list of platform to scan.
 	// If not set, all the available platforms of a container image will be scanned.
 	Platforms []Platform `json:"platforms,omitempty"`
+	// Mirrors is the list of mirrors used to pull the images of the registry, in order of preference.
+	// Each mirror is a host, with an optional port and path prefix, e.g. "mirror.example.com:5000/docker.io".
+	// When a mirror cannot serve a request, the next one is tried, falling back to the registry URI.
+	// Images are always recorded with the registry URI, regardless of the mirror used to pull them.
+	// +optional
+	Mirrors []string `json:"mirrors,omitempty"`
+	// RateLimit configures the client side throttling of the requests sent to the registry.
+	// If not set, requests are not throttled, but rate limit responses sent by the registry are still honored.
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	// SuccessfulScanJobsHistoryLimit is the number of completed ScanJobs of the registry to keep.
+	// If not set, it defaults to 10.
+	// If neither history limit is set, the 10 most recent finished ScanJobs are kept, whatever their status.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulScanJobsHistoryLimit *int32 `json:"successfulScanJobsHistoryLimit,omitempty"`
+	// FailedScanJobsHistoryLimit is the number of failed or cancelled ScanJobs of the registry to keep.
+	// If not set, it defaults to 10.
+	// If neither history limit is set, the 10 most recent finished ScanJobs are kept, whatever their status.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedScanJobsHistoryLimit *int32 `json:"failedScanJobsHistoryLimit,omitempty"`
+	// ScanJobsHistoryMaxAge is how long finished ScanJobs of the registry are kept, e.g. "720h".
+	// If not set, ScanJobs are deleted only when exceeding the history limits.
+	// +optional
+	ScanJobsHistoryMaxAge *metav1.Duration `json:"scanJobsHistoryMaxAge,omitempty"`
 }
 
+// DefaultScanJobsHistoryLimit is the number of finished ScanJobs kept for a registry, whatever their status,
+// when no history limit is set, and the default value of the history limit that is not set otherwise.
+const DefaultScanJobsHistoryLimit = 10
+
+// RateLimit defines the client side rate limit used when talking to a registry.
+type RateLimit struct {
+	// RequestsPerSecond is the maximum sustained number of requests per second sent to the registry.
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerSecond int `json:"requestsPerSecond"`
+	// Burst is the maximum number of requests that can be sent at once, exceeding RequestsPerSecond.
+	// If not set, it defaults to RequestsPerSecond.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Burst int `json:"burst,omitempty"`
+}
+
+// BlackoutWindow is a recurring period of time during which automatic scans are not started.
+type BlackoutWindow struct {
+	// Start is a cron expression defining when the window starts, e.g. "0 9 * * 1-5".
+	Start string `json:"start"`
+	// Duration is how long the window lasts, e.g. "8h".
+	Duration metav1.Duration `json:"duration"`
+}
+
+const (
+	// RegistryConditionTypeReady tells whether the registry could be scanned by the most recent ScanJob.
+	RegistryConditionTypeReady = "Ready"
+	// RegistryConditionTypeValidated tells whether the registry could be reached with the configured
+	// TLS settings and credentials, the last time the controller probed it.
+	RegistryConditionTypeValidated = "Validated"
+)
+
+const (
+	RegistryReasonScanSucceeded        = "ScanSucceeded"
+	RegistryReasonScanFailed           = "ScanFailed"
+	RegistryReasonAuthFailed           = "AuthFailed"
+	RegistryReasonUnreachable          = "Unreachable"
+	RegistryReasonNotScanned           = "NotScanned"
+	RegistryReasonValidated            = "Validated"
+	RegistryReasonTLSFailed            = "TLSFailed"
+	RegistryReasonCatalogUnavailable   = "CatalogUnavailable"
+	RegistryReasonInvalidConfiguration = "InvalidConfiguration"
+)
+
 // RegistryStatus defines the observed state of Registry
 type RegistryStatus struct {
 	// Represents the observations of a Registry's current state.
-	// Registry.status.conditions.type are: "Discovering", "Scanning", and "UpToDate"
+	// Registry.status.conditions.type are: "Ready" and "Validated"
 	// Registry.status.conditions.status are one of True, False, Unknown.
 	// Registry.status.conditions.reason the value should be a CamelCase string and producers of specific
 	// condition types may define expected values and meanings for this field, and whether the values
@@ -48,6 +148,65 @@ type RegistryStatus struct {
 	// For further information see: https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#typical-status-properties
 
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// NextScanTime is when the next automatic scan is expected to start.
+	// It is not set when automatic scanning is disabled, or when it depends on a running scan.
+	// +optional
+	NextScanTime *metav1.Time `json:"nextScanTime,omitempty"`
+
+	// LastValidationTime is when the controller last probed the registry to set the Validated condition.
+	// +optional
+	LastValidationTime *metav1.Time `json:"lastValidationTime,omitempty"`
+
+	// LastScanJob is the summary of the most recent finished ScanJob of the registry.
+	// +optional
+	LastScanJob *ScanJobSummary `json:"lastScanJob,omitempty"`
+
+	// RepositoriesCount is the number of repositories cataloged in the registry.
+	// +optional
+	RepositoriesCount int `json:"repositoriesCount,omitempty"`
+
+	// ImagesCount is the number of images cataloged in the registry, counting each platform of an image separately.
+	// +optional
+	ImagesCount int `json:"imagesCount,omitempty"`
+
+	// PlatformsCount is the number of distinct platforms of the images cataloged in the registry.
+	// +optional
+	PlatformsCount int `json:"platformsCount,omitempty"`
+
+	// Vulnerabilities is the number of vulnerabilities found in the images of the registry, per severity.
+	// +optional
+	Vulnerabilities *VulnerabilitiesSummary `json:"vulnerabilities,omitempty"`
+}
+
+// ScanJobSummary is the outcome of a finished ScanJob.
+type ScanJobSummary struct {
+	// Name is the name of the ScanJob.
+	Name string `json:"name"`
+	// Result is how the ScanJob finished: Complete, Failed or Cancelled.
+	Result string `json:"result"`
+	// Reason is the reason of the condition the ScanJob finished with.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// CompletionTime is when the ScanJob finished.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// ScannedImagesCount is the number of images scanned by the ScanJob.
+	// +optional
+	ScannedImagesCount int `json:"scannedImagesCount,omitempty"`
+	// FailedImagesCount is the number of images that could not be scanned by the ScanJob.
+	// +optional
+	FailedImagesCount int `json:"failedImagesCount,omitempty"`
+}
+
+// VulnerabilitiesSummary is the number of vulnerabilities per severity.
+type VulnerabilitiesSummary struct {
+	Critical   int `json:"critical"`
+	High       int `json:"high"`
+	Medium     int `json:"medium"`
+	Low        int `json:"low"`
+	Unknown    int `json:"unknown"`
+	Suppressed int `json:"suppressed"`
 }
 
 // Platform describes the platform which the image in the manifest runs on.
@@ -74,6 +233,15 @@ func (p *Platform) String() string {
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="URI",type="string",JSONPath=".spec.uri"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].status"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type=='Ready')].reason"
+// +kubebuilder:printcolumn:name="Images",type="integer",JSONPath=".status.imagesCount"
+// +kubebuilder:printcolumn:name="Critical",type="integer",JSONPath=".status.vulnerabilities.critical"
+// +kubebuilder:printcolumn:name="High",type="integer",JSONPath=".status.vulnerabilities.high"
+// +kubebuilder:printcolumn:name="Last Scan",type="date",JSONPath=".status.lastScanJob.completionTime"
+// +kubebuilder:printcolumn:name="Next Scan",type="date",JSONPath=".status.nextScanTime"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Registry is the Schema for the registries API
 type Registry struct {
@@ -84,9 +252,36 @@ type Registry struct {
 	Status RegistryStatus `json:"status,omitempty"`
 }
 
+// IsScanScheduled returns true when the registry is scanned automatically.
+func (r *Registry) IsScanScheduled() bool {
+	return r.Spec.Schedule != "" || (r.Spec.ScanInterval != nil && r.Spec.ScanInterval.Duration != 0)
+}
+
+// HasScanJobsHistoryLimits returns true when the successful or the failed history limit is set.
+// Otherwise, the finished ScanJobs are limited to DefaultScanJobsHistoryLimit, whatever their status.
+func (r *Registry) HasScanJobsHistoryLimits() bool {
+	return r.Spec.SuccessfulScanJobsHistoryLimit != nil || r.Spec.FailedScanJobsHistoryLimit != nil
+}
+
+// GetSuccessfulScanJobsHistoryLimit returns the number of completed ScanJobs to keep for the registry.
+func (r *Registry) GetSuccessfulScanJobsHistoryLimit() int {
+	if r.Spec.SuccessfulScanJobsHistoryLimit == nil {
+		return DefaultScanJobsHistoryLimit
+	}
+	return int(*r.Spec.SuccessfulScanJobsHistoryLimit)
+}
+
+// GetFailedScanJobsHistoryLimit returns the number of failed or cancelled ScanJobs to keep for the registry.
+func (r *Registry) GetFailedScanJobsHistoryLimit() int {
+	if r.Spec.FailedScanJobsHistoryLimit == nil {
+		return DefaultScanJobsHistoryLimit
+	}
+	return int(*r.Spec.FailedScanJobsHistoryLimit)
+}
+
 // IsPrivate returns true when the registry requires authentication.
 func (r *Registry) IsPrivate() bool {
-	return r.Spec.AuthSecret != ""
+	return r.Spec.AuthSecret != "" || r.Spec.CredentialProvider != ""
 }
 
 // +kubebuilder:object:root=true
@@ -1,6 +1,8 @@
 package v1alpha1
 
 import (
+	"fmt"
+	"slices"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -24,6 +26,10 @@ const (
 	AnnotationScanJobCreationTimestampKey = "sbomscanner.kubewarden.io/creation-timestamp"
 	// AnnotationScanJobTriggerKey is used to identify the source of the ScanJob trigger.
 	AnnotationScanJobTriggerKey = "sbomscanner.kubewarden.io/trigger"
+	// AnnotationScanJobTraceIDKey stores the ID of the trace following the ScanJob across the controller and the workers.
+	AnnotationScanJobTraceIDKey = "sbomscanner.kubewarden.io/trace-id"
+	// AnnotationScanJobTraceParentKey stores the W3C trace context of the root span of the ScanJob.
+	AnnotationScanJobTraceParentKey = "sbomscanner.kubewarden.io/traceparent"
 )
 
 // ScanJobSpec defines the desired state of ScanJob.
@@ -31,6 +37,34 @@ type ScanJobSpec struct {
 	// Registry is the registry in the same namespace to scan.
 	// +kubebuilder:validation:Required
 	Registry string `json:"registry"`
+
+	// Repositories restricts the scan to the given repositories of the registry.
+	// An empty list means all the repositories configured in the Registry are scanned.
+	// +optional
+	Repositories []string `json:"repositories,omitempty"`
+
+	// Tags restricts the scan to the given tags of the scanned repositories.
+	// An empty list means all the tags are scanned.
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// Images restricts the scan to the given images of the registry,
+	// referenced by tag (e.g. "myrepo:1.2.3") or by digest (e.g. "myrepo@sha256:..."),
+	// optionally prefixed by the registry URI (e.g. "registry.example.com/myrepo:1.2.3").
+	// It cannot be combined with Repositories and Tags.
+	// +optional
+	Images []string `json:"images,omitempty"`
+
+	// Suspend pauses the ScanJob.
+	// A suspended ScanJob is not scheduled, and a running one is stopped.
+	// The ScanJob is scheduled again from the beginning once it is resumed.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Cancel stops the ScanJob permanently, keeping its status and results.
+	// Once set, it cannot be unset.
+	// +optional
+	Cancel bool `json:"cancel,omitempty"`
 }
 
 const (
@@ -38,6 +72,7 @@ const (
 	ConditionTypeInProgress = "InProgress"
 	ConditionTypeComplete   = "Complete"
 	ConditionTypeFailed     = "Failed"
+	ConditionTypeCancelled  = "Cancelled"
 )
 
 const (
@@ -51,16 +86,29 @@ const (
 	ReasonFailed                    = "Failed"
 	ReasonNoImagesToScan            = "NoImagesToScan"
 	ReasonAllImagesScanned          = "AllImagesScanned"
+	ReasonPartiallyFailed           = "PartiallyFailed"
 	ReasonRegistryNotFound          = "RegistryNotFound"
 	ReasonInternalError             = "InternalError"
+	ReasonAuthFailed                = "AuthFailed"
+	ReasonRegistryUnreachable       = "RegistryUnreachable"
+	ReasonSuspended                 = "Suspended"
+	ReasonCancelled                 = "Cancelled"
 )
 
+// MaxFailedImages is the maximum number of failed images listed in the ScanJob status.
+const MaxFailedImages = 50
+
+// MaxNewCriticalCVEs is the maximum number of new critical CVEs listed in the ScanJob status.
+const MaxNewCriticalCVEs = 50
+
 const (
 	messagePending    = "ScanJob is pending"
 	messageScheduled  = "ScanJob is scheduled"
 	messageInProgress = "ScanJob is in progress"
 	messageCompleted  = "ScanJob completed successfully"
 	messageFailed     = "ScanJob failed"
+	messageSuspended  = "ScanJob is suspended"
+	messageCancelled  = "ScanJob was cancelled"
 )
 
 // ScanJobStatus defines the observed state of ScanJob.
@@ -75,6 +123,28 @@ type ScanJobStatus struct {
 	// ScannedImagesCount is the number of images that have been scanned.
 	ScannedImagesCount int `json:"scannedImagesCount,omitempty"`
 
+	// FailedImagesCount is the number of images that could not be scanned.
+	FailedImagesCount int `json:"failedImagesCount,omitempty"`
+
+	// FailedImages lists the images that could not be scanned, up to MaxFailedImages.
+	// +optional
+	// +kubebuilder:validation:MaxItems=50
+	FailedImages []FailedImage `json:"failedImages,omitempty"`
+
+	// NewCriticalCVEsCount is the number of critical CVEs found in the scanned images
+	// that were not critical in their previous scan.
+	NewCriticalCVEsCount int `json:"newCriticalCVEsCount,omitempty"`
+
+	// NewCriticalCVEs lists the critical CVEs found in the scanned images
+	// that were not critical in their previous scan, up to MaxNewCriticalCVEs.
+	// +optional
+	// +kubebuilder:validation:MaxItems=50
+	NewCriticalCVEs []string `json:"newCriticalCVEs,omitempty"`
+
+	// ThrottledRequestsCount is the number of registry requests that were throttled
+	// because the registry signaled that the rate limit was exceeded.
+	ThrottledRequestsCount int `json:"throttledRequestsCount,omitempty"`
+
 	// StartTime is when the job started processing.
 	// +optional
 	StartTime *metav1.Time `json:"startTime,omitempty"`
@@ -84,6 +154,19 @@ type ScanJobStatus struct {
 	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
+// FailedImage is an image that could not be scanned.
+type FailedImage struct {
+	// Image is the reference of the image.
+	Image string `json:"image"`
+
+	// Platform is the platform of the image.
+	// +optional
+	Platform string `json:"platform,omitempty"`
+
+	// Error is the error that caused the failure.
+	Error string `json:"error"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:selectablefield:JSONPath=`.spec.registry`
@@ -91,6 +174,7 @@ type ScanJobStatus struct {
 // +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.status=='True')].type",description="Current status"
 // +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.status=='True')].reason",description="Status reason"
 // +kubebuilder:printcolumn:name="Scanned",type="integer",JSONPath=".status.scannedImagesCount"
+// +kubebuilder:printcolumn:name="Failed",type="integer",JSONPath=".status.failedImagesCount"
 // +kubebuilder:printcolumn:name="Total",type="integer",JSONPath=".status.imagesCount"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
@@ -149,6 +233,13 @@ func (s *ScanJob) InitializeConditions() {
 		Message:            messagePending,
 		ObservedGeneration: s.Generation,
 	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeCancelled,
+		Status:             metav1.ConditionUnknown,
+		Reason:             ReasonPending,
+		Message:            messagePending,
+		ObservedGeneration: s.Generation,
+	})
 }
 
 // MarkScheduled marks the job as scheduled.
@@ -181,6 +272,13 @@ func (s *ScanJob) MarkScheduled(reason, message string) {
 		Message:            messageScheduled,
 		ObservedGeneration: s.Generation,
 	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeCancelled,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonScheduled,
+		Message:            messageScheduled,
+		ObservedGeneration: s.Generation,
+	})
 }
 
 // MarkInProgress marks the job as in progress.
@@ -216,6 +314,13 @@ func (s *ScanJob) MarkInProgress(reason, message string) {
 		Message:            messageInProgress,
 		ObservedGeneration: s.Generation,
 	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeCancelled,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonInProgress,
+		Message:            messageInProgress,
+		ObservedGeneration: s.Generation,
+	})
 }
 
 // MarkComplete marks the job as complete.
@@ -251,6 +356,13 @@ func (s *ScanJob) MarkComplete(reason, message string) {
 		Message:            messageCompleted,
 		ObservedGeneration: s.Generation,
 	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeCancelled,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonComplete,
+		Message:            messageCompleted,
+		ObservedGeneration: s.Generation,
+	})
 }
 
 // MarkFailed marks the job as failed.
@@ -286,11 +398,88 @@ func (s *ScanJob) MarkFailed(reason, message string) {
 		Message:            message,
 		ObservedGeneration: s.Generation,
 	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeCancelled,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonFailed,
+		Message:            messageFailed,
+		ObservedGeneration: s.Generation,
+	})
+}
+
+// MarkSuspended marks the job as suspended.
+// The progress of the job is reset, since it is scheduled again from the beginning once resumed.
+func (s *ScanJob) MarkSuspended() {
+	s.InitializeConditions()
+	s.Status.ImagesCount = 0
+	s.Status.ScannedImagesCount = 0
+	s.Status.FailedImagesCount = 0
+	s.Status.FailedImages = nil
+	s.Status.StartTime = nil
+
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeScheduled,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonSuspended,
+		Message:            messageSuspended,
+		ObservedGeneration: s.Generation,
+	})
+}
+
+// MarkCancelled marks the job as cancelled.
+func (s *ScanJob) MarkCancelled(reason, message string) {
+	now := metav1.Now()
+	s.Status.CompletionTime = &now
+
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeScheduled,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonCancelled,
+		Message:            messageCancelled,
+		ObservedGeneration: s.Generation,
+	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeInProgress,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonCancelled,
+		Message:            messageCancelled,
+		ObservedGeneration: s.Generation,
+	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeComplete,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonCancelled,
+		Message:            messageCancelled,
+		ObservedGeneration: s.Generation,
+	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeFailed,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonCancelled,
+		Message:            messageCancelled,
+		ObservedGeneration: s.Generation,
+	})
+	meta.SetStatusCondition(&s.Status.Conditions, metav1.Condition{
+		Type:               ConditionTypeCancelled,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: s.Generation,
+	})
 }
 
 // IsPending returns true if the job is not in any other state.
 func (s *ScanJob) IsPending() bool {
-	return !s.IsScheduled() && !s.IsInProgress() && !s.IsComplete() && !s.IsFailed()
+	return !s.IsScheduled() && !s.IsInProgress() && !s.IsComplete() && !s.IsFailed() && !s.IsCancelled()
+}
+
+// IsSuspended returns true if the job has been suspended by the controller.
+func (s *ScanJob) IsSuspended() bool {
+	scheduledCond := meta.FindStatusCondition(s.Status.Conditions, ConditionTypeScheduled)
+	if scheduledCond == nil {
+		return false
+	}
+	return scheduledCond.Status == metav1.ConditionFalse && scheduledCond.Reason == ReasonSuspended
 }
 
 // IsScheduled returns true if the job is scheduled.
@@ -329,6 +518,75 @@ func (s *ScanJob) IsFailed() bool {
 	return failedCond.Status == metav1.ConditionTrue
 }
 
+// IsCancelled returns true if the job has been cancelled.
+func (s *ScanJob) IsCancelled() bool {
+	cancelledCond := meta.FindStatusCondition(s.Status.Conditions, ConditionTypeCancelled)
+	if cancelledCond == nil {
+		return false
+	}
+	return cancelledCond.Status == metav1.ConditionTrue
+}
+
+// IsScoped returns true if the job scans only a subset of the registry.
+func (s *ScanJob) IsScoped() bool {
+	return len(s.Spec.Repositories) > 0 || len(s.Spec.Tags) > 0 || len(s.Spec.Images) > 0
+}
+
+// IsStopRequested returns true if the job has been requested to be cancelled or suspended.
+// The workers check it to stop processing the job before the controller updates its status.
+func (s *ScanJob) IsStopRequested() bool {
+	return s.Spec.Cancel || s.Spec.Suspend
+}
+
+// AddFailedImage records an image that could not be scanned.
+// Only the first MaxFailedImages images are listed, but all of them are counted.
+func (s *ScanJob) AddFailedImage(failedImage FailedImage) {
+	s.Status.FailedImagesCount++
+	if len(s.Status.FailedImages) < MaxFailedImages {
+		s.Status.FailedImages = append(s.Status.FailedImages, failedImage)
+	}
+}
+
+// RemoveFailedImage removes a listed failed image, e.g. once it is scanned by a replay of its message.
+// It returns false when the image is not listed.
+func (s *ScanJob) RemoveFailedImage(image, platform string) bool {
+	index := slices.IndexFunc(s.Status.FailedImages, func(failedImage FailedImage) bool {
+		return failedImage.Image == image && failedImage.Platform == platform
+	})
+	if index < 0 {
+		return false
+	}
+
+	s.Status.FailedImages = slices.Delete(s.Status.FailedImages, index, index+1)
+	s.Status.FailedImagesCount--
+
+	return true
+}
+
+// SetNewCriticalCVEs records the sorted critical CVEs introduced since the previous scan of the images.
+// Only the first MaxNewCriticalCVEs CVEs are listed, but all of them are counted.
+func (s *ScanJob) SetNewCriticalCVEs(cves []string) {
+	s.Status.NewCriticalCVEsCount = len(cves)
+	s.Status.NewCriticalCVEs = slices.Clone(cves[:min(len(cves), MaxNewCriticalCVEs)])
+}
+
+// AllImagesProcessed returns true if all the images have been either scanned or failed.
+func (s *ScanJob) AllImagesProcessed() bool {
+	return s.Status.ScannedImagesCount+s.Status.FailedImagesCount >= s.Status.ImagesCount
+}
+
+// MarkImagesProcessed marks the job as complete once all the images have been processed,
+// with the PartiallyFailed reason if some of them could not be scanned.
+func (s *ScanJob) MarkImagesProcessed() {
+	if s.Status.FailedImagesCount > 0 {
+		s.MarkComplete(ReasonPartiallyFailed,
+			fmt.Sprintf("%d of %d images could not be scanned", s.Status.FailedImagesCount, s.Status.ImagesCount))
+		return
+	}
+
+	s.MarkComplete(ReasonAllImagesScanned, "All images scanned successfully")
+}
+
 // +kubebuilder:object:root=true
 
 // ScanJobList contains a list of ScanJob.
@@ -9,6 +9,37 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlackoutWindow) DeepCopyInto(out *BlackoutWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlackoutWindow.
+func (in *BlackoutWindow) DeepCopy() *BlackoutWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(BlackoutWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailedImage) DeepCopyInto(out *FailedImage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailedImage.
+func (in *FailedImage) DeepCopy() *FailedImage {
+	if in == nil {
+		return nil
+	}
+	out := new(FailedImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Platform) DeepCopyInto(out *Platform) {
 	*out = *in
@@ -24,6 +55,21 @@ func (in *Platform) DeepCopy() *Platform {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Registry) DeepCopyInto(out *Registry) {
 	*out = *in
@@ -96,11 +142,41 @@ func (in *RegistrySpec) DeepCopyInto(out *RegistrySpec) {
 		*out = new(v1.Duration)
 		**out = **in
 	}
+	if in.BlackoutWindows != nil {
+		in, out := &in.BlackoutWindows, &out.BlackoutWindows
+		*out = make([]BlackoutWindow, len(*in))
+		copy(*out, *in)
+	}
 	if in.Platforms != nil {
 		in, out := &in.Platforms, &out.Platforms
 		*out = make([]Platform, len(*in))
 		copy(*out, *in)
 	}
+	if in.Mirrors != nil {
+		in, out := &in.Mirrors, &out.Mirrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		**out = **in
+	}
+	if in.SuccessfulScanJobsHistoryLimit != nil {
+		in, out := &in.SuccessfulScanJobsHistoryLimit, &out.SuccessfulScanJobsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.FailedScanJobsHistoryLimit != nil {
+		in, out := &in.FailedScanJobsHistoryLimit, &out.FailedScanJobsHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ScanJobsHistoryMaxAge != nil {
+		in, out := &in.ScanJobsHistoryMaxAge, &out.ScanJobsHistoryMaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistrySpec.
@@ -123,6 +199,24 @@ func (in *RegistryStatus) DeepCopyInto(out *RegistryStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.NextScanTime != nil {
+		in, out := &in.NextScanTime, &out.NextScanTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastValidationTime != nil {
+		in, out := &in.LastValidationTime, &out.LastValidationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastScanJob != nil {
+		in, out := &in.LastScanJob, &out.LastScanJob
+		*out = new(ScanJobSummary)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Vulnerabilities != nil {
+		in, out := &in.Vulnerabilities, &out.Vulnerabilities
+		*out = new(VulnerabilitiesSummary)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryStatus.
@@ -140,7 +234,7 @@ func (in *ScanJob) DeepCopyInto(out *ScanJob) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -197,6 +291,21 @@ func (in *ScanJobList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScanJobSpec) DeepCopyInto(out *ScanJobSpec) {
 	*out = *in
+	if in.Repositories != nil {
+		in, out := &in.Repositories, &out.Repositories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanJobSpec.
@@ -219,6 +328,16 @@ func (in *ScanJobStatus) DeepCopyInto(out *ScanJobStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.FailedImages != nil {
+		in, out := &in.FailedImages, &out.FailedImages
+		*out = make([]FailedImage, len(*in))
+		copy(*out, *in)
+	}
+	if in.NewCriticalCVEs != nil {
+		in, out := &in.NewCriticalCVEs, &out.NewCriticalCVEs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.StartTime != nil {
 		in, out := &in.StartTime, &out.StartTime
 		*out = (*in).DeepCopy()
@@ -239,6 +358,25 @@ func (in *ScanJobStatus) DeepCopy() *ScanJobStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScanJobSummary) DeepCopyInto(out *ScanJobSummary) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScanJobSummary.
+func (in *ScanJobSummary) DeepCopy() *ScanJobSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(ScanJobSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VEXHub) DeepCopyInto(out *VEXHub) {
 	*out = *in
@@ -327,3 +465,18 @@ func (in *VEXHubStatus) DeepCopy() *VEXHubStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VulnerabilitiesSummary) DeepCopyInto(out *VulnerabilitiesSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VulnerabilitiesSummary.
+func (in *VulnerabilitiesSummary) DeepCopy() *VulnerabilitiesSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(VulnerabilitiesSummary)
+	in.DeepCopyInto(out)
+	return out
+}
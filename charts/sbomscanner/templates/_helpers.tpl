@@ -83,4 +83,24 @@ allowPrivilegeEscalation: false
 capabilities:
     drop:
     - "ALL"
-{{- end}}
\ No newline at end of file
+{{- end}}
+
+{{/*
+OpenTelemetry tracing environment variables
+*/}}
+{{- define "sbomscanner.tracingEnv" -}}
+{{- if .Values.tracing.otlpEndpoint }}
+- name: OTEL_EXPORTER_OTLP_ENDPOINT
+  value: {{ .Values.tracing.otlpEndpoint | quote }}
+- name: OTEL_EXPORTER_OTLP_PROTOCOL
+  value: {{ .Values.tracing.otlpProtocol | quote }}
+{{- with .Values.tracing.sampler }}
+- name: OTEL_TRACES_SAMPLER
+  value: {{ . | quote }}
+{{- end }}
+{{- with .Values.tracing.samplerArg }}
+- name: OTEL_TRACES_SAMPLER_ARG
+  value: {{ . | quote }}
+{{- end }}
+{{- end }}
+{{- end }}
\ No newline at end of file
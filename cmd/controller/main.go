@@ -17,11 +17,15 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"log/slog"
+	"net/http"
 	"os"
 	"time"
+	// Embed the time zone database, used to evaluate the registry scan schedules.
+	_ "time/tzdata"
 
 	"github.com/go-logr/logr"
 	"github.com/nats-io/nats.go"
@@ -35,6 +39,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -188,6 +193,12 @@ func main() {
 		os.Exit(0)
 	}
 
+	shutdownTracing, err := cmdutil.SetupTracing(signalHandler, "sbomscanner-controller")
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
 	nc, err := nats.Connect(cfg.NatsURL, natsOpts...)
 	if err != nil {
 		setupLog.Error(err, "unable to connect to NATS server", "natsURL", cfg.NatsURL)
@@ -200,6 +211,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	deadLetterQueue, err := messaging.NewNatsDeadLetterQueue(signalHandler, nc, slogger)
+	if err != nil {
+		setupLog.Error(err, "unable to create NATS dead-letter queue")
+		os.Exit(1)
+	}
+	// The dead-letter endpoints are served by the metrics server,
+	// so that they are protected by the same authentication and authorization.
+	deadLetterHandler := messaging.NewDeadLetterHTTPHandler(deadLetterQueue, slogger)
+	metricsServerOptions.ExtraHandlers = map[string]http.Handler{
+		messaging.DeadLetterPath:       deadLetterHandler,
+		messaging.DeadLetterPath + "/": deadLetterHandler,
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
@@ -267,8 +291,9 @@ func main() {
 	}
 
 	if err = (&controller.RegistryReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Registry")
 		os.Exit(1)
@@ -278,14 +303,16 @@ func main() {
 		Client:    mgr.GetClient(),
 		Scheme:    mgr.GetScheme(),
 		Publisher: publisher,
+		Recorder:  mgr.GetEventRecorderFor("sbomscanner-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ScanJob")
 		os.Exit(1)
 	}
 
 	if err = (&controller.VulnerabilityReportReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("sbomscanner-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "VulnerabilityReport")
 		os.Exit(1)
@@ -298,6 +325,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = metrics.Registry.Register(&controller.PostureCollector{
+		Reader: mgr.GetCache(),
+	}); err != nil {
+		setupLog.Error(err, "unable to register metrics", "collector", "PostureCollector")
+		os.Exit(1)
+	}
+
 	if err = webhookv1alpha1.SetupRegistryWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Registry")
 		os.Exit(1)
@@ -324,4 +358,9 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	if err = shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "unable to flush pending spans")
+		os.Exit(1)
+	}
 }
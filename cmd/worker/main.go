@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -10,9 +12,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/authn"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
@@ -22,10 +29,21 @@ import (
 	"github.com/kubewarden/sbomscanner/internal/messaging"
 	"github.com/kubewarden/sbomscanner/pkg/generated/clientset/versioned/scheme"
 	"github.com/nats-io/nats.go"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
 	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 )
 
 func main() {
+	// The worker runs Trivy in a child process of itself when the Trivy subprocess mode is enabled.
+	if len(os.Args) > 1 && os.Args[1] == handlers.TrivySubcommand {
+		runTrivy(os.Args[2:])
+	}
+
 	var natsURL string
 	var natsCertFile string
 	var natsKeyFile string
@@ -33,8 +51,28 @@ func main() {
 	var runDir string
 	var trivyDBRepository string
 	var trivyJavaDBRepository string
+	var trivySubprocess bool
+	var trivyMemoryLimit string
+	var trivyMaxProcs int
+	var trivyTimeout time.Duration
+	var scannerEngine string
+	var syftPath string
+	var grypePath string
+	var dbRefreshInterval time.Duration
+	var offline bool
+	var offlineBundlePath string
+	var maxConcurrentCatalog int
+	var maxConcurrentGenerateSBOM int
+	var maxConcurrentScanSBOM int
+	var drainTimeout time.Duration
+	var catalogRetryPolicy string
+	var generateSBOMRetryPolicy string
+	var scanSBOMRetryPolicy string
 	var init bool
 	var logLevel string
+	var metricsAddr string
+	var secureMetrics bool
+	var enableHTTP2 bool
 
 	flag.StringVar(&natsURL, "nats-url", "localhost:4222", "The URL of the NATS server.")
 	flag.StringVar(&natsCertFile, "nats-cert-file", "/nats/tls/tls.crt", "The path to the NATS client certificate.")
@@ -43,8 +81,33 @@ func main() {
 	flag.StringVar(&runDir, "run-dir", "/var/run/worker", "Directory to store temporary files.")
 	flag.StringVar(&trivyDBRepository, "trivy-db-repository", "public.ecr.aws/aquasecurity/trivy-db", "OCI repository to retrieve trivy-db.")
 	flag.StringVar(&trivyJavaDBRepository, "trivy-java-db-repository", "public.ecr.aws/aquasecurity/trivy-java-db", "OCI repository to retrieve trivy-java-db.")
+	flag.BoolVar(&trivySubprocess, "trivy-subprocess", false, "Run Trivy in a child process, isolating the worker from Trivy failures and enforcing the Trivy resource limits. "+
+		"Required to generate and scan SBOMs concurrently.")
+	flag.StringVar(&trivyMemoryLimit, "trivy-memory-limit", "", "Maximum memory of the Trivy child process, as a Kubernetes quantity, e.g. 2Gi. Leave empty for no limit.")
+	flag.IntVar(&trivyMaxProcs, "trivy-max-procs", 0, "GOMAXPROCS of the Trivy child process, the number of threads running Go code simultaneously. Leave as 0 for the Go runtime default.")
+	flag.DurationVar(&trivyTimeout, "trivy-timeout", 0, "Maximum duration of a Trivy execution in a child process. Leave as 0 for no timeout.")
+	flag.StringVar(&scannerEngine, "scanner-engine", handlers.EngineTrivy, fmt.Sprintf("The engine generating and scanning the SBOMs, either %q or %q.", handlers.EngineTrivy, handlers.EngineGrype))
+	flag.StringVar(&syftPath, "syft-path", "syft", "The path to the syft executable, used by the grype engine.")
+	flag.StringVar(&grypePath, "grype-path", "grype", "The path to the grype executable, used by the grype engine.")
+	flag.DurationVar(&dbRefreshInterval, "db-refresh-interval", 6*time.Hour, "Interval between the updates of the vulnerability database, downloaded in the background.")
+	flag.BoolVar(&offline, "offline", false, "Load the vulnerability databases and VEX repositories from the offline bundle, without reaching the network. Only the registries of the scanned images are reached.")
+	flag.StringVar(&offlineBundlePath, "offline-bundle-path", "", "The path to the offline bundle directory, required by the offline mode.")
+	flag.IntVar(&maxConcurrentCatalog, "max-concurrent-catalog", 1, "Maximum number of catalog creation messages processed concurrently.")
+	flag.IntVar(&maxConcurrentGenerateSBOM, "max-concurrent-generate-sbom", 1, "Maximum number of SBOM generation messages processed concurrently. "+
+		"Trivy runs one execution at a time inside the worker process: values above 1 require --trivy-subprocess with the trivy engine.")
+	flag.IntVar(&maxConcurrentScanSBOM, "max-concurrent-scan-sbom", 1, "Maximum number of SBOM scan messages processed concurrently. "+
+		"Trivy runs one execution at a time inside the worker process: values above 1 require --trivy-subprocess with the trivy engine.")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 25*time.Second, "Time to wait for in-flight messages to be processed when shutting down.")
+	flag.StringVar(&catalogRetryPolicy, "catalog-retry-policy", "", retryPolicyUsage("catalog creation"))
+	flag.StringVar(&generateSBOMRetryPolicy, "generate-sbom-retry-policy", "", retryPolicyUsage("SBOM generation"))
+	flag.StringVar(&scanSBOMRetryPolicy, "scan-sbom-retry-policy", "", retryPolicyUsage("SBOM scan"))
 	flag.BoolVar(&init, "init", false, "Run initialization tasks and exit.")
 	flag.StringVar(&logLevel, "log-level", slog.LevelInfo.String(), "Log level.")
+	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
+		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics endpoint.")
+	flag.BoolVar(&secureMetrics, "metrics-secure", true,
+		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
+	flag.BoolVar(&enableHTTP2, "enable-http2", false, "If set, HTTP/2 will be enabled for the metrics server.")
 	flag.Parse()
 
 	slogLevel, err := cmdutil.ParseLogLevel(logLevel)
@@ -61,6 +124,7 @@ func main() {
 		Level: slogLevel,
 	}
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &opts)).With("component", "worker")
+	ctrl.SetLogger(logr.FromSlogHandler(logger.Handler()))
 	logger.Info("Starting worker")
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -95,6 +159,25 @@ func main() {
 		os.Exit(0)
 	}
 
+	var offlineConfig *handlers.OfflineConfig
+	if offline {
+		if offlineBundlePath == "" {
+			logger.Error("The offline mode requires the offline bundle path")
+			os.Exit(1)
+		}
+		offlineConfig = &handlers.OfflineConfig{BundlePath: offlineBundlePath}
+		if err = offlineConfig.Validate(scannerEngine); err != nil {
+			logger.Error("Error validating the offline bundle", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	shutdownTracing, err := cmdutil.SetupTracing(ctx, "sbomscanner-worker")
+	if err != nil {
+		logger.Error("Unable to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	nc, err := nats.Connect(natsURL,
 		natsOpts...,
 	)
@@ -127,29 +210,101 @@ func main() {
 		logger.Error("Error creating k8s client", "error", err)
 		os.Exit(1)
 	}
-	registryClientFactory := func(transport http.RoundTripper) *registry.Client {
-		return registry.NewClient(transport, logger)
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		logger.Error("Error creating kubernetes clientset", "error", err)
+		os.Exit(1)
+	}
+	eventBroadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	defer eventBroadcaster.Shutdown()
+	recorder := eventBroadcaster.NewRecorder(scheme, corev1.EventSource{Component: "sbomscanner-worker"})
+
+	registryClientFactory := func(transport http.RoundTripper, keychain authn.Keychain) *registry.Client {
+		return registry.NewClient(transport, keychain, logger)
+	}
+
+	// The rate limit of a Registry applies to all the messages processed by the worker.
+	rateLimiters := registry.NewRateLimiters(logger)
+
+	var generator handlers.SBOMGenerator
+	var scanner handlers.Scanner
+	var dbUpdater handlers.DatabaseUpdater
+	switch scannerEngine {
+	case handlers.EngineTrivy:
+		var subprocessConfig *handlers.TrivySubprocessConfig
+		if !trivySubprocess && (maxConcurrentGenerateSBOM > 1 || maxConcurrentScanSBOM > 1) {
+			logger.Warn("Trivy runs inside the worker process and its executions are serialized, "+
+				"enable the Trivy subprocess to generate and scan SBOMs concurrently",
+				"maxConcurrentGenerateSBOM", maxConcurrentGenerateSBOM,
+				"maxConcurrentScanSBOM", maxConcurrentScanSBOM)
+		}
+		if trivySubprocess {
+			subprocessConfig, err = trivySubprocessConfig(trivyMemoryLimit, trivyMaxProcs, trivyTimeout)
+			if err != nil {
+				logger.Error("Error configuring the Trivy subprocess", "error", err)
+				os.Exit(1)
+			}
+		}
+		engine := handlers.NewTrivyEngine(k8sClient, runDir, trivyDBRepository, trivyJavaDBRepository, subprocessConfig, offlineConfig, logger)
+		generator, scanner, dbUpdater = engine, engine, engine
+	case handlers.EngineGrype:
+		engine, err := handlers.NewGrypeEngine(k8sClient, runDir, syftPath, grypePath, offlineConfig, logger)
+		if err != nil {
+			logger.Error("Error creating the grype scanner engine", "error", err)
+			os.Exit(1)
+		}
+		generator, scanner, dbUpdater = engine, engine, engine
+	default:
+		logger.Error("Unsupported scanner engine", "engine", scannerEngine)
+		os.Exit(1)
 	}
 
 	registry := messaging.HandlerRegistry{
-		handlers.CreateCatalogSubject: handlers.NewCreateCatalogHandler(registryClientFactory, k8sClient, scheme, publisher, logger),
-		handlers.GenerateSBOMSubject:  handlers.NewGenerateSBOMHandler(k8sClient, scheme, runDir, trivyJavaDBRepository, publisher, logger),
-		handlers.ScanSBOMSubject:      handlers.NewScanSBOMHandler(k8sClient, scheme, runDir, trivyDBRepository, trivyJavaDBRepository, logger),
+		handlers.CreateCatalogSubject: handlers.NewCreateCatalogHandler(registryClientFactory, rateLimiters, k8sClient, scheme, publisher, recorder, logger),
+		handlers.GenerateSBOMSubject:  handlers.NewGenerateSBOMHandler(k8sClient, scheme, generator, rateLimiters, publisher, logger),
+		handlers.ScanSBOMSubject:      handlers.NewScanSBOMHandler(k8sClient, scheme, scanner, logger),
 	}
-	failureHandler := handlers.NewScanJobFailureHandler(k8sClient, logger)
-	retryConfig := &messaging.RetryConfig{
-		BaseDelay:   5 * time.Second,
-		Jitter:      0.2,
-		MaxAttempts: 5,
+	failureHandler := handlers.NewScanJobFailureHandler(k8sClient, recorder, logger)
+	retryPolicies := messaging.RetryPolicies{}
+	for subject, retryPolicy := range map[string]string{
+		handlers.CreateCatalogSubject: catalogRetryPolicy,
+		handlers.GenerateSBOMSubject:  generateSBOMRetryPolicy,
+		handlers.ScanSBOMSubject:      scanSBOMRetryPolicy,
+	} {
+		retryConfig, err := cmdutil.ParseRetryConfig(retryPolicy)
+		if err != nil {
+			logger.Error("Error parsing retry policy", "subject", subject, "error", err)
+			os.Exit(1)
+		}
+		retryPolicies[subject] = retryConfig
+	}
+
+	concurrencyConfig := &messaging.ConcurrencyConfig{
+		MaxInFlight: map[string]int{
+			handlers.CreateCatalogSubject: maxConcurrentCatalog,
+			handlers.GenerateSBOMSubject:  maxConcurrentGenerateSBOM,
+			handlers.ScanSBOMSubject:      maxConcurrentScanSBOM,
+		},
+		DrainTimeout: drainTimeout,
 	}
 
-	subscriber, err := messaging.NewNatsSubscriber(ctx, nc, "worker", registry, failureHandler, retryConfig, logger)
+	subscriber, err := messaging.NewNatsSubscriber(ctx, nc, "worker", registry, failureHandler, retryPolicies, concurrencyConfig, logger)
 	if err != nil {
 		logger.Error("Error creating NATS subscriber", "error", err)
 		os.Exit(1)
 	}
 
-	healthServer := runHealthServer(logger)
+	go handlers.RunDatabaseUpdater(ctx, dbUpdater, dbRefreshInterval, logger)
+
+	healthServer := runHealthServer(dbUpdater, logger)
+
+	if metricsAddr != "0" {
+		if err := runMetricsServer(ctx, config, metricsAddr, secureMetrics, enableHTTP2, logger); err != nil {
+			logger.Error("Error starting metrics server", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	err = subscriber.Run(ctx)
 	if err != nil {
@@ -162,14 +317,71 @@ func main() {
 		logger.Error("Error shutting down health check server", "error", err)
 		os.Exit(1)
 	}
+
+	logger.Debug("Flushing pending spans")
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error("Error shutting down tracing", "error", err)
+		os.Exit(1)
+	}
 }
 
-func runHealthServer(logger *slog.Logger) *http.Server {
-	handler := &healthz.Handler{}
+// runTrivy runs Trivy with the given arguments and exits, as the Trivy child process of a worker.
+func runTrivy(args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err := handlers.RunTrivy(ctx, args)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// trivySubprocessConfig returns the configuration of the Trivy child process.
+func trivySubprocessConfig(memoryLimit string, maxProcs int, timeout time.Duration) (*handlers.TrivySubprocessConfig, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the worker executable: %w", err)
+	}
+
+	config := &handlers.TrivySubprocessConfig{
+		Executable: executable,
+		MaxProcs:   maxProcs,
+		Timeout:    timeout,
+	}
+	if memoryLimit != "" {
+		quantity, err := resource.ParseQuantity(memoryLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Trivy memory limit %q: %w", memoryLimit, err)
+		}
+		config.MemoryLimit = quantity.Value()
+	}
+
+	return config, nil
+}
+
+// retryPolicyUsage returns the usage of the retry policy flag of the given task.
+func retryPolicyUsage(task string) string {
+	defaults := messaging.DefaultRetryConfig
+	return fmt.Sprintf("Retry policy of the %s messages, in the form \"max-attempts=%d,base-delay=%s,max-delay=%s,jitter=%g\". Omitted fields use the default values.",
+		task, defaults.MaxAttempts, defaults.BaseDelay, defaults.MaxDelay, defaults.Jitter)
+}
+
+// runHealthServer serves the health checks of the worker.
+// The worker is ready once the vulnerability database is downloaded.
+func runHealthServer(dbUpdater handlers.DatabaseUpdater, logger *slog.Logger) *http.Server {
+	livezHandler := &healthz.Handler{}
+	readyzHandler := &healthz.Handler{
+		Checks: map[string]healthz.Checker{
+			"vulnerability-db": func(_ *http.Request) error {
+				return dbUpdater.DatabaseReady()
+			},
+		},
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/livez/", http.StripPrefix("/livez", handler))
-	mux.Handle("/readyz/", http.StripPrefix("/readyz", handler))
+	mux.Handle("/livez/", http.StripPrefix("/livez", livezHandler))
+	mux.Handle("/readyz/", http.StripPrefix("/readyz", readyzHandler))
 
 	server := &http.Server{
 		Addr:        ":8081",
@@ -186,3 +398,53 @@ func runHealthServer(logger *slog.Logger) *http.Server {
 
 	return server
 }
+
+// runMetricsServer serves the Prometheus metrics of the worker on the given address until the context is canceled.
+// Like the controller, the endpoint is served over HTTPS by default,
+// and requests are authenticated and authorized against the Kubernetes API.
+func runMetricsServer(ctx context.Context, config *rest.Config, addr string, secure, enableHTTP2 bool, logger *slog.Logger) error {
+	if err := messaging.RegisterMetrics(metrics.Registry); err != nil {
+		return err //nolint:wrapcheck // already wrapped
+	}
+	if err := handlers.RegisterMetrics(metrics.Registry); err != nil {
+		return err //nolint:wrapcheck // already wrapped
+	}
+	if err := registry.RegisterMetrics(metrics.Registry); err != nil {
+		return err //nolint:wrapcheck // already wrapped
+	}
+
+	// HTTP/2 is disabled by default, see the controller for the rationale.
+	var tlsOpts []func(*tls.Config)
+	if !enableHTTP2 {
+		tlsOpts = append(tlsOpts, func(c *tls.Config) {
+			c.NextProtos = []string{"http/1.1"}
+		})
+	}
+
+	options := metricsserver.Options{
+		BindAddress:   addr,
+		SecureServing: secure,
+		TLSOpts:       tlsOpts,
+	}
+	if secure {
+		options.FilterProvider = filters.WithAuthenticationAndAuthorization
+	}
+
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return fmt.Errorf("failed to create the metrics server HTTP client: %w", err)
+	}
+	server, err := metricsserver.NewServer(options, config, httpClient)
+	if err != nil {
+		return fmt.Errorf("failed to create the metrics server: %w", err)
+	}
+
+	go func() {
+		logger.Info("Starting metrics server", "addr", addr, "secure", secure)
+		if err := server.Start(ctx); err != nil {
+			logger.Error("Metrics server error", "error", err)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmdutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubewarden/sbomscanner/internal/messaging"
+)
+
+// ParseRetryConfig parses a retry policy in the form "max-attempts=5,base-delay=5s,max-delay=5m,jitter=0.2".
+// Omitted fields keep the values of messaging.DefaultRetryConfig.
+func ParseRetryConfig(s string) (*messaging.RetryConfig, error) {
+	retryConfig := messaging.DefaultRetryConfig
+	if strings.TrimSpace(s) == "" {
+		return &retryConfig, nil
+	}
+
+	for field := range strings.SplitSeq(s, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			return nil, fmt.Errorf("unable to parse retry policy field: %q, expected key=value", field)
+		}
+
+		var err error
+		switch key {
+		case "max-attempts":
+			retryConfig.MaxAttempts, err = strconv.Atoi(value)
+			if err == nil && retryConfig.MaxAttempts < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		case "base-delay":
+			retryConfig.BaseDelay, err = time.ParseDuration(value)
+		case "max-delay":
+			retryConfig.MaxDelay, err = time.ParseDuration(value)
+		case "jitter":
+			retryConfig.Jitter, err = strconv.ParseFloat(value, 64)
+			if err == nil && (retryConfig.Jitter < 0 || retryConfig.Jitter > 1) {
+				err = fmt.Errorf("must be between 0 and 1")
+			}
+		default:
+			return nil, fmt.Errorf("unknown retry policy field: %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse retry policy field %q: %w", key, err)
+		}
+	}
+
+	return &retryConfig, nil
+}
@@ -0,0 +1,83 @@
+package cmdutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewarden/sbomscanner/internal/messaging"
+)
+
+func TestParseRetryConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      messaging.RetryConfig
+		expectedError string
+	}{
+		{
+			name:     "empty policy uses the defaults",
+			input:    "",
+			expected: messaging.DefaultRetryConfig,
+		},
+		{
+			name:  "all fields",
+			input: "max-attempts=3,base-delay=1s,max-delay=1m,jitter=0.5",
+			expected: messaging.RetryConfig{
+				MaxAttempts: 3,
+				BaseDelay:   time.Second,
+				MaxDelay:    time.Minute,
+				Jitter:      0.5,
+			},
+		},
+		{
+			name:  "partial policy keeps the other defaults",
+			input: "max-attempts=10",
+			expected: messaging.RetryConfig{
+				MaxAttempts: 10,
+				BaseDelay:   messaging.DefaultRetryConfig.BaseDelay,
+				MaxDelay:    messaging.DefaultRetryConfig.MaxDelay,
+				Jitter:      messaging.DefaultRetryConfig.Jitter,
+			},
+		},
+		{
+			name:          "malformed field",
+			input:         "max-attempts",
+			expectedError: "expected key=value",
+		},
+		{
+			name:          "unknown field",
+			input:         "retries=3",
+			expectedError: "unknown retry policy field",
+		},
+		{
+			name:          "invalid duration",
+			input:         "base-delay=soon",
+			expectedError: "unable to parse retry policy field \"base-delay\"",
+		},
+		{
+			name:          "invalid max attempts",
+			input:         "max-attempts=0",
+			expectedError: "must be at least 1",
+		},
+		{
+			name:          "invalid jitter",
+			input:         "jitter=2",
+			expectedError: "must be between 0 and 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			retryConfig, err := ParseRetryConfig(test.input)
+			if test.expectedError != "" {
+				require.ErrorContains(t, err, test.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, test.expected, *retryConfig)
+		})
+	}
+}
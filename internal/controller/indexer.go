@@ -22,6 +22,16 @@ func SetupIndexer(ctx context.Context, mgr ctrl.Manager) error {
 		return fmt.Errorf("unable to create field indexer: %w", err)
 	}
 
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &storagev1alpha1.VulnerabilityReport{}, storagev1alpha1.IndexImageMetadataRegistry, func(rawObj client.Object) []string {
+		vulnerabilityReport, ok := rawObj.(*storagev1alpha1.VulnerabilityReport)
+		if !ok {
+			panic(fmt.Sprintf("Expected VulnerabilityReport, got %T", rawObj))
+		}
+		return []string{vulnerabilityReport.ImageMetadata.Registry}
+	}); err != nil {
+		return fmt.Errorf("unable to create field indexer: %w", err)
+	}
+
 	if err := mgr.GetFieldIndexer().IndexField(ctx, &v1alpha1.ScanJob{}, v1alpha1.IndexScanJobSpecRegistry, func(rawObj client.Object) []string {
 		scanJob, ok := rawObj.(*v1alpha1.ScanJob)
 		if !ok {
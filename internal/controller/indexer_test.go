@@ -48,6 +48,15 @@ var _ = Describe("SetupIndexer", func() {
 		})
 		Expect(err).NotTo(HaveOccurred())
 
+		By("Listing VulnerabilityReports by registry index")
+		var vulnerabilityReportList storagev1alpha1.VulnerabilityReportList
+		err = mgr.GetClient().List(ctx, &vulnerabilityReportList, &client.ListOptions{
+			FieldSelector: fields.SelectorFromSet(fields.Set{
+				storagev1alpha1.IndexImageMetadataRegistry: "test-registry",
+			}),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
 		By("Listing ScanJobs by registry and UID indexes")
 		var scanJobList v1alpha1.ScanJobList
 		err = mgr.GetClient().List(ctx, &scanJobList, &client.ListOptions{
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+// postureCollectTimeout is the maximum time spent listing the objects on every scrape.
+const postureCollectTimeout = 10 * time.Second
+
+var (
+	vulnerabilitiesDesc = prometheus.NewDesc(
+		"sbomscanner_vulnerabilities",
+		"Number of vulnerabilities found in the images of a repository, per severity.",
+		[]string{"namespace", "registry", "repository", "severity"}, nil,
+	)
+	imagesScannedDesc = prometheus.NewDesc(
+		"sbomscanner_images_scanned",
+		"Number of images of a registry that have a VulnerabilityReport.",
+		[]string{"namespace", "registry"}, nil,
+	)
+	scanJobDurationDesc = prometheus.NewDesc(
+		"sbomscanner_scanjob_duration_seconds",
+		"Time between the creation and the completion of the last completed or failed ScanJob of a registry.",
+		[]string{"namespace", "registry"}, nil,
+	)
+	reportAgeDesc = prometheus.NewDesc(
+		"sbomscanner_report_age_seconds",
+		"Age of the oldest VulnerabilityReport of a repository, measured from the last scan of the image.",
+		[]string{"namespace", "registry", "repository"}, nil,
+	)
+)
+
+// PostureCollector exports the vulnerability posture of the registries as Prometheus metrics.
+// The metrics are computed on every scrape from the VulnerabilityReports and ScanJobs held by the Reader,
+// usually the informer cache of the manager, so that no series is left behind when an object is deleted.
+type PostureCollector struct {
+	Reader client.Reader
+}
+
+// registryKey identifies a registry.
+type registryKey struct {
+	namespace string
+	registry  string
+}
+
+// repositoryKey identifies a repository of a registry.
+type repositoryKey struct {
+	registryKey
+	repository string
+}
+
+// Describe implements prometheus.Collector.
+func (c *PostureCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- vulnerabilitiesDesc
+	ch <- imagesScannedDesc
+	ch <- scanJobDurationDesc
+	ch <- reportAgeDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *PostureCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), postureCollectTimeout)
+	defer cancel()
+	log := logf.Log.WithName("posture-collector")
+
+	scanJobs := &v1alpha1.ScanJobList{}
+	if err := c.Reader.List(ctx, scanJobs); err != nil {
+		log.Error(err, "unable to list ScanJobs")
+		return
+	}
+	// The VulnerabilityReports are only read, there is no need to copy them out of the cache.
+	vulnerabilityReports := &storagev1alpha1.VulnerabilityReportList{}
+	if err := c.Reader.List(ctx, vulnerabilityReports, client.UnsafeDisableDeepCopy); err != nil {
+		log.Error(err, "unable to list VulnerabilityReports")
+		return
+	}
+
+	now := time.Now()
+	collectScanJobMetrics(ch, scanJobs.Items)
+	collectVulnerabilityReportMetrics(ch, vulnerabilityReports.Items, scanJobs.Items, now)
+}
+
+// collectScanJobMetrics sends the duration of the last completed or failed ScanJob of every registry.
+func collectScanJobMetrics(ch chan<- prometheus.Metric, scanJobs []v1alpha1.ScanJob) {
+	lastScanJobs := map[registryKey]*v1alpha1.ScanJob{}
+	for i := range scanJobs {
+		scanJob := &scanJobs[i]
+		if !scanJob.IsComplete() && !scanJob.IsFailed() {
+			continue
+		}
+		key := registryKey{namespace: scanJob.Namespace, registry: scanJob.Spec.Registry}
+		if lastScanJob, ok := lastScanJobs[key]; !ok || scanJobFinishedAt(scanJob).After(scanJobFinishedAt(lastScanJob)) {
+			lastScanJobs[key] = scanJob
+		}
+	}
+
+	for key, scanJob := range lastScanJobs {
+		duration := scanJobFinishedAt(scanJob).Sub(scanJob.GetCreationTimestampFromAnnotation())
+		ch <- prometheus.MustNewConstMetric(scanJobDurationDesc, prometheus.GaugeValue,
+			duration.Seconds(), key.namespace, key.registry)
+	}
+}
+
+// collectVulnerabilityReportMetrics sends the vulnerabilities, the scanned images and the report age
+// of every registry and repository.
+// Reports are aged from their last scan. Reports written before the scan time was recorded
+// are aged from the creation of their ScanJob, or from their own creation when the ScanJob no longer exists.
+func collectVulnerabilityReportMetrics(
+	ch chan<- prometheus.Metric,
+	vulnerabilityReports []storagev1alpha1.VulnerabilityReport,
+	scanJobs []v1alpha1.ScanJob,
+	now time.Time,
+) {
+	scanJobCreationTimes := make(map[string]time.Time, len(scanJobs))
+	for i := range scanJobs {
+		scanJobCreationTimes[string(scanJobs[i].UID)] = scanJobs[i].GetCreationTimestampFromAnnotation()
+	}
+
+	imagesScanned := map[registryKey]int{}
+	vulnerabilities := map[repositoryKey]*storagev1alpha1.Summary{}
+	oldestReports := map[repositoryKey]time.Time{}
+	for i := range vulnerabilityReports {
+		vulnerabilityReport := &vulnerabilityReports[i]
+		key := repositoryKey{
+			registryKey: registryKey{
+				namespace: vulnerabilityReport.Namespace,
+				registry:  vulnerabilityReport.ImageMetadata.Registry,
+			},
+			repository: vulnerabilityReport.ImageMetadata.Repository,
+		}
+
+		imagesScanned[key.registryKey]++
+
+		summary, ok := vulnerabilities[key]
+		if !ok {
+			summary = &storagev1alpha1.Summary{}
+			vulnerabilities[key] = summary
+		}
+		summary.Critical += vulnerabilityReport.Report.Summary.Critical
+		summary.High += vulnerabilityReport.Report.Summary.High
+		summary.Medium += vulnerabilityReport.Report.Summary.Medium
+		summary.Low += vulnerabilityReport.Report.Summary.Low
+		summary.Unknown += vulnerabilityReport.Report.Summary.Unknown
+		summary.Suppressed += vulnerabilityReport.Report.Summary.Suppressed
+
+		scannedAt := reportScannedAt(vulnerabilityReport, scanJobCreationTimes)
+		if oldestReport, ok := oldestReports[key]; !ok || scannedAt.Before(oldestReport) {
+			oldestReports[key] = scannedAt
+		}
+	}
+
+	for key, count := range imagesScanned {
+		ch <- prometheus.MustNewConstMetric(imagesScannedDesc, prometheus.GaugeValue,
+			float64(count), key.namespace, key.registry)
+	}
+	for key, summary := range vulnerabilities {
+		for severity, count := range map[string]int{
+			"critical":   summary.Critical,
+			"high":       summary.High,
+			"medium":     summary.Medium,
+			"low":        summary.Low,
+			"unknown":    summary.Unknown,
+			"suppressed": summary.Suppressed,
+		} {
+			ch <- prometheus.MustNewConstMetric(vulnerabilitiesDesc, prometheus.GaugeValue,
+				float64(count), key.namespace, key.registry, key.repository, severity)
+		}
+	}
+	for key, oldestReport := range oldestReports {
+		ch <- prometheus.MustNewConstMetric(reportAgeDesc, prometheus.GaugeValue,
+			now.Sub(oldestReport).Seconds(), key.namespace, key.registry, key.repository)
+	}
+}
+
+// reportScannedAt returns when the VulnerabilityReport was last scanned.
+func reportScannedAt(vulnerabilityReport *storagev1alpha1.VulnerabilityReport, scanJobCreationTimes map[string]time.Time) time.Time {
+	if vulnerabilityReport.Report.ScannedAt != nil {
+		return vulnerabilityReport.Report.ScannedAt.Time
+	}
+	if scannedAt, ok := scanJobCreationTimes[vulnerabilityReport.Labels[v1alpha1.LabelScanJobUIDKey]]; ok {
+		return scannedAt
+	}
+	return vulnerabilityReport.CreationTimestamp.Time
+}
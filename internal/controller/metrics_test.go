@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+var _ = Describe("PostureCollector", func() {
+	It("Should export the vulnerability posture of the registries", func() {
+		creationTime := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+
+		completedScanJob := &v1alpha1.ScanJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "completed",
+				Namespace: "default",
+				UID:       types.UID("completed-uid"),
+				Annotations: map[string]string{
+					v1alpha1.AnnotationScanJobCreationTimestampKey: creationTime.Format(time.RFC3339Nano),
+				},
+			},
+			Spec: v1alpha1.ScanJobSpec{Registry: "registry"},
+		}
+		completedScanJob.InitializeConditions()
+		completedScanJob.MarkComplete(v1alpha1.ReasonAllImagesScanned, "All images scanned")
+		completedScanJob.Status.CompletionTime = &metav1.Time{Time: creationTime.Add(90 * time.Second)}
+
+		runningScanJob := &v1alpha1.ScanJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "running",
+				Namespace: "default",
+				UID:       types.UID("running-uid"),
+			},
+			Spec: v1alpha1.ScanJobSpec{Registry: "registry"},
+		}
+		runningScanJob.InitializeConditions()
+		runningScanJob.MarkInProgress(v1alpha1.ReasonImageScanInProgress, "Image scan in progress")
+
+		newVulnerabilityReport := func(name, repository string, summary storagev1alpha1.Summary) *storagev1alpha1.VulnerabilityReport {
+			return &storagev1alpha1.VulnerabilityReport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+					Labels:    map[string]string{v1alpha1.LabelScanJobUIDKey: string(completedScanJob.UID)},
+				},
+				ImageMetadata: storagev1alpha1.ImageMetadata{
+					Registry:   "registry",
+					Repository: repository,
+				},
+				Report: storagev1alpha1.Report{Summary: summary},
+			}
+		}
+
+		// The prod report was rescanned recently by a ScanJob that has since been pruned.
+		scannedAt := time.Now().Add(-10 * time.Minute)
+		prodVulnerabilityReport := newVulnerabilityReport("prod-amd64", "sbomscanner-prod", storagev1alpha1.Summary{Medium: 4, Suppressed: 1})
+		prodVulnerabilityReport.Labels[v1alpha1.LabelScanJobUIDKey] = "pruned-uid"
+		prodVulnerabilityReport.Report.ScannedAt = &metav1.Time{Time: scannedAt}
+
+		reader := fake.NewClientBuilder().
+			WithScheme(k8sClient.Scheme()).
+			WithObjects(
+				completedScanJob,
+				runningScanJob,
+				newVulnerabilityReport("dev-amd64", "sbomscanner-dev", storagev1alpha1.Summary{Critical: 1, High: 2}),
+				newVulnerabilityReport("dev-arm64", "sbomscanner-dev", storagev1alpha1.Summary{Critical: 1, Low: 3}),
+				prodVulnerabilityReport,
+			).
+			Build()
+		collector := &PostureCollector{Reader: reader}
+
+		expected := `
+# HELP sbomscanner_images_scanned Number of images of a registry that have a VulnerabilityReport.
+# TYPE sbomscanner_images_scanned gauge
+sbomscanner_images_scanned{namespace="default",registry="registry"} 3
+# HELP sbomscanner_scanjob_duration_seconds Time between the creation and the completion of the last completed or failed ScanJob of a registry.
+# TYPE sbomscanner_scanjob_duration_seconds gauge
+sbomscanner_scanjob_duration_seconds{namespace="default",registry="registry"} 90
+# HELP sbomscanner_vulnerabilities Number of vulnerabilities found in the images of a repository, per severity.
+# TYPE sbomscanner_vulnerabilities gauge
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-dev",severity="critical"} 2
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-dev",severity="high"} 2
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-dev",severity="low"} 3
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-dev",severity="medium"} 0
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-dev",severity="suppressed"} 0
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-dev",severity="unknown"} 0
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-prod",severity="critical"} 0
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-prod",severity="high"} 0
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-prod",severity="low"} 0
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-prod",severity="medium"} 4
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-prod",severity="suppressed"} 1
+sbomscanner_vulnerabilities{namespace="default",registry="registry",repository="sbomscanner-prod",severity="unknown"} 0
+`
+		Expect(testutil.CollectAndCompare(collector, strings.NewReader(expected),
+			"sbomscanner_images_scanned",
+			"sbomscanner_scanjob_duration_seconds",
+			"sbomscanner_vulnerabilities",
+		)).To(Succeed())
+
+		By("Aging the reports from their last scan, or from the creation of their ScanJob")
+		registry := prometheus.NewPedanticRegistry()
+		Expect(registry.Register(collector)).To(Succeed())
+		metricFamilies, err := registry.Gather()
+		Expect(err).NotTo(HaveOccurred())
+		reportAges := map[string]float64{}
+		for _, metricFamily := range metricFamilies {
+			if metricFamily.GetName() != "sbomscanner_report_age_seconds" {
+				continue
+			}
+			for _, metric := range metricFamily.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "repository" {
+						reportAges[label.GetValue()] = metric.GetGauge().GetValue()
+					}
+				}
+			}
+		}
+		Expect(reportAges).To(HaveLen(2))
+		Expect(reportAges["sbomscanner-dev"]).To(BeNumerically(">=", (2 * time.Hour).Seconds()))
+		Expect(reportAges["sbomscanner-prod"]).To(BeNumerically("~", (10 * time.Minute).Seconds(), 60))
+	})
+})
@@ -2,32 +2,67 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/handlers/dockerauth"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
+)
+
+const (
+	// registryValidationInterval is how often a validated registry is probed again.
+	registryValidationInterval = time.Hour
+	// registryValidationRetryInterval is how often a registry that could not be validated is probed again.
+	registryValidationRetryInterval = 5 * time.Minute
+	// registryProbeTimeout is the maximum time spent probing a registry.
+	registryProbeTimeout = 30 * time.Second
+	// registryStatusUpdateDelay is the time the status of a registry is updated after
+	// one of its ScanJobs, Images or VulnerabilityReports changes.
+	registryStatusUpdateDelay = 10 * time.Second
 )
 
 // RegistryReconciler reconciles a Registry object
 type RegistryReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// APIReader reads the Secrets holding the registry credentials directly from the API server,
+	// so that Secrets are not cached by the controller.
+	APIReader client.Reader
 }
 
 // +kubebuilder:rbac:groups=sbomscanner.kubewarden.io,resources=registries,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=sbomscanner.kubewarden.io,resources=registries/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=sbomscanner.kubewarden.io,resources=registries/finalizers,verbs=update
 // +kubebuilder:rbac:groups=storage.sbomscanner.kubewarden.io,resources=images,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=storage.sbomscanner.kubewarden.io,resources=vulnerabilityreports,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
 
 // Reconcile reconciles a Registry.
 // If the Registry has repositories specified, it deletes all images that are not in the current list of repositories.
+// It then probes the registry to check its connectivity and credentials,
+// and updates the Registry status from its ScanJobs, Images and VulnerabilityReports.
 func (r *RegistryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
@@ -45,7 +80,19 @@ func (r *RegistryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, nil
 	}
 
-	return r.reconcileRegistry(ctx, &registry)
+	result, err := r.reconcileRegistry(ctx, &registry)
+	if err != nil {
+		return result, err
+	}
+
+	original := registry.DeepCopy()
+	result.RequeueAfter = r.validateRegistry(ctx, &registry)
+
+	if err := r.updateStatus(ctx, &registry, original); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
 }
 
 func (r *RegistryReconciler) reconcileRegistry(ctx context.Context, registry *v1alpha1.Registry) (ctrl.Result, error) {
@@ -88,10 +135,273 @@ func (r *RegistryReconciler) reconcileRegistry(ctx context.Context, registry *v1
 	return ctrl.Result{}, nil
 }
 
+// updateStatus updates the status of the Registry with the summary of its last ScanJob,
+// the number of cataloged repositories, images and platforms, and the number of vulnerabilities found.
+// The status is patched against the given original Registry.
+func (r *RegistryReconciler) updateStatus(ctx context.Context, registry, original *v1alpha1.Registry) error {
+	scanJobs := &v1alpha1.ScanJobList{}
+	if err := r.List(ctx, scanJobs,
+		client.InNamespace(registry.Namespace),
+		client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name},
+	); err != nil {
+		return fmt.Errorf("unable to list ScanJobs: %w", err)
+	}
+
+	// The last finished ScanJob is reported as is, while only the ScanJobs that completed or failed
+	// tell whether the registry can be scanned.
+	var lastScanJob, lastResultScanJob *v1alpha1.ScanJob
+	for i := range scanJobs.Items {
+		scanJob := &scanJobs.Items[i]
+		if !scanJob.IsComplete() && !scanJob.IsFailed() && !scanJob.IsCancelled() {
+			continue
+		}
+		if lastScanJob == nil || scanJobFinishedAt(scanJob).After(scanJobFinishedAt(lastScanJob)) {
+			lastScanJob = scanJob
+		}
+		if scanJob.IsCancelled() {
+			continue
+		}
+		if lastResultScanJob == nil || scanJobFinishedAt(scanJob).After(scanJobFinishedAt(lastResultScanJob)) {
+			lastResultScanJob = scanJob
+		}
+	}
+	registry.Status.LastScanJob = scanJobSummary(lastScanJob)
+	readyCondition := registryReadyCondition(lastResultScanJob)
+	readyCondition.ObservedGeneration = registry.Generation
+	meta.SetStatusCondition(&registry.Status.Conditions, readyCondition)
+
+	images := &storagev1alpha1.ImageList{}
+	if err := r.List(ctx, images,
+		client.InNamespace(registry.Namespace),
+		client.MatchingFields{storagev1alpha1.IndexImageMetadataRegistry: registry.Name},
+	); err != nil {
+		return fmt.Errorf("unable to list Images: %w", err)
+	}
+	repositories := sets.New[string]()
+	platforms := sets.New[string]()
+	for _, image := range images.Items {
+		repositories.Insert(image.Repository)
+		platforms.Insert(image.Platform)
+	}
+	registry.Status.RepositoriesCount = repositories.Len()
+	registry.Status.ImagesCount = len(images.Items)
+	registry.Status.PlatformsCount = platforms.Len()
+
+	vulnerabilityReports := &storagev1alpha1.VulnerabilityReportList{}
+	if err := r.List(ctx, vulnerabilityReports,
+		client.InNamespace(registry.Namespace),
+		client.MatchingFields{storagev1alpha1.IndexImageMetadataRegistry: registry.Name},
+	); err != nil {
+		return fmt.Errorf("unable to list VulnerabilityReports: %w", err)
+	}
+	registry.Status.Vulnerabilities = nil
+	if len(vulnerabilityReports.Items) > 0 {
+		vulnerabilities := &v1alpha1.VulnerabilitiesSummary{}
+		for _, vulnerabilityReport := range vulnerabilityReports.Items {
+			summary := vulnerabilityReport.Report.Summary
+			vulnerabilities.Critical += summary.Critical
+			vulnerabilities.High += summary.High
+			vulnerabilities.Medium += summary.Medium
+			vulnerabilities.Low += summary.Low
+			vulnerabilities.Unknown += summary.Unknown
+			vulnerabilities.Suppressed += summary.Suppressed
+		}
+		registry.Status.Vulnerabilities = vulnerabilities
+	}
+
+	if equality.Semantic.DeepEqual(original.Status, registry.Status) {
+		return nil
+	}
+
+	// Patch the status, so that the next scan time set by the RegistryScanRunner is not overwritten.
+	if err := r.Status().Patch(ctx, registry, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("unable to update Registry status: %w", err)
+	}
+
+	return nil
+}
+
+// validateRegistry probes the registry and sets the Validated condition, when the registry changed
+// or when the validation interval elapsed. It returns when the registry has to be probed again.
+func (r *RegistryReconciler) validateRegistry(ctx context.Context, registry *v1alpha1.Registry) time.Duration {
+	log := log.FromContext(ctx)
+
+	interval := registryValidationInterval
+	condition := meta.FindStatusCondition(registry.Status.Conditions, v1alpha1.RegistryConditionTypeValidated)
+	if condition != nil && condition.Status != metav1.ConditionTrue {
+		interval = registryValidationRetryInterval
+	}
+	if condition != nil && condition.ObservedGeneration == registry.Generation && registry.Status.LastValidationTime != nil {
+		if nextValidation := registry.Status.LastValidationTime.Add(interval); time.Now().Before(nextValidation) {
+			return time.Until(nextValidation)
+		}
+	}
+
+	log.V(1).Info("Probing registry", "name", registry.Name, "namespace", registry.Namespace, "uri", registry.Spec.URI)
+	validatedCondition := r.probeRegistry(ctx, registry)
+	validatedCondition.ObservedGeneration = registry.Generation
+	meta.SetStatusCondition(&registry.Status.Conditions, validatedCondition)
+	registry.Status.LastValidationTime = &metav1.Time{Time: time.Now().Truncate(time.Second)}
+
+	if validatedCondition.Status != metav1.ConditionTrue {
+		log.Info("Registry validation failed", "name", registry.Name, "namespace", registry.Namespace,
+			"reason", validatedCondition.Reason, "message", validatedCondition.Message)
+		return registryValidationRetryInterval
+	}
+
+	return registryValidationInterval
+}
+
+// probeRegistry checks the TLS settings, the credentials and the catalog access of the registry,
+// and returns the resulting Validated condition.
+// The credentials obtained through a credential provider belong to the workers, so they are not checked.
+func (r *RegistryReconciler) probeRegistry(ctx context.Context, registry *v1alpha1.Registry) metav1.Condition {
+	invalid := func(reason, message string) metav1.Condition {
+		return metav1.Condition{
+			Type:    v1alpha1.RegistryConditionTypeValidated,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: message,
+		}
+	}
+
+	reg, err := name.NewRegistry(registry.Spec.URI)
+	if err != nil {
+		return invalid(v1alpha1.RegistryReasonInvalidConfiguration, fmt.Sprintf("Invalid registry URI: %s", err))
+	}
+
+	rootCAs, err := registryclient.RootCAs(registry.Spec.CABundle)
+	if err != nil {
+		return invalid(v1alpha1.RegistryReasonInvalidConfiguration, fmt.Sprintf("Invalid CA bundle: %s", err))
+	}
+	transport, err := registryclient.NewTransport(registry.Spec.Insecure, rootCAs)
+	if err != nil {
+		return invalid(v1alpha1.RegistryReasonInvalidConfiguration, fmt.Sprintf("Cannot create transport: %s", err))
+	}
+
+	var keychain authn.Keychain = authn.DefaultKeychain
+	if registry.Spec.CredentialProvider == "" {
+		keychain, err = dockerauth.KeychainForRegistry(ctx, r.APIReader, registry)
+		if err != nil {
+			return invalid(v1alpha1.RegistryReasonInvalidConfiguration, fmt.Sprintf("Invalid credentials: %s", err))
+		}
+	}
+
+	logger := slog.New(logr.ToSlogHandler(log.FromContext(ctx)))
+	registryClient := registryclient.NewClient(transport, keychain, logger)
+
+	probeCtx, cancel := context.WithTimeout(ctx, registryProbeTimeout)
+	defer cancel()
+	listCatalog := registry.Spec.CatalogType != v1alpha1.CatalogTypeNoCatalog && registry.Spec.CredentialProvider == ""
+	err = registryClient.Probe(probeCtx, reg, listCatalog)
+	switch {
+	case err == nil:
+	case errors.Is(err, registryclient.ErrAuthFailed) && registry.Spec.CredentialProvider != "":
+		return metav1.Condition{
+			Type:   v1alpha1.RegistryConditionTypeValidated,
+			Status: metav1.ConditionTrue,
+			Reason: v1alpha1.RegistryReasonValidated,
+			Message: fmt.Sprintf("The registry is reachable, the credentials of the %s credential provider are checked by the workers",
+				registry.Spec.CredentialProvider),
+		}
+	case errors.Is(err, registryclient.ErrTLSFailed):
+		return invalid(v1alpha1.RegistryReasonTLSFailed, err.Error())
+	case errors.Is(err, registryclient.ErrAuthFailed):
+		return invalid(v1alpha1.RegistryReasonAuthFailed, err.Error())
+	case errors.Is(err, registryclient.ErrCatalogUnavailable):
+		return invalid(v1alpha1.RegistryReasonCatalogUnavailable, err.Error())
+	default:
+		return invalid(v1alpha1.RegistryReasonUnreachable, err.Error())
+	}
+
+	return metav1.Condition{
+		Type:    v1alpha1.RegistryConditionTypeValidated,
+		Status:  metav1.ConditionTrue,
+		Reason:  v1alpha1.RegistryReasonValidated,
+		Message: "The registry is reachable and accepts the credentials",
+	}
+}
+
+// scanJobSummary returns the summary of a finished ScanJob.
+func scanJobSummary(scanJob *v1alpha1.ScanJob) *v1alpha1.ScanJobSummary {
+	if scanJob == nil {
+		return nil
+	}
+
+	var result string
+	switch {
+	case scanJob.IsComplete():
+		result = v1alpha1.ConditionTypeComplete
+	case scanJob.IsFailed():
+		result = v1alpha1.ConditionTypeFailed
+	default:
+		result = v1alpha1.ConditionTypeCancelled
+	}
+
+	summary := &v1alpha1.ScanJobSummary{
+		Name:               scanJob.Name,
+		Result:             result,
+		CompletionTime:     scanJob.Status.CompletionTime,
+		ScannedImagesCount: scanJob.Status.ScannedImagesCount,
+		FailedImagesCount:  scanJob.Status.FailedImagesCount,
+	}
+	if condition := meta.FindStatusCondition(scanJob.Status.Conditions, result); condition != nil {
+		summary.Reason = condition.Reason
+	}
+
+	return summary
+}
+
+// registryReadyCondition returns the Ready condition of a registry whose last completed or failed ScanJob is the given one.
+func registryReadyCondition(scanJob *v1alpha1.ScanJob) metav1.Condition {
+	if scanJob == nil {
+		return metav1.Condition{
+			Type:    v1alpha1.RegistryConditionTypeReady,
+			Status:  metav1.ConditionUnknown,
+			Reason:  v1alpha1.RegistryReasonNotScanned,
+			Message: "The registry has not been scanned yet",
+		}
+	}
+
+	if scanJob.IsComplete() {
+		return metav1.Condition{
+			Type:    v1alpha1.RegistryConditionTypeReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  v1alpha1.RegistryReasonScanSucceeded,
+			Message: fmt.Sprintf("ScanJob %s completed", scanJob.Name),
+		}
+	}
+
+	failedCondition := meta.FindStatusCondition(scanJob.Status.Conditions, v1alpha1.ConditionTypeFailed)
+	reason := v1alpha1.RegistryReasonScanFailed
+	message := fmt.Sprintf("ScanJob %s failed", scanJob.Name)
+	if failedCondition != nil {
+		switch failedCondition.Reason {
+		case v1alpha1.ReasonAuthFailed:
+			reason = v1alpha1.RegistryReasonAuthFailed
+		case v1alpha1.ReasonRegistryUnreachable:
+			reason = v1alpha1.RegistryReasonUnreachable
+		}
+		message = fmt.Sprintf("%s: %s", message, failedCondition.Message)
+	}
+
+	return metav1.Condition{
+		Type:    v1alpha1.RegistryConditionTypeReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
 // SetupWithManager sets up the controller with the Manager.
+// The Registry is reconciled again when its ScanJobs, Images or VulnerabilityReports change,
+// to keep its status up to date.
 func (r *RegistryReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	err := ctrl.NewControllerManagedBy(mgr).
 		For(&v1alpha1.Registry{}).
+		Watches(&v1alpha1.ScanJob{}, enqueueRegistryAfterDelay(registryRequestFromScanJob)).
+		Watches(&storagev1alpha1.Image{}, enqueueRegistryAfterDelay(registryRequestFromImageMetadata)).
+		Watches(&storagev1alpha1.VulnerabilityReport{}, enqueueRegistryAfterDelay(registryRequestFromImageMetadata)).
 		Complete(r)
 	if err != nil {
 		return fmt.Errorf("failed to create Registry controller: %w", err)
@@ -99,3 +409,50 @@ func (r *RegistryReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	return nil
 }
+
+// enqueueRegistryAfterDelay returns an event handler enqueuing the Registry of the changed object
+// after registryStatusUpdateDelay.
+// The status lists all the Images and VulnerabilityReports of the Registry, so the events received
+// while a request is waiting in the queue, e.g. for each image processed by a ScanJob, are coalesced into it.
+func enqueueRegistryAfterDelay(mapFunc handler.MapFunc) handler.EventHandler {
+	enqueue := func(ctx context.Context, obj client.Object, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		for _, request := range mapFunc(ctx, obj) {
+			queue.AddAfter(request, registryStatusUpdateDelay)
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, queue)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.ObjectNew, queue)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, queue)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, queue)
+		},
+	}
+}
+
+// registryRequestFromScanJob maps a ScanJob to the reconcile request of its Registry.
+func registryRequestFromScanJob(_ context.Context, obj client.Object) []reconcile.Request {
+	scanJob, ok := obj.(*v1alpha1.ScanJob)
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: scanJob.Namespace, Name: scanJob.Spec.Registry}}}
+}
+
+// registryRequestFromImageMetadata maps an object carrying image metadata to the reconcile request of its Registry.
+func registryRequestFromImageMetadata(_ context.Context, obj client.Object) []reconcile.Request {
+	accessor, ok := obj.(storagev1alpha1.ImageMetadataAccessor)
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: accessor.GetImageMetadata().Registry}}}
+}
@@ -2,10 +2,14 @@ package controller
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
@@ -96,4 +100,248 @@ var _ = Describe("Registry Controller", func() {
 			Expect(images.Items[0].GetImageMetadata().Repository).To(Equal("sbomscanner-prod"))
 		})
 	})
+
+	When("The Registry has been scanned", func() {
+		var registry v1alpha1.Registry
+
+		BeforeEach(func(ctx context.Context) {
+			By("Creating a new Registry")
+			registry = v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      uuid.New().String(),
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI: "ghcr.io/kubewarden",
+				},
+			}
+			Expect(k8sClient.Create(ctx, &registry)).To(Succeed())
+
+			By("Creating Images and VulnerabilityReports in two repositories and platforms")
+			for i, imageMetadata := range []storagev1alpha1.ImageMetadata{
+				{Registry: registry.Name, Repository: "sbomscanner-dev", Tag: "latest", Digest: "sha256:123", Platform: "linux/amd64"},
+				{Registry: registry.Name, Repository: "sbomscanner-dev", Tag: "latest", Digest: "sha256:234", Platform: "linux/arm64"},
+				{Registry: registry.Name, Repository: "sbomscanner-prod", Tag: "latest", Digest: "sha256:345", Platform: "linux/amd64"},
+			} {
+				image := storagev1alpha1.Image{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      uuid.New().String(),
+						Namespace: "default",
+					},
+					ImageMetadata: imageMetadata,
+				}
+				Expect(k8sClient.Create(ctx, &image)).To(Succeed())
+
+				vulnerabilityReport := storagev1alpha1.VulnerabilityReport{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      uuid.New().String(),
+						Namespace: "default",
+					},
+					ImageMetadata: imageMetadata,
+					Report: storagev1alpha1.Report{
+						Summary: storagev1alpha1.Summary{
+							Critical: i,
+							High:     2,
+							Low:      1,
+						},
+						Results: []storagev1alpha1.Result{},
+					},
+				}
+				Expect(k8sClient.Create(ctx, &vulnerabilityReport)).To(Succeed())
+			}
+		})
+
+		It("Should report a successful scan in the Registry status", func(ctx context.Context) {
+			By("Creating a completed ScanJob")
+			scanJob := v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      uuid.New().String(),
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: registry.Name,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &scanJob)).To(Succeed())
+			scanJob.InitializeConditions()
+			scanJob.Status.ImagesCount = 3
+			scanJob.Status.ScannedImagesCount = 3
+			scanJob.MarkComplete(v1alpha1.ReasonAllImagesScanned, "Scan completed successfully")
+			Expect(k8sClient.Status().Update(ctx, &scanJob)).To(Succeed())
+
+			By("Reconciling the Registry")
+			reconciler := RegistryReconciler{
+				Client: k8sClient,
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      registry.Name,
+					Namespace: registry.Namespace,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Expecting the Registry status to summarize the scan")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&registry), &registry)).To(Succeed())
+			Expect(registry.Status.LastScanJob).NotTo(BeNil())
+			Expect(registry.Status.LastScanJob.Name).To(Equal(scanJob.Name))
+			Expect(registry.Status.LastScanJob.Result).To(Equal(v1alpha1.ConditionTypeComplete))
+			Expect(registry.Status.LastScanJob.Reason).To(Equal(v1alpha1.ReasonAllImagesScanned))
+			Expect(registry.Status.LastScanJob.ScannedImagesCount).To(Equal(3))
+			Expect(registry.Status.RepositoriesCount).To(Equal(2))
+			Expect(registry.Status.ImagesCount).To(Equal(3))
+			Expect(registry.Status.PlatformsCount).To(Equal(2))
+			Expect(registry.Status.Vulnerabilities).To(Equal(&v1alpha1.VulnerabilitiesSummary{
+				Critical: 3,
+				High:     6,
+				Low:      3,
+			}))
+
+			readyCondition := meta.FindStatusCondition(registry.Status.Conditions, v1alpha1.RegistryConditionTypeReady)
+			Expect(readyCondition).NotTo(BeNil())
+			Expect(readyCondition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(readyCondition.Reason).To(Equal(v1alpha1.RegistryReasonScanSucceeded))
+		})
+
+		It("Should report an authentication failure in the Ready condition", func(ctx context.Context) {
+			By("Creating a ScanJob that failed to authenticate to the registry")
+			scanJob := v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      uuid.New().String(),
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: registry.Name,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &scanJob)).To(Succeed())
+			scanJob.InitializeConditions()
+			scanJob.MarkFailed(v1alpha1.ReasonAuthFailed, "registry authentication failed: UNAUTHORIZED")
+			Expect(k8sClient.Status().Update(ctx, &scanJob)).To(Succeed())
+
+			By("Reconciling the Registry")
+			reconciler := RegistryReconciler{
+				Client: k8sClient,
+			}
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      registry.Name,
+					Namespace: registry.Namespace,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Expecting the Registry not to be ready")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&registry), &registry)).To(Succeed())
+			Expect(registry.Status.LastScanJob).NotTo(BeNil())
+			Expect(registry.Status.LastScanJob.Result).To(Equal(v1alpha1.ConditionTypeFailed))
+
+			readyCondition := meta.FindStatusCondition(registry.Status.Conditions, v1alpha1.RegistryConditionTypeReady)
+			Expect(readyCondition).NotTo(BeNil())
+			Expect(readyCondition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(readyCondition.Reason).To(Equal(v1alpha1.RegistryReasonAuthFailed))
+			Expect(readyCondition.Message).To(ContainSubstring("registry authentication failed"))
+		})
+	})
+
+	When("The Registry is probed", func() {
+		var server *httptest.Server
+		var requestsCount atomic.Int32
+
+		BeforeEach(func() {
+			By("Starting a registry server")
+			requestsCount.Store(0)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestsCount.Add(1)
+				switch r.URL.Path {
+				case "/v2/":
+					w.WriteHeader(http.StatusOK)
+				case "/v2/_catalog":
+					_, _ = w.Write([]byte(`{"repositories":[]}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			DeferCleanup(server.Close)
+		})
+
+		It("Should validate a reachable registry and not probe it again before the validation interval", func(ctx context.Context) {
+			By("Creating a Registry pointing to the registry server")
+			registry := v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      uuid.New().String(),
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI:         server.Listener.Addr().String(),
+					CatalogType: v1alpha1.CatalogTypeOCIDistribution,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &registry)).To(Succeed())
+
+			By("Reconciling the Registry")
+			reconciler := RegistryReconciler{
+				Client:    k8sClient,
+				APIReader: k8sClient,
+			}
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&registry),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(registryValidationInterval))
+
+			By("Expecting the Registry to be validated")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&registry), &registry)).To(Succeed())
+			validatedCondition := meta.FindStatusCondition(registry.Status.Conditions, v1alpha1.RegistryConditionTypeValidated)
+			Expect(validatedCondition).NotTo(BeNil())
+			Expect(validatedCondition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(validatedCondition.Reason).To(Equal(v1alpha1.RegistryReasonValidated))
+			Expect(registry.Status.LastValidationTime).NotTo(BeNil())
+			Expect(requestsCount.Load()).To(BeNumerically(">", 0))
+
+			By("Reconciling the Registry again")
+			requestsCount.Store(0)
+			result, err = reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&registry),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("<=", registryValidationInterval))
+			Expect(requestsCount.Load()).To(BeZero())
+		})
+
+		It("Should report a missing auth Secret as an invalid configuration", func(ctx context.Context) {
+			By("Creating a Registry referencing a missing Secret")
+			registry := v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      uuid.New().String(),
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI:        server.Listener.Addr().String(),
+					AuthSecret: "missing-secret",
+				},
+			}
+			Expect(k8sClient.Create(ctx, &registry)).To(Succeed())
+
+			By("Reconciling the Registry")
+			reconciler := RegistryReconciler{
+				Client:    k8sClient,
+				APIReader: k8sClient,
+			}
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(&registry),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(registryValidationRetryInterval))
+
+			By("Expecting the Registry not to be validated")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&registry), &registry)).To(Succeed())
+			validatedCondition := meta.FindStatusCondition(registry.Status.Conditions, v1alpha1.RegistryConditionTypeValidated)
+			Expect(validatedCondition).NotTo(BeNil())
+			Expect(validatedCondition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(validatedCondition.Reason).To(Equal(v1alpha1.RegistryReasonInvalidConfiguration))
+			Expect(validatedCondition.Message).To(ContainSubstring("missing-secret"))
+			Expect(requestsCount.Load()).To(BeZero())
+		})
+	})
 })
@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
 	"time"
 
@@ -13,11 +14,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/schedule"
 )
 
 const scanInterval = 1 * time.Minute
 
-// RegistryScanRunner handles periodic scanning of registries based on their scan intervals.
+// RegistryScanRunner handles periodic scanning of registries based on their scan intervals or cron schedules.
 type RegistryScanRunner struct {
 	client.Client
 }
@@ -70,52 +72,117 @@ func (r *RegistryScanRunner) scanRegistries(ctx context.Context) error {
 func (r *RegistryScanRunner) checkRegistryForScan(ctx context.Context, registry *v1alpha1.Registry) error {
 	log := log.FromContext(ctx)
 
-	if registry.Spec.ScanInterval == nil || registry.Spec.ScanInterval.Duration == 0 {
-		log.V(2).Info("Skipping registry with disabled scan interval", "registry", registry.Name)
+	if !registry.IsScanScheduled() {
+		log.V(2).Info("Skipping registry with disabled automatic scanning", "registry", registry.Name)
 
-		return nil
+		return r.updateNextScanTime(ctx, registry, nil)
 	}
 
-	lastScanJob, err := r.getLastScanJob(ctx, registry)
+	scanSchedule, err := schedule.ForRegistry(registry)
 	if err != nil {
-		// If no ScanJob exists, create the initial one
-		if apierrors.IsNotFound(err) {
-			if err = r.createScanJob(ctx, registry); err != nil {
-				return fmt.Errorf("failed to create initial scan job for registry %s: %w", registry.Name, err)
-			}
-			log.Info("Created initial scan job for registry", "registry", registry.Name, "namespace", registry.Namespace)
-
-			return nil
-		}
+		return fmt.Errorf("invalid scan schedule for registry %s: %w", registry.Name, err)
+	}
 
+	// If no ScanJob exists, lastScanJob is nil and the initial one is scheduled.
+	lastScanJob, err := r.getLastScanJob(ctx, registry)
+	if err != nil && !apierrors.IsNotFound(err) {
 		return fmt.Errorf("failed to get last scan job for registry %s: %w", registry.Name, err)
 	}
 
-	if !lastScanJob.IsComplete() && !lastScanJob.IsFailed() {
+	nextScanTime, err := r.nextScanTime(registry, scanSchedule, lastScanJob)
+	if err != nil {
+		return fmt.Errorf("failed to compute next scan time for registry %s: %w", registry.Name, err)
+	}
+
+	if lastScanJob != nil && !lastScanJob.IsComplete() && !lastScanJob.IsFailed() && !lastScanJob.IsCancelled() {
 		log.V(1).Info("Registry has a running ScanJob, skipping.", "registry", registry.Name, "scanJob", lastScanJob)
 
-		return nil
+		return r.updateNextScanTime(ctx, registry, nextScanTime)
 	}
 
-	if lastScanJob.Status.CompletionTime != nil {
-		timeSinceLastScan := time.Since(lastScanJob.Status.CompletionTime.Time)
-		if timeSinceLastScan < registry.Spec.ScanInterval.Duration {
-			log.V(2).Info("Registry doesn't need scanning yet", "registry", registry.Name, "timeSinceLastScan", timeSinceLastScan)
+	if nextScanTime == nil || time.Now().Before(*nextScanTime) {
+		log.V(2).Info("Registry doesn't need scanning yet", "registry", registry.Name, "nextScanTime", nextScanTime)
 
-			return nil
-		}
+		return r.updateNextScanTime(ctx, registry, nextScanTime)
+	}
+
+	// The ScanJobs of a registry cannot scan the same images at the same time,
+	// the scan is delayed until the scoped ScanJobs are done.
+	scopedScanJobRunning, err := r.hasRunningScopedScanJob(ctx, registry)
+	if err != nil {
+		return fmt.Errorf("failed to list scoped scan jobs for registry %s: %w", registry.Name, err)
+	}
+	if scopedScanJobRunning {
+		log.V(1).Info("Registry has a running scoped ScanJob, delaying the scan.", "registry", registry.Name)
+
+		return r.updateNextScanTime(ctx, registry, nextScanTime)
 	}
 
-	if err := r.createScanJob(ctx, registry); err != nil {
+	scanJob, err := r.createScanJob(ctx, registry)
+	if err != nil {
 		return fmt.Errorf("failed to create scan job for registry %s: %w", registry.Name, err)
 	}
 
 	log.Info("Created scan job for registry", "registry", registry.Name, "namespace", registry.Namespace)
 
+	nextScanTime, err = r.nextScanTime(registry, scanSchedule, scanJob)
+	if err != nil {
+		return fmt.Errorf("failed to compute next scan time for registry %s: %w", registry.Name, err)
+	}
+
+	return r.updateNextScanTime(ctx, registry, nextScanTime)
+}
+
+// nextScanTime returns when the next scan of the registry is due.
+// Cron schedules are evaluated from the start of the last scan, or from the creation of the registry.
+// Interval schedules are evaluated from the completion of the last scan, so the next scan time
+// is unknown while a scan is running. If the registry was never scanned, a scan is due immediately.
+func (r *RegistryScanRunner) nextScanTime(registry *v1alpha1.Registry, scanSchedule *schedule.Schedule, lastScanJob *v1alpha1.ScanJob) (*time.Time, error) {
+	var nextScanTime time.Time
+	var err error
+
+	switch {
+	case scanSchedule.IsCron() && lastScanJob == nil:
+		nextScanTime, err = scanSchedule.Next(registry.CreationTimestamp.Time)
+	case scanSchedule.IsCron():
+		nextScanTime, err = scanSchedule.Next(lastScanJob.CreationTimestamp.Time)
+	case lastScanJob == nil:
+		nextScanTime, err = scanSchedule.SkipBlackoutWindows(time.Now())
+	case !lastScanJob.IsComplete() && !lastScanJob.IsFailed() && !lastScanJob.IsCancelled():
+		return nil, nil
+	case lastScanJob.Status.CompletionTime == nil:
+		nextScanTime, err = scanSchedule.SkipBlackoutWindows(time.Now())
+	default:
+		nextScanTime, err = scanSchedule.Next(lastScanJob.Status.CompletionTime.Time)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &nextScanTime, nil
+}
+
+// updateNextScanTime updates the next scan time in the Registry status, if it changed.
+func (r *RegistryScanRunner) updateNextScanTime(ctx context.Context, registry *v1alpha1.Registry, nextScanTime *time.Time) error {
+	var next *metav1.Time
+	if nextScanTime != nil {
+		next = &metav1.Time{Time: nextScanTime.Truncate(time.Second)}
+	}
+	if registry.Status.NextScanTime.Equal(next) {
+		return nil
+	}
+
+	original := registry.DeepCopy()
+	registry.Status.NextScanTime = next
+	if err := r.Status().Patch(ctx, registry, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to update next scan time of registry %s: %w", registry.Name, err)
+	}
+
 	return nil
 }
 
 // getLastScanJob finds the most recent ScanJob for a registry (any status).
+// Scoped ScanJobs scan only a subset of the registry, so they are ignored.
 func (r *RegistryScanRunner) getLastScanJob(ctx context.Context, registry *v1alpha1.Registry) (*v1alpha1.ScanJob, error) {
 	var scanJobs v1alpha1.ScanJobList
 
@@ -127,6 +194,10 @@ func (r *RegistryScanRunner) getLastScanJob(ctx context.Context, registry *v1alp
 		return nil, fmt.Errorf("failed to list scan jobs: %w", err)
 	}
 
+	scanJobs.Items = slices.DeleteFunc(scanJobs.Items, func(scanJob v1alpha1.ScanJob) bool {
+		return scanJob.IsScoped()
+	})
+
 	if len(scanJobs.Items) == 0 {
 		return nil, apierrors.NewNotFound(
 			v1alpha1.GroupVersion.WithResource("scanjobs").GroupResource(),
@@ -142,8 +213,23 @@ func (r *RegistryScanRunner) getLastScanJob(ctx context.Context, registry *v1alp
 	return &scanJobs.Items[0], nil
 }
 
+// hasRunningScopedScanJob returns true if a scoped ScanJob of the registry is running.
+func (r *RegistryScanRunner) hasRunningScopedScanJob(ctx context.Context, registry *v1alpha1.Registry) (bool, error) {
+	var scanJobs v1alpha1.ScanJobList
+	if err := r.List(ctx, &scanJobs,
+		client.InNamespace(registry.Namespace),
+		client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name},
+	); err != nil {
+		return false, fmt.Errorf("failed to list scan jobs: %w", err)
+	}
+
+	return slices.ContainsFunc(scanJobs.Items, func(scanJob v1alpha1.ScanJob) bool {
+		return scanJob.IsScoped() && !scanJob.IsComplete() && !scanJob.IsFailed() && !scanJob.IsCancelled()
+	}), nil
+}
+
 // createScanJob creates a new ScanJob for the given registry.
-func (r *RegistryScanRunner) createScanJob(ctx context.Context, registry *v1alpha1.Registry) error {
+func (r *RegistryScanRunner) createScanJob(ctx context.Context, registry *v1alpha1.Registry) (*v1alpha1.ScanJob, error) {
 	scanJob := &v1alpha1.ScanJob{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: fmt.Sprintf("%s-", registry.Name),
@@ -158,10 +244,10 @@ func (r *RegistryScanRunner) createScanJob(ctx context.Context, registry *v1alph
 	}
 
 	if err := r.Create(ctx, scanJob); err != nil {
-		return fmt.Errorf("failed to create ScanJob: %w", err)
+		return nil, fmt.Errorf("failed to create ScanJob: %w", err)
 	}
 
-	return nil
+	return scanJob, nil
 }
 
 // NeedLeaderElection implements the LeaderElectionRunnable interface.
@@ -93,6 +93,49 @@ var _ = Describe("RegistryScanRunner", func() {
 				Expect(scanJobs.Items).To(HaveLen(1))
 			})
 
+			It("Should delay the scan while a scoped scan job is running", func(ctx context.Context) {
+				By("Creating a running scoped scan job for the registry")
+				scopedJob := &v1alpha1.ScanJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "scoped-job",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.ScanJobSpec{
+						Registry: registry.Name,
+						Images:   []string{"myrepo:1.2.3"},
+					},
+				}
+				Expect(k8sClient.Create(ctx, scopedJob)).To(Succeed())
+
+				By("Running the registry scanner")
+				err := runner.scanRegistries(ctx)
+				Expect(err).To(Succeed())
+
+				By("Verifying no full scan job was created")
+				scanJobs := &v1alpha1.ScanJobList{}
+				Expect(k8sClient.List(ctx, scanJobs,
+					client.InNamespace("default"),
+					client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name},
+				)).To(Succeed())
+				Expect(scanJobs.Items).To(HaveLen(1))
+
+				By("Completing the scoped scan job")
+				scopedJob.MarkComplete(v1alpha1.ReasonComplete, "Done")
+				scopedJob.Status.CompletionTime = &metav1.Time{Time: time.Now()}
+				Expect(k8sClient.Status().Update(ctx, scopedJob)).To(Succeed())
+
+				By("Running the registry scanner again")
+				err = runner.scanRegistries(ctx)
+				Expect(err).To(Succeed())
+
+				By("Verifying a full scan job was created")
+				Expect(k8sClient.List(ctx, scanJobs,
+					client.InNamespace("default"),
+					client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name},
+				)).To(Succeed())
+				Expect(scanJobs.Items).To(HaveLen(2))
+			})
+
 			It("Should create a new scan job when the last one completed and interval has passed", func(ctx context.Context) {
 				By("Creating a completed scan job that's older than the scan interval")
 				completedJob := &v1alpha1.ScanJob{
@@ -153,6 +196,49 @@ var _ = Describe("RegistryScanRunner", func() {
 			})
 		})
 
+		When("A registry is scanned on a cron schedule", func() {
+			BeforeEach(func(ctx context.Context) {
+				By("Creating a Registry scanned every day at 02:00")
+				registry = &v1alpha1.Registry{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      uuid.New().String(),
+						Namespace: "default",
+					},
+					Spec: v1alpha1.RegistrySpec{
+						Schedule: "0 2 * * *",
+						TimeZone: "Europe/Berlin",
+					},
+				}
+				Expect(k8sClient.Create(ctx, registry)).To(Succeed())
+			})
+
+			It("Should not create a scan job before the scheduled time and show the next scan time", func(ctx context.Context) {
+				By("Running the registry scanner")
+				err := runner.scanRegistries(ctx)
+				Expect(err).To(Succeed())
+
+				By("Verifying no scan job was created")
+				scanJobs := &v1alpha1.ScanJobList{}
+				Expect(k8sClient.List(ctx, scanJobs,
+					client.InNamespace("default"),
+					client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name},
+				)).To(Succeed())
+				Expect(scanJobs.Items).To(BeEmpty())
+
+				By("Verifying the next scan time is shown in the Registry status")
+				updatedRegistry := &v1alpha1.Registry{}
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(registry), updatedRegistry)).To(Succeed())
+				Expect(updatedRegistry.Status.NextScanTime).NotTo(BeNil())
+
+				location, err := time.LoadLocation("Europe/Berlin")
+				Expect(err).NotTo(HaveOccurred())
+				nextScanTime := updatedRegistry.Status.NextScanTime.In(location)
+				Expect(nextScanTime.Hour()).To(Equal(2))
+				Expect(nextScanTime.Minute()).To(Equal(0))
+				Expect(nextScanTime).To(BeTemporally(">", time.Now()))
+			})
+		})
+
 		When("A Registry has no scan interval", func() {
 			BeforeEach(func(ctx context.Context) {
 				By("Creating a Registry with scan interval disabled (0 duration)")
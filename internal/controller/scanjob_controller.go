@@ -5,9 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -21,7 +30,19 @@ import (
 
 const (
 	maxConcurrentReconciles = 10
-	scanJobsHistoryLimit    = 10
+)
+
+var tracer = otel.Tracer("github.com/kubewarden/sbomscanner/internal/controller")
+
+const (
+	// EventReasonScanJobsPruned is the reason of the events emitted on a Registry when old ScanJobs are deleted.
+	EventReasonScanJobsPruned = "ScanJobsPruned"
+	// EventReasonScanJobScheduled is the reason of the events emitted on a ScanJob when it is sent to the workers.
+	EventReasonScanJobScheduled = "ScanJobScheduled"
+	// EventReasonScanJobSuspended is the reason of the events emitted on a ScanJob when it is suspended.
+	EventReasonScanJobSuspended = "ScanJobSuspended"
+	// EventReasonScanJobCancelled is the reason of the events emitted on a ScanJob when it is cancelled.
+	EventReasonScanJobCancelled = "ScanJobCancelled"
 )
 
 // ScanJobReconciler reconciles a ScanJob object
@@ -29,11 +50,13 @@ type ScanJobReconciler struct {
 	client.Client
 	Scheme    *runtime.Scheme
 	Publisher messaging.Publisher
+	Recorder  record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=sbomscanner.kubewarden.io,resources=scanjobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=sbomscanner.kubewarden.io,resources=scanjobs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=sbomscanner.kubewarden.io,resources=scanjobs/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile reconciles a ScanJob object.
 func (r *ScanJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -54,6 +77,36 @@ func (r *ScanJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, nil
 	}
 
+	if scanJob.IsComplete() || scanJob.IsFailed() || scanJob.IsCancelled() {
+		log.V(1).Info("ScanJob is finished, enforcing the history limits", "scanJob", req.NamespacedName)
+		return r.reconcileFinishedScanJob(ctx, scanJob)
+	}
+
+	if scanJob.Spec.Cancel {
+		log.Info("Cancelling ScanJob", "scanJob", req.NamespacedName)
+		scanJob.MarkCancelled(v1alpha1.ReasonCancelled, "ScanJob has been cancelled by the user")
+		if err := r.Status().Update(ctx, scanJob); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update ScanJob status: %w", err)
+		}
+		r.Recorder.Event(scanJob, corev1.EventTypeNormal, EventReasonScanJobCancelled, "ScanJob has been cancelled by the user")
+		return ctrl.Result{}, nil
+	}
+
+	if scanJob.Spec.Suspend {
+		if scanJob.IsSuspended() {
+			log.V(1).Info("ScanJob is suspended, skipping reconciliation", "scanJob", req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+
+		log.Info("Suspending ScanJob", "scanJob", req.NamespacedName)
+		scanJob.MarkSuspended()
+		if err := r.Status().Update(ctx, scanJob); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to update ScanJob status: %w", err)
+		}
+		r.Recorder.Event(scanJob, corev1.EventTypeNormal, EventReasonScanJobSuspended, "ScanJob has been suspended by the user")
+		return ctrl.Result{}, nil
+	}
+
 	if !scanJob.IsPending() {
 		log.V(1).Info("ScanJob is not in pending state, skipping reconciliation", "scanJob", req.NamespacedName)
 		return ctrl.Result{}, nil
@@ -67,6 +120,13 @@ func (r *ScanJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, fmt.Errorf("failed to update ScanJob status: %w", err)
 	}
 
+	switch {
+	case scanJob.IsScheduled():
+		r.Recorder.Eventf(scanJob, corev1.EventTypeNormal, EventReasonScanJobScheduled, "Scheduled the scan of registry %s", scanJob.Spec.Registry)
+	case scanJob.IsFailed():
+		r.Recorder.Eventf(scanJob, corev1.EventTypeWarning, handlers.EventReasonScanJobFailed, "Registry %s not found", scanJob.Spec.Registry)
+	}
+
 	return reconcileResult, reconcileErr
 }
 
@@ -74,10 +134,6 @@ func (r *ScanJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 func (r *ScanJobReconciler) reconcileScanJob(ctx context.Context, scanJob *v1alpha1.ScanJob) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 
-	if err := r.cleanupOldScanJobs(ctx, scanJob); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to cleanup old ScanJobs: %w", err)
-	}
-
 	registry := &v1alpha1.Registry{}
 	if err := r.Get(ctx, client.ObjectKey{
 		Name:      scanJob.Spec.Registry,
@@ -93,6 +149,10 @@ func (r *ScanJobReconciler) reconcileScanJob(ctx context.Context, scanJob *v1alp
 		return ctrl.Result{}, fmt.Errorf("unable to get Registry %s: %w", scanJob.Spec.Registry, err)
 	}
 
+	if err := r.cleanupOldScanJobs(ctx, registry); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to cleanup old ScanJobs: %w", err)
+	}
+
 	// Only patch if we haven't already set the registry annotation
 	// This avoids triggering multiple reconciles while we're still processing
 	if _, hasAnnotation := scanJob.Annotations[v1alpha1.AnnotationScanJobRegistryKey]; !hasAnnotation {
@@ -111,6 +171,13 @@ func (r *ScanJobReconciler) reconcileScanJob(ctx context.Context, scanJob *v1alp
 		}
 		scanJob.Annotations[v1alpha1.AnnotationScanJobRegistryKey] = string(registryData)
 
+		// The root span of the ScanJob trace is started here, and its context is stored
+		// in the annotations so that the next reconcile can publish the message as its child.
+		var span trace.Span
+		ctx, span = tracer.Start(ctx, "ScanJob "+scanJob.Name, trace.WithNewRoot(), trace.WithAttributes(scanJobAttributes(scanJob)...))
+		defer span.End()
+		setTraceAnnotations(ctx, scanJob)
+
 		if err = r.Patch(ctx, scanJob, client.MergeFrom(original)); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to update ScanJob with registry data: %w", err)
 		}
@@ -123,7 +190,8 @@ func (r *ScanJobReconciler) reconcileScanJob(ctx context.Context, scanJob *v1alp
 	}
 
 	log.V(1).Info("Publishing CreateCatalog message for ScanJob", "scanJob", scanJob.Name, "namespace", scanJob.Namespace, "registry", scanJob.Spec.Registry)
-	messageID := fmt.Sprintf("createCatalog/%s", scanJob.GetUID())
+	// The generation is part of the message ID, so that a resumed ScanJob is not deduplicated.
+	messageID := fmt.Sprintf("createCatalog/%s/%d", scanJob.GetUID(), scanJob.GetGeneration())
 	message, err := json.Marshal(&handlers.CreateCatalogMessage{
 		BaseMessage: handlers.BaseMessage{
 			ScanJob: handlers.ObjectRef{
@@ -137,7 +205,11 @@ func (r *ScanJobReconciler) reconcileScanJob(ctx context.Context, scanJob *v1alp
 		return ctrl.Result{}, fmt.Errorf("unable to marshal CreateCatalog message: %w", err)
 	}
 
+	ctx, span := tracer.Start(traceContextFromAnnotations(ctx, scanJob), "schedule ScanJob", trace.WithAttributes(scanJobAttributes(scanJob)...))
+	defer span.End()
 	if err := r.Publisher.Publish(ctx, handlers.CreateCatalogSubject, messageID, message); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return ctrl.Result{}, fmt.Errorf("unable to publish CreateSBOM message: %w", err)
 	}
 
@@ -146,49 +218,187 @@ func (r *ScanJobReconciler) reconcileScanJob(ctx context.Context, scanJob *v1alp
 	return ctrl.Result{}, nil
 }
 
-// cleanupOldScanJobs ensures we don't have more than scanJobsHistoryLimit for any registry
-func (r *ScanJobReconciler) cleanupOldScanJobs(ctx context.Context, currentScanJob *v1alpha1.ScanJob) error {
+// reconcileFinishedScanJob deletes the finished ScanJobs of the registry exceeding its history limits.
+// When a maximum age is set, the ScanJob is requeued to be deleted once it expires.
+func (r *ScanJobReconciler) reconcileFinishedScanJob(ctx context.Context, scanJob *v1alpha1.ScanJob) (ctrl.Result, error) {
+	registry := &v1alpha1.Registry{}
+	if err := r.Get(ctx, client.ObjectKey{
+		Name:      scanJob.Spec.Registry,
+		Namespace: scanJob.Namespace,
+	}, registry); err != nil {
+		if errors.IsNotFound(err) {
+			// The ScanJobs are garbage collected along with the registry.
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("unable to get Registry %s: %w", scanJob.Spec.Registry, err)
+	}
+
+	if err := r.cleanupOldScanJobs(ctx, registry); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to cleanup old ScanJobs: %w", err)
+	}
+
+	if registry.Spec.ScanJobsHistoryMaxAge == nil {
+		return ctrl.Result{}, nil
+	}
+
+	expiresIn := time.Until(scanJobFinishedAt(scanJob).Add(registry.Spec.ScanJobsHistoryMaxAge.Duration))
+	if expiresIn <= 0 {
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{RequeueAfter: expiresIn}, nil
+}
+
+// cleanupOldScanJobs deletes the finished ScanJobs of the registry exceeding the successful or failed history limits,
+// or older than the maximum age.
+// When no history limit is set, the finished ScanJobs exceeding DefaultScanJobsHistoryLimit are deleted, whatever their status.
+// Pending and running ScanJobs, as well as the most recent ScanJob of the registry, are never deleted.
+func (r *ScanJobReconciler) cleanupOldScanJobs(ctx context.Context, registry *v1alpha1.Registry) error {
 	log := logf.FromContext(ctx)
 
 	scanJobList := &v1alpha1.ScanJobList{}
 	listOpts := []client.ListOption{
-		client.InNamespace(currentScanJob.Namespace),
-		client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: currentScanJob.Spec.Registry},
+		client.InNamespace(registry.Namespace),
+		client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name},
 	}
 
 	if err := r.List(ctx, scanJobList, listOpts...); err != nil {
-		return fmt.Errorf("failed to list ScanJobs for registry %s: %w", currentScanJob.Spec.Registry, err)
-	}
-
-	if len(scanJobList.Items) <= scanJobsHistoryLimit {
-		return nil
+		return fmt.Errorf("failed to list ScanJobs for registry %s: %w", registry.Name, err)
 	}
 
+	// Sort from the newest to the oldest, so that the newest ScanJobs are kept.
 	sort.Slice(scanJobList.Items, func(i, j int) bool {
 		ti := scanJobList.Items[i].GetCreationTimestampFromAnnotation()
 		tj := scanJobList.Items[j].GetCreationTimestampFromAnnotation()
 
-		return ti.Before(tj)
+		return ti.After(tj)
 	})
 
-	log.V(1).Info("Sorting ScanJobs by creation timestamp for cleanup2",
-		"registry", currentScanJob.Spec.Registry,
-		"scanjobs", scanJobList.Items)
+	limitByStatus := registry.HasScanJobsHistoryLimits()
+	successfulLimit := registry.GetSuccessfulScanJobsHistoryLimit()
+	failedLimit := registry.GetFailedScanJobsHistoryLimit()
+	var successfulCount, failedCount int
+	var prunedSuccessful, prunedFailed, prunedFinished, prunedExpired int
+	var lastScanJobFound bool
+
+	for _, scanJob := range scanJobList.Items {
+		// The most recent ScanJob scanning the whole registry is used to schedule the next scan,
+		// so it is never deleted.
+		isLastScanJob := !lastScanJobFound && !scanJob.IsScoped()
+		if isLastScanJob {
+			lastScanJobFound = true
+		}
+
+		var overLimit bool
+		switch {
+		case scanJob.IsComplete():
+			successfulCount++
+			overLimit = successfulCount > successfulLimit
+		case scanJob.IsFailed() || scanJob.IsCancelled():
+			failedCount++
+			overLimit = failedCount > failedLimit
+		default:
+			continue
+		}
+		if !limitByStatus {
+			overLimit = successfulCount+failedCount > v1alpha1.DefaultScanJobsHistoryLimit
+		}
+
+		expired := registry.Spec.ScanJobsHistoryMaxAge != nil &&
+			time.Since(scanJobFinishedAt(&scanJob)) >= registry.Spec.ScanJobsHistoryMaxAge.Duration
+		if isLastScanJob || (!overLimit && !expired) {
+			continue
+		}
 
-	scanJobsToDelete := len(scanJobList.Items) - scanJobsHistoryLimit
-	for _, scanJob := range scanJobList.Items[:scanJobsToDelete] {
 		if err := r.Delete(ctx, &scanJob); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
 			return fmt.Errorf("failed to delete old ScanJob %s: %w", scanJob.Name, err)
 		}
 		log.Info("cleaned up old ScanJob",
 			"name", scanJob.Name,
 			"registry", scanJob.Spec.Registry,
-			"creationTimestamp", scanJob.CreationTimestamp)
+			"creationTimestamp", scanJob.CreationTimestamp,
+			"expired", expired)
+
+		switch {
+		case overLimit && !limitByStatus:
+			prunedFinished++
+		case overLimit && scanJob.IsComplete():
+			prunedSuccessful++
+		case overLimit:
+			prunedFailed++
+		default:
+			prunedExpired++
+		}
+	}
+
+	if pruned := prunedSuccessful + prunedFailed + prunedFinished + prunedExpired; pruned > 0 {
+		var details []string
+		if prunedFinished > 0 {
+			details = append(details, fmt.Sprintf("%d exceeding the history limit of %d", prunedFinished, v1alpha1.DefaultScanJobsHistoryLimit))
+		}
+		if prunedSuccessful > 0 {
+			details = append(details, fmt.Sprintf("%d exceeding the successful history limit of %d", prunedSuccessful, successfulLimit))
+		}
+		if prunedFailed > 0 {
+			details = append(details, fmt.Sprintf("%d exceeding the failed history limit of %d", prunedFailed, failedLimit))
+		}
+		if prunedExpired > 0 {
+			details = append(details, fmt.Sprintf("%d older than %s", prunedExpired, registry.Spec.ScanJobsHistoryMaxAge.Duration))
+		}
+		r.Recorder.Eventf(registry, corev1.EventTypeNormal, EventReasonScanJobsPruned,
+			"Deleted %d old ScanJobs: %s", pruned, strings.Join(details, ", "))
 	}
 
 	return nil
 }
 
+// scanJobFinishedAt returns when the ScanJob finished, falling back to its creation time.
+func scanJobFinishedAt(scanJob *v1alpha1.ScanJob) time.Time {
+	if scanJob.Status.CompletionTime != nil {
+		return scanJob.Status.CompletionTime.Time
+	}
+
+	return scanJob.GetCreationTimestampFromAnnotation()
+}
+
+// scanJobAttributes returns the attributes identifying the ScanJob in its spans.
+func scanJobAttributes(scanJob *v1alpha1.ScanJob) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("k8s.namespace.name", scanJob.Namespace),
+		attribute.String("sbomscanner.scanjob.name", scanJob.Name),
+		attribute.String("sbomscanner.scanjob.uid", string(scanJob.UID)),
+		attribute.String("sbomscanner.registry.name", scanJob.Spec.Registry),
+	}
+}
+
+// setTraceAnnotations stores the trace ID and the W3C trace context of the span of ctx in the ScanJob annotations.
+// Nothing is stored when tracing is disabled.
+func setTraceAnnotations(ctx context.Context, scanJob *v1alpha1.ScanJob) {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return
+	}
+
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	scanJob.Annotations[v1alpha1.AnnotationScanJobTraceIDKey] = spanContext.TraceID().String()
+	scanJob.Annotations[v1alpha1.AnnotationScanJobTraceParentKey] = carrier.Get("traceparent")
+}
+
+// traceContextFromAnnotations returns a copy of ctx holding the trace context stored in the ScanJob annotations.
+func traceContextFromAnnotations(ctx context.Context, scanJob *v1alpha1.ScanJob) context.Context {
+	traceParent, ok := scanJob.Annotations[v1alpha1.AnnotationScanJobTraceParentKey]
+	if !ok {
+		return ctx
+	}
+
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceParent})
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ScanJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	err := ctrl.NewControllerManagedBy(mgr).
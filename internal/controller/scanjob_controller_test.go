@@ -13,6 +13,8 @@ import (
 	"github.com/google/uuid"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -24,6 +26,7 @@ import (
 var _ = Describe("ScanJob Controller", func() {
 	When("A ScanJob is created with a valid Registry", func() {
 		var reconciler ScanJobReconciler
+		var recorder *record.FakeRecorder
 		var scanJob v1alpha1.ScanJob
 		var registry v1alpha1.Registry
 		var mockPublisher *messagingMocks.MockPublisher
@@ -31,10 +34,12 @@ var _ = Describe("ScanJob Controller", func() {
 		BeforeEach(func(ctx context.Context) {
 			By("Creating a new ScanJobReconciler")
 			mockPublisher = messagingMocks.NewMockPublisher(GinkgoT())
+			recorder = record.NewFakeRecorder(10)
 			reconciler = ScanJobReconciler{
 				Client:    k8sClient,
 				Publisher: mockPublisher,
 				Scheme:    k8sClient.Scheme(),
+				Recorder:  recorder,
 			}
 
 			By("Creating a Registry")
@@ -74,7 +79,7 @@ var _ = Describe("ScanJob Controller", func() {
 				},
 			})
 			Expect(err).NotTo(HaveOccurred())
-			mockPublisher.On("Publish", mock.Anything, handlers.CreateCatalogSubject, fmt.Sprintf("createCatalog/%s", scanJob.GetUID()), message).Return(nil)
+			mockPublisher.On("Publish", mock.Anything, handlers.CreateCatalogSubject, fmt.Sprintf("createCatalog/%s/%d", scanJob.GetUID(), scanJob.GetGeneration()), message).Return(nil)
 
 			By("Reconciling the ScanJob")
 			_, err = reconciler.Reconcile(ctx, reconcile.Request{
@@ -125,21 +130,25 @@ var _ = Describe("ScanJob Controller", func() {
 			}, &scanJob)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(scanJob.IsScheduled()).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal("Normal ScanJobScheduled Scheduled the scan of registry test-registry")))
 		})
 	})
 
 	When("A ScanJob references a non-existent Registry", func() {
 		var reconciler ScanJobReconciler
+		var recorder *record.FakeRecorder
 		var scanJob v1alpha1.ScanJob
 		var mockPublisher *messagingMocks.MockPublisher
 
 		BeforeEach(func(ctx context.Context) {
 			By("Creating a new ScanJobReconciler")
 			mockPublisher = messagingMocks.NewMockPublisher(GinkgoT())
+			recorder = record.NewFakeRecorder(10)
 			reconciler = ScanJobReconciler{
 				Client:    k8sClient,
 				Publisher: mockPublisher,
 				Scheme:    k8sClient.Scheme(),
+				Recorder:  recorder,
 			}
 
 			By("Creating a ScanJob with non-existent Registry")
@@ -173,21 +182,102 @@ var _ = Describe("ScanJob Controller", func() {
 			}, updatedScanJob)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(updatedScanJob.IsFailed()).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal("Warning ScanJobFailed Registry non-existent-registry not found")))
+		})
+	})
+
+	When("A ScanJob is suspended or cancelled", func() {
+		var reconciler ScanJobReconciler
+		var recorder *record.FakeRecorder
+		var scanJob v1alpha1.ScanJob
+		var mockPublisher *messagingMocks.MockPublisher
+
+		BeforeEach(func(ctx context.Context) {
+			By("Creating a new ScanJobReconciler")
+			mockPublisher = messagingMocks.NewMockPublisher(GinkgoT())
+			recorder = record.NewFakeRecorder(10)
+			reconciler = ScanJobReconciler{
+				Client:    k8sClient,
+				Publisher: mockPublisher,
+				Scheme:    k8sClient.Scheme(),
+				Recorder:  recorder,
+			}
+
+			By("Creating a suspended ScanJob")
+			scanJob = v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      uuid.New().String(),
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "test-registry",
+					Suspend:  true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &scanJob)).To(Succeed())
+		})
+
+		It("should not schedule a suspended ScanJob and cancel it on request", func(ctx context.Context) {
+			By("Reconciling the suspended ScanJob")
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      scanJob.Name,
+					Namespace: scanJob.Namespace,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying the ScanJob is suspended and no message was published")
+			err = k8sClient.Get(ctx, types.NamespacedName{
+				Name:      scanJob.Name,
+				Namespace: scanJob.Namespace,
+			}, &scanJob)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scanJob.IsSuspended()).To(BeTrue())
+			Expect(scanJob.IsPending()).To(BeTrue())
+			mockPublisher.AssertNotCalled(GinkgoT(), "Publish")
+
+			By("Cancelling the ScanJob")
+			scanJob.Spec.Cancel = true
+			Expect(k8sClient.Update(ctx, &scanJob)).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      scanJob.Name,
+					Namespace: scanJob.Namespace,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Verifying the ScanJob is cancelled")
+			err = k8sClient.Get(ctx, types.NamespacedName{
+				Name:      scanJob.Name,
+				Namespace: scanJob.Namespace,
+			}, &scanJob)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(scanJob.IsCancelled()).To(BeTrue())
+			Expect(scanJob.IsPending()).To(BeFalse())
+			Expect(scanJob.Status.CompletionTime).NotTo(BeNil())
+			Expect(recorder.Events).To(Receive(Equal("Normal ScanJobSuspended ScanJob has been suspended by the user")))
+			Expect(recorder.Events).To(Receive(Equal("Normal ScanJobCancelled ScanJob has been cancelled by the user")))
 		})
 	})
 
 	When("A ScanJob is already completed", func() {
 		var reconciler ScanJobReconciler
+		var recorder *record.FakeRecorder
 		var scanJob v1alpha1.ScanJob
 		var mockPublisher *messagingMocks.MockPublisher
 
 		BeforeEach(func(ctx context.Context) {
 			By("Creating a new ScanJobReconciler")
 			mockPublisher = messagingMocks.NewMockPublisher(GinkgoT())
+			recorder = record.NewFakeRecorder(10)
 			reconciler = ScanJobReconciler{
 				Client:    k8sClient,
 				Publisher: mockPublisher,
 				Scheme:    k8sClient.Scheme(),
+				Recorder:  recorder,
 			}
 
 			By("Creating a completed ScanJob")
@@ -219,20 +309,22 @@ var _ = Describe("ScanJob Controller", func() {
 		})
 	})
 
-	When("There are more than scanJobsHistoryLimit ScanJobs for a registry", func() {
+	When("There are more finished ScanJobs than the history limits for a registry", func() {
 		var reconciler ScanJobReconciler
 		var mockPublisher *messagingMocks.MockPublisher
+		var recorder *record.FakeRecorder
 		var registry v1alpha1.Registry
-		var scanJobs []v1alpha1.ScanJob
 		var newScanJob v1alpha1.ScanJob
 
 		BeforeEach(func(ctx context.Context) {
 			By("Creating a new ScanJobReconciler")
 			mockPublisher = messagingMocks.NewMockPublisher(GinkgoT())
+			recorder = record.NewFakeRecorder(10)
 			reconciler = ScanJobReconciler{
 				Client:    k8sClient,
 				Publisher: mockPublisher,
 				Scheme:    k8sClient.Scheme(),
+				Recorder:  recorder,
 			}
 			By("Creating a Registry")
 			registry = v1alpha1.Registry{
@@ -246,10 +338,9 @@ var _ = Describe("ScanJob Controller", func() {
 			}
 			Expect(k8sClient.Create(ctx, &registry)).To(Succeed())
 
-			By("Creating scanJobsHistoryLimit existing ScanJobs")
-			scanJobs = make([]v1alpha1.ScanJob, 12)
-			for i := range scanJobsHistoryLimit {
-				creationTimestamp := time.Now().Add(-time.Duration(i) * time.Hour).UTC().Format(time.RFC3339Nano)
+			By("Creating more completed and failed ScanJobs than the default history limit")
+			for i := range v1alpha1.DefaultScanJobsHistoryLimit + 2 {
+				creationTimestamp := time.Now().Add(-time.Duration(i+1) * time.Hour).UTC().Format(time.RFC3339Nano)
 
 				scanJob := v1alpha1.ScanJob{
 					ObjectMeta: metav1.ObjectMeta{
@@ -264,7 +355,12 @@ var _ = Describe("ScanJob Controller", func() {
 					},
 				}
 				Expect(k8sClient.Create(ctx, &scanJob)).To(Succeed())
-				scanJobs[i] = scanJob
+				if i%2 == 0 {
+					scanJob.MarkComplete(v1alpha1.ReasonAllImagesScanned, "Scan completed successfully")
+				} else {
+					scanJob.MarkFailed(v1alpha1.ReasonInternalError, "Scan failed")
+				}
+				Expect(k8sClient.Status().Update(ctx, &scanJob)).To(Succeed())
 			}
 
 			By("Creating a new ScanJob that will trigger cleanup")
@@ -280,6 +376,11 @@ var _ = Describe("ScanJob Controller", func() {
 			Expect(k8sClient.Create(ctx, &newScanJob)).To(Succeed())
 		})
 
+		AfterEach(func(ctx context.Context) {
+			Expect(k8sClient.DeleteAllOf(ctx, &v1alpha1.ScanJob{}, client.InNamespace("default"))).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &registry)).To(Succeed())
+		})
+
 		It("should cleanup old ScanJobs during reconciliation", func(ctx context.Context) {
 			By("Setting up the expected message publication")
 			expectedMessage, err := json.Marshal(&handlers.CreateCatalogMessage{
@@ -292,7 +393,7 @@ var _ = Describe("ScanJob Controller", func() {
 				},
 			})
 			Expect(err).NotTo(HaveOccurred())
-			mockPublisher.On("Publish", mock.Anything, handlers.CreateCatalogSubject, fmt.Sprintf("createCatalog/%s", newScanJob.GetUID()), expectedMessage).Return(nil)
+			mockPublisher.On("Publish", mock.Anything, handlers.CreateCatalogSubject, fmt.Sprintf("createCatalog/%s/%d", newScanJob.GetUID(), newScanJob.GetGeneration()), expectedMessage).Return(nil)
 
 			By("Reconciling the new ScanJob")
 			_, err = reconciler.Reconcile(ctx, reconcile.Request{
@@ -303,11 +404,22 @@ var _ = Describe("ScanJob Controller", func() {
 			})
 			Expect(err).NotTo(HaveOccurred())
 
-			By("Verifying that only 10 ScanJobs remain for this registry")
+			By("Verifying that only the most recent finished ScanJobs, whatever their status, and the new one remain for this registry")
 			scanJobList := &v1alpha1.ScanJobList{}
 			err = k8sClient.List(ctx, scanJobList, client.InNamespace("default"), client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(scanJobList.Items).To(HaveLen(scanJobsHistoryLimit))
+			Expect(scanJobList.Items).To(HaveLen(v1alpha1.DefaultScanJobsHistoryLimit + 1))
+			for _, scanJob := range scanJobList.Items {
+				Expect(scanJob.Name).NotTo(BeElementOf(
+					fmt.Sprintf("old-scanjob-%d", v1alpha1.DefaultScanJobsHistoryLimit),
+					fmt.Sprintf("old-scanjob-%d", v1alpha1.DefaultScanJobsHistoryLimit+1),
+				))
+			}
+
+			By("Verifying that an event summarizing the pruned ScanJobs is emitted")
+			Expect(recorder.Events).To(Receive(Equal(
+				"Normal ScanJobsPruned Deleted 2 old ScanJobs: 2 exceeding the history limit of 10",
+			)))
 
 			By("Reconciling the ScanJob again after the patch")
 			_, err = reconciler.Reconcile(ctx, reconcile.Request{
@@ -327,4 +439,108 @@ var _ = Describe("ScanJob Controller", func() {
 			Expect(newScanJob.IsScheduled()).To(BeTrue())
 		})
 	})
+
+	When("A ScanJob of a registry with custom history limits finishes", func() {
+		var reconciler ScanJobReconciler
+		var recorder *record.FakeRecorder
+		var registry v1alpha1.Registry
+		var runningScanJob v1alpha1.ScanJob
+
+		createScanJob := func(ctx context.Context, name string, age time.Duration, finish func(*v1alpha1.ScanJob)) v1alpha1.ScanJob {
+			scanJob := v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "default",
+					Annotations: map[string]string{
+						v1alpha1.AnnotationScanJobCreationTimestampKey: time.Now().Add(-age).UTC().Format(time.RFC3339Nano),
+					},
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: registry.Name,
+				},
+			}
+			Expect(k8sClient.Create(ctx, &scanJob)).To(Succeed())
+			scanJob.InitializeConditions()
+			finish(&scanJob)
+			Expect(k8sClient.Status().Update(ctx, &scanJob)).To(Succeed())
+
+			return scanJob
+		}
+
+		BeforeEach(func(ctx context.Context) {
+			By("Creating a new ScanJobReconciler")
+			recorder = record.NewFakeRecorder(10)
+			reconciler = ScanJobReconciler{
+				Client:    k8sClient,
+				Publisher: messagingMocks.NewMockPublisher(GinkgoT()),
+				Scheme:    k8sClient.Scheme(),
+				Recorder:  recorder,
+			}
+
+			By("Creating a Registry with custom history limits")
+			registry = v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "history-limits-test-registry",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI:                            "https://registry.example.com",
+					SuccessfulScanJobsHistoryLimit: ptr.To[int32](2),
+					FailedScanJobsHistoryLimit:     ptr.To[int32](1),
+					ScanJobsHistoryMaxAge:          &metav1.Duration{Duration: 24 * time.Hour},
+				},
+			}
+			Expect(k8sClient.Create(ctx, &registry)).To(Succeed())
+
+			By("Creating finished and running ScanJobs")
+			markComplete := func(scanJob *v1alpha1.ScanJob) {
+				scanJob.MarkComplete(v1alpha1.ReasonAllImagesScanned, "Scan completed successfully")
+			}
+			markFailed := func(scanJob *v1alpha1.ScanJob) {
+				scanJob.MarkFailed(v1alpha1.ReasonInternalError, "Scan failed")
+			}
+			createScanJob(ctx, "completed-1", 1*time.Hour, markComplete)
+			createScanJob(ctx, "completed-2", 2*time.Hour, markComplete)
+			createScanJob(ctx, "failed-1", 3*time.Hour, markFailed)
+			createScanJob(ctx, "failed-2", 4*time.Hour, markFailed)
+			createScanJob(ctx, "cancelled-1", 5*time.Hour, func(scanJob *v1alpha1.ScanJob) {
+				scanJob.MarkCancelled(v1alpha1.ReasonCancelled, "ScanJob has been cancelled by the user")
+			})
+			runningScanJob = createScanJob(ctx, "running-1", 48*time.Hour, func(scanJob *v1alpha1.ScanJob) {
+				scanJob.MarkInProgress(v1alpha1.ReasonImageScanInProgress, "Scanning images")
+			})
+		})
+
+		AfterEach(func(ctx context.Context) {
+			Expect(k8sClient.DeleteAllOf(ctx, &v1alpha1.ScanJob{}, client.InNamespace("default"))).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &registry)).To(Succeed())
+		})
+
+		It("should enforce the history limits and requeue the ScanJob until it expires", func(ctx context.Context) {
+			By("Reconciling the most recent completed ScanJob")
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "completed-1",
+					Namespace: "default",
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("~", 24*time.Hour, time.Minute))
+
+			By("Verifying that the failed and cancelled ScanJobs exceeding the limit are deleted")
+			scanJobList := &v1alpha1.ScanJobList{}
+			err = k8sClient.List(ctx, scanJobList, client.InNamespace("default"), client.MatchingFields{v1alpha1.IndexScanJobSpecRegistry: registry.Name})
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, scanJob := range scanJobList.Items {
+				names = append(names, scanJob.Name)
+			}
+			Expect(names).To(ConsistOf("completed-1", "completed-2", "failed-1", runningScanJob.Name))
+
+			By("Verifying that an event summarizing the pruned ScanJobs is emitted")
+			Expect(recorder.Events).To(Receive(Equal(
+				"Normal ScanJobsPruned Deleted 2 old ScanJobs: 2 exceeding the failed history limit of 1",
+			)))
+		})
+	})
 })
@@ -3,25 +3,30 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/handlers"
 )
 
 // VulnerabilityReportReconciler reconciles a VulnerabilityReport object
 type VulnerabilityReportReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=storage.sbomscanner.kubewarden.io,resources=vulnerabilityreports,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile reconciles a VulnerabilityReport object.
 func (r *VulnerabilityReportReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -58,6 +63,13 @@ func (r *VulnerabilityReportReconciler) Reconcile(ctx context.Context, req ctrl.
 	}
 	scanJob := &scanJobs.Items[0]
 
+	// A suspended ScanJob is scheduled again from the beginning once resumed,
+	// so there is no progress to track.
+	if scanJob.Spec.Suspend {
+		log.V(1).Info("ScanJob is suspended, skipping", "vulnerabilityReport", req.NamespacedName, "scanJobUID", scanJobUID)
+		return ctrl.Result{}, nil
+	}
+
 	vulnerabilityReports := &storagev1alpha1.VulnerabilityReportList{}
 	if err := r.List(ctx, vulnerabilityReports,
 		client.InNamespace(req.Namespace),
@@ -71,14 +83,16 @@ func (r *VulnerabilityReportReconciler) Reconcile(ctx context.Context, req ctrl.
 		"imagesCount", scanJob.Status.ImagesCount,
 		"scannedImagesCount", len(vulnerabilityReports.Items))
 
+	wasComplete := scanJob.IsComplete()
 	scanJob.Status.ScannedImagesCount = len(vulnerabilityReports.Items)
-	// If the ScanJob is failed, we don't want to override its status conditions.
+	scanJob.SetNewCriticalCVEs(newCriticalCVEs(vulnerabilityReports.Items))
+	// If the ScanJob is failed or cancelled, we don't want to override its status conditions.
 	// We still update the ScannedImagesCount in case some reports were generated before the failure.
-	if !scanJob.IsFailed() {
-		if scanJob.Status.ScannedImagesCount == scanJob.Status.ImagesCount {
-			now := metav1.Now()
-			scanJob.Status.CompletionTime = &now
-			scanJob.MarkComplete(v1alpha1.ReasonAllImagesScanned, "All images scanned successfully")
+	if !scanJob.IsFailed() && !scanJob.IsStopRequested() {
+		// Images that could not be scanned are recorded by the worker, the job completes
+		// once every image has either a VulnerabilityReport or a failure.
+		if scanJob.AllImagesProcessed() {
+			scanJob.MarkImagesProcessed()
 		} else {
 			scanJob.MarkInProgress(v1alpha1.ReasonImageScanInProgress, "Image scan in progress")
 		}
@@ -86,10 +100,32 @@ func (r *VulnerabilityReportReconciler) Reconcile(ctx context.Context, req ctrl.
 	if err := r.Status().Update(ctx, scanJob); err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to update ScanJob status: %w", err)
 	}
+	if !wasComplete && scanJob.IsComplete() {
+		handlers.RecordScanJobCompleted(r.Recorder, scanJob)
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// newCriticalCVEs returns the sorted CVEs that became critical since the previous scan of the images,
+// either because they were introduced or because their severity was raised.
+func newCriticalCVEs(vulnerabilityReports []storagev1alpha1.VulnerabilityReport) []string {
+	cves := sets.New[string]()
+	for _, vulnerabilityReport := range vulnerabilityReports {
+		delta := vulnerabilityReport.Report.Delta
+		if delta == nil {
+			continue
+		}
+		for _, change := range slices.Concat(delta.Introduced, delta.SeverityChanges) {
+			if change.Severity == "CRITICAL" {
+				cves.Insert(change.CVE)
+			}
+		}
+	}
+
+	return sets.List(cves)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *VulnerabilityReportReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	err := ctrl.NewControllerManagedBy(mgr).
@@ -8,8 +8,10 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/config"
@@ -23,6 +25,7 @@ import (
 var _ = Describe("VulnerabilityReport Controller", func() {
 	var cancel context.CancelFunc
 	var mgrClient client.Client
+	var recorder *record.FakeRecorder
 
 	// Unlike our other controller tests that use field selectors for lookups,
 	// this reconciler relies on an indexer to find ScanJobs by metadata.uid.
@@ -49,9 +52,11 @@ var _ = Describe("VulnerabilityReport Controller", func() {
 		err = SetupIndexer(ctx, mgr)
 		Expect(err).ToNot(HaveOccurred())
 
+		recorder = record.NewFakeRecorder(10)
 		reconciler := VulnerabilityReportReconciler{
-			Client: mgr.GetClient(),
-			Scheme: mgr.GetScheme(),
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: recorder,
 		}
 
 		mgrClient = mgr.GetClient()
@@ -73,7 +78,7 @@ var _ = Describe("VulnerabilityReport Controller", func() {
 
 	When("VulnerabilityReports are created during a succesfull Scan", func() {
 		DescribeTable("All VulnerabilityReports are created for a ScanJob",
-			func(ctx context.Context, setupScanJob func(*v1alpha1.ScanJob), expectScanJob func(*v1alpha1.ScanJob)) {
+			func(ctx context.Context, setupScanJob func(*v1alpha1.ScanJob), expectScanJob func(*v1alpha1.ScanJob), expectedEvent string) {
 				By("Creating a ScanJob with 2 total images")
 				scanJob := &v1alpha1.ScanJob{
 					ObjectMeta: metav1.ObjectMeta{
@@ -150,6 +155,13 @@ var _ = Describe("VulnerabilityReport Controller", func() {
 				By("Verifying final state")
 				Expect(finalScanJob.Status.ScannedImagesCount).To(Equal(2))
 				expectScanJob(finalScanJob)
+
+				By("Verifying the completion of the ScanJob was recorded")
+				if expectedEvent == "" {
+					Consistently(recorder.Events).ShouldNot(Receive())
+				} else {
+					Eventually(recorder.Events).Should(Receive(Equal(expectedEvent)))
+				}
 			},
 			Entry("should mark the ScanJob as complete when all reports are created",
 				func(_ *v1alpha1.ScanJob) {
@@ -158,6 +170,25 @@ var _ = Describe("VulnerabilityReport Controller", func() {
 					Expect(scanJob.IsComplete()).To(BeTrue())
 					Expect(scanJob.IsFailed()).To(BeFalse())
 				},
+				"Normal ScanJobCompleted All images scanned successfully",
+			),
+			Entry("should mark the ScanJob as partially failed when some images could not be scanned",
+				func(scanJob *v1alpha1.ScanJob) {
+					scanJob.Status.ImagesCount = 3
+					scanJob.AddFailedImage(v1alpha1.FailedImage{
+						Image: "registry.example.com/library/nginx:1.27",
+						Error: "SBOM generation failed",
+					})
+				},
+				func(scanJob *v1alpha1.ScanJob) {
+					Expect(scanJob.IsComplete()).To(BeTrue())
+					Expect(scanJob.IsFailed()).To(BeFalse())
+					completeCondition := meta.FindStatusCondition(scanJob.Status.Conditions, v1alpha1.ConditionTypeComplete)
+					Expect(completeCondition).ToNot(BeNil())
+					Expect(completeCondition.Reason).To(Equal(v1alpha1.ReasonPartiallyFailed))
+					Expect(scanJob.Status.FailedImagesCount).To(Equal(1))
+				},
+				"Warning ScanJobCompleted 1 of 3 images could not be scanned",
 			),
 			Entry("should update count but preserve failed status when ScanJob is already failed",
 				func(scanJob *v1alpha1.ScanJob) {
@@ -167,10 +198,84 @@ var _ = Describe("VulnerabilityReport Controller", func() {
 					Expect(scanJob.IsFailed()).To(BeTrue())
 					Expect(scanJob.IsComplete()).To(BeFalse())
 				},
+				"",
 			),
 		)
 	})
 
+	When("VulnerabilityReports carry the changes since the previous scan", func() {
+		It("should aggregate the new critical CVEs into the ScanJob status", func(ctx context.Context) {
+			By("Creating a ScanJob with 2 total images")
+			scanJob := &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scanjob-" + uuid.New().String()[:8],
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "test-registry",
+				},
+			}
+			Expect(k8sClient.Create(ctx, scanJob)).To(Succeed())
+			scanJob.Status.ImagesCount = 2
+			Expect(k8sClient.Status().Update(ctx, scanJob)).To(Succeed())
+
+			By("Creating the VulnerabilityReports")
+			deltas := []*storagev1alpha1.ReportDelta{
+				{
+					Introduced: []storagev1alpha1.VulnerabilityChange{
+						{CVE: "CVE-2024-0002", PURL: "pkg:apk/alpine/openssl@3.3.0", Severity: "CRITICAL"},
+						{CVE: "CVE-2024-0003", PURL: "pkg:apk/alpine/curl@8.9.0", Severity: "HIGH"},
+					},
+					Fixed: []storagev1alpha1.VulnerabilityChange{
+						{CVE: "CVE-2024-0004", PURL: "pkg:apk/alpine/curl@8.9.0", Severity: "CRITICAL"},
+					},
+					SeverityChanges: []storagev1alpha1.VulnerabilityChange{},
+				},
+				{
+					Introduced: []storagev1alpha1.VulnerabilityChange{
+						{CVE: "CVE-2024-0002", PURL: "pkg:apk/alpine/openssl@3.3.0", Severity: "CRITICAL"},
+					},
+					Fixed: []storagev1alpha1.VulnerabilityChange{},
+					SeverityChanges: []storagev1alpha1.VulnerabilityChange{
+						{CVE: "CVE-2024-0001", PURL: "pkg:apk/alpine/busybox@1.36.1", Severity: "CRITICAL", PreviousSeverity: "HIGH"},
+					},
+				},
+			}
+			for _, delta := range deltas {
+				vulnerabilityReport := storagev1alpha1.VulnerabilityReport{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      uuid.New().String(),
+						Namespace: "default",
+						Labels: map[string]string{
+							v1alpha1.LabelScanJobUIDKey: string(scanJob.UID),
+						},
+					},
+					Report: storagev1alpha1.Report{
+						Results: []storagev1alpha1.Result{},
+						Delta:   delta,
+					},
+				}
+				Expect(k8sClient.Create(ctx, &vulnerabilityReport)).To(Succeed())
+			}
+
+			By("Waiting for the new critical CVEs to be aggregated")
+			updatedScanJob := &v1alpha1.ScanJob{}
+			Eventually(func() int {
+				err := k8sClient.Get(ctx, types.NamespacedName{
+					Name:      scanJob.Name,
+					Namespace: scanJob.Namespace,
+				}, updatedScanJob)
+				if err != nil {
+					return 0
+				}
+				return updatedScanJob.Status.ScannedImagesCount
+			}, "10s").Should(Equal(2))
+
+			Expect(updatedScanJob.Status.NewCriticalCVEsCount).To(Equal(2))
+			Expect(updatedScanJob.Status.NewCriticalCVEs).To(Equal([]string{"CVE-2024-0001", "CVE-2024-0002"}))
+		})
+	})
+
 	Describe("VulnerabilityReports are reconciled with invalid ScanJob references", func() {
 		When("A VulnerabilityReport references a non-existent ScanJob", func() {
 			var vulnerabilityReport storagev1alpha1.VulnerabilityReport
@@ -195,8 +300,9 @@ var _ = Describe("VulnerabilityReport Controller", func() {
 			It("should skip reconciliation without error to avoid reconciliation loops", func(ctx context.Context) {
 				By("Creating a VulnerabilityReport reconciler")
 				reconciler := VulnerabilityReportReconciler{
-					Client: mgrClient,
-					Scheme: k8sClient.Scheme(),
+					Client:   mgrClient,
+					Scheme:   k8sClient.Scheme(),
+					Recorder: record.NewFakeRecorder(10),
 				}
 
 				By("Reconciling the VulnerabilityReport")
@@ -232,8 +338,9 @@ var _ = Describe("VulnerabilityReport Controller", func() {
 			It("should skip reconciliation without error to avoid reconciliation loops", func(ctx context.Context) {
 				By("Creating a VulnerabilityReport reconciler")
 				reconciler := VulnerabilityReportReconciler{
-					Client: mgrClient,
-					Scheme: k8sClient.Scheme(),
+					Client:   mgrClient,
+					Scheme:   k8sClient.Scheme(),
+					Recorder: record.NewFakeRecorder(10),
 				}
 
 				By("Reconciling the VulnerabilityReport")
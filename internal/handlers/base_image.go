@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+const (
+	// annotationBaseImageName is the OCI annotation holding the reference of the base image.
+	annotationBaseImageName = "org.opencontainers.image.base.name"
+	// annotationBaseImageDigest is the OCI annotation holding the digest of the base image.
+	annotationBaseImageDigest = "org.opencontainers.image.base.digest"
+)
+
+// baseImageFromAnnotations returns the base image declared by the annotations of the image manifest,
+// or nil if the image does not declare its base image.
+func baseImageFromAnnotations(annotations map[string]string) *storagev1alpha1.BaseImage {
+	ref, err := name.ParseReference(annotations[annotationBaseImageName])
+	if err != nil {
+		return nil
+	}
+
+	baseImage := &storagev1alpha1.BaseImage{
+		RegistryURI: ref.Context().RegistryStr(),
+		Repository:  ref.Context().RepositoryStr(),
+		Digest:      annotations[annotationBaseImageDigest],
+		Source:      storagev1alpha1.BaseImageSourceAnnotations,
+	}
+	switch ref := ref.(type) {
+	case name.Tag:
+		baseImage.Tag = ref.TagStr()
+	case name.Digest:
+		if baseImage.Digest == "" {
+			baseImage.Digest = ref.DigestStr()
+		}
+	}
+
+	return baseImage
+}
+
+// detectBaseImage detects the base image of the image among the candidate images of the catalog,
+// and flags the layers of the image belonging to it.
+//
+// A candidate is a base image of the image when its layers are the first layers of the image.
+// When several candidates match, the one with the most layers is the closest base image.
+// The base image declared by the annotations takes precedence, the candidates are then used
+// only to find its layers.
+// The image is left unchanged if no base image is found.
+func detectBaseImage(image *storagev1alpha1.Image, candidates []storagev1alpha1.Image) {
+	var baseImage *storagev1alpha1.Image
+	for i := range candidates {
+		candidate := &candidates[i]
+		if candidate.Platform != image.Platform || !isBaseImageOf(candidate, image) {
+			continue
+		}
+		if image.BaseImage != nil &&
+			(candidate.RegistryURI != image.BaseImage.RegistryURI || candidate.Repository != image.BaseImage.Repository) {
+			continue
+		}
+		if baseImage == nil || compareBaseImages(candidate, baseImage) < 0 {
+			baseImage = candidate
+		}
+	}
+	if baseImage == nil {
+		return
+	}
+
+	if image.BaseImage == nil {
+		image.BaseImage = &storagev1alpha1.BaseImage{
+			RegistryURI: baseImage.RegistryURI,
+			Repository:  baseImage.Repository,
+			Tag:         baseImage.Tag,
+			Digest:      baseImage.Digest,
+			Source:      storagev1alpha1.BaseImageSourceLayers,
+		}
+	}
+	for i := range image.Layers {
+		image.Layers[i].BaseImage = i < len(baseImage.Layers)
+	}
+}
+
+// isBaseImageOf tells if the layers of the candidate are the first layers of the image.
+func isBaseImageOf(candidate, image *storagev1alpha1.Image) bool {
+	if len(candidate.Layers) == 0 || len(candidate.Layers) >= len(image.Layers) {
+		return false
+	}
+
+	for i, layer := range candidate.Layers {
+		if layer.DiffID != image.Layers[i].DiffID {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareBaseImages orders the candidate base images, the closest base image first.
+// Candidates with the same layers are ordered by reference, to always pick the same one.
+func compareBaseImages(a, b *storagev1alpha1.Image) int {
+	return cmp.Or(
+		cmp.Compare(len(b.Layers), len(a.Layers)),
+		cmp.Compare(a.RegistryURI, b.RegistryURI),
+		cmp.Compare(a.Repository, b.Repository),
+		cmp.Compare(a.Tag, b.Tag),
+	)
+}
+
+// baseImageChanged tells if the base image or the base image layers of the images differ.
+func baseImageChanged(a, b *storagev1alpha1.Image) bool {
+	return !equalBaseImages(a.BaseImage, b.BaseImage) || !slices.Equal(a.Layers, b.Layers)
+}
+
+// equalBaseImages tells if the base images are the same.
+func equalBaseImages(a, b *storagev1alpha1.BaseImage) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
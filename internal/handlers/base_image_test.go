@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
+	"github.com/kubewarden/sbomscanner/pkg/generated/clientset/versioned/scheme"
+)
+
+// newLayeredImage returns an image made of the layers with the given DiffIDs.
+func newLayeredImage(name, repository, tag string, diffIDs ...string) storagev1alpha1.Image {
+	image := storagev1alpha1.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		ImageMetadata: storagev1alpha1.ImageMetadata{
+			Registry:    "test-registry",
+			RegistryURI: "registry.test",
+			Repository:  repository,
+			Tag:         tag,
+			Platform:    "linux/amd64",
+			Digest:      "sha256:" + name,
+		},
+	}
+	for _, diffID := range diffIDs {
+		image.Layers = append(image.Layers, storagev1alpha1.ImageLayer{DiffID: diffID})
+	}
+
+	return image
+}
+
+func TestBaseImageFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    *storagev1alpha1.BaseImage
+	}{
+		{
+			name:        "no annotations",
+			annotations: nil,
+			expected:    nil,
+		},
+		{
+			name: "tag and digest",
+			annotations: map[string]string{
+				annotationBaseImageName:   "docker.io/library/alpine:3.20",
+				annotationBaseImageDigest: "sha256:beefdbd8a1da6d2915566fde36db9db0b524eb737fc57cd1367effd16dc0d06d",
+			},
+			expected: &storagev1alpha1.BaseImage{
+				RegistryURI: "index.docker.io",
+				Repository:  "library/alpine",
+				Tag:         "3.20",
+				Digest:      "sha256:beefdbd8a1da6d2915566fde36db9db0b524eb737fc57cd1367effd16dc0d06d",
+				Source:      storagev1alpha1.BaseImageSourceAnnotations,
+			},
+		},
+		{
+			name: "digest reference",
+			annotations: map[string]string{
+				annotationBaseImageName: "ghcr.io/example/base@sha256:beefdbd8a1da6d2915566fde36db9db0b524eb737fc57cd1367effd16dc0d06d",
+			},
+			expected: &storagev1alpha1.BaseImage{
+				RegistryURI: "ghcr.io",
+				Repository:  "example/base",
+				Digest:      "sha256:beefdbd8a1da6d2915566fde36db9db0b524eb737fc57cd1367effd16dc0d06d",
+				Source:      storagev1alpha1.BaseImageSourceAnnotations,
+			},
+		},
+		{
+			name:        "invalid reference",
+			annotations: map[string]string{annotationBaseImageName: "INVALID:reference:"},
+			expected:    nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, baseImageFromAnnotations(test.annotations))
+		})
+	}
+}
+
+func TestDetectBaseImage(t *testing.T) {
+	alpine := newLayeredImage("alpine", "library/alpine", "3.20", "sha256:a")
+	alpineLatest := newLayeredImage("alpine-latest", "library/alpine", "latest", "sha256:a")
+	golang := newLayeredImage("golang", "library/golang", "1.23-alpine", "sha256:a", "sha256:b")
+	otherPlatform := newLayeredImage("golang-arm64", "library/golang", "1.23-alpine", "sha256:a", "sha256:b")
+	otherPlatform.Platform = "linux/arm64"
+	candidates := []storagev1alpha1.Image{alpineLatest, alpine, golang, otherPlatform}
+
+	t.Run("closest base image", func(t *testing.T) {
+		image := newLayeredImage("app", "app", "v1", "sha256:a", "sha256:b", "sha256:c")
+		detectBaseImage(&image, candidates)
+
+		assert.Equal(t, &storagev1alpha1.BaseImage{
+			RegistryURI: "registry.test",
+			Repository:  "library/golang",
+			Tag:         "1.23-alpine",
+			Digest:      "sha256:golang",
+			Source:      storagev1alpha1.BaseImageSourceLayers,
+		}, image.BaseImage)
+		assert.True(t, image.Layers[0].BaseImage)
+		assert.True(t, image.Layers[1].BaseImage)
+		assert.False(t, image.Layers[2].BaseImage)
+	})
+
+	t.Run("candidates with the same layers", func(t *testing.T) {
+		image := newLayeredImage("tool", "tool", "v1", "sha256:a", "sha256:d")
+		detectBaseImage(&image, candidates)
+
+		require.NotNil(t, image.BaseImage)
+		assert.Equal(t, "3.20", image.BaseImage.Tag)
+	})
+
+	t.Run("base image declared by the annotations", func(t *testing.T) {
+		image := newLayeredImage("app", "app", "v1", "sha256:a", "sha256:b", "sha256:c")
+		declared := &storagev1alpha1.BaseImage{
+			RegistryURI: "registry.test",
+			Repository:  "library/alpine",
+			Tag:         "3.20",
+			Source:      storagev1alpha1.BaseImageSourceAnnotations,
+		}
+		image.BaseImage = declared
+		detectBaseImage(&image, candidates)
+
+		assert.Equal(t, declared, image.BaseImage)
+		assert.True(t, image.Layers[0].BaseImage)
+		assert.False(t, image.Layers[1].BaseImage)
+		assert.False(t, image.Layers[2].BaseImage)
+	})
+
+	t.Run("no base image", func(t *testing.T) {
+		image := newLayeredImage("scratch", "scratch", "v1", "sha256:e", "sha256:f")
+		image.Layers[0].BaseImage = true
+		detectBaseImage(&image, candidates)
+
+		assert.Nil(t, image.BaseImage)
+		assert.True(t, image.Layers[0].BaseImage, "the layers must be left unchanged")
+	})
+}
+
+func TestCreateCatalogHandler_detectBaseImages(t *testing.T) {
+	base := newLayeredImage("base", "base", "1.0", "sha256:a")
+	app := newLayeredImage("app", "app", "v1", "sha256:a", "sha256:b")
+	unchanged := newLayeredImage("unchanged", "unchanged", "v1", "sha256:c")
+
+	testScheme := scheme.Scheme
+	require.NoError(t, storagev1alpha1.AddToScheme(testScheme))
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(testScheme).
+		WithRuntimeObjects([]runtime.Object{&base, &app, &unchanged}...).
+		Build()
+
+	handler := NewCreateCatalogHandler(nil, registryclient.NewRateLimiters(slog.Default()), k8sClient, testScheme, nil, record.NewFakeRecorder(10), slog.Default())
+	err := handler.detectBaseImages(t.Context(), []storagev1alpha1.Image{base, app, unchanged}, "default", &testMessage{})
+	require.NoError(t, err)
+
+	storedApp := &storagev1alpha1.Image{}
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKeyFromObject(&app), storedApp))
+	require.NotNil(t, storedApp.BaseImage)
+	assert.Equal(t, "base", storedApp.BaseImage.Repository)
+	assert.Equal(t, storagev1alpha1.BaseImageSourceLayers, storedApp.BaseImage.Source)
+	assert.True(t, storedApp.Layers[0].BaseImage)
+	assert.False(t, storedApp.Layers[1].BaseImage)
+
+	storedUnchanged := &storagev1alpha1.Image{}
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKeyFromObject(&unchanged), storedUnchanged))
+	assert.Nil(t, storedUnchanged.BaseImage)
+	assert.Equal(t, unchanged.ResourceVersion, storedUnchanged.ResourceVersion, "unchanged images must not be updated")
+}
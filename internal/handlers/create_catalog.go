@@ -3,28 +3,26 @@ package handlers
 import (
 	"context"
 	"crypto/sha256"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
 	"path"
 	"slices"
 
+	trivyImage "github.com/aquasecurity/trivy/pkg/fanal/image"
 	"github.com/google/go-containerregistry/pkg/name"
 	cranev1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -40,24 +38,30 @@ import (
 // CreateCatalogHandler is a handler for creating a catalog of images in a registry.
 type CreateCatalogHandler struct {
 	registryClientFactory registryclient.ClientFactory
+	rateLimiters          *registryclient.RateLimiters
 	k8sClient             client.Client
 	scheme                *runtime.Scheme
 	publisher             messaging.Publisher
+	recorder              record.EventRecorder
 	logger                *slog.Logger
 }
 
 // NewCreateCatalogHandler creates a new instance of CreateCatalogHandler.
 func NewCreateCatalogHandler(
 	registryClientFactory registryclient.ClientFactory,
+	rateLimiters *registryclient.RateLimiters,
 	k8sClient client.Client,
 	scheme *runtime.Scheme,
 	publisher messaging.Publisher,
+	recorder record.EventRecorder,
 	logger *slog.Logger,
 ) *CreateCatalogHandler {
 	return &CreateCatalogHandler{
 		registryClientFactory: registryClientFactory,
+		rateLimiters:          rateLimiters,
 		k8sClient:             k8sClient,
 		publisher:             publisher,
+		recorder:              recorder,
 		scheme:                scheme,
 		logger:                logger.With("handler", "create_catalog_handler"),
 	}
@@ -68,7 +72,7 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 	createCatalogMessage := &CreateCatalogMessage{}
 	err := json.Unmarshal(message.Data(), createCatalogMessage)
 	if err != nil {
-		return fmt.Errorf("cannot unmarshal message: %w", err)
+		return messaging.NewPermanentError(fmt.Errorf("cannot unmarshal message: %w", err))
 	}
 
 	h.logger.InfoContext(ctx, "Catalog creation requested",
@@ -79,6 +83,7 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 	// It is possible that the controller is slow to set the status condition "Scheduled" to true,
 	// so we might encounter conflicts when setting the status condition to "InProgress".
 	scanJob := &v1alpha1.ScanJob{}
+	var stopRequested bool
 	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		if err = h.k8sClient.Get(ctx, client.ObjectKey{
 			Name:      createCatalogMessage.ScanJob.Name,
@@ -94,6 +99,11 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 			)
 		}
 
+		stopRequested = scanJob.IsStopRequested()
+		if stopRequested {
+			return nil
+		}
+
 		scanJob.MarkInProgress(v1alpha1.ReasonCatalogCreationInProgress, "Catalog creation in progress")
 		return h.k8sClient.Status().Update(ctx, scanJob)
 	})
@@ -105,6 +115,11 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 		}
 		return fmt.Errorf("cannot update scan job status %s/%s: %w", createCatalogMessage.ScanJob.Namespace, createCatalogMessage.ScanJob.Name, err)
 	}
+	if stopRequested {
+		h.logger.InfoContext(ctx, "ScanJob is cancelled or suspended, stopping catalog creation", "scanjob", createCatalogMessage.ScanJob.Name, "namespace", createCatalogMessage.ScanJob.Namespace)
+		return nil
+	}
+	h.recorder.Eventf(scanJob, corev1.EventTypeNormal, EventReasonCatalogCreationStarted, "Discovering the images of registry %s", scanJob.Spec.Registry)
 
 	// Retrieve the registry from the scan job annotations.
 	registryData, ok := scanJob.Annotations[v1alpha1.AnnotationScanJobRegistryKey]
@@ -121,41 +136,16 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 	if err != nil {
 		return fmt.Errorf("cannot create transport for registry %s: %w", registry.Name, err)
 	}
-	registryClient := h.registryClientFactory(transport)
-	// if authSecret value is set, then setup Docker
-	// authentication to get access to the registry
-	if registry.IsPrivate() {
-		var dockerConfig string
-		dockerConfig, err = dockerauth.BuildDockerConfigForRegistry(ctx, h.k8sClient, registry)
-		if err != nil {
-			return fmt.Errorf("cannot setup docker auth: %w", err)
-		}
-		h.logger.DebugContext(ctx, "Setup registry authentication", "dockerconfig", os.Getenv("DOCKER_CONFIG"))
-		defer func() {
-			if err = os.RemoveAll(dockerConfig); err != nil {
-				h.logger.Error("failed to remove dockerconfig directory", "error", err)
-			}
-			// uset the DOCKER_CONFIG variable so at every run
-			// we start from a clean environment.
-			if err = os.Unsetenv("DOCKER_CONFIG"); err != nil {
-				h.logger.Error("failed to unset DOCKER_CONFIG variable", "error", err)
-			}
-		}()
-	}
-
-	repositories, err := h.discoverRepositories(ctx, registryClient, registry)
+	rateLimitedTransport := registryclient.NewRateLimitedTransport(transport, registryRateLimiter(h.rateLimiters, registry))
+	keychain, err := dockerauth.KeychainForRegistry(ctx, h.k8sClient, registry)
 	if err != nil {
-		return fmt.Errorf("cannot discover repositories: %w", err)
+		return fmt.Errorf("cannot setup registry authentication: %w: %w", registryclient.ErrAuthFailed, err)
 	}
+	registryClient := h.registryClientFactory(rateLimitedTransport, keychain).WithMirrors(registry.Spec.Mirrors)
 
-	discoveredImageReferences := sets.Set[string]{}
-	for _, repository := range repositories {
-		var repoImages []string
-		repoImages, err = h.discoverImages(ctx, registryClient, repository)
-		if err != nil {
-			return fmt.Errorf("cannot discover images in registry %s: %w", registry.Name, err)
-		}
-		discoveredImageReferences.Insert(repoImages...)
+	discoveredImageReferences, err := h.discoverImageReferences(ctx, registryClient, registry, scanJob)
+	if err != nil {
+		return fmt.Errorf("cannot discover images in registry %s: %w", registry.Name, registryclient.ClassifyError(err))
 	}
 
 	existingImageList := &storagev1alpha1.ImageList{}
@@ -176,6 +166,7 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 	}
 
 	var discoveredImages []storagev1alpha1.Image
+	var addedImagesCount int
 	for newImageName := range discoveredImageReferences {
 		var ref name.Reference
 		ref, err = name.ParseReference(newImageName)
@@ -212,6 +203,10 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 					"uid", createCatalogMessage.ScanJob.UID)
 				return nil
 			}
+			if scanJob.IsStopRequested() {
+				h.logger.InfoContext(ctx, "ScanJob is cancelled or suspended, stopping catalog creation", "scanjob", createCatalogMessage.ScanJob.Name, "namespace", createCatalogMessage.ScanJob.Namespace)
+				return nil
+			}
 
 			discoveredImages = append(discoveredImages, image)
 
@@ -220,13 +215,16 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 			}
 
 			h.logger.InfoContext(ctx, "Creating image", "image", image.Name, "namespace", image.Namespace)
-			if err = h.k8sClient.Create(ctx, &image); err != nil {
+			if err = traceStorageWrite(ctx, "create", &image, func(ctx context.Context) error {
+				return h.k8sClient.Create(ctx, &image)
+			}); err != nil {
 				if apierrors.IsAlreadyExists(err) {
 					h.logger.InfoContext(ctx, "Image already exists, skipping creation", "image", image.Name, "namespace", image.Namespace)
 					continue
 				}
 				return fmt.Errorf("cannot create image %s: %w", image.Name, err)
 			}
+			addedImagesCount++
 
 			if err = message.InProgress(); err != nil {
 				return fmt.Errorf("failed to ack message as in progress: %w", err)
@@ -234,12 +232,24 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 		}
 	}
 
-	discoveredImageNames := sets.Set[string]{}
-	for _, image := range discoveredImages {
-		discoveredImageNames.Insert(image.Name)
+	// A scoped ScanJob discovers only a subset of the registry,
+	// so the images that were not discovered are not necessarily obsolete.
+	var removedImagesCount int
+	if scanJob.IsScoped() {
+		h.logger.DebugContext(ctx, "Scoped ScanJob, skipping obsolete images deletion", "scanjob", scanJob.Name, "namespace", scanJob.Namespace)
+	} else {
+		discoveredImageNames := sets.Set[string]{}
+		for _, image := range discoveredImages {
+			discoveredImageNames.Insert(image.Name)
+		}
+		removedImagesCount, err = h.deleteObsoleteImages(ctx, existingImageNames, discoveredImageNames, registry.Namespace, message)
+		if err != nil {
+			return fmt.Errorf("cannot delete obsolete images in registry %s: %w", registry.Name, err)
+		}
 	}
-	if err = h.deleteObsoleteImages(ctx, existingImageNames, discoveredImageNames, registry.Namespace, message); err != nil {
-		return fmt.Errorf("cannot delete obsolete images in registry %s: %w", registry.Name, err)
+
+	if err = h.detectBaseImages(ctx, discoveredImages, registry.Namespace, message); err != nil {
+		return fmt.Errorf("cannot detect base images in registry %s: %w", registry.Name, err)
 	}
 
 	// It is possible that the controller is slow to set the status condition "Scheduled" to true,
@@ -259,6 +269,11 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 			)
 		}
 
+		stopRequested = scanJob.IsStopRequested()
+		if stopRequested {
+			return nil
+		}
+
 		if len(discoveredImages) == 0 {
 			h.logger.InfoContext(ctx, "No images to process", "scanjob", scanJob.Name, "namespace", scanJob.Namespace)
 			scanJob.MarkComplete(v1alpha1.ReasonNoImagesToScan, "No images to process")
@@ -268,6 +283,8 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 			scanJob.Status.ImagesCount = len(discoveredImages)
 			scanJob.Status.ScannedImagesCount = 0
 		}
+		// The requests throttled by a previous delivery of the message are kept.
+		scanJob.Status.ThrottledRequestsCount += rateLimitedTransport.ThrottledRequests()
 
 		return h.k8sClient.Status().Update(ctx, scanJob)
 	})
@@ -279,14 +296,20 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 		}
 		return fmt.Errorf("cannot update scan job status %s/%s: %w", createCatalogMessage.ScanJob.Namespace, createCatalogMessage.ScanJob.Name, err)
 	}
+	if stopRequested {
+		h.logger.InfoContext(ctx, "ScanJob is cancelled or suspended, stopping catalog creation", "scanjob", createCatalogMessage.ScanJob.Name, "namespace", createCatalogMessage.ScanJob.Namespace)
+		return nil
+	}
+	h.recordCatalogEvents(scanJob, registry, len(discoveredImages), addedImagesCount, removedImagesCount)
 
 	for _, image := range discoveredImages {
 		h.logger.DebugContext(ctx, "Sending generate SBOM message", "image", image.Name, "namespace", image.Namespace)
 
-		messageID := fmt.Sprintf("generateSBOM/%s/%s", scanJob.UID, image.Name)
+		messageID := fmt.Sprintf("generateSBOM/%s/%d/%s", scanJob.UID, scanJob.Generation, image.Name)
 		message, err := json.Marshal(&GenerateSBOMMessage{
 			BaseMessage: BaseMessage{
-				ScanJob: createCatalogMessage.ScanJob,
+				ScanJob:    createCatalogMessage.ScanJob,
+				Generation: scanJob.Generation,
 			},
 			Image: ObjectRef{
 				Name:      image.Name,
@@ -305,23 +328,74 @@ func (h *CreateCatalogHandler) Handle(ctx context.Context, message messaging.Mes
 	return nil
 }
 
-// discoverRepositories discovers all the repositories in a registry.
-// Returns the list of fully qualified repository names (e.g. registryclientexample.com/repo)
-func (h *CreateCatalogHandler) discoverRepositories(
+// discoverImageReferences discovers the images to catalog, restricted to the scope of the ScanJob.
+// Returns the set of fully qualified image names (e.g. registryclientexample.com/repo:tag)
+func (h *CreateCatalogHandler) discoverImageReferences(
 	ctx context.Context,
 	registryClient *registryclient.Client,
 	registry *v1alpha1.Registry,
-) ([]string, error) {
+	scanJob *v1alpha1.ScanJob,
+) (sets.Set[string], error) {
 	reg, err := name.NewRegistry(registry.Spec.URI)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse registry %s %s: %w", registry.Name, registry.Namespace, err)
 	}
 
+	imageReferences := sets.Set[string]{}
+
+	// Images are referenced directly, no discovery is needed.
+	if len(scanJob.Spec.Images) > 0 {
+		for _, image := range scanJob.Spec.Images {
+			imageReferences.Insert(qualifyImageReference(reg, image))
+		}
+
+		return imageReferences, nil
+	}
+
+	var repositories []string
+	if len(scanJob.Spec.Repositories) > 0 {
+		for _, repository := range scanJob.Spec.Repositories {
+			repositories = append(repositories, path.Join(reg.Name(), repository))
+		}
+	} else {
+		repositories, err = h.discoverRepositories(ctx, registryClient, reg, registry)
+		if err != nil {
+			return nil, fmt.Errorf("cannot discover repositories: %w", err)
+		}
+	}
+
+	for _, repository := range repositories {
+		// Tags are referenced directly, there is no need to list the repository contents.
+		if len(scanJob.Spec.Tags) > 0 {
+			for _, tag := range scanJob.Spec.Tags {
+				imageReferences.Insert(fmt.Sprintf("%s:%s", repository, tag))
+			}
+			continue
+		}
+
+		var repoImages []string
+		repoImages, err = h.discoverImages(ctx, registryClient, repository)
+		if err != nil {
+			return nil, err
+		}
+		imageReferences.Insert(repoImages...)
+	}
+
+	return imageReferences, nil
+}
+
+// discoverRepositories discovers all the repositories in a registry.
+// Returns the list of fully qualified repository names (e.g. registryclientexample.com/repo)
+func (h *CreateCatalogHandler) discoverRepositories(
+	ctx context.Context,
+	registryClient *registryclient.Client,
+	reg name.Registry,
+	registry *v1alpha1.Registry,
+) ([]string, error) {
 	// If the registry doesn't have any repositories defined, it means we need to catalog all of them.
 	// In this case, we need to discover all the repositories in the registry.
 	if len(registry.Spec.Repositories) == 0 {
-		var allRepositories []string
-		allRepositories, err = registryClient.Catalog(ctx, reg)
+		allRepositories, err := registryClient.Catalog(ctx, reg)
 		if err != nil {
 			return []string{}, fmt.Errorf("cannot discover repositories: %w", err)
 		}
@@ -365,7 +439,7 @@ func (h *CreateCatalogHandler) refToImages(
 	registry *v1alpha1.Registry,
 	message messaging.Message,
 ) ([]storagev1alpha1.Image, error) {
-	platforms, err := h.refToPlatforms(registryClient, ref, registry.Spec.Platforms)
+	platforms, err := h.refToPlatforms(ctx, registryClient, ref, registry.Spec.Platforms)
 	if err != nil {
 		return []storagev1alpha1.Image{}, fmt.Errorf("cannot get platforms for %s: %w", ref, err)
 	}
@@ -374,7 +448,7 @@ func (h *CreateCatalogHandler) refToImages(
 
 	for _, platform := range platforms {
 		var imageDetails registryclient.ImageDetails
-		imageDetails, err = registryClient.GetImageDetails(ref, platform)
+		imageDetails, err = registryClient.GetImageDetails(ctx, ref, platform)
 		if err != nil {
 			h.logger.WarnContext(ctx, "cannot get image details", "reference", ref.Name(), "platform", imageDetails.Platform, "error", err)
 			// Avoid blocking other images to be cataloged
@@ -411,11 +485,12 @@ func (h *CreateCatalogHandler) refToImages(
 // refToPlatforms returns the list of platforms for the given image reference.
 // If the image is not multi-architecture, it returns an empty list.
 func (h *CreateCatalogHandler) refToPlatforms(
+	ctx context.Context,
 	registryClient *registryclient.Client,
 	ref name.Reference,
 	allowedPlatforms []v1alpha1.Platform,
 ) ([]*cranev1.Platform, error) {
-	imgIndex, err := registryClient.GetImageIndex(ref)
+	imgIndex, err := registryClient.GetImageIndex(ctx, ref)
 	if err != nil {
 		h.logger.Debug(
 			"image doesn't seem to be multi-architecture",
@@ -441,47 +516,64 @@ func (h *CreateCatalogHandler) refToPlatforms(
 	return platforms, nil
 }
 
+// registryRateLimiter returns the rate limiter shared by all the messages processed for the registry.
+func registryRateLimiter(rateLimiters *registryclient.RateLimiters, registry *v1alpha1.Registry) *registryclient.RateLimiter {
+	var requestsPerSecond, burst int
+	if registry.Spec.RateLimit != nil {
+		requestsPerSecond = registry.Spec.RateLimit.RequestsPerSecond
+		burst = registry.Spec.RateLimit.Burst
+	}
+
+	return rateLimiters.Get(client.ObjectKeyFromObject(registry).String(), requestsPerSecond, burst)
+}
+
 // transportFromRegistry creates a new http.RoundTripper from the options specified in the Registry spec.
 func (h *CreateCatalogHandler) transportFromRegistry(registry *v1alpha1.Registry) (http.RoundTripper, error) {
-	transport, ok := remote.DefaultTransport.(*http.Transport)
-	if !ok {
-		// should not happen
-		return nil, errors.New("remote.DefaultTransport is not an *http.Transport")
+	rootCAs, err := registryclient.RootCAs(registry.Spec.CABundle)
+	if err != nil {
+		h.logger.Info("cannot load the given CA bundle",
+			"registry", registry.Name,
+			"namespace", registry.Namespace,
+			"error", err)
 	}
-	transport = transport.Clone()
 
-	transport.TLSClientConfig = &tls.Config{
-		InsecureSkipVerify: registry.Spec.Insecure, //nolint:gosec // this a user provided option
+	transport, err := registryclient.NewTransport(registry.Spec.Insecure, rootCAs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create transport: %w", err)
 	}
 
-	if len(registry.Spec.CABundle) > 0 {
-		rootCAs, err := x509.SystemCertPool()
-		if err != nil {
-			h.logger.Error("cannot load system cert pool, using empty pool", "error", err)
-			rootCAs = x509.NewCertPool()
-		}
+	return transport, nil
+}
 
-		ok = rootCAs.AppendCertsFromPEM([]byte(registry.Spec.CABundle))
-		if ok {
-			transport.TLSClientConfig.RootCAs = rootCAs
-		} else {
-			h.logger.Info("cannot load the given CA bundle",
-				"registry", registry.Name,
-				"namespace", registry.Namespace)
-		}
+// recordCatalogEvents records the outcome of the catalog creation on the ScanJob,
+// and the images added to or removed from the catalog on the Registry.
+func (h *CreateCatalogHandler) recordCatalogEvents(scanJob *v1alpha1.ScanJob, registry *v1alpha1.Registry, imagesCount, addedImagesCount, removedImagesCount int) {
+	if imagesCount == 0 {
+		RecordScanJobCompleted(h.recorder, scanJob)
+	} else {
+		h.recorder.Eventf(scanJob, corev1.EventTypeNormal, EventReasonCatalogCreated,
+			"Cataloged %d images, %d added and %d removed", imagesCount, addedImagesCount, removedImagesCount)
 	}
 
-	return transport, nil
+	if addedImagesCount > 0 {
+		h.recorder.Eventf(registry, corev1.EventTypeNormal, EventReasonImagesAdded,
+			"Added %d images to the catalog by ScanJob %s", addedImagesCount, scanJob.Name)
+	}
+	if removedImagesCount > 0 {
+		h.recorder.Eventf(registry, corev1.EventTypeNormal, EventReasonImagesRemoved,
+			"Removed %d images no longer found in the registry by ScanJob %s", removedImagesCount, scanJob.Name)
+	}
 }
 
 // deleteObsoleteImages deletes images that are not present in the discovered registry anymore.
+// Returns the number of deleted images.
 func (h *CreateCatalogHandler) deleteObsoleteImages(
 	ctx context.Context,
 	existingImageNames sets.Set[string],
 	discoveredImageNames sets.Set[string],
 	namespace string,
 	message messaging.Message,
-) error {
+) (int, error) {
 	obsoleteImageNames := existingImageNames.Difference(discoveredImageNames)
 
 	h.logger.DebugContext(ctx, "Existing images", "names", existingImageNames)
@@ -498,8 +590,61 @@ func (h *CreateCatalogHandler) deleteObsoleteImages(
 
 		h.logger.DebugContext(ctx, "Deleting obsolete image", "name", obsoleteImageName, "namespace", namespace)
 
-		if err := h.k8sClient.Delete(ctx, &existingImage); err != nil {
-			return fmt.Errorf("cannot delete image %s/%s: %w", obsoleteImageName, namespace, err)
+		if err := traceStorageWrite(ctx, "delete", &existingImage, func(ctx context.Context) error {
+			return h.k8sClient.Delete(ctx, &existingImage)
+		}); err != nil {
+			return 0, fmt.Errorf("cannot delete image %s/%s: %w", obsoleteImageName, namespace, err)
+		}
+		if err := message.InProgress(); err != nil {
+			return 0, fmt.Errorf("cannot mark message as in progress: %w", err)
+		}
+	}
+
+	return obsoleteImageNames.Len(), nil
+}
+
+// detectBaseImages detects the base images of the discovered images among all the images of the namespace,
+// and updates the images whose base image changed.
+// A base image stored in another registry is detected once that registry is cataloged as well.
+func (h *CreateCatalogHandler) detectBaseImages(
+	ctx context.Context,
+	discoveredImages []storagev1alpha1.Image,
+	namespace string,
+	message messaging.Message,
+) error {
+	imageList := &storagev1alpha1.ImageList{}
+	if err := h.k8sClient.List(ctx, imageList, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("cannot list images in namespace %s: %w", namespace, err)
+	}
+	storedImages := make(map[string]*storagev1alpha1.Image, len(imageList.Items))
+	for i := range imageList.Items {
+		storedImages[imageList.Items[i].Name] = &imageList.Items[i]
+	}
+
+	for _, discoveredImage := range discoveredImages {
+		storedImage, found := storedImages[discoveredImage.Name]
+		if !found {
+			continue
+		}
+
+		// Start from the discovered image, as the annotations and the history of the image
+		// take precedence over the images of the catalog.
+		image := storedImage.DeepCopy()
+		image.BaseImage = discoveredImage.BaseImage
+		image.Layers = slices.Clone(discoveredImage.Layers)
+		detectBaseImage(image, imageList.Items)
+		if !baseImageChanged(image, storedImage) {
+			continue
+		}
+
+		h.logger.DebugContext(ctx, "Updating base image", "image", image.Name, "namespace", image.Namespace, "baseImage", image.BaseImage)
+		if err := traceStorageWrite(ctx, "update", image, func(ctx context.Context) error {
+			return h.k8sClient.Update(ctx, image)
+		}); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("cannot update image %s/%s: %w", namespace, image.Name, err)
 		}
 		if err := message.InProgress(); err != nil {
 			return fmt.Errorf("cannot mark message as in progress: %w", err)
@@ -517,10 +662,13 @@ func imageDetailsToImage(
 ) (storagev1alpha1.Image, error) {
 	imageLayers := []storagev1alpha1.ImageLayer{}
 
+	// The layers created before the last CMD of the base image belong to the base image.
+	baseImageIndex := trivyImage.GuessBaseImageIndex(details.History)
+
 	// There can be more history entries than layers, as some history entries are empty layers
 	// For example, a command like "ENV VAR=1" will create a new history entry but no new layer
 	layerCounter := 0
-	for _, history := range details.History {
+	for i, history := range details.History {
 		if history.EmptyLayer {
 			continue
 		}
@@ -543,17 +691,24 @@ func imageDetailsToImage(
 		}
 
 		imageLayers = append(imageLayers, storagev1alpha1.ImageLayer{
-			Command: base64.StdEncoding.EncodeToString([]byte(history.CreatedBy)),
-			Digest:  digest.String(),
-			DiffID:  diffID.String(),
+			Command:   base64.StdEncoding.EncodeToString([]byte(history.CreatedBy)),
+			Digest:    digest.String(),
+			DiffID:    diffID.String(),
+			BaseImage: i < baseImageIndex,
 		})
 
 		layerCounter++
 	}
 
+	// Images referenced by digest have no tag.
+	tag := ref.Identifier()
+	if _, ok := ref.(name.Digest); ok {
+		tag = ""
+	}
+
 	image := storagev1alpha1.Image{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      computeImageUID(ref.Context().Name(), ref.Identifier(), details.Digest.String()),
+			Name:      computeImageUID(ref.Context().Name(), tag, details.Digest.String()),
 			Namespace: registry.Namespace,
 			Labels: map[string]string{
 				api.LabelManagedByKey: api.LabelManagedByValue,
@@ -564,9 +719,10 @@ func imageDetailsToImage(
 			Registry:    registry.Name,
 			RegistryURI: ref.Context().RegistryStr(),
 			Repository:  ref.Context().RepositoryStr(),
-			Tag:         ref.Identifier(),
+			Tag:         tag,
 			Platform:    details.Platform.String(),
 			Digest:      details.Digest.String(),
+			BaseImage:   baseImageFromAnnotations(details.Annotations),
 		},
 		Layers: imageLayers,
 	}
@@ -574,6 +730,17 @@ func imageDetailsToImage(
 	return image, nil
 }
 
+// qualifyImageReference returns the fully qualified reference of an image of the registry.
+// Images are referenced by their path in the registry, or by their fully qualified reference.
+func qualifyImageReference(reg name.Registry, image string) string {
+	ref, err := name.ParseReference(image, name.WithDefaultRegistry(reg.Name()))
+	if err == nil && ref.Context().RegistryStr() == reg.RegistryStr() {
+		return ref.Name()
+	}
+
+	return path.Join(reg.Name(), image)
+}
+
 // computeImageUID returns a unique identifier for an image.
 func computeImageUID(name, identifier, digest string) string {
 	sha := sha256.New()
@@ -17,6 +17,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	cranev1 "github.com/google/go-containerregistry/pkg/v1"
 
@@ -25,6 +26,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
@@ -59,8 +61,11 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 		name           string
 		registry       *v1alpha1.Registry
 		authSecret     *corev1.Secret
+		scanJobSpec    v1alpha1.ScanJobSpec
 		existingImages []*storagev1alpha1.Image
 		expectedImages []*storagev1alpha1.Image
+		// expectedKeptImages are existing images that are not deleted by a scoped scan.
+		expectedKeptImages []*storagev1alpha1.Image
 	}{
 		{
 			name: "catalog all images",
@@ -183,6 +188,73 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 				imageFactory(testRegistry.RegistryName, multiArchRef.Context().RepositoryStr(), multiArchRef.Identifier(), "linux/amd64", imageDigestLinuxAmd64MultiArch),
 			},
 		},
+		{
+			name: "scoped to an image",
+			registry: &v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-registry",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI:         testRegistry.RegistryName,
+					CatalogType: v1alpha1.CatalogTypeOCIDistribution,
+				},
+			},
+			scanJobSpec: v1alpha1.ScanJobSpec{
+				Images: []string{fmt.Sprintf("%s:%s", singleArchRef.Context().RepositoryStr(), singleArchRef.Identifier())},
+			},
+			existingImages: []*storagev1alpha1.Image{
+				imageFactory(testRegistry.RegistryName, multiArchRef.Context().RepositoryStr(), "other-tag", "linux/amd64", "sha256:other"),
+			},
+			expectedImages: []*storagev1alpha1.Image{
+				imageFactory(testRegistry.RegistryName, singleArchRef.Context().RepositoryStr(), singleArchRef.Identifier(), "linux/amd64", imageDigestSingleArch),
+			},
+			expectedKeptImages: []*storagev1alpha1.Image{
+				imageFactory(testRegistry.RegistryName, multiArchRef.Context().RepositoryStr(), "other-tag", "linux/amd64", "sha256:other"),
+			},
+		},
+		{
+			name: "scoped to an image digest",
+			registry: &v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-registry",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI:         testRegistry.RegistryName,
+					CatalogType: v1alpha1.CatalogTypeOCIDistribution,
+				},
+			},
+			scanJobSpec: v1alpha1.ScanJobSpec{
+				Images: []string{fmt.Sprintf("%s@%s", singleArchRef.Context().RepositoryStr(), imageDigestSingleArch)},
+			},
+			expectedImages: []*storagev1alpha1.Image{
+				imageFactory(testRegistry.RegistryName, singleArchRef.Context().RepositoryStr(), "", "linux/amd64", imageDigestSingleArch),
+			},
+		},
+		{
+			name: "scoped to repositories and tags",
+			registry: &v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-registry",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI:         testRegistry.RegistryName,
+					CatalogType: v1alpha1.CatalogTypeOCIDistribution,
+					Platforms: []v1alpha1.Platform{
+						{OS: "linux", Architecture: "amd64"},
+					},
+				},
+			},
+			scanJobSpec: v1alpha1.ScanJobSpec{
+				Repositories: []string{multiArchRef.Context().RepositoryStr()},
+				Tags:         []string{multiArchRef.Identifier(), "missing-tag"},
+			},
+			expectedImages: []*storagev1alpha1.Image{
+				imageFactory(testRegistry.RegistryName, multiArchRef.Context().RepositoryStr(), multiArchRef.Identifier(), "linux/amd64", imageDigestLinuxAmd64MultiArch),
+			},
+		},
 		{
 			name: "private registry",
 			registry: &v1alpha1.Registry{
@@ -224,6 +296,8 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 			registryData, err := json.Marshal(test.registry)
 			require.NoError(t, err)
 
+			scanJobSpec := test.scanJobSpec
+			scanJobSpec.Registry = test.registry.Name
 			scanJob := &v1alpha1.ScanJob{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "test-scanjob",
@@ -233,9 +307,7 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 						v1alpha1.AnnotationScanJobRegistryKey: string(registryData),
 					},
 				},
-				Spec: v1alpha1.ScanJobSpec{
-					Registry: test.registry.Name,
-				},
+				Spec: scanJobSpec,
 			}
 
 			scheme := scheme.Scheme
@@ -267,13 +339,13 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 				}).
 				Build()
 
-			registryClientFactory := func(rt http.RoundTripper) *registryClient.Client {
-				return registryClient.NewClient(rt, slog.Default())
+			registryClientFactory := func(rt http.RoundTripper, keychain authn.Keychain) *registryClient.Client {
+				return registryClient.NewClient(rt, keychain, slog.Default())
 			}
 
 			mockPublisher := messagingMocks.NewMockPublisher(t)
 			for _, expectedImage := range test.expectedImages {
-				messageID := fmt.Sprintf("generateSBOM/%s/%s", scanJob.UID, expectedImage.Name)
+				messageID := fmt.Sprintf("generateSBOM/%s/%d/%s", scanJob.UID, scanJob.Generation, expectedImage.Name)
 				expectedMessage, err := json.Marshal(&GenerateSBOMMessage{
 					BaseMessage: BaseMessage{
 						ScanJob: ObjectRef{
@@ -292,7 +364,8 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 				mockPublisher.On("Publish", mock.Anything, GenerateSBOMSubject, messageID, expectedMessage).Return(nil).Once()
 			}
 
-			handler := NewCreateCatalogHandler(registryClientFactory, k8sClient, scheme, mockPublisher, slog.Default())
+			recorder := record.NewFakeRecorder(10)
+			handler := NewCreateCatalogHandler(registryClientFactory, registryClient.NewRateLimiters(slog.Default()), k8sClient, scheme, mockPublisher, recorder, slog.Default())
 
 			message, err := json.Marshal(&CreateCatalogMessage{
 				BaseMessage: BaseMessage{
@@ -312,7 +385,7 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 			imageList := &storagev1alpha1.ImageList{}
 			err = k8sClient.List(context.Background(), imageList)
 			require.NoError(t, err)
-			require.Len(t, imageList.Items, len(test.expectedImages))
+			require.Len(t, imageList.Items, len(test.expectedImages)+len(test.expectedKeptImages))
 
 			// Verify all expected images exist in actual results
 			for _, expected := range test.expectedImages {
@@ -327,7 +400,7 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 					Name:      obsoleteImg.Name,
 					Namespace: obsoleteImg.Namespace,
 				}, &storagev1alpha1.Image{})
-				if !slices.ContainsFunc(test.expectedImages, func(expected *storagev1alpha1.Image) bool {
+				if !slices.ContainsFunc(slices.Concat(test.expectedImages, test.expectedKeptImages), func(expected *storagev1alpha1.Image) bool {
 					return expected.ImageMetadata.Digest == obsoleteImg.ImageMetadata.Digest
 				}) {
 					assert.True(t, apierrors.IsNotFound(err), "Obsolete image %s should be deleted", obsoleteImg.Name)
@@ -342,6 +415,12 @@ func TestCreateCatalogHandler_Handle(t *testing.T) {
 			}, updatedScanJob)
 			require.NoError(t, err)
 			assert.Equal(t, len(test.expectedImages), updatedScanJob.Status.ImagesCount)
+
+			// Verify the catalog creation was recorded
+			events := recordedEvents(recorder)
+			require.GreaterOrEqual(t, len(events), 2)
+			assert.Equal(t, "Normal CatalogCreationStarted Discovering the images of registry "+test.registry.Name, events[0])
+			assert.True(t, strings.HasPrefix(events[1], fmt.Sprintf("Normal CatalogCreated Cataloged %d images,", len(test.expectedImages))), events[1])
 		})
 	}
 }
@@ -514,13 +593,13 @@ func TestCreateCatalogHandler_Handle_StopProcessing(t *testing.T) {
 
 			test.setup(k8sClient, scanJob)
 
-			registryClient := func(rt http.RoundTripper) *registryClient.Client {
-				return registryClient.NewClient(rt, slog.Default())
+			registryClientFactory := func(rt http.RoundTripper, keychain authn.Keychain) *registryClient.Client {
+				return registryClient.NewClient(rt, keychain, slog.Default())
 			}
 
 			mockPublisher := messagingMocks.NewMockPublisher(t)
 
-			handler := NewCreateCatalogHandler(registryClient, k8sClientWithInterceptors, scheme, mockPublisher, slog.Default())
+			handler := NewCreateCatalogHandler(registryClientFactory, registryClient.NewRateLimiters(slog.Default()), k8sClientWithInterceptors, scheme, mockPublisher, record.NewFakeRecorder(10), slog.Default())
 
 			message, err := json.Marshal(&CreateCatalogMessage{
 				BaseMessage: BaseMessage{
@@ -602,6 +681,69 @@ func TestCreateCatalogHandler_imageDetailsToImage(t *testing.T) {
 		command, err = base64.StdEncoding.DecodeString(layer.Command)
 		require.NoError(t, err)
 		assert.Equal(t, fmt.Sprintf("command-%d", i), string(command))
+		assert.False(t, layer.BaseImage, "no base image can be detected without a CMD instruction")
+	}
+}
+
+func TestCreateCatalogHandler_imageDetailsToImage_Digest(t *testing.T) {
+	digest, err := cranev1.NewHash("sha256:f41b7d70c5779beba4a570ca861f788d480156321de2876ce479e072fb0246f1")
+	require.NoError(t, err)
+
+	platform, err := cranev1.ParsePlatform("linux/amd64")
+	require.NoError(t, err)
+
+	details, err := buildImageDetails(digest, *platform)
+	require.NoError(t, err)
+
+	ref, err := name.ParseReference("registry.test/repo1@" + digest.String())
+	require.NoError(t, err)
+
+	registry := &v1alpha1.Registry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-registry",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RegistrySpec{
+			URI: "registry.test",
+		},
+	}
+
+	image, err := imageDetailsToImage(ref, details, registry)
+	require.NoError(t, err)
+
+	assert.Equal(t, computeImageUID(ref.Context().Name(), "", digest.String()), image.Name)
+	assert.Empty(t, image.GetImageMetadata().Tag)
+	assert.Equal(t, digest.String(), image.GetImageMetadata().Digest)
+	assert.Equal(t, "registry.test/repo1@"+digest.String(), imageReference(image.GetImageMetadata()))
+}
+
+func TestCreateCatalogHandler_imageDetailsToImage_BaseImageLayers(t *testing.T) {
+	digest, err := cranev1.NewHash("sha256:f41b7d70c5779beba4a570ca861f788d480156321de2876ce479e072fb0246f1")
+	require.NoError(t, err)
+
+	platform, err := cranev1.ParsePlatform("linux/amd64")
+	require.NoError(t, err)
+
+	details, err := buildImageDetails(digest, *platform)
+	require.NoError(t, err)
+	// The empty layer following the third layer is the CMD instruction of the base image.
+	details.History[5].CreatedBy = `CMD ["/bin/bash"]`
+
+	ref, err := name.ParseReference("registry.test/repo1:latest")
+	require.NoError(t, err)
+	registry := &v1alpha1.Registry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-registry",
+			Namespace: "default",
+		},
+	}
+
+	image, err := imageDetailsToImage(ref, details, registry)
+	require.NoError(t, err)
+
+	require.Len(t, image.Layers, len(details.Layers))
+	for i, layer := range image.Layers {
+		assert.Equal(t, i < 3, layer.BaseImage, "unexpected base image flag for layer %d", i)
 	}
 }
 
@@ -667,6 +809,31 @@ func fakeDigestAndDiffID(layerIndex int) (cranev1.Hash, cranev1.Hash, error) {
 	return digest, diffID, nil
 }
 
+func Test_qualifyImageReference(t *testing.T) {
+	reg, err := name.NewRegistry("registry.example.com:5000")
+	require.NoError(t, err)
+	dockerHub, err := name.NewRegistry("docker.io")
+	require.NoError(t, err)
+
+	tests := []struct {
+		registry name.Registry
+		image    string
+		expected string
+	}{
+		{registry: reg, image: "myrepo:1.2.3", expected: "registry.example.com:5000/myrepo:1.2.3"},
+		{registry: reg, image: "team/myrepo@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", expected: "registry.example.com:5000/team/myrepo@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"},
+		{registry: reg, image: "registry.example.com:5000/myrepo:1.2.3", expected: "registry.example.com:5000/myrepo:1.2.3"},
+		{registry: dockerHub, image: "docker.io/library/nginx:1.27", expected: "index.docker.io/library/nginx:1.27"},
+		{registry: dockerHub, image: "nginx:1.27", expected: "index.docker.io/library/nginx:1.27"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			assert.Equal(t, test.expected, qualifyImageReference(test.registry, test.image))
+		})
+	}
+}
+
 func Test_isPlatformAllowed(t *testing.T) {
 	tests := []struct {
 		name             string // description of this test case
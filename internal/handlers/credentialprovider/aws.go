@@ -0,0 +1,61 @@
+package credentialprovider
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// ecrRegistryPattern matches the ECR endpoints, capturing the region.
+// Endpoints take the form:
+// <registry-id>.dkr.ecr.<region>.amazonaws.com
+// <registry-id>.dkr.ecr-fips.<region>.amazonaws.com
+// <registry-id>.dkr.ecr.<region>.amazonaws.com.cn
+// See: https://docs.aws.amazon.com/general/latest/gr/ecr.html
+var ecrRegistryPattern = regexp.MustCompile(`^[^.]+\.dkr\.ecr(?:-fips)?\.([^.]+)\.amazonaws\.com(?:\.cn)?$`)
+
+func isECRRegistry(registry string) bool {
+	return ecrRegistryPattern.MatchString(registry)
+}
+
+// ecrCredentials exchanges the AWS credentials of the worker for an ECR authorization token.
+// The AWS credentials are loaded from the default chain, which supports IRSA and EKS Pod Identity.
+func ecrCredentials(ctx context.Context, registry string) (string, string, error) {
+	matches := ecrRegistryPattern.FindStringSubmatch(registry)
+	if matches == nil {
+		return "", "", fmt.Errorf("%s is not an ECR registry", registry)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(matches[1]))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot load AWS config: %w", err)
+	}
+
+	output, err := ecr.NewFromConfig(cfg).GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return "", "", fmt.Errorf("cannot get ECR authorization token: %w", err)
+	}
+
+	for _, data := range output.AuthorizationData {
+		if data.AuthorizationToken == nil {
+			continue
+		}
+		token, err := base64.StdEncoding.DecodeString(*data.AuthorizationToken)
+		if err != nil {
+			return "", "", fmt.Errorf("cannot decode ECR authorization token: %w", err)
+		}
+		// The token has the form "AWS:<password>".
+		username, password, ok := strings.Cut(string(token), ":")
+		if ok {
+			return username, password, nil
+		}
+	}
+
+	return "", "", errors.New("no ECR authorization token returned")
+}
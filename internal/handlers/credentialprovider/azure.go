@@ -0,0 +1,78 @@
+package credentialprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/containers/azcontainerregistry"
+)
+
+const (
+	// azureUsername is the username used to authenticate to ACR with a refresh token.
+	azureUsername = "00000000-0000-0000-0000-000000000000"
+
+	azurePublicSuffix = ".azurecr.io"
+	azureChinaSuffix  = ".azurecr.cn"
+	azurePublicScope  = "https://management.azure.com/.default"
+	azureChinaScope   = "https://management.chinacloudapi.cn/.default"
+)
+
+func isAzureRegistry(registry string) bool {
+	return strings.HasSuffix(registry, azurePublicSuffix) || strings.HasSuffix(registry, azureChinaSuffix)
+}
+
+// azureCredentials exchanges the Microsoft Entra token of the worker for an ACR refresh token.
+// The token is obtained from the default Azure credential chain, which supports AKS workload identity.
+func azureCredentials(ctx context.Context, registry string) (string, string, error) {
+	cloudConfig, scope := cloud.AzurePublic, azurePublicScope
+	if strings.HasSuffix(registry, azureChinaSuffix) {
+		cloudConfig, scope = cloud.AzureChina, azureChinaScope
+	}
+
+	credential, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Cloud: cloudConfig},
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("cannot create Azure credential: %w", err)
+	}
+
+	accessToken, err := credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}})
+	if err != nil {
+		return "", "", fmt.Errorf("cannot get Azure access token: %w", err)
+	}
+
+	// AZURE_TENANT_ID is injected by the workload identity webhook.
+	tenantID := os.Getenv("AZURE_TENANT_ID")
+	if tenantID == "" {
+		return "", "", errors.New("missing environment variable AZURE_TENANT_ID")
+	}
+
+	client, err := azcontainerregistry.NewAuthenticationClient("https://"+registry, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot create ACR authentication client: %w", err)
+	}
+
+	response, err := client.ExchangeAADAccessTokenForACRRefreshToken(ctx,
+		azcontainerregistry.PostContentSchemaGrantTypeAccessToken,
+		registry,
+		&azcontainerregistry.AuthenticationClientExchangeAADAccessTokenForACRRefreshTokenOptions{
+			AccessToken: &accessToken.Token,
+			Tenant:      &tenantID,
+		},
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot exchange Azure access token for ACR refresh token: %w", err)
+	}
+	if response.RefreshToken == nil {
+		return "", "", errors.New("no ACR refresh token returned")
+	}
+
+	return azureUsername, *response.RefreshToken, nil
+}
@@ -0,0 +1,3 @@
+// Package credentialprovider obtains short-lived registry credentials from cloud providers,
+// using the workload identity of the worker.
+package credentialprovider
@@ -0,0 +1,38 @@
+package credentialprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// googleUsername is the username used to authenticate to Google registries with an access token.
+	googleUsername = "oauth2accesstoken"
+	googleScope    = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// isGoogleRegistry returns true for Container Registry and Artifact Registry endpoints.
+func isGoogleRegistry(registry string) bool {
+	return registry == "gcr.io" ||
+		strings.HasSuffix(registry, ".gcr.io") ||
+		strings.HasSuffix(registry, "-docker.pkg.dev")
+}
+
+// googleCredentials returns an access token of the worker.
+// The token is obtained from the application default credentials, which support GKE workload identity.
+func googleCredentials(ctx context.Context, _ string) (string, string, error) {
+	tokenSource, err := google.DefaultTokenSource(ctx, googleScope)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot find Google default credentials: %w", err)
+	}
+
+	token, err := tokenSource.Token()
+	if err != nil {
+		return "", "", fmt.Errorf("cannot get Google access token: %w", err)
+	}
+
+	return googleUsername, token.AccessToken, nil
+}
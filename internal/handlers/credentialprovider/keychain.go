@@ -0,0 +1,139 @@
+package credentialprovider
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+// credentialsTTL is the time the credentials obtained from a cloud provider are cached for.
+// It is shorter than the lifetime of the tokens issued by all the supported providers.
+const credentialsTTL = 10 * time.Minute
+
+// credentialsFunc returns the username and password to access the given registry.
+type credentialsFunc func(ctx context.Context, registry string) (string, string, error)
+
+// cachedCredentials holds the credentials of a registry with their expiration time.
+type cachedCredentials struct {
+	authConfig authn.AuthConfig
+	expiresAt  time.Time
+}
+
+// Keychain is an authn.Keychain that obtains the credentials from a cloud provider.
+// Registries not hosted by the cloud provider cannot be resolved.
+type Keychain struct {
+	provider       string
+	matches        func(registry string) bool
+	getCredentials credentialsFunc
+	// fetches deduplicates the concurrent requests of the credentials of the same registry,
+	// without blocking the requests of the other registries.
+	fetches singleflight.Group
+	mu      sync.Mutex
+	cache   map[string]cachedCredentials
+}
+
+var _ authn.ContextKeychain = &Keychain{}
+
+var (
+	keychains     = map[string]*Keychain{}
+	keychainsOnce sync.Once
+)
+
+// KeychainForProvider returns the keychain of the given cloud provider.
+// Keychains are shared, so that the credentials are cached across requests.
+func KeychainForProvider(provider string) (*Keychain, error) {
+	keychainsOnce.Do(func() {
+		keychains[v1alpha1.CredentialProviderAWS] = newKeychain(v1alpha1.CredentialProviderAWS, isECRRegistry, ecrCredentials)
+		keychains[v1alpha1.CredentialProviderGCP] = newKeychain(v1alpha1.CredentialProviderGCP, isGoogleRegistry, googleCredentials)
+		keychains[v1alpha1.CredentialProviderAzure] = newKeychain(v1alpha1.CredentialProviderAzure, isAzureRegistry, azureCredentials)
+	})
+
+	keychain, ok := keychains[provider]
+	if !ok {
+		return nil, fmt.Errorf("unsupported credential provider %q", provider)
+	}
+
+	return keychain, nil
+}
+
+// SupportsRegistry reports whether the registry is hosted by the given cloud provider,
+// so that its credentials can be obtained from the provider.
+func SupportsRegistry(provider, registry string) (bool, error) {
+	keychain, err := KeychainForProvider(provider)
+	if err != nil {
+		return false, err
+	}
+
+	return keychain.matches(registry), nil
+}
+
+func newKeychain(provider string, matches func(string) bool, getCredentials credentialsFunc) *Keychain {
+	return &Keychain{
+		provider:       provider,
+		matches:        matches,
+		getCredentials: getCredentials,
+		cache:          map[string]cachedCredentials{},
+	}
+}
+
+// Resolve implements authn.Keychain.
+func (k *Keychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return k.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext implements authn.ContextKeychain.
+func (k *Keychain) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	registry := target.RegistryStr()
+	if !k.matches(registry) {
+		return nil, fmt.Errorf("registry %s is not hosted by credential provider %s", registry, k.provider)
+	}
+
+	authConfig, err := k.credentials(ctx, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	return authn.FromConfig(authConfig), nil
+}
+
+// credentials returns the cached credentials of the registry, refreshing them when expired.
+// The credentials are fetched outside of the lock, so that a slow provider does not block the other registries.
+func (k *Keychain) credentials(ctx context.Context, registry string) (authn.AuthConfig, error) {
+	k.mu.Lock()
+	cached, ok := k.cache[registry]
+	k.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.authConfig, nil
+	}
+
+	result, err, _ := k.fetches.Do(registry, func() (any, error) {
+		username, password, err := k.getCredentials(ctx, registry)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get %s credentials for registry %s: %w", k.provider, registry, err)
+		}
+
+		authConfig := authn.AuthConfig{
+			Username: username,
+			Password: password,
+		}
+		k.mu.Lock()
+		k.cache[registry] = cachedCredentials{
+			authConfig: authConfig,
+			expiresAt:  time.Now().Add(credentialsTTL),
+		}
+		k.mu.Unlock()
+
+		return authConfig, nil
+	})
+	if err != nil {
+		return authn.AuthConfig{}, err //nolint:wrapcheck // wrapped by the fetch function
+	}
+
+	return result.(authn.AuthConfig), nil //nolint:forcetypeassert // the fetch function always returns an authn.AuthConfig
+}
@@ -0,0 +1,144 @@
+package credentialprovider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+func TestKeychainForProvider(t *testing.T) {
+	for _, provider := range []string{
+		v1alpha1.CredentialProviderAWS,
+		v1alpha1.CredentialProviderGCP,
+		v1alpha1.CredentialProviderAzure,
+	} {
+		keychain, err := KeychainForProvider(provider)
+		require.NoError(t, err)
+		assert.Equal(t, provider, keychain.provider)
+	}
+
+	_, err := KeychainForProvider("OnPrem")
+	require.Error(t, err)
+}
+
+func TestKeychain_ResolveContext(t *testing.T) {
+	calls := 0
+	keychain := newKeychain("test", isECRRegistry, func(_ context.Context, _ string) (string, string, error) {
+		calls++
+		return "AWS", "password", nil
+	})
+
+	registry, err := name.NewRegistry("123456789012.dkr.ecr.eu-west-1.amazonaws.com")
+	require.NoError(t, err)
+
+	for range 2 {
+		var authenticator authn.Authenticator
+		authenticator, err = keychain.ResolveContext(t.Context(), registry)
+		require.NoError(t, err)
+		var authConfig *authn.AuthConfig
+		authConfig, err = authenticator.Authorization()
+		require.NoError(t, err)
+		assert.Equal(t, "AWS", authConfig.Username)
+		assert.Equal(t, "password", authConfig.Password)
+	}
+	assert.Equal(t, 1, calls, "credentials should be cached")
+
+	otherRegistry, err := name.NewRegistry("ghcr.io")
+	require.NoError(t, err)
+	_, err = keychain.ResolveContext(t.Context(), otherRegistry)
+	require.ErrorContains(t, err, "is not hosted by credential provider test")
+}
+
+func TestKeychain_ResolveContextConcurrent(t *testing.T) {
+	var calls atomic.Int32
+	slowRegistry := "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+	release := make(chan struct{})
+	keychain := newKeychain("test", isECRRegistry, func(_ context.Context, registry string) (string, string, error) {
+		calls.Add(1)
+		if registry == slowRegistry {
+			<-release
+		}
+		return "AWS", "password", nil
+	})
+
+	slow, err := name.NewRegistry(slowRegistry)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() {
+			_, resolveErr := keychain.ResolveContext(t.Context(), slow)
+			assert.NoError(t, resolveErr)
+		})
+	}
+
+	// The slow provider must not block the other registries.
+	fast, err := name.NewRegistry("210987654321.dkr.ecr.us-east-1.amazonaws.com")
+	require.NoError(t, err)
+	_, err = keychain.ResolveContext(t.Context(), fast)
+	require.NoError(t, err)
+
+	close(release)
+	wg.Wait()
+	assert.LessOrEqual(t, calls.Load(), int32(4), "concurrent requests of the same registry should be deduplicated")
+	assert.GreaterOrEqual(t, calls.Load(), int32(2))
+}
+
+func TestSupportsRegistry(t *testing.T) {
+	supported, err := SupportsRegistry(v1alpha1.CredentialProviderAzure, "myregistry.azurecr.io")
+	require.NoError(t, err)
+	assert.True(t, supported)
+
+	supported, err = SupportsRegistry(v1alpha1.CredentialProviderAzure, "ghcr.io")
+	require.NoError(t, err)
+	assert.False(t, supported)
+
+	_, err = SupportsRegistry("OnPrem", "ghcr.io")
+	require.Error(t, err)
+}
+
+func TestKeychain_ResolveContextError(t *testing.T) {
+	keychain := newKeychain("test", isGoogleRegistry, func(_ context.Context, _ string) (string, string, error) {
+		return "", "", errors.New("no credentials")
+	})
+
+	registry, err := name.NewRegistry("europe-west1-docker.pkg.dev")
+	require.NoError(t, err)
+
+	_, err = keychain.ResolveContext(t.Context(), registry)
+	require.ErrorContains(t, err, "no credentials")
+}
+
+func TestRegistryMatchers(t *testing.T) {
+	tests := []struct {
+		registry string
+		matches  func(string) bool
+		expected bool
+	}{
+		{registry: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", matches: isECRRegistry, expected: true},
+		{registry: "123456789012.dkr.ecr-fips.us-east-1.amazonaws.com", matches: isECRRegistry, expected: true},
+		{registry: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn", matches: isECRRegistry, expected: true},
+		{registry: "public.ecr.aws", matches: isECRRegistry, expected: false},
+		{registry: "gcr.io", matches: isGoogleRegistry, expected: true},
+		{registry: "eu.gcr.io", matches: isGoogleRegistry, expected: true},
+		{registry: "europe-west1-docker.pkg.dev", matches: isGoogleRegistry, expected: true},
+		{registry: "ghcr.io", matches: isGoogleRegistry, expected: false},
+		{registry: "myregistry.azurecr.io", matches: isAzureRegistry, expected: true},
+		{registry: "myregistry.azurecr.cn", matches: isAzureRegistry, expected: true},
+		{registry: "docker.io", matches: isAzureRegistry, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.registry, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.matches(test.registry))
+		})
+	}
+}
@@ -4,76 +4,87 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"os"
-	"path"
 
 	"github.com/docker/cli/cli/config"
-	"github.com/docker/cli/cli/config/types"
+	"github.com/docker/cli/cli/config/configfile"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/handlers/credentialprovider"
 	corev1 "k8s.io/api/core/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// BuildDockerConfigForRegistry retrieve the Secret listed in the Registry resource
-// and creates the dockerconfig file.
-func BuildDockerConfigForRegistry(ctx context.Context, k8sClient client.Client, registry *v1alpha1.Registry) (string, error) {
+// KeychainForRegistry returns the keychain used to authenticate to the registry.
+// The credentials are kept in memory and never exposed through the process environment,
+// so that handlers running concurrently do not share or clobber each other's credentials.
+func KeychainForRegistry(ctx context.Context, k8sClient client.Reader, registry *v1alpha1.Registry) (authn.Keychain, error) {
+	switch {
+	case registry.Spec.CredentialProvider != "":
+		keychain, err := credentialprovider.KeychainForProvider(registry.Spec.CredentialProvider)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get keychain: %w", err)
+		}
+		return keychain, nil
+	case registry.Spec.AuthSecret != "":
+		return keychainFromSecret(ctx, k8sClient, registry)
+	default:
+		return authn.DefaultKeychain, nil
+	}
+}
+
+// keychainFromSecret retrieves the Secret listed in the Registry resource
+// and creates a keychain from the dockerconfig it contains.
+func keychainFromSecret(ctx context.Context, k8sClient client.Reader, registry *v1alpha1.Registry) (authn.Keychain, error) {
 	authSecret := &corev1.Secret{}
 	err := k8sClient.Get(ctx, k8stypes.NamespacedName{
 		Name:      registry.Spec.AuthSecret,
 		Namespace: registry.Namespace,
 	}, authSecret)
 	if err != nil {
-		return "", fmt.Errorf("cannot get Secret %s: %w", registry.Spec.AuthSecret, err)
+		return nil, fmt.Errorf("cannot get Secret %s: %w", registry.Spec.AuthSecret, err)
 	}
 
 	if authSecret.Type != corev1.SecretTypeDockerConfigJson {
-		return "", fmt.Errorf("secret is not of type %s", corev1.SecretTypeDockerConfigJson)
-	}
-	secretData := authSecret.Data[corev1.DockerConfigJsonKey]
-	dockerConfig, err := createDockerConfigJSON(registry.Spec.URI, secretData)
-	if err != nil {
-		return "", fmt.Errorf("cannot create dockerconfig file: %w", err)
+		return nil, fmt.Errorf("secret is not of type %s", corev1.SecretTypeDockerConfigJson)
 	}
 
-	err = os.Setenv("DOCKER_CONFIG", dockerConfig)
+	cf, err := config.LoadFromReader(bytes.NewReader(authSecret.Data[corev1.DockerConfigJsonKey]))
 	if err != nil {
-		return "", fmt.Errorf("cannot set DOCKER_CONFIG env: %w", err)
+		return nil, fmt.Errorf("failed to load docker config: %w", err)
 	}
-	return dockerConfig, nil
+
+	return &configFileKeychain{configFile: cf}, nil
 }
 
-// createDockerConfigJSON creates the config.json file used by docker / trivy to
-// get credentials to connect to the registry.
-func createDockerConfigJSON(serverAddress string, data []byte) (string, error) {
-	cf, err := config.LoadFromReader(bytes.NewReader(data))
-	if err != nil {
-		return "", fmt.Errorf("failed to load docker config: %w", err)
-	}
-	creds := cf.GetCredentialsStore(serverAddress)
-	if serverAddress == name.DefaultRegistry {
-		serverAddress = authn.DefaultAuthKey
-	}
-	authConfig, err := creds.Get(serverAddress)
-	if err != nil {
-		return "", fmt.Errorf("failed to get credentials from store: %w", err)
+// configFileKeychain is an authn.Keychain resolving the credentials from an in-memory docker config.
+type configFileKeychain struct {
+	configFile *configfile.ConfigFile
+}
+
+// Resolve implements authn.Keychain.
+func (k *configFileKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	key := target.RegistryStr()
+	if key == name.DefaultRegistry {
+		key = authn.DefaultAuthKey
 	}
-	dockerConfig, err := os.MkdirTemp("", "dockerconfig-*")
+
+	cfg, err := k.configFile.GetAuthConfig(key)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temporary dockerconfig dir: %w", err)
+		return nil, fmt.Errorf("failed to get credentials for %s: %w", key, err)
 	}
-	cf.Filename = path.Join(dockerConfig, "config.json")
-	if err := creds.Store(types.AuthConfig{
-		ServerAddress: serverAddress,
-		Username:      authConfig.Username,
-		Password:      authConfig.Password,
-	}); err != nil {
-		return "", fmt.Errorf("failed to store credentials: %w", err)
+
+	authConfig := authn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
 	}
-	if err := cf.Save(); err != nil {
-		return "", fmt.Errorf("failed to save docker config: %w", err)
+	if authConfig == (authn.AuthConfig{}) {
+		return authn.Anonymous, nil
 	}
-	return dockerConfig, nil
+
+	return authn.FromConfig(authConfig), nil
 }
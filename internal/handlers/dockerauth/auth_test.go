@@ -0,0 +1,122 @@
+package dockerauth
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/handlers/credentialprovider"
+)
+
+func TestKeychainForRegistry(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "registry-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			// dXNlcjpwYXNzd29yZA== -> user:password
+			corev1.DockerConfigJsonKey: []byte(`{
+				"auths": {
+					"registry.test.local": {"auth": "dXNlcjpwYXNzd29yZA=="},
+					"https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNzd29yZA=="}
+				}
+			}`),
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+	}
+	opaqueSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "opaque-secret",
+			Namespace: "default",
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret, opaqueSecret).Build()
+
+	t.Run("public registry", func(t *testing.T) {
+		registry := &v1alpha1.Registry{Spec: v1alpha1.RegistrySpec{URI: "registry.test.local"}}
+
+		keychain, err := KeychainForRegistry(t.Context(), k8sClient, registry)
+		require.NoError(t, err)
+		assert.Equal(t, authn.DefaultKeychain, keychain)
+	})
+
+	t.Run("credential provider", func(t *testing.T) {
+		registry := &v1alpha1.Registry{Spec: v1alpha1.RegistrySpec{
+			URI:                "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+			CredentialProvider: v1alpha1.CredentialProviderAWS,
+		}}
+
+		keychain, err := KeychainForRegistry(t.Context(), k8sClient, registry)
+		require.NoError(t, err)
+		assert.IsType(t, &credentialprovider.Keychain{}, keychain)
+	})
+
+	t.Run("auth secret", func(t *testing.T) {
+		registry := &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec: v1alpha1.RegistrySpec{
+				URI:        "registry.test.local",
+				AuthSecret: "registry-secret",
+			},
+		}
+
+		keychain, err := KeychainForRegistry(t.Context(), k8sClient, registry)
+		require.NoError(t, err)
+
+		for _, registryName := range []string{"registry.test.local", name.DefaultRegistry} {
+			reg, err := name.NewRegistry(registryName)
+			require.NoError(t, err)
+			authenticator, err := keychain.Resolve(reg)
+			require.NoError(t, err)
+			authConfig, err := authenticator.Authorization()
+			require.NoError(t, err)
+			assert.Equal(t, "user", authConfig.Username)
+			assert.Equal(t, "password", authConfig.Password)
+		}
+
+		reg, err := name.NewRegistry("other.test.local")
+		require.NoError(t, err)
+		authenticator, err := keychain.Resolve(reg)
+		require.NoError(t, err)
+		assert.Equal(t, authn.Anonymous, authenticator)
+	})
+
+	t.Run("auth secret of the wrong type", func(t *testing.T) {
+		registry := &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec: v1alpha1.RegistrySpec{
+				URI:        "registry.test.local",
+				AuthSecret: "opaque-secret",
+			},
+		}
+
+		_, err := KeychainForRegistry(t.Context(), k8sClient, registry)
+		require.ErrorContains(t, err, "secret is not of type")
+	})
+
+	t.Run("missing auth secret", func(t *testing.T) {
+		registry := &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+			Spec: v1alpha1.RegistrySpec{
+				URI:        "registry.test.local",
+				AuthSecret: "missing-secret",
+			},
+		}
+
+		_, err := KeychainForRegistry(t.Context(), k8sClient, registry)
+		require.ErrorContains(t, err, "cannot get Secret missing-secret")
+	})
+}
@@ -1,4 +1,4 @@
-// Package dockerauth provides functions to setup Docker authentication
-// for private registries using the `config.json` file defined
-// inside of a Kubernetes Secret.
+// Package dockerauth provides the keychains used to authenticate to the registries,
+// either from the `config.json` file defined inside of a Kubernetes Secret,
+// or from a cloud credential provider.
 package dockerauth
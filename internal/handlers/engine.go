@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/handlers/dockerauth"
+)
+
+const (
+	// EngineTrivy identifies the Trivy scanner engine.
+	EngineTrivy = "trivy"
+	// EngineGrype identifies the Anchore Syft and Grype scanner engine.
+	EngineGrype = "grype"
+)
+
+// databaseRetryInterval is the interval between the attempts to download the vulnerability database,
+// until the first download succeeds.
+const databaseRetryInterval = time.Minute
+
+// SBOMGenerator generates the SBOM of an image.
+type SBOMGenerator interface {
+	// GenerateSBOM pulls the image from the registry and returns its SBOM, as a SPDX JSON document.
+	GenerateSBOM(ctx context.Context, image *storagev1alpha1.Image, registry *v1alpha1.Registry) ([]byte, error)
+}
+
+// Scanner scans SBOMs for vulnerabilities.
+type Scanner interface {
+	// Name returns the name of the engine, recorded in the VulnerabilityReports.
+	Name() string
+	// Scan returns the vulnerabilities affecting the packages of the SPDX JSON document,
+	// and the vulnerability database used to find them.
+	// The vulnerabilities matching a statement of the VEXHub repositories are marked as suppressed.
+	Scan(ctx context.Context, spdx []byte, vexHubs []v1alpha1.VEXHub) ([]storagev1alpha1.Result, *storagev1alpha1.VulnerabilityDatabase, error)
+}
+
+// DatabaseUpdater keeps the vulnerability database of an engine up to date.
+// The engines never download the database while scanning.
+type DatabaseUpdater interface {
+	// UpdateDatabase downloads the latest vulnerability database, when it differs from the one in use.
+	UpdateDatabase(ctx context.Context) error
+	// DatabaseReady returns an error until the vulnerability database is downloaded.
+	DatabaseReady() error
+}
+
+// RunDatabaseUpdater updates the vulnerability database immediately and then on every interval,
+// until the context is canceled.
+// The first download is retried every databaseRetryInterval until it succeeds,
+// as the worker cannot scan without a database.
+func RunDatabaseUpdater(ctx context.Context, updater DatabaseUpdater, interval time.Duration, logger *slog.Logger) {
+	logger = logger.With("component", "database-updater")
+
+	for {
+		next := interval
+		if err := updater.UpdateDatabase(ctx); err != nil {
+			logger.ErrorContext(ctx, "Failed to update the vulnerability database", "error", err)
+			if updater.DatabaseReady() != nil {
+				next = databaseRetryInterval
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next):
+		}
+	}
+}
+
+// registryAuthConfig resolves the credentials of the private registry of the image.
+func registryAuthConfig(ctx context.Context, k8sClient client.Client, image *storagev1alpha1.Image, registry *v1alpha1.Registry) (*authn.AuthConfig, error) {
+	keychain, err := dockerauth.KeychainForRegistry(ctx, k8sClient, registry)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get keychain: %w", err)
+	}
+
+	reg, err := name.NewRegistry(image.GetImageMetadata().RegistryURI)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse registry %s: %w", image.GetImageMetadata().RegistryURI, err)
+	}
+	authenticator, err := authn.Resolve(ctx, keychain, reg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve credentials: %w", err)
+	}
+	authConfig, err := authn.Authorization(ctx, authenticator)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get credentials: %w", err)
+	}
+
+	return authConfig, nil
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+// Reasons of the Kubernetes events recorded by the handlers.
+const (
+	// EventReasonCatalogCreationStarted is recorded on a ScanJob when the worker starts discovering the images of the registry.
+	EventReasonCatalogCreationStarted = "CatalogCreationStarted"
+	// EventReasonCatalogCreated is recorded on a ScanJob when the images of the registry have been discovered.
+	EventReasonCatalogCreated = "CatalogCreated"
+	// EventReasonImagesAdded is recorded on a Registry when new images are cataloged.
+	EventReasonImagesAdded = "ImagesAdded"
+	// EventReasonImagesRemoved is recorded on a Registry when images no longer found in the registry are deleted.
+	EventReasonImagesRemoved = "ImagesRemoved"
+	// EventReasonSBOMGenerationFailed is recorded on a ScanJob and on the Image when the SBOM of the image cannot be generated.
+	EventReasonSBOMGenerationFailed = "SBOMGenerationFailed"
+	// EventReasonImageScanFailed is recorded on a ScanJob and on the Image when the SBOM of the image cannot be scanned.
+	EventReasonImageScanFailed = "ImageScanFailed"
+	// EventReasonScanJobFailed is recorded on a ScanJob when it fails.
+	EventReasonScanJobFailed = "ScanJobFailed"
+	// EventReasonScanJobCompleted is recorded on a ScanJob when all its images have been processed.
+	EventReasonScanJobCompleted = "ScanJobCompleted"
+)
+
+// RecordScanJobCompleted records the completion of the ScanJob with the message of its Complete condition.
+// The event is a warning when some images could not be scanned.
+func RecordScanJobCompleted(recorder record.EventRecorder, scanJob *v1alpha1.ScanJob) {
+	eventType := corev1.EventTypeNormal
+	if scanJob.Status.FailedImagesCount > 0 {
+		eventType = corev1.EventTypeWarning
+	}
+
+	var message string
+	if condition := meta.FindStatusCondition(scanJob.Status.Conditions, v1alpha1.ConditionTypeComplete); condition != nil {
+		message = condition.Message
+	}
+
+	recorder.Event(scanJob, eventType, EventReasonScanJobCompleted, message)
+}
@@ -4,13 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"os"
 
-	_ "modernc.org/sqlite" // sqlite driver for RPM DB and Java DB
-
-	trivyCommands "github.com/aquasecurity/trivy/pkg/commands"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -20,36 +15,36 @@ import (
 	"github.com/kubewarden/sbomscanner/api"
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
-	"github.com/kubewarden/sbomscanner/internal/handlers/dockerauth"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
 	"github.com/kubewarden/sbomscanner/internal/messaging"
 )
 
 // GenerateSBOMHandler is responsible for handling SBOM generation requests.
 type GenerateSBOMHandler struct {
-	k8sClient             client.Client
-	scheme                *runtime.Scheme
-	workDir               string
-	trivyJavaDBRepository string
-	publisher             messaging.Publisher
-	logger                *slog.Logger
+	k8sClient    client.Client
+	scheme       *runtime.Scheme
+	generator    SBOMGenerator
+	rateLimiters *registryclient.RateLimiters
+	publisher    messaging.Publisher
+	logger       *slog.Logger
 }
 
 // NewGenerateSBOMHandler creates a new instance of GenerateSBOMHandler.
 func NewGenerateSBOMHandler(
 	k8sClient client.Client,
 	scheme *runtime.Scheme,
-	workDir string,
-	trivyJavaDBRepository string,
+	generator SBOMGenerator,
+	rateLimiters *registryclient.RateLimiters,
 	publisher messaging.Publisher,
 	logger *slog.Logger,
 ) *GenerateSBOMHandler {
 	return &GenerateSBOMHandler{
-		k8sClient:             k8sClient,
-		scheme:                scheme,
-		workDir:               workDir,
-		trivyJavaDBRepository: trivyJavaDBRepository,
-		publisher:             publisher,
-		logger:                logger.With("handler", "generate_sbom_handler"),
+		k8sClient:    k8sClient,
+		scheme:       scheme,
+		generator:    generator,
+		rateLimiters: rateLimiters,
+		publisher:    publisher,
+		logger:       logger.With("handler", "generate_sbom_handler"),
 	}
 }
 
@@ -57,7 +52,7 @@ func NewGenerateSBOMHandler(
 func (h *GenerateSBOMHandler) Handle(ctx context.Context, message messaging.Message) error {
 	generateSBOMMessage := &GenerateSBOMMessage{}
 	if err := json.Unmarshal(message.Data(), generateSBOMMessage); err != nil {
-		return fmt.Errorf("failed to unmarshal GenerateSBOM message: %w", err)
+		return messaging.NewPermanentError(fmt.Errorf("failed to unmarshal GenerateSBOM message: %w", err))
 	}
 
 	h.logger.InfoContext(ctx, "SBOM generation requested",
@@ -85,6 +80,12 @@ func (h *GenerateSBOMHandler) Handle(ctx context.Context, message messaging.Mess
 		return nil
 	}
 
+	if generateSBOMMessage.IsStale(scanJob) {
+		h.logger.InfoContext(ctx, "Message published for an earlier generation of the ScanJob, stopping SBOM generation", "scanjob", scanJob.Name, "namespace", scanJob.Namespace,
+			"generation", generateSBOMMessage.Generation, "currentGeneration", scanJob.Generation)
+		return nil
+	}
+
 	h.logger.DebugContext(ctx, "ScanJob found", "scanjob", scanJob)
 
 	if scanJob.IsFailed() {
@@ -92,6 +93,11 @@ func (h *GenerateSBOMHandler) Handle(ctx context.Context, message messaging.Mess
 		return nil
 	}
 
+	if scanJob.IsStopRequested() {
+		h.logger.InfoContext(ctx, "ScanJob is cancelled or suspended, stopping SBOM generation", "scanjob", scanJob.Name, "namespace", scanJob.Namespace)
+		return nil
+	}
+
 	image := &storagev1alpha1.Image{}
 	err = h.k8sClient.Get(ctx, client.ObjectKey{
 		Name:      generateSBOMMessage.Image.Name,
@@ -127,7 +133,9 @@ func (h *GenerateSBOMHandler) Handle(ctx context.Context, message messaging.Mess
 		return fmt.Errorf("failed to ack message as in progress: %w", err)
 	}
 
-	if err = h.k8sClient.Create(ctx, sbom); err != nil {
+	if err = traceStorageWrite(ctx, "create", sbom, func(ctx context.Context) error {
+		return h.k8sClient.Create(ctx, sbom)
+	}); err != nil {
 		if apierrors.IsAlreadyExists(err) {
 			h.logger.InfoContext(ctx, "SBOM already exists, skipping creation", "sbom", generateSBOMMessage.Image.Name, "namespace", generateSBOMMessage.Image.Namespace)
 		} else {
@@ -135,11 +143,9 @@ func (h *GenerateSBOMHandler) Handle(ctx context.Context, message messaging.Mess
 		}
 	}
 
-	scanSBOMMessageID := fmt.Sprintf("scanSBOM/%s/%s", scanJob.UID, generateSBOMMessage.Image.Name)
+	scanSBOMMessageID := fmt.Sprintf("scanSBOM/%s/%d/%s", scanJob.UID, scanJob.Generation, generateSBOMMessage.Image.Name)
 	scanSBOMMessage, err := json.Marshal(&ScanSBOMMessage{
-		BaseMessage: BaseMessage{
-			ScanJob: generateSBOMMessage.ScanJob,
-		},
+		BaseMessage: generateSBOMMessage.BaseMessage,
 		SBOM: ObjectRef{
 			Name:      generateSBOMMessage.Image.Name,
 			Namespace: generateSBOMMessage.Image.Namespace,
@@ -173,7 +179,12 @@ func (h *GenerateSBOMHandler) getOrGenerateSBOM(ctx context.Context, image *stor
 		spdxBytes = existingSBOM.SPDX.Raw
 	} else {
 		h.logger.InfoContext(ctx, "No existing SBOM found, generating new one", "digest", image.GetImageMetadata().Digest)
-		spdxBytes, err = h.generateSPDX(ctx, image, registry)
+		// The image is pulled by the generator, which does not send its requests through the rate limiter:
+		// the pull is accounted as a single request of the registry.
+		if err = registryRateLimiter(h.rateLimiters, registry).Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limiter wait failed: %w", err)
+		}
+		spdxBytes, err = h.generator.GenerateSBOM(ctx, image, registry)
 		if err != nil {
 			return nil, err
 		}
@@ -217,73 +228,3 @@ func (h *GenerateSBOMHandler) findSBOMByDigest(ctx context.Context, digest strin
 
 	return &sbomList.Items[0], nil
 }
-
-// generateSPDX generates SPDX JSON content for an image using Trivy.
-func (h *GenerateSBOMHandler) generateSPDX(ctx context.Context, image *storagev1alpha1.Image, registry *v1alpha1.Registry) ([]byte, error) {
-	sbomFile, err := os.CreateTemp(h.workDir, "trivy.sbom.*.json")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary SBOM file: %w", err)
-	}
-	defer func() {
-		if err = sbomFile.Close(); err != nil {
-			h.logger.Error("failed to close temporary SBOM file", "error", err)
-		}
-		if err = os.Remove(sbomFile.Name()); err != nil {
-			h.logger.Error("failed to remove temporary SBOM file", "error", err)
-		}
-	}()
-
-	// if authSecret value is set, then setup Docker
-	// authentication to get access to the registry
-	if registry.IsPrivate() {
-		var dockerConfig string
-		dockerConfig, err = dockerauth.BuildDockerConfigForRegistry(ctx, h.k8sClient, registry)
-		if err != nil {
-			return nil, fmt.Errorf("cannot setup docker auth for registry %s: %w", registry.Name, err)
-		}
-		h.logger.DebugContext(ctx, "Setup registry authentication", "dockerconfig", os.Getenv("DOCKER_CONFIG"))
-		defer func() {
-			if err = os.RemoveAll(dockerConfig); err != nil {
-				h.logger.Error("failed to remove dockerconfig directory", "error", err)
-			}
-			// unset the DOCKER_CONFIG variable so at every run
-			// we start from a clean environment.
-			if err = os.Unsetenv("DOCKER_CONFIG"); err != nil {
-				h.logger.Error("failed to unset DOCKER_CONFIG variable", "error", err)
-			}
-		}()
-	}
-
-	app := trivyCommands.NewApp()
-	app.SetArgs([]string{
-		"image",
-		"--skip-version-check",
-		"--disable-telemetry",
-		"--cache-dir", h.workDir,
-		"--format", "spdx-json",
-		"--skip-db-update",
-		// The Java DB is needed to generate SBOMs for images containing Java components
-		// See: https://github.com/aquasecurity/trivy/discussions/9666
-		"--java-db-repository", h.trivyJavaDBRepository,
-		"--output", sbomFile.Name(),
-		fmt.Sprintf(
-			"%s/%s@%s",
-			image.GetImageMetadata().RegistryURI,
-			image.GetImageMetadata().Repository,
-			image.GetImageMetadata().Digest,
-		),
-	})
-
-	if err = app.ExecuteContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to execute trivy: %w", err)
-	}
-
-	h.logger.DebugContext(ctx, "SPDX generated", "image", image.Name, "namespace", image.Namespace)
-
-	spdxBytes, err := io.ReadAll(sbomFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read SBOM output: %w", err)
-	}
-
-	return spdxBytes, nil
-}
@@ -27,6 +27,7 @@ import (
 
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
 	messagingMocks "github.com/kubewarden/sbomscanner/internal/messaging/mocks"
 	"github.com/kubewarden/sbomscanner/pkg/generated/clientset/versioned/scheme"
 )
@@ -165,11 +166,11 @@ func testGenerateSBOM(t *testing.T, platform, sha256, expectedSPDXJSON string) {
 	publisher.On("Publish",
 		mock.Anything,
 		ScanSBOMSubject,
-		fmt.Sprintf("scanSBOM/%s/%s", scanJob.UID, image.Name),
+		fmt.Sprintf("scanSBOM/%s/%d/%s", scanJob.UID, scanJob.Generation, image.Name),
 		expectedScanMessage,
 	).Return(nil).Once()
 
-	handler := NewGenerateSBOMHandler(k8sClient, scheme, "/tmp", testTrivyJavaDBRepository, publisher, slog.Default())
+	handler := NewGenerateSBOMHandler(k8sClient, scheme, newTestTrivyEngine(t, k8sClient, "/tmp"), registryclient.NewRateLimiters(slog.Default()), publisher, slog.Default())
 
 	message, err := json.Marshal(&GenerateSBOMMessage{
 		BaseMessage: BaseMessage{
@@ -316,11 +317,11 @@ func TestGenerateSBOMHandler_Handle_ReuseSBOMWithSameDigest(t *testing.T) {
 	publisher.On("Publish",
 		mock.Anything,
 		ScanSBOMSubject,
-		fmt.Sprintf("scanSBOM/%s/%s", scanJob.UID, newImage.Name),
+		fmt.Sprintf("scanSBOM/%s/%d/%s", scanJob.UID, scanJob.Generation, newImage.Name),
 		expectedScanMessage,
 	).Return(nil).Once()
 
-	handler := NewGenerateSBOMHandler(k8sClient, scheme, "/tmp", testTrivyJavaDBRepository, publisher, slog.Default())
+	handler := NewGenerateSBOMHandler(k8sClient, scheme, newTestTrivyEngine(t, k8sClient, "/tmp"), registryclient.NewRateLimiters(slog.Default()), publisher, slog.Default())
 
 	message, err := json.Marshal(&GenerateSBOMMessage{
 		BaseMessage: BaseMessage{
@@ -399,10 +400,15 @@ func TestGenerateSBOMHandler_Handle_StopProcessing(t *testing.T) {
 	failedScanJob := scanJob.DeepCopy()
 	failedScanJob.MarkFailed(v1alpha1.ReasonInternalError, "kaboom")
 
+	// The ScanJob was suspended and resumed after the message was published.
+	resumedScanJob := scanJob.DeepCopy()
+	resumedScanJob.Generation = 3
+
 	tests := []struct {
-		name            string
-		scanJob         *v1alpha1.ScanJob
-		existingObjects []runtime.Object
+		name              string
+		scanJob           *v1alpha1.ScanJob
+		messageGeneration int64
+		existingObjects   []runtime.Object
 	}{
 		{
 			name:            "scanjob not found",
@@ -419,6 +425,12 @@ func TestGenerateSBOMHandler_Handle_StopProcessing(t *testing.T) {
 			scanJob:         failedScanJob,
 			existingObjects: []runtime.Object{failedScanJob, image, registry},
 		},
+		{
+			name:              "message published for an earlier generation",
+			scanJob:           resumedScanJob,
+			messageGeneration: 1,
+			existingObjects:   []runtime.Object{resumedScanJob, image, registry},
+		},
 		{
 			name:            "image not found",
 			scanJob:         scanJob,
@@ -448,7 +460,7 @@ func TestGenerateSBOMHandler_Handle_StopProcessing(t *testing.T) {
 			publisher := messagingMocks.NewMockPublisher(t)
 			// Publisher should not be called since we exit early
 
-			handler := NewGenerateSBOMHandler(k8sClient, scheme, "/tmp", testTrivyJavaDBRepository, publisher, slog.Default())
+			handler := NewGenerateSBOMHandler(k8sClient, scheme, NewTrivyEngine(k8sClient, "/tmp", testTrivyDBRepository, testTrivyJavaDBRepository, nil, nil, slog.Default()), registryclient.NewRateLimiters(slog.Default()), publisher, slog.Default())
 
 			message, err := json.Marshal(&GenerateSBOMMessage{
 				BaseMessage: BaseMessage{
@@ -457,6 +469,7 @@ func TestGenerateSBOMHandler_Handle_StopProcessing(t *testing.T) {
 						Namespace: test.scanJob.Namespace,
 						UID:       string(test.scanJob.UID),
 					},
+					Generation: test.messageGeneration,
 				},
 				Image: ObjectRef{
 					Name:      image.Name,
@@ -569,11 +582,11 @@ func TestGenerateSBOMHandler_Handle_ExistingSBOM(t *testing.T) {
 	publisher.On("Publish",
 		mock.Anything,
 		ScanSBOMSubject,
-		fmt.Sprintf("scanSBOM/%s/%s", scanJob.UID, existingSBOM.Name),
+		fmt.Sprintf("scanSBOM/%s/%d/%s", scanJob.UID, scanJob.Generation, existingSBOM.Name),
 		expectedScanMessage,
 	).Return(nil).Once()
 
-	handler := NewGenerateSBOMHandler(k8sClient, scheme, "/tmp", testTrivyJavaDBRepository, publisher, slog.Default())
+	handler := NewGenerateSBOMHandler(k8sClient, scheme, newTestTrivyEngine(t, k8sClient, "/tmp"), registryclient.NewRateLimiters(slog.Default()), publisher, slog.Default())
 
 	message, err := json.Marshal(&GenerateSBOMMessage{
 		BaseMessage: BaseMessage{
@@ -703,11 +716,11 @@ func TestGenerateSBOMHandler_Handle_PrivateRegistry(t *testing.T) {
 	publisher.On("Publish",
 		mock.Anything,
 		ScanSBOMSubject,
-		fmt.Sprintf("scanSBOM/%s/%s", scanJob.UID, image.Name),
+		fmt.Sprintf("scanSBOM/%s/%d/%s", scanJob.UID, scanJob.Generation, image.Name),
 		expectedScanMessage,
 	).Return(nil).Once()
 
-	handler := NewGenerateSBOMHandler(k8sClient, scheme, "/tmp", testTrivyJavaDBRepository, publisher, slog.Default())
+	handler := NewGenerateSBOMHandler(k8sClient, scheme, newTestTrivyEngine(t, k8sClient, "/tmp"), registryclient.NewRateLimiters(slog.Default()), publisher, slog.Default())
 
 	message, err := json.Marshal(&GenerateSBOMMessage{
 		BaseMessage: BaseMessage{
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
+	vulnReport "github.com/kubewarden/sbomscanner/internal/handlers/vulnerabilityreport"
+	"github.com/kubewarden/sbomscanner/internal/messaging"
+)
+
+// grypeDBSubPath is the directory of the work directory holding the Grype vulnerability database.
+const grypeDBSubPath = "grype-db"
+
+// GrypeEngine generates SBOMs using Anchore Syft and scans them using Anchore Grype.
+// The syft and grype executables must be available in the worker image.
+// Registry mirrors and VEXHub repositories are not supported, and are rejected.
+type GrypeEngine struct {
+	k8sClient client.Client
+	workDir   string
+	syftPath  string
+	grypePath string
+	// offline configures the bundle holding the database.
+	// The database is downloaded when nil.
+	offline *OfflineConfig
+	// importedArchive identifies the bundled database archive imported last, in offline mode.
+	importedArchive string
+	// dbReady is set once the vulnerability database has been downloaded.
+	dbReady atomic.Bool
+	logger  *slog.Logger
+}
+
+// NewGrypeEngine creates a new instance of GrypeEngine.
+// An error is returned when the syft or grype executable cannot be found.
+func NewGrypeEngine(
+	k8sClient client.Client,
+	workDir string,
+	syftPath string,
+	grypePath string,
+	offline *OfflineConfig,
+	logger *slog.Logger,
+) (*GrypeEngine, error) {
+	syftPath, err := exec.LookPath(syftPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the syft executable: %w", err)
+	}
+	grypePath, err = exec.LookPath(grypePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the grype executable: %w", err)
+	}
+
+	return &GrypeEngine{
+		k8sClient: k8sClient,
+		workDir:   workDir,
+		syftPath:  syftPath,
+		grypePath: grypePath,
+		offline:   offline,
+		logger:    logger.With("engine", EngineGrype),
+	}, nil
+}
+
+// Name returns the name of the engine.
+func (e *GrypeEngine) Name() string {
+	return EngineGrype
+}
+
+// GenerateSBOM generates SPDX JSON content for an image using Syft.
+// Registry mirrors are not supported by Syft, the SBOM generation fails when the registry has mirrors.
+func (e *GrypeEngine) GenerateSBOM(ctx context.Context, image *storagev1alpha1.Image, registry *v1alpha1.Registry) ([]byte, error) {
+	if len(registry.Spec.Mirrors) > 0 {
+		return nil, messaging.NewPermanentError(
+			fmt.Errorf("registry %s has mirrors, which are not supported by the %s scanner engine", registry.Name, EngineGrype))
+	}
+
+	// The credentials and the TLS settings are passed through the environment of the syft process,
+	// so that concurrent SBOM generations do not share them.
+	env := []string{"SYFT_CHECK_FOR_APP_UPDATE=false"}
+	if registry.Spec.Insecure {
+		env = append(env, "SYFT_REGISTRY_INSECURE_SKIP_TLS_VERIFY=true")
+	}
+	if registry.Spec.CABundle != "" {
+		caFile, err := e.writeCABundle(registry.Spec.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err = os.Remove(caFile); err != nil {
+				e.logger.Error("failed to remove temporary CA bundle file", "error", err)
+			}
+		}()
+		env = append(env, "SYFT_REGISTRY_CA_CERT="+caFile)
+	}
+	if registry.IsPrivate() {
+		authConfig, err := registryAuthConfig(ctx, e.k8sClient, image, registry)
+		if err != nil {
+			return nil, fmt.Errorf("cannot setup syft for registry %s: %w", registry.Name, err)
+		}
+		env = append(env,
+			"SYFT_REGISTRY_AUTH_AUTHORITY="+image.GetImageMetadata().RegistryURI,
+			"SYFT_REGISTRY_AUTH_USERNAME="+authConfig.Username,
+			"SYFT_REGISTRY_AUTH_PASSWORD="+authConfig.Password,
+			"SYFT_REGISTRY_AUTH_TOKEN="+authConfig.RegistryToken,
+		)
+	}
+	syftArgs := []string{
+		"scan",
+		"--quiet",
+		"--output", "spdx-json",
+		fmt.Sprintf(
+			"registry:%s/%s@%s",
+			image.GetImageMetadata().RegistryURI,
+			image.GetImageMetadata().Repository,
+			image.GetImageMetadata().Digest,
+		),
+	}
+
+	spdxBytes, err := e.execute(ctx, e.syftPath, syftArgs, env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute syft: %w", err)
+	}
+
+	e.logger.DebugContext(ctx, "SPDX generated", "image", image.Name, "namespace", image.Namespace)
+
+	return spdxBytes, nil
+}
+
+// writeCABundle writes the CA bundle of a registry to a temporary file, read by syft.
+func (e *GrypeEngine) writeCABundle(caBundle string) (string, error) {
+	if _, err := registryclient.RootCAs(caBundle); err != nil {
+		return "", messaging.NewPermanentError(fmt.Errorf("cannot load the CA bundle: %w", err))
+	}
+
+	caFile, err := os.CreateTemp(e.workDir, "syft.ca.*.pem")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary CA bundle file: %w", err)
+	}
+	defer func() {
+		if err := caFile.Close(); err != nil {
+			e.logger.Error("failed to close temporary CA bundle file", "error", err)
+		}
+	}()
+
+	if _, err = caFile.WriteString(caBundle); err != nil {
+		return "", fmt.Errorf("failed to write CA bundle file: %w", err)
+	}
+
+	return caFile.Name(), nil
+}
+
+// UpdateDatabase downloads the latest Grype vulnerability database,
+// or imports the archive of the bundle in offline mode when it changed.
+// Grype validates the checksum of the downloaded archive and replaces the database in use
+// only when the download succeeds.
+func (e *GrypeEngine) UpdateDatabase(ctx context.Context) error {
+	if e.offline != nil {
+		return e.importDatabase(ctx)
+	}
+
+	if _, err := e.execute(ctx, e.grypePath, []string{"db", "update"}, e.grypeEnv()); err != nil {
+		return fmt.Errorf("failed to update grype database: %w", err)
+	}
+	e.dbReady.Store(true)
+
+	return nil
+}
+
+// importDatabase imports the Grype vulnerability database archive of the bundle.
+func (e *GrypeEngine) importDatabase(ctx context.Context) error {
+	archive := filepath.Join(e.offline.BundlePath, bundleGrypeDBArchive)
+	info, err := os.Stat(archive)
+	if err != nil {
+		return fmt.Errorf("cannot find bundled grype database: %w", err)
+	}
+	revision := fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano())
+	if revision == e.importedArchive {
+		e.logger.DebugContext(ctx, "Grype database up to date", "archive", archive)
+		return nil
+	}
+
+	if _, err = e.execute(ctx, e.grypePath, []string{"db", "import", archive}, e.grypeEnv()); err != nil {
+		return fmt.Errorf("failed to import grype database: %w", err)
+	}
+	e.importedArchive = revision
+	e.dbReady.Store(true)
+	e.logger.InfoContext(ctx, "Grype database imported", "archive", archive)
+
+	return nil
+}
+
+// DatabaseReady returns an error until the Grype vulnerability database is downloaded.
+func (e *GrypeEngine) DatabaseReady() error {
+	if !e.dbReady.Load() {
+		return errors.New("grype database not downloaded yet")
+	}
+
+	return nil
+}
+
+// Scan scans the SPDX JSON content for vulnerabilities using Grype.
+// VEXHub repositories are specific to Trivy, the scan fails when VEXHub repositories are enabled.
+func (e *GrypeEngine) Scan(ctx context.Context, spdx []byte, vexHubs []v1alpha1.VEXHub) ([]storagev1alpha1.Result, *storagev1alpha1.VulnerabilityDatabase, error) {
+	if len(vexHubs) > 0 {
+		return nil, nil, messaging.NewPermanentError(
+			fmt.Errorf("VEXHub repositories are enabled, which are not supported by the %s scanner engine", EngineGrype))
+	}
+	if err := e.DatabaseReady(); err != nil {
+		return nil, nil, err
+	}
+
+	sbomFile, err := os.CreateTemp(e.workDir, "grype.sbom.*.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary SBOM file: %w", err)
+	}
+	defer func() {
+		if err = sbomFile.Close(); err != nil {
+			e.logger.Error("failed to close temporary SBOM file", "error", err)
+		}
+
+		if err = os.Remove(sbomFile.Name()); err != nil {
+			e.logger.Error("failed to remove temporary SBOM file", "error", err)
+		}
+	}()
+
+	if _, err = sbomFile.Write(spdx); err != nil {
+		return nil, nil, fmt.Errorf("failed to write SBOM file: %w", err)
+	}
+
+	grypeArgs := []string{
+		"sbom:" + sbomFile.Name(),
+		"--quiet",
+		"--output", "json",
+	}
+	// The database is updated by UpdateDatabase only.
+	env := append(e.grypeEnv(), "GRYPE_DB_AUTO_UPDATE=false")
+	if e.offline != nil {
+		// The freshness of the bundled database is up to the operator.
+		env = append(env, "GRYPE_DB_VALIDATE_AGE=false")
+	}
+
+	reportBytes, err := e.execute(ctx, e.grypePath, grypeArgs, env)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute grype: %w", err)
+	}
+
+	document := vulnReport.GrypeDocument{}
+	if err = json.Unmarshal(reportBytes, &document); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal report: %w", err)
+	}
+
+	results, err := vulnReport.NewFromGrypeResults(document)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert from grype results: %w", err)
+	}
+
+	return results, newGrypeDatabase(document.Descriptor.DB.Status), nil
+}
+
+// grypeEnv returns the environment variables of the grype executions.
+func (e *GrypeEngine) grypeEnv() []string {
+	return []string{
+		"GRYPE_CHECK_FOR_APP_UPDATE=false",
+		"GRYPE_DB_CACHE_DIR=" + filepath.Join(e.workDir, grypeDBSubPath),
+	}
+}
+
+// newGrypeDatabase describes the Grype vulnerability database used by a scan.
+func newGrypeDatabase(status vulnReport.GrypeDBStatus) *storagev1alpha1.VulnerabilityDatabase {
+	database := &storagev1alpha1.VulnerabilityDatabase{
+		Version: status.SchemaVersion,
+	}
+	if built, err := time.Parse(time.RFC3339, status.Built); err == nil {
+		database.UpdatedAt = &metav1.Time{Time: built}
+	}
+
+	return database
+}
+
+// execute runs the executable within a span and returns its standard output.
+// The standard error is included in the returned error when the execution fails.
+func (e *GrypeEngine) execute(ctx context.Context, executable string, args, env []string) ([]byte, error) {
+	tool := filepath.Base(executable)
+	ctx, span := tracer.Start(ctx, tool+" "+args[0], trace.WithAttributes(
+		attribute.String("process.executable.name", tool),
+	))
+	defer span.End()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Dir = e.workDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	observeEngineDuration(tool, start, err)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	return stdout.Bytes(), nil
+}
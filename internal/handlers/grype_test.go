@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/pem"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/messaging"
+)
+
+// writeFakeExecutable writes a shell script saving its arguments and environment, and then printing the given file.
+func writeFakeExecutable(t *testing.T, name, outputFile string) string {
+	t.Helper()
+
+	executable := filepath.Join(t.TempDir(), name)
+	script := "#!/bin/sh\necho \"$@\" > \"$0.args\"\nenv > \"$0.env\"\ncat " + outputFile + "\n"
+	require.NoError(t, os.WriteFile(executable, []byte(script), 0o700)) //nolint:gosec // the script must be executable
+
+	return executable
+}
+
+func TestGrypeEngine_GenerateSBOM(t *testing.T) {
+	spdxFile, err := filepath.Abs(filepath.Join("..", "..", "test", "fixtures", "golang-1.12-alpine-amd64.spdx.json"))
+	require.NoError(t, err)
+	syftPath := writeFakeExecutable(t, "syft", spdxFile)
+
+	engine, err := NewGrypeEngine(nil, t.TempDir(), syftPath, writeFakeExecutable(t, "grype", "/dev/null"), nil, slog.Default())
+	require.NoError(t, err)
+	image := &storagev1alpha1.Image{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-image", Namespace: "default"},
+		ImageMetadata: storagev1alpha1.ImageMetadata{
+			RegistryURI: "ghcr.io/kubewarden/sbomscanner",
+			Repository:  "test-assets/golang",
+			Digest:      imageDigestLinuxAmd64MultiArch,
+		},
+	}
+	registry := &v1alpha1.Registry{ObjectMeta: metav1.ObjectMeta{Name: "test-registry", Namespace: "default"}}
+
+	spdx, err := engine.GenerateSBOM(t.Context(), image, registry)
+	require.NoError(t, err)
+
+	expectedSPDX, err := os.ReadFile(spdxFile)
+	require.NoError(t, err)
+	assert.Equal(t, expectedSPDX, spdx)
+
+	args, err := os.ReadFile(syftPath + ".args")
+	require.NoError(t, err)
+	assert.Equal(t, "scan --quiet --output spdx-json registry:ghcr.io/kubewarden/sbomscanner/test-assets/golang@"+imageDigestLinuxAmd64MultiArch+"\n", string(args))
+}
+
+func TestGrypeEngine_Scan(t *testing.T) {
+	reportFile, err := filepath.Abs(filepath.Join("..", "..", "test", "fixtures", "vulnerabilityreport", "grype.report.json"))
+	require.NoError(t, err)
+	grypePath := writeFakeExecutable(t, "grype", reportFile)
+
+	engine, err := NewGrypeEngine(nil, t.TempDir(), writeFakeExecutable(t, "syft", "/dev/null"), grypePath, nil, slog.Default())
+	require.NoError(t, err)
+	assert.Equal(t, EngineGrype, engine.Name())
+
+	_, _, err = engine.Scan(t.Context(), []byte("{}"), nil)
+	require.Error(t, err, "the scan must fail until the database is downloaded")
+	require.Error(t, engine.DatabaseReady())
+
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	require.NoError(t, engine.DatabaseReady())
+	args, err := os.ReadFile(grypePath + ".args")
+	require.NoError(t, err)
+	assert.Equal(t, "db update\n", string(args))
+
+	results, database, err := engine.Scan(t.Context(), []byte("{}"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &storagev1alpha1.VulnerabilityDatabase{
+		Version:   "v6.0.2",
+		UpdatedAt: &metav1.Time{Time: time.Date(2025, 5, 14, 4, 33, 19, 0, time.UTC)},
+	}, database)
+	require.Len(t, results, 2)
+	assert.Equal(t, "/nginx-ingress-controller", results[0].Target)
+	assert.Len(t, results[0].Vulnerabilities, 2)
+	assert.Equal(t, "alpine 3.21.2", results[1].Target)
+	assert.Len(t, results[1].Vulnerabilities, 2)
+
+	// VEXHub repositories are not supported
+	_, _, err = engine.Scan(t.Context(), []byte("{}"), []v1alpha1.VEXHub{{ObjectMeta: metav1.ObjectMeta{Name: "vexhub"}}})
+	require.ErrorContains(t, err, "VEXHub repositories are enabled")
+	assert.True(t, messaging.IsPermanent(err))
+}
+
+func TestGrypeEngine_GenerateSBOM_RegistrySettings(t *testing.T) {
+	syftPath := writeFakeExecutable(t, "syft", "/dev/null")
+	engine, err := NewGrypeEngine(nil, t.TempDir(), syftPath, writeFakeExecutable(t, "grype", "/dev/null"), nil, slog.Default())
+	require.NoError(t, err)
+	image := &storagev1alpha1.Image{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-image", Namespace: "default"},
+		ImageMetadata: storagev1alpha1.ImageMetadata{
+			RegistryURI: "registry.local:5000",
+			Repository:  "test-assets/golang",
+			Digest:      imageDigestLinuxAmd64MultiArch,
+		},
+	}
+
+	// the certificate of a TLS test server is a valid CA bundle
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	registry := &v1alpha1.Registry{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-registry", Namespace: "default"},
+		Spec: v1alpha1.RegistrySpec{
+			URI:      "registry.local:5000",
+			Insecure: true,
+			CABundle: string(caBundle),
+		},
+	}
+	_, err = engine.GenerateSBOM(t.Context(), image, registry)
+	require.NoError(t, err)
+
+	env, err := os.ReadFile(syftPath + ".env")
+	require.NoError(t, err)
+	assert.Contains(t, string(env), "SYFT_REGISTRY_INSECURE_SKIP_TLS_VERIFY=true\n")
+	assert.Regexp(t, "SYFT_REGISTRY_CA_CERT=.*/syft\\.ca\\..*\\.pem\n", string(env))
+	entries, err := os.ReadDir(engine.workDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the CA bundle file must be removed")
+
+	// registry mirrors are not supported
+	registry.Spec.Mirrors = []string{"mirror.local:5000"}
+	_, err = engine.GenerateSBOM(t.Context(), image, registry)
+	require.ErrorContains(t, err, "not supported by the grype scanner engine")
+	assert.True(t, messaging.IsPermanent(err))
+}
+
+func TestNewGrypeEngine_MissingExecutable(t *testing.T) {
+	_, err := NewGrypeEngine(nil, t.TempDir(), filepath.Join(t.TempDir(), "syft"), writeFakeExecutable(t, "grype", "/dev/null"), nil, slog.Default())
+	require.ErrorContains(t, err, "cannot find the syft executable")
+}
+
+func TestGrypeEngine_UpdateDatabase_Error(t *testing.T) {
+	grypePath := filepath.Join(t.TempDir(), "grype")
+	require.NoError(t, os.WriteFile(grypePath, []byte("#!/bin/sh\necho 'failed to load vulnerability db' >&2\nexit 1\n"), 0o700)) //nolint:gosec // the script must be executable
+
+	engine, err := NewGrypeEngine(nil, t.TempDir(), writeFakeExecutable(t, "syft", "/dev/null"), grypePath, nil, slog.Default())
+	require.NoError(t, err)
+
+	err = engine.UpdateDatabase(t.Context())
+	require.ErrorContains(t, err, "failed to load vulnerability db")
+	require.Error(t, engine.DatabaseReady())
+}
+
+func TestGrypeEngine_UpdateDatabase_Offline(t *testing.T) {
+	bundlePath := t.TempDir()
+	archive := filepath.Join(bundlePath, bundleGrypeDBArchive)
+	require.NoError(t, os.WriteFile(archive, []byte("archive"), 0o600))
+	grypePath := writeFakeExecutable(t, "grype", "/dev/null")
+
+	engine, err := NewGrypeEngine(nil, t.TempDir(), writeFakeExecutable(t, "syft", "/dev/null"), grypePath, &OfflineConfig{BundlePath: bundlePath}, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	require.NoError(t, engine.DatabaseReady())
+
+	args, err := os.ReadFile(grypePath + ".args")
+	require.NoError(t, err)
+	assert.Equal(t, "db import "+archive+"\n", string(args))
+
+	// the archive is imported again only when it changes
+	require.NoError(t, os.Remove(grypePath+".args"))
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	assert.NoFileExists(t, grypePath+".args")
+
+	require.NoError(t, os.WriteFile(archive, []byte("new archive"), 0o600))
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	assert.FileExists(t, grypePath+".args")
+}
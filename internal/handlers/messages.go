@@ -1,5 +1,9 @@
 package handlers
 
+import (
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
 const (
 	GenerateSBOMSubject  = "sbomscanner.sbom.generate"
 	ScanSBOMSubject      = "sbomscanner.sbom.scan"
@@ -18,6 +22,15 @@ type ObjectRef struct {
 // BaseMessage is the base structure for messages.
 type BaseMessage struct {
 	ScanJob ObjectRef `json:"scanjob"`
+	// Generation is the generation of the ScanJob the message was published for.
+	// The messages published for an earlier generation, e.g. before the ScanJob was suspended and resumed, are dropped.
+	Generation int64 `json:"generation,omitempty"`
+}
+
+// IsStale returns true when the message was published for an earlier generation of the ScanJob.
+// Messages published without a generation are never stale.
+func (m BaseMessage) IsStale(scanJob *v1alpha1.ScanJob) bool {
+	return m.Generation != 0 && m.Generation != scanJob.Generation
 }
 
 // CreateCatalogMessage represents a request to create a catalog of images in a registry.
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// trivyDuration measures the executions of Trivy, per command and result.
+var trivyDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "sbomscanner",
+		Subsystem: "trivy",
+		Name:      "execution_duration_seconds",
+		Help:      "Time spent executing Trivy, per command and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	},
+	[]string{"command", "result"},
+)
+
+// engineDuration measures the executions of the syft and grype executables, per tool and result.
+var engineDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: "sbomscanner",
+		Subsystem: "engine",
+		Name:      "execution_duration_seconds",
+		Help:      "Time spent executing the syft and grype executables of the Grype engine, per tool and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	},
+	[]string{"tool", "result"},
+)
+
+// RegisterMetrics registers the metrics of the handlers with the given registerer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{trivyDuration, engineDuration} {
+		if err := registerer.Register(collector); err != nil {
+			var alreadyRegistered prometheus.AlreadyRegisteredError
+			if errors.As(err, &alreadyRegistered) {
+				continue
+			}
+			return fmt.Errorf("failed to register handlers metrics: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// observeTrivyDuration records the duration of a Trivy execution started at the given time.
+func observeTrivyDuration(command string, start time.Time, err error) {
+	trivyDuration.WithLabelValues(command, executionResult(err)).Observe(time.Since(start).Seconds())
+}
+
+// observeEngineDuration records the duration of a syft or grype execution started at the given time.
+func observeEngineDuration(tool string, start time.Time, err error) {
+	engineDuration.WithLabelValues(tool, executionResult(err)).Observe(time.Since(start).Seconds())
+}
+
+// executionResult returns the result label of an execution.
+func executionResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
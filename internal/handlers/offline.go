@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// bundleTrivyDBDir is the directory of the bundle holding the Trivy vulnerability database.
+	bundleTrivyDBDir = "trivy-db"
+	// bundleTrivyJavaDBDir is the directory of the bundle holding the Trivy Java database.
+	bundleTrivyJavaDBDir = "trivy-java-db"
+	// bundleVEXDir is the directory of the bundle holding the VEX repositories, one directory per VEXHub.
+	bundleVEXDir = "vex"
+	// bundleGrypeDBArchive is the Grype vulnerability database archive of the bundle.
+	bundleGrypeDBArchive = "grype-db.tar.zst"
+
+	// trivyDBMediaType is the media type of the layer of the trivy-db images.
+	trivyDBMediaType types.MediaType = "application/vnd.aquasec.trivy.db.layer.v1.tar+gzip"
+	// trivyJavaDBMediaType is the media type of the layer of the trivy-java-db images.
+	trivyJavaDBMediaType types.MediaType = "application/vnd.aquasec.trivy.javadb.layer.v1.tar+gzip"
+)
+
+// ErrOffline is returned when an operation would need to reach the network in offline mode.
+var ErrOffline = errors.New("network access disabled in offline mode")
+
+// OfflineConfig configures the engines to load the vulnerability databases and the VEX repositories
+// from a local bundle, instead of downloading them.
+// Only the registries of the scanned images are reached.
+//
+// The bundle is a directory containing:
+//   - trivy-db: the Trivy vulnerability database, either as an OCI image layout or as the extracted database files
+//   - trivy-java-db: the Trivy Java database, in the same formats
+//   - vex: the VEX repositories, one directory per VEXHub, named after the VEXHub resource
+//   - grype-db.tar.zst: the Grype vulnerability database archive
+type OfflineConfig struct {
+	// BundlePath is the path to the bundle directory.
+	BundlePath string
+}
+
+// Validate checks that the bundle contains the databases needed by the given engine.
+func (c *OfflineConfig) Validate(engine string) error {
+	required := []string{bundleTrivyDBDir, bundleTrivyJavaDBDir}
+	if engine == EngineGrype {
+		required = []string{bundleGrypeDBArchive}
+	}
+
+	for _, entry := range required {
+		if _, err := os.Stat(filepath.Join(c.BundlePath, entry)); err != nil {
+			return fmt.Errorf("invalid bundle %s: %w", c.BundlePath, err)
+		}
+	}
+
+	return nil
+}
+
+// bundleDB is a Trivy database of the bundle.
+type bundleDB struct {
+	path      string
+	mediaType types.MediaType
+	// layer is the layer holding the database, when the bundle contains an OCI image layout.
+	layer v1.Layer
+	// digest is the digest of the database image, when the bundle contains an OCI image layout.
+	digest string
+	// revision identifies the content of the database.
+	revision string
+}
+
+// openBundleDB opens the Trivy database of the bundle in the given path.
+// The revision of an OCI image layout is the digest of the image,
+// the one of the database files is the checksum of their metadata.
+func openBundleDB(path string, mediaType types.MediaType) (*bundleDB, error) {
+	db := &bundleDB{path: path, mediaType: mediaType}
+
+	if _, err := os.Stat(filepath.Join(path, "oci-layout")); err != nil {
+		metadata, err := os.ReadFile(filepath.Join(path, "metadata.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read database metadata: %w", err)
+		}
+		checksum := sha256.Sum256(metadata)
+		db.revision = hex.EncodeToString(checksum[:])
+
+		return db, nil
+	}
+
+	layoutPath, err := layout.FromPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI image layout %s: %w", path, err)
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI image layout index: %w", err)
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI image layout index: %w", err)
+	}
+	if len(indexManifest.Manifests) == 0 {
+		return nil, fmt.Errorf("OCI image layout %s contains no images", path)
+	}
+	digest := indexManifest.Manifests[0].Digest
+	image, err := index.Image(digest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image %s: %w", digest, err)
+	}
+	layers, err := image.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layers of image %s: %w", digest, err)
+	}
+	for _, layer := range layers {
+		layerMediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer media type: %w", err)
+		}
+		if layerMediaType == mediaType {
+			db.layer = layer
+			break
+		}
+	}
+	if db.layer == nil {
+		return nil, fmt.Errorf("image %s has no %s layer", digest, mediaType)
+	}
+	db.digest = digest.String()
+	db.revision = digest.String()
+
+	return db, nil
+}
+
+// extract writes the database files into the given directory.
+// The layer of an OCI image layout is validated against its digest.
+func (b *bundleDB) extract(dst string) error {
+	if err := os.MkdirAll(dst, 0o750); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	if b.layer == nil {
+		return copyFiles(b.path, dst)
+	}
+
+	expectedDigest, err := b.layer.Digest()
+	if err != nil {
+		return fmt.Errorf("failed to read layer digest: %w", err)
+	}
+	compressed, err := b.layer.Compressed()
+	if err != nil {
+		return fmt.Errorf("failed to open layer %s: %w", expectedDigest, err)
+	}
+	defer compressed.Close()
+
+	hash := sha256.New()
+	gzipReader, err := gzip.NewReader(io.TeeReader(compressed, hash))
+	if err != nil {
+		return fmt.Errorf("failed to decompress layer %s: %w", expectedDigest, err)
+	}
+	if err = extractTar(gzipReader, dst); err != nil {
+		return fmt.Errorf("failed to extract layer %s: %w", expectedDigest, err)
+	}
+	// Read the rest of the layer, to compute the digest of the whole blob.
+	if _, err = io.Copy(io.Discard, compressed); err != nil {
+		return fmt.Errorf("failed to read layer %s: %w", expectedDigest, err)
+	}
+
+	actualDigest := v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(hash.Sum(nil))}
+	if actualDigest != expectedDigest {
+		return fmt.Errorf("layer digest mismatch: expected %s, got %s", expectedDigest, actualDigest)
+	}
+
+	return nil
+}
+
+// extractTar writes the regular files of the tar archive into the given directory.
+// The directories of the archive are flattened, as the databases are made of top level files only.
+func extractTar(reader io.Reader, dst string) error {
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if err = writeFile(filepath.Join(dst, filepath.Base(header.Name)), tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// copyFiles copies the regular files of the src directory into the dst directory.
+func copyFiles(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", src, err)
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		file, err := os.Open(filepath.Join(src, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", entry.Name(), err)
+		}
+		err = writeFile(filepath.Join(dst, entry.Name()), file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes the content of the reader into a new file.
+func writeFile(path string, reader io.Reader) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err = io.Copy(file, reader); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+
+	return nil
+}
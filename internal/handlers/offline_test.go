@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/messaging"
+)
+
+const testDBMetadata = `{"Version":2,"NextUpdate":"2025-01-02T00:00:00Z","UpdatedAt":"2025-01-01T00:00:00Z","DownloadedAt":"2025-01-01T01:00:00Z"}`
+
+// writeBundleDBFiles writes the files of a database into the given directory of the bundle.
+func writeBundleDBFiles(t *testing.T, dir, dbFile string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "metadata.json"), []byte(testDBMetadata), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, dbFile), []byte("database"), 0o600))
+}
+
+// writeBundleDBLayout writes a database image into an OCI image layout, and returns the image digest.
+func writeBundleDBLayout(t *testing.T, dir, dbFile string, mediaType types.MediaType) string {
+	t.Helper()
+
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range map[string]string{"metadata.json": testDBMetadata, dbFile: "database"} {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+
+	image, err := mutate.AppendLayers(empty.Image, static.NewLayer(archive.Bytes(), mediaType))
+	require.NoError(t, err)
+	layoutPath, err := layout.Write(dir, empty.Index)
+	require.NoError(t, err)
+	require.NoError(t, layoutPath.AppendImage(image))
+
+	digest, err := image.Digest()
+	require.NoError(t, err)
+
+	return digest.String()
+}
+
+func TestOfflineConfig_Validate(t *testing.T) {
+	bundlePath := t.TempDir()
+	config := &OfflineConfig{BundlePath: bundlePath}
+
+	require.Error(t, config.Validate(EngineTrivy))
+	writeBundleDBFiles(t, filepath.Join(bundlePath, bundleTrivyDBDir), "trivy.db")
+	writeBundleDBFiles(t, filepath.Join(bundlePath, bundleTrivyJavaDBDir), "trivy-java.db")
+	require.NoError(t, config.Validate(EngineTrivy))
+
+	require.Error(t, config.Validate(EngineGrype))
+	require.NoError(t, os.WriteFile(filepath.Join(bundlePath, bundleGrypeDBArchive), []byte("archive"), 0o600))
+	require.NoError(t, config.Validate(EngineGrype))
+}
+
+func TestBundleDB_Extract(t *testing.T) {
+	t.Run("database files", func(t *testing.T) {
+		dbDir := t.TempDir()
+		writeBundleDBFiles(t, dbDir, "trivy.db")
+
+		db, err := openBundleDB(dbDir, trivyDBMediaType)
+		require.NoError(t, err)
+		assert.Empty(t, db.digest)
+		assert.NotEmpty(t, db.revision)
+
+		dst := filepath.Join(t.TempDir(), "db")
+		require.NoError(t, db.extract(dst))
+		assert.FileExists(t, filepath.Join(dst, "trivy.db"))
+		assert.FileExists(t, filepath.Join(dst, "metadata.json"))
+	})
+
+	t.Run("OCI image layout", func(t *testing.T) {
+		layoutDir := t.TempDir()
+		digest := writeBundleDBLayout(t, layoutDir, "trivy.db", trivyDBMediaType)
+
+		db, err := openBundleDB(layoutDir, trivyDBMediaType)
+		require.NoError(t, err)
+		assert.Equal(t, digest, db.digest)
+		assert.Equal(t, digest, db.revision)
+
+		dst := filepath.Join(t.TempDir(), "db")
+		require.NoError(t, db.extract(dst))
+		content, err := os.ReadFile(filepath.Join(dst, "trivy.db"))
+		require.NoError(t, err)
+		assert.Equal(t, "database", string(content))
+		assert.FileExists(t, filepath.Join(dst, "metadata.json"))
+	})
+
+	t.Run("OCI image layout without the database layer", func(t *testing.T) {
+		layoutDir := t.TempDir()
+		writeBundleDBLayout(t, layoutDir, "trivy-java.db", trivyJavaDBMediaType)
+
+		_, err := openBundleDB(layoutDir, trivyDBMediaType)
+		require.ErrorContains(t, err, "has no "+string(trivyDBMediaType)+" layer")
+	})
+
+	t.Run("OCI image layout with a corrupted layer", func(t *testing.T) {
+		layoutDir := t.TempDir()
+		writeBundleDBLayout(t, layoutDir, "trivy.db", trivyDBMediaType)
+
+		db, err := openBundleDB(layoutDir, trivyDBMediaType)
+		require.NoError(t, err)
+		layerDigest, err := db.layer.Digest()
+		require.NoError(t, err)
+		blob := filepath.Join(layoutDir, "blobs", layerDigest.Algorithm, layerDigest.Hex)
+		file, err := os.OpenFile(blob, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = file.WriteString("corrupted")
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		err = db.extract(filepath.Join(t.TempDir(), "db"))
+		require.ErrorContains(t, err, "layer digest mismatch")
+	})
+}
+
+func TestTrivyEngine_UpdateDatabase_Offline(t *testing.T) {
+	bundlePath := t.TempDir()
+	writeBundleDBFiles(t, filepath.Join(bundlePath, bundleTrivyDBDir), "trivy.db")
+	javaDBDigest := writeBundleDBLayout(t, filepath.Join(bundlePath, bundleTrivyJavaDBDir), "trivy-java.db", trivyJavaDBMediaType)
+	require.NoError(t, os.MkdirAll(filepath.Join(bundlePath, bundleVEXDir, "bundled"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(bundlePath, bundleVEXDir, "bundled", "vex-repository.json"), []byte("{}"), 0o600))
+
+	// The repositories are not reachable: the databases must be loaded from the bundle.
+	engine := NewTrivyEngine(nil, t.TempDir(), "unreachable.invalid/trivy-db", "unreachable.invalid/trivy-java-db", nil, &OfflineConfig{BundlePath: bundlePath}, slog.Default())
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	require.NoError(t, engine.DatabaseReady())
+
+	cacheDir, database, release, err := engine.acquireDatabase()
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, "2", database.Version)
+	assert.Empty(t, database.Digest, "bundled database files have no digest")
+	assert.FileExists(t, filepath.Join(cacheDir, "java-db", "trivy-java.db"))
+	assert.FileExists(t, filepath.Join(cacheDir, "vex", "repositories", "bundled", "vex-repository.json"))
+
+	info := trivyDBInfo{}
+	infoBytes, err := os.ReadFile(filepath.Join(cacheDir, trivyDBInfoFile))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(infoBytes, &info))
+	assert.Equal(t, javaDBDigest, info.JavaDBDigest)
+
+	// a new bundle replaces the databases in use
+	require.NoError(t, os.WriteFile(filepath.Join(bundlePath, bundleTrivyDBDir, "metadata.json"),
+		[]byte(`{"Version":2,"UpdatedAt":"2025-02-01T00:00:00Z"}`), 0o600))
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	newCacheDir, _, release, err := engine.acquireDatabase()
+	require.NoError(t, err)
+	release()
+	assert.NotEqual(t, cacheDir, newCacheDir)
+
+	// VEXHubs missing from the bundle fail the scan, instead of being downloaded
+	_, _, err = engine.Scan(t.Context(), []byte("{}"), []v1alpha1.VEXHub{
+		{ObjectMeta: metav1.ObjectMeta{Name: "bundled"}, Spec: v1alpha1.VEXHubSpec{URL: "https://bundled.invalid", Enabled: true}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "missing"}, Spec: v1alpha1.VEXHubSpec{URL: "https://missing.invalid", Enabled: true}},
+	})
+	require.ErrorIs(t, err, ErrOffline)
+	require.ErrorContains(t, err, "VEXHub missing")
+	assert.True(t, messaging.IsPermanent(err))
+}
@@ -18,49 +18,54 @@ type ImageDetails struct {
 	Layers   []cranev1.Layer
 	History  []cranev1.History
 	Platform cranev1.Platform
+	// Annotations are the annotations of the image manifest.
+	Annotations map[string]string
 }
 
-type ClientFactory func(http.RoundTripper) *Client
+type ClientFactory func(http.RoundTripper, authn.Keychain) *Client
 
 type Client struct {
 	transport http.RoundTripper
+	keychain  authn.Keychain
+	mirrors   []string
 	logger    *slog.Logger
 }
 
-func NewClient(transport http.RoundTripper, logger *slog.Logger) *Client {
+// NewClient creates a new registry client.
+// The keychain is used to resolve the credentials of every request,
+// so that clients of different registries can be used concurrently.
+func NewClient(transport http.RoundTripper, keychain authn.Keychain, logger *slog.Logger) *Client {
 	return &Client{
 		transport: transport,
+		keychain:  keychain,
 		logger:    logger.With("component", "registry_client"),
 	}
 }
 
+// WithMirrors returns a copy of the client that pulls from the given mirrors, in order of preference,
+// falling back to the upstream registry when none of the mirrors can serve a request.
+// The tags of a repository are listed on the mirrors and on the upstream registry, and merged.
+// The names returned by the client always refer to the upstream registry.
+func (c *Client) WithMirrors(mirrors []string) *Client {
+	client := *c
+	client.mirrors = mirrors
+
+	return &client
+}
+
 func (c *Client) Catalog(ctx context.Context, registry name.Registry) ([]string, error) {
 	c.logger.DebugContext(ctx, "Catalog called", "registry", registry)
 
-	puller, err := remote.NewPuller(
-		remote.WithAuthFromKeychain(authn.DefaultKeychain),
-		remote.WithTransport(c.transport),
-	)
-	if err != nil {
-		return []string{}, fmt.Errorf("cannot create puller: %w", err)
-	}
-
-	catalogger, err := puller.Catalogger(ctx, registry)
+	repos, err := tryWithMirrors(c, c.mirrorRegistries(registry), func(reg name.Registry) ([]string, error) {
+		return c.catalog(ctx, reg)
+	})
 	if err != nil {
-		return []string{}, fmt.Errorf("cannot create catalogger for %s: %w", registry.Name(), err)
+		return []string{}, err
 	}
 
 	repositories := []string{}
-
-	for catalogger.HasNext() {
-		var repos *remote.Catalogs
-		repos, err = catalogger.Next(ctx)
-		if err != nil {
-			return []string{}, fmt.Errorf("cannot iterate over repository %s contents: %w", registry.Name(), err)
-		}
-		for _, repo := range repos.Repos {
-			repositories = append(repositories, path.Join(registry.Name(), repo))
-		}
+	for _, repo := range repos {
+		repositories = append(repositories, path.Join(registry.Name(), repo))
 	}
 
 	c.logger.DebugContext(ctx, "Repositories found",
@@ -74,29 +79,17 @@ func (c *Client) Catalog(ctx context.Context, registry name.Registry) ([]string,
 func (c *Client) ListRepositoryContents(ctx context.Context, repo name.Repository) ([]string, error) {
 	c.logger.DebugContext(ctx, "List repository contents", "repository", repo)
 
-	puller, err := remote.NewPuller(
-		remote.WithAuthFromKeychain(authn.DefaultKeychain),
-		remote.WithTransport(c.transport),
-	)
+	// The tags are listed on every mirror and on upstream, since a mirror can lag behind.
+	tags, err := mergeFromMirrors(c, c.mirrorRepositories(repo), func(r name.Repository) ([]string, error) {
+		return c.listTags(ctx, r)
+	})
 	if err != nil {
-		return []string{}, fmt.Errorf("cannot create puller: %w", err)
-	}
-
-	lister, err := puller.Lister(ctx, repo)
-	if err != nil {
-		return []string{}, fmt.Errorf("cannot create lister for repository %s: %w", repo, err)
+		return []string{}, err
 	}
 
 	images := []string{}
-	for lister.HasNext() {
-		var tags *remote.Tags
-		tags, err = lister.Next(ctx)
-		if err != nil {
-			return []string{}, fmt.Errorf("cannot iterate over repository contents: %w", err)
-		}
-		for _, tag := range tags.Tags {
-			images = append(images, repo.Tag(tag).String())
-		}
+	for _, tag := range tags {
+		images = append(images, repo.Tag(tag).String())
 	}
 
 	c.logger.DebugContext(ctx, "Images found",
@@ -107,33 +100,43 @@ func (c *Client) ListRepositoryContents(ctx context.Context, repo name.Repositor
 	return images, nil
 }
 
-func (c *Client) GetImageIndex(ref name.Reference) (cranev1.ImageIndex, error) {
-	c.logger.Debug("GetImageIndex called", "image", ref.Name())
+func (c *Client) GetImageIndex(ctx context.Context, ref name.Reference) (cranev1.ImageIndex, error) {
+	c.logger.DebugContext(ctx, "GetImageIndex called", "image", ref.Name())
 
-	index, err := remote.Index(ref,
-		remote.WithAuthFromKeychain(authn.DefaultKeychain),
-		remote.WithTransport(c.transport),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("cannot fetch image index %q: %w", ref, err)
-	}
-	return index, nil
+	return tryWithMirrors(c, c.mirrorReferences(ref), func(r name.Reference) (cranev1.ImageIndex, error) {
+		index, err := remote.Index(r,
+			remote.WithContext(ctx),
+			remote.WithAuthFromKeychain(c.keychain),
+			remote.WithTransport(c.transport),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch image index %q: %w", r, err)
+		}
+		return index, nil
+	})
 }
 
-func (c *Client) GetImageDetails(ref name.Reference, platform *cranev1.Platform) (ImageDetails, error) {
-	c.logger.Debug("GetImageDetails called", "image", ref.Name(), "platform", platform)
+func (c *Client) GetImageDetails(ctx context.Context, ref name.Reference, platform *cranev1.Platform) (ImageDetails, error) {
+	c.logger.DebugContext(ctx, "GetImageDetails called", "image", ref.Name(), "platform", platform)
 
 	options := []remote.Option{
-		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(c.keychain),
 		remote.WithTransport(c.transport),
 	}
 	if platform != nil {
 		options = append(options, remote.WithPlatform(*platform))
 	}
 
-	img, err := remote.Image(ref, options...)
+	img, err := tryWithMirrors(c, c.mirrorReferences(ref), func(r name.Reference) (cranev1.Image, error) {
+		img, err := remote.Image(r, options...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch image %q: %w", r, err)
+		}
+		return img, nil
+	})
 	if err != nil {
-		return ImageDetails{}, fmt.Errorf("cannot fetch image %q: %w", ref, err)
+		return ImageDetails{}, err
 	}
 
 	imageDigest, err := img.Digest()
@@ -162,10 +165,72 @@ func (c *Client) GetImageDetails(ref name.Reference, platform *cranev1.Platform)
 		return ImageDetails{}, fmt.Errorf("cannot read layers for %s: %w", ref, err)
 	}
 
+	manifest, err := img.Manifest()
+	if err != nil {
+		return ImageDetails{}, fmt.Errorf("cannot read manifest for %s: %w", ref, err)
+	}
+
 	return ImageDetails{
-		History:  cfgFile.History,
-		Layers:   layers,
-		Platform: *platform,
-		Digest:   imageDigest,
+		History:     cfgFile.History,
+		Layers:      layers,
+		Platform:    *platform,
+		Digest:      imageDigest,
+		Annotations: manifest.Annotations,
 	}, nil
 }
+
+// catalog lists the repositories of the given registry.
+func (c *Client) catalog(ctx context.Context, registry name.Registry) ([]string, error) {
+	puller, err := remote.NewPuller(
+		remote.WithAuthFromKeychain(c.keychain),
+		remote.WithTransport(c.transport),
+	)
+	if err != nil {
+		return []string{}, fmt.Errorf("cannot create puller: %w", err)
+	}
+
+	catalogger, err := puller.Catalogger(ctx, registry)
+	if err != nil {
+		return []string{}, fmt.Errorf("cannot create catalogger for %s: %w", registry.Name(), err)
+	}
+
+	repositories := []string{}
+	for catalogger.HasNext() {
+		var repos *remote.Catalogs
+		repos, err = catalogger.Next(ctx)
+		if err != nil {
+			return []string{}, fmt.Errorf("cannot iterate over repository %s contents: %w", registry.Name(), err)
+		}
+		repositories = append(repositories, repos.Repos...)
+	}
+
+	return repositories, nil
+}
+
+// listTags lists the tags of the given repository.
+func (c *Client) listTags(ctx context.Context, repo name.Repository) ([]string, error) {
+	puller, err := remote.NewPuller(
+		remote.WithAuthFromKeychain(c.keychain),
+		remote.WithTransport(c.transport),
+	)
+	if err != nil {
+		return []string{}, fmt.Errorf("cannot create puller: %w", err)
+	}
+
+	lister, err := puller.Lister(ctx, repo)
+	if err != nil {
+		return []string{}, fmt.Errorf("cannot create lister for repository %s: %w", repo, err)
+	}
+
+	tags := []string{}
+	for lister.HasNext() {
+		var page *remote.Tags
+		page, err = lister.Next(ctx)
+		if err != nil {
+			return []string{}, fmt.Errorf("cannot iterate over repository contents: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+	}
+
+	return tags, nil
+}
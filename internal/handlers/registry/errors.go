@@ -0,0 +1,44 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+var (
+	// ErrAuthFailed is reported when the registry rejects the credentials, or when they cannot be obtained.
+	ErrAuthFailed = errors.New("registry authentication failed")
+	// ErrUnreachable is reported when no connection can be established with the registry.
+	ErrUnreachable = errors.New("registry unreachable")
+)
+
+// ClassifyError wraps the error of a registry request with ErrAuthFailed or ErrUnreachable,
+// when the request failed because of the credentials or because the registry could not be reached.
+// Other errors are returned unchanged.
+func ClassifyError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		if transportErr.StatusCode == http.StatusUnauthorized || transportErr.StatusCode == http.StatusForbidden {
+			return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+		}
+
+		return err
+	}
+
+	// Connection, DNS and TLS failures are reported by the HTTP client as net.Error.
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,70 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		expectedErr error
+	}{
+		{
+			name:        "unauthorized",
+			err:         fmt.Errorf("cannot list catalog: %w", &transport.Error{StatusCode: http.StatusUnauthorized}),
+			expectedErr: ErrAuthFailed,
+		},
+		{
+			name:        "forbidden",
+			err:         &transport.Error{StatusCode: http.StatusForbidden},
+			expectedErr: ErrAuthFailed,
+		},
+		{
+			name: "connection refused",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "https://registry.test.local/v2/",
+				Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			},
+			expectedErr: ErrUnreachable,
+		},
+		{
+			name:        "unknown host",
+			err:         fmt.Errorf("cannot ping registry: %w", &net.DNSError{Err: "no such host", Name: "registry.test.local"}),
+			expectedErr: ErrUnreachable,
+		},
+		{
+			name: "not found",
+			err:  &transport.Error{StatusCode: http.StatusNotFound},
+		},
+		{
+			name: "context canceled",
+			err:  &url.Error{Op: "Get", URL: "https://registry.test.local/v2/", Err: context.Canceled},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ClassifyError(test.err)
+			require.ErrorIs(t, err, test.err)
+
+			if test.expectedErr == nil {
+				assert.NotErrorIs(t, err, ErrAuthFailed)
+				assert.NotErrorIs(t, err, ErrUnreachable)
+				return
+			}
+			require.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestsTotal counts the HTTP requests sent to the registries.
+// Requests that did not get a response, e.g. because of a connection failure, are counted with the "error" code.
+var requestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "sbomscanner",
+		Subsystem: "registry",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests sent to the registries, per registry host, method and status code.",
+	},
+	[]string{"registry", "method", "code"},
+)
+
+// RegisterMetrics registers the metrics of the registry client with the given registerer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	if err := registerer.Register(requestsTotal); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			return nil
+		}
+		return fmt.Errorf("failed to register registry metrics: %w", err)
+	}
+
+	return nil
+}
+
+var tracer = otel.Tracer("github.com/kubewarden/sbomscanner/internal/handlers/registry")
+
+// instrumentedTransport is an http.RoundTripper counting and tracing the requests sent to the registries.
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), "registry "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLPath(req.URL.Path),
+		),
+	)
+	defer span.End()
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	} else {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	requestsTotal.WithLabelValues(req.URL.Host, req.Method, code).Inc()
+
+	return resp, err //nolint:wrapcheck // errors must be returned unwrapped by a RoundTripper
+}
@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentedTransport_RoundTrip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddress := closedListener.Addr().String()
+	require.NoError(t, closedListener.Close())
+
+	transport, err := NewTransport(false, nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: transport}
+
+	okBefore := testutil.ToFloat64(requestsTotal.WithLabelValues(serverURL.Host, http.MethodGet, "200"))
+	notFoundBefore := testutil.ToFloat64(requestsTotal.WithLabelValues(serverURL.Host, http.MethodGet, "404"))
+	errorBefore := testutil.ToFloat64(requestsTotal.WithLabelValues(closedAddress, http.MethodGet, "error"))
+
+	for _, path := range []string{"/v2/", "/v2/", "/v2/_catalog"} {
+		resp, err := client.Get(server.URL + path)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+	_, err = client.Get("http://" + closedAddress + "/v2/") //nolint:bodyclose // the request fails
+	require.Error(t, err)
+
+	assert.InDelta(t, 2, testutil.ToFloat64(requestsTotal.WithLabelValues(serverURL.Host, http.MethodGet, "200"))-okBefore, 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(requestsTotal.WithLabelValues(serverURL.Host, http.MethodGet, "404"))-notFoundBefore, 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(requestsTotal.WithLabelValues(closedAddress, http.MethodGet, "error"))-errorBefore, 0)
+}
@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// tryWithMirrors calls fn for each candidate, in order, until one of them succeeds.
+// The candidates are the targets rewritten for each mirror, followed by the upstream one.
+// The next candidate is tried only when the previous one is unavailable, see canFallBack:
+// the other errors, like the authentication ones, are returned right away.
+// If all the candidates are unavailable, the errors are joined together.
+func tryWithMirrors[K fmt.Stringer, T any](c *Client, candidates []K, fn func(K) (T, error)) (T, error) {
+	var errs []error
+	for i, candidate := range candidates {
+		result, err := fn(candidate)
+		if err != nil {
+			if !canFallBack(err) {
+				var zero T
+				return zero, err
+			}
+			if i < len(candidates)-1 {
+				c.logger.Debug("Cannot pull from mirror, trying the next one", "target", candidate.String(), "error", err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		if i < len(candidates)-1 {
+			c.logger.Debug("Pulled from mirror", "target", candidate.String())
+		}
+		return result, nil
+	}
+
+	var zero T
+	return zero, errors.Join(errs...)
+}
+
+// mergeFromMirrors calls fn for every candidate and merges the results, without duplicates,
+// so that a stale mirror does not hide the content available on the other candidates.
+// The unavailable candidates are skipped, see canFallBack, while the other errors are returned right away.
+// If all the candidates are unavailable, the errors are joined together.
+func mergeFromMirrors[K fmt.Stringer](c *Client, candidates []K, fn func(K) ([]string, error)) ([]string, error) {
+	var errs []error
+	var merged []string
+	seen := make(map[string]struct{})
+	for _, candidate := range candidates {
+		results, err := fn(candidate)
+		if err != nil {
+			if !canFallBack(err) {
+				return nil, err
+			}
+			c.logger.Debug("Cannot list from candidate, skipping it", "target", candidate.String(), "error", err)
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, result := range results {
+			if _, found := seen[result]; !found {
+				seen[result] = struct{}{}
+				merged = append(merged, result)
+			}
+		}
+	}
+
+	if len(errs) == len(candidates) {
+		return nil, errors.Join(errs...)
+	}
+
+	return merged, nil
+}
+
+// canFallBack tells if the request failed because the candidate is unavailable, so the next one can be tried:
+// the candidate cannot be reached, fails with a server error, throttles the requests,
+// or does not have the requested content, e.g. a mirror that is not a pull-through cache.
+// Authentication and authorization errors are not retried on the next candidates, which would hide them.
+func canFallBack(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) {
+		return transportErr.StatusCode >= http.StatusInternalServerError ||
+			transportErr.StatusCode == http.StatusNotFound ||
+			transportErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// mirrorReferences returns the given reference rewritten for each mirror, followed by the reference itself.
+func (c *Client) mirrorReferences(ref name.Reference) []name.Reference {
+	refs := []name.Reference{}
+	for _, mirror := range c.mirrors {
+		mirrorRef, err := name.ParseReference(rewriteToMirror(ref.Name(), ref.Context().RegistryStr(), mirror))
+		if err != nil {
+			c.logger.Warn("Cannot parse image reference for mirror, skipping it", "mirror", mirror, "image", ref.Name(), "error", err)
+			continue
+		}
+		refs = append(refs, mirrorRef)
+	}
+
+	return append(refs, ref)
+}
+
+// mirrorRepositories returns the given repository rewritten for each mirror, followed by the repository itself.
+func (c *Client) mirrorRepositories(repo name.Repository) []name.Repository {
+	repos := []name.Repository{}
+	for _, mirror := range c.mirrors {
+		mirrorRepo, err := name.NewRepository(rewriteToMirror(repo.Name(), repo.RegistryStr(), mirror))
+		if err != nil {
+			c.logger.Warn("Cannot parse repository for mirror, skipping it", "mirror", mirror, "repository", repo.Name(), "error", err)
+			continue
+		}
+		repos = append(repos, mirrorRepo)
+	}
+
+	return append(repos, repo)
+}
+
+// mirrorRegistries returns the registries of the mirrors, followed by the given registry.
+// Mirrors with a path prefix are skipped, since the catalog can only be listed at the registry root.
+func (c *Client) mirrorRegistries(registry name.Registry) []name.Registry {
+	registries := []name.Registry{}
+	for _, mirror := range c.mirrors {
+		if strings.Contains(mirror, "/") {
+			c.logger.Debug("Mirror has a path prefix, skipping it for catalog", "mirror", mirror)
+			continue
+		}
+		mirrorRegistry, err := name.NewRegistry(mirror)
+		if err != nil {
+			c.logger.Warn("Cannot parse mirror registry, skipping it", "mirror", mirror, "error", err)
+			continue
+		}
+		registries = append(registries, mirrorRegistry)
+	}
+
+	return append(registries, registry)
+}
+
+// rewriteToMirror replaces the registry of the given name with the mirror.
+func rewriteToMirror(fullName, registry, mirror string) string {
+	return strings.TrimSuffix(mirror, "/") + strings.TrimPrefix(fullName, registry)
+}
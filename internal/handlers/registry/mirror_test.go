@@ -0,0 +1,166 @@
+package registry
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+
+	server := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(server.Close)
+
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func pushRandomImage(t *testing.T, reference string) {
+	t.Helper()
+
+	ref, err := name.ParseReference(reference)
+	require.NoError(t, err)
+	img, err := random.Image(1024, 1)
+	require.NoError(t, err)
+	configFile, err := img.ConfigFile()
+	require.NoError(t, err)
+	configFile.OS = "linux"
+	configFile.Architecture = "amd64"
+	img, err = mutate.ConfigFile(img, configFile)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+}
+
+func TestClient_Mirrors(t *testing.T) {
+	upstream := newTestRegistry(t)
+	mirror := newTestRegistry(t)
+
+	// The image is available only on the mirror, under a path prefix.
+	pushRandomImage(t, mirror+"/upstream/repo:mirrored")
+	// The image is available only on upstream.
+	pushRandomImage(t, upstream+"/repo:upstream-only")
+
+	client := NewClient(http.DefaultTransport, authn.DefaultKeychain, slog.Default()).WithMirrors([]string{mirror + "/upstream"})
+
+	repo, err := name.NewRepository(upstream + "/repo")
+	require.NoError(t, err)
+
+	images, err := client.ListRepositoryContents(t.Context(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, []string{upstream + "/repo:mirrored", upstream + "/repo:upstream-only"}, images,
+		"the tags of the mirror and upstream must be merged")
+
+	ref, err := name.ParseReference(upstream + "/repo:mirrored")
+	require.NoError(t, err)
+	details, err := client.GetImageDetails(t.Context(), ref, nil)
+	require.NoError(t, err)
+	assert.Len(t, details.Layers, 1)
+
+	ref, err = name.ParseReference(upstream + "/repo:upstream-only")
+	require.NoError(t, err)
+	details, err = client.GetImageDetails(t.Context(), ref, nil)
+	require.NoError(t, err, "should fall back to upstream")
+	assert.Len(t, details.Layers, 1)
+}
+
+func TestClient_MirrorsAuthFailure(t *testing.T) {
+	upstream := newTestRegistry(t)
+	pushRandomImage(t, upstream+"/repo:latest")
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer mirror.Close()
+
+	client := NewClient(http.DefaultTransport, authn.DefaultKeychain, slog.Default()).
+		WithMirrors([]string{strings.TrimPrefix(mirror.URL, "http://")})
+
+	repo, err := name.NewRepository(upstream + "/repo")
+	require.NoError(t, err)
+	_, err = client.ListRepositoryContents(t.Context(), repo)
+	require.ErrorIs(t, ClassifyError(err), ErrAuthFailed, "authorization errors must not fall back to upstream")
+
+	ref, err := name.ParseReference(upstream + "/repo:latest")
+	require.NoError(t, err)
+	_, err = client.GetImageDetails(t.Context(), ref, nil)
+	require.ErrorIs(t, ClassifyError(err), ErrAuthFailed, "authorization errors must not fall back to upstream")
+}
+
+func TestClient_MirrorsUnavailable(t *testing.T) {
+	upstream := newTestRegistry(t)
+	pushRandomImage(t, upstream+"/repo:latest")
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer mirror.Close()
+
+	client := NewClient(http.DefaultTransport, authn.DefaultKeychain, slog.Default()).
+		WithMirrors([]string{strings.TrimPrefix(mirror.URL, "http://")})
+
+	repo, err := name.NewRepository(upstream + "/repo")
+	require.NoError(t, err)
+	images, err := client.ListRepositoryContents(t.Context(), repo)
+	require.NoError(t, err)
+	assert.Equal(t, []string{upstream + "/repo:latest"}, images)
+
+	ref, err := name.ParseReference(upstream + "/repo:latest")
+	require.NoError(t, err)
+	_, err = client.GetImageDetails(t.Context(), ref, nil)
+	require.NoError(t, err, "should fall back to upstream")
+}
+
+func TestClient_CatalogMirrors(t *testing.T) {
+	upstream := newTestRegistry(t)
+	mirror := newTestRegistry(t)
+
+	pushRandomImage(t, mirror+"/repo:latest")
+
+	client := NewClient(http.DefaultTransport, authn.DefaultKeychain, slog.Default()).WithMirrors([]string{mirror})
+
+	reg, err := name.NewRegistry(upstream)
+	require.NoError(t, err)
+
+	repositories, err := client.Catalog(t.Context(), reg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{upstream + "/repo"}, repositories)
+}
+
+func Test_rewriteToMirror(t *testing.T) {
+	tests := []struct {
+		fullName string
+		registry string
+		mirror   string
+		expected string
+	}{
+		{
+			fullName: "index.docker.io/library/nginx:latest",
+			registry: "index.docker.io",
+			mirror:   "mirror.example.com",
+			expected: "mirror.example.com/library/nginx:latest",
+		},
+		{
+			fullName: "ghcr.io/kubewarden/sbomscanner@sha256:abc",
+			registry: "ghcr.io",
+			mirror:   "mirror.example.com:5000/ghcr.io/",
+			expected: "mirror.example.com:5000/ghcr.io/kubewarden/sbomscanner@sha256:abc",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fullName, func(t *testing.T) {
+			assert.Equal(t, test.expected, rewriteToMirror(test.fullName, test.registry, test.mirror))
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+var (
+	// ErrTLSFailed is reported when the certificate of the registry cannot be verified,
+	// or when the registry does not talk TLS.
+	ErrTLSFailed = errors.New("registry TLS verification failed")
+	// ErrCatalogUnavailable is reported when the registry does not allow listing its catalog.
+	ErrCatalogUnavailable = errors.New("registry catalog unavailable")
+)
+
+// Probe checks that the registry can be reached and that it accepts the credentials of the client,
+// by sending an authenticated request to the /v2/ endpoint.
+// When listCatalog is true, it also checks that the first page of the catalog can be listed.
+// The mirrors of the client are not probed.
+//
+// The returned error wraps ErrTLSFailed, ErrUnreachable, ErrAuthFailed or ErrCatalogUnavailable
+// when the probe failed for one of these reasons.
+func (c *Client) Probe(ctx context.Context, registry name.Registry, listCatalog bool) error {
+	authenticator, err := c.keychain.Resolve(registry)
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve credentials: %w", ErrAuthFailed, err)
+	}
+
+	// Pinging the registry tells which authentication scheme it uses and,
+	// for token based authentication, exchanges the credentials for a token.
+	authTransport, err := transport.NewWithContext(ctx, registry, authenticator, c.transport, []string{})
+	if err != nil {
+		return classifyProbeError(fmt.Errorf("cannot ping registry %s: %w", registry.Name(), err))
+	}
+
+	url := fmt.Sprintf("%s://%s/v2/", registry.Scheme(), registry.RegistryStr())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("cannot create request: %w", err)
+	}
+	resp, err := (&http.Client{Transport: authTransport}).Do(req)
+	if err != nil {
+		return classifyProbeError(fmt.Errorf("cannot query registry %s: %w", registry.Name(), err))
+	}
+	defer resp.Body.Close()
+	if err = transport.CheckError(resp, http.StatusOK); err != nil {
+		return classifyProbeError(fmt.Errorf("cannot query registry %s: %w", registry.Name(), err))
+	}
+
+	if !listCatalog {
+		return nil
+	}
+
+	_, err = remote.CatalogPage(registry, "", 1,
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(c.keychain),
+		remote.WithTransport(c.transport),
+	)
+	if err != nil {
+		err = classifyProbeError(fmt.Errorf("cannot list catalog of registry %s: %w", registry.Name(), err))
+		if errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrUnreachable) || errors.Is(err, ErrTLSFailed) {
+			return err
+		}
+
+		return fmt.Errorf("%w: %w", ErrCatalogUnavailable, err)
+	}
+
+	return nil
+}
+
+// classifyProbeError wraps the error with ErrTLSFailed when the TLS handshake failed,
+// and classifies the other errors with ClassifyError.
+func classifyProbeError(err error) error {
+	var certificateErr *tls.CertificateVerificationError
+	var recordHeaderErr tls.RecordHeaderError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &certificateErr) || errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) {
+		return fmt.Errorf("%w: %w", ErrTLSFailed, err)
+	}
+
+	return ClassifyError(err)
+}
@@ -0,0 +1,138 @@
+package registry
+
+import (
+	"context"
+	"crypto/x509"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/require"
+)
+
+// staticKeychain resolves every registry to the same credentials.
+type staticKeychain struct {
+	username string
+	password string
+}
+
+func (k staticKeychain) Resolve(_ authn.Resource) (authn.Authenticator, error) {
+	return authn.FromConfig(authn.AuthConfig{Username: k.username, Password: k.password}), nil
+}
+
+func newProbeRegistryServer(t *testing.T, catalogStatus int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "password" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+		case "/v2/_catalog":
+			w.WriteHeader(catalogStatus)
+			if catalogStatus == http.StatusOK {
+				_, _ = w.Write([]byte(`{"repositories":["library/nginx"]}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newProbeClient returns a client sending all the requests to the given address.
+func newProbeClient(t *testing.T, address string, rootCAs *x509.CertPool, keychain authn.Keychain) *Client {
+	t.Helper()
+
+	rt, err := NewTransport(false, rootCAs)
+	require.NoError(t, err)
+	instrumented, ok := rt.(*instrumentedTransport)
+	require.True(t, ok)
+	httpTransport, ok := instrumented.base.(*http.Transport)
+	require.True(t, ok)
+	httpTransport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	return NewClient(rt, keychain, slog.Default())
+}
+
+func TestClient_Probe(t *testing.T) {
+	server := newProbeRegistryServer(t, http.StatusOK)
+	serverWithoutCatalog := newProbeRegistryServer(t, http.StatusNotFound)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddress := closedListener.Addr().String()
+	require.NoError(t, closedListener.Close())
+
+	validCredentials := staticKeychain{username: "user", password: "password"}
+
+	tests := []struct {
+		name        string
+		client      *Client
+		listCatalog bool
+		expectedErr error
+	}{
+		{
+			name:        "registry reachable with valid credentials",
+			client:      newProbeClient(t, server.Listener.Addr().String(), rootCAs, validCredentials),
+			listCatalog: true,
+		},
+		{
+			name:        "certificate signed by an unknown authority",
+			client:      newProbeClient(t, server.Listener.Addr().String(), x509.NewCertPool(), validCredentials),
+			expectedErr: ErrTLSFailed,
+		},
+		{
+			name:        "invalid credentials",
+			client:      newProbeClient(t, server.Listener.Addr().String(), rootCAs, staticKeychain{username: "user", password: "wrong"}),
+			expectedErr: ErrAuthFailed,
+		},
+		{
+			name:        "connection refused",
+			client:      newProbeClient(t, closedAddress, rootCAs, validCredentials),
+			expectedErr: ErrUnreachable,
+		},
+		{
+			name:        "catalog not available",
+			client:      newProbeClient(t, serverWithoutCatalog.Listener.Addr().String(), rootCAs, validCredentials),
+			listCatalog: true,
+			expectedErr: ErrCatalogUnavailable,
+		},
+		{
+			name:   "catalog not listed",
+			client: newProbeClient(t, serverWithoutCatalog.Listener.Addr().String(), rootCAs, validCredentials),
+		},
+	}
+
+	// The certificate of the test server is valid for example.com.
+	registry, err := name.NewRegistry("example.com")
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.client.Probe(t.Context(), registry, test.listCatalog)
+
+			if test.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxThrottledRetries is the maximum number of times a throttled request is retried.
+	maxThrottledRetries = 5
+	// baseThrottleDelay is the delay used when the registry throttles a request
+	// without telling how long to wait.
+	// Subsequent retries use an exponential backoff strategy based on this value.
+	baseThrottleDelay = 2 * time.Second
+	// maxThrottleDelay caps the delay requested by the registry,
+	// to avoid keeping the message in progress for too long.
+	maxThrottleDelay = 2 * time.Minute
+	// minRequestsPerSecond is the lower bound of the adaptive rate limit.
+	minRequestsPerSecond = 0.1
+
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+)
+
+// RateLimiter throttles the requests sent to a registry.
+// It honors the Retry-After and RateLimit-Remaining headers sent by the registry,
+// and adapts the request rate: every throttled response halves it, every successful response slowly restores it.
+// A RateLimiter is shared by all the requests sent by the worker to the same Registry,
+// see RateLimiters.
+type RateLimiter struct {
+	limiter           *rate.Limiter
+	maxLimit          rate.Limit
+	requestsPerSecond int
+	burst             int
+	mu                sync.Mutex
+	pausedUntil       time.Time
+	logger            *slog.Logger
+}
+
+// NewRateLimiter creates a new RateLimiter.
+// A requestsPerSecond value of 0 disables the client side throttling.
+// If burst is 0, it defaults to requestsPerSecond.
+func NewRateLimiter(requestsPerSecond, burst int, logger *slog.Logger) *RateLimiter {
+	limit := rate.Inf
+	if requestsPerSecond > 0 {
+		limit = rate.Limit(requestsPerSecond)
+	}
+	effectiveBurst := burst
+	if effectiveBurst <= 0 {
+		effectiveBurst = max(requestsPerSecond, 1)
+	}
+
+	return &RateLimiter{
+		limiter:           rate.NewLimiter(limit, effectiveBurst),
+		maxLimit:          limit,
+		requestsPerSecond: requestsPerSecond,
+		burst:             burst,
+		logger:            logger.With("component", "registry_rate_limiter"),
+	}
+}
+
+// Wait blocks until a request can be sent, according to both the request rate
+// and the pause requested by the registry.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck // wrapped by the caller
+		case <-timer.C:
+		}
+	}
+
+	return l.limiter.Wait(ctx) //nolint:wrapcheck // wrapped by the caller
+}
+
+// pause suspends all the requests for the given duration.
+func (l *RateLimiter) pause(delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until := time.Now().Add(delay); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// observeRateLimitHeaders pauses the requests when the registry reports that
+// no more requests are allowed in the current window.
+// When the registry does not tell when the window resets, as Docker Hub does,
+// the requests are paused for the window, or for baseThrottleDelay if the window is unknown.
+func (l *RateLimiter) observeRateLimitHeaders(resp *http.Response) {
+	remainingValue := resp.Header.Get(headerRateLimitRemaining)
+	remaining, ok := parseRateLimitValue(remainingValue)
+	if !ok || remaining > 0 {
+		return
+	}
+
+	delay, ok := retryAfter(resp.Header)
+	if reset, found := parseRateLimitValue(resp.Header.Get(headerRateLimitReset)); found && !ok {
+		delay, ok = time.Duration(reset)*time.Second, true
+	}
+	if !ok {
+		delay = baseThrottleDelay
+		if window, found := parseRateLimitWindow(remainingValue); found {
+			delay = window
+		}
+	}
+	if delay == 0 {
+		return
+	}
+	delay = min(delay, maxThrottleDelay)
+
+	l.logger.Info("Registry rate limit exhausted, pausing requests",
+		"host", resp.Request.URL.Host,
+		"delay", delay,
+	)
+	l.pause(delay)
+}
+
+// slowDown halves the request rate, down to minRequestsPerSecond.
+func (l *RateLimiter) slowDown() {
+	if l.maxLimit == rate.Inf {
+		return
+	}
+	l.limiter.SetLimit(max(l.limiter.Limit()/2, minRequestsPerSecond))
+}
+
+// speedUp increases the request rate by 10%, up to the configured rate.
+func (l *RateLimiter) speedUp() {
+	if l.maxLimit == rate.Inf || l.limiter.Limit() >= l.maxLimit {
+		return
+	}
+	l.limiter.SetLimit(min(l.limiter.Limit()*1.1, l.maxLimit))
+}
+
+// RateLimiters holds the RateLimiter of every Registry for the lifetime of the worker,
+// so that the rate limit applies to all the messages processed for the same Registry.
+type RateLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*RateLimiter
+	logger   *slog.Logger
+}
+
+// NewRateLimiters creates a new RateLimiters.
+func NewRateLimiters(logger *slog.Logger) *RateLimiters {
+	return &RateLimiters{
+		limiters: make(map[string]*RateLimiter),
+		logger:   logger,
+	}
+}
+
+// Get returns the RateLimiter of the Registry identified by the given key.
+// The RateLimiter is created on first use, and replaced when the rate limit of the Registry changes.
+func (r *RateLimiters) Get(key string, requestsPerSecond, burst int) *RateLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, found := r.limiters[key]
+	if !found || limiter.requestsPerSecond != requestsPerSecond || limiter.burst != burst {
+		limiter = NewRateLimiter(requestsPerSecond, burst, r.logger.With("registry", key))
+		r.limiters[key] = limiter
+	}
+
+	return limiter
+}
+
+// RateLimitedTransport is an http.RoundTripper sending the requests through a RateLimiter.
+// The requests rejected with 429 Too Many Requests are retried using an exponential backoff.
+// The throttled requests are counted per transport, while the RateLimiter can be shared.
+type RateLimitedTransport struct {
+	base      http.RoundTripper
+	limiter   *RateLimiter
+	throttled atomic.Int64
+}
+
+// NewRateLimitedTransport creates a new RateLimitedTransport wrapping the given transport.
+func NewRateLimitedTransport(base http.RoundTripper, limiter *RateLimiter) *RateLimitedTransport {
+	return &RateLimitedTransport{
+		base:    base,
+		limiter: limiter,
+	}
+}
+
+// ThrottledRequests returns the number of requests sent through the transport that were throttled by the registry.
+func (t *RateLimitedTransport) ThrottledRequests() int {
+	return int(t.throttled.Load())
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limiter wait failed: %w", err)
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // errors must be returned unwrapped by a RoundTripper
+		}
+
+		t.limiter.observeRateLimitHeaders(resp)
+
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.limiter.speedUp()
+			return resp, nil
+		}
+
+		t.throttled.Add(1)
+		t.limiter.slowDown()
+
+		if attempt >= maxThrottledRetries || !isReplayable(req) {
+			return resp, nil
+		}
+
+		delay, ok := retryAfter(resp.Header)
+		if !ok {
+			delay = baseThrottleDelay * time.Duration(math.Pow(2, float64(attempt)))
+		}
+		delay = min(delay, maxThrottleDelay)
+
+		t.limiter.logger.InfoContext(ctx, "Request throttled by registry, retrying after delay",
+			"host", req.URL.Host,
+			"path", req.URL.Path,
+			"attempt", attempt+1,
+			"delay", delay,
+		)
+
+		// Drain the body to allow the connection to be reused.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		t.limiter.pause(delay)
+
+		if req, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryAfter parses the Retry-After header, which can be either
+// a number of seconds or an HTTP date.
+// It returns false if the header is missing or malformed.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get(headerRetryAfter)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+// parseRateLimitValue parses the value of the RateLimit-* headers.
+// The value can contain parameters, e.g. "76;w=21600" as sent by Docker Hub.
+func parseRateLimitValue(value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	value, _, _ = strings.Cut(value, ";")
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, false
+	}
+
+	return number, true
+}
+
+// parseRateLimitWindow parses the window parameter of the value of the RateLimit-* headers,
+// e.g. 21600 seconds for "76;w=21600".
+func parseRateLimitWindow(value string) (time.Duration, bool) {
+	_, parameters, _ := strings.Cut(value, ";")
+	for parameter := range strings.SplitSeq(parameters, ";") {
+		key, window, found := strings.Cut(strings.TrimSpace(parameter), "=")
+		if !found || key != "w" {
+			continue
+		}
+		seconds, err := strconv.Atoi(window)
+		if err != nil || seconds <= 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	return 0, false
+}
+
+// isReplayable returns true if the request can be sent again.
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of the request with a fresh body, so it can be sent again.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("cannot rewind request body: %w", err)
+	}
+	newReq := req.Clone(req.Context())
+	newReq.Body = body
+
+	return newReq, nil
+}
@@ -0,0 +1,295 @@
+package registry
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedTransport_RetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := NewRateLimitedTransport(http.DefaultTransport, NewRateLimiter(0, 0, slog.Default()))
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/v2/", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Equal(t, 2, transport.ThrottledRequests())
+}
+
+func TestRateLimitedTransport_MaxRetriesExceeded(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	transport := NewRateLimitedTransport(http.DefaultTransport, NewRateLimiter(0, 0, slog.Default()))
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/v2/", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, int32(maxThrottledRetries+1), requests.Load())
+	assert.Equal(t, maxThrottledRetries+1, transport.ThrottledRequests())
+}
+
+func TestRateLimitedTransport_RateLimitRemaining(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("RateLimit-Remaining", "0;w=21600")
+		w.Header().Set("RateLimit-Reset", "1")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := NewRateLimitedTransport(http.DefaultTransport, NewRateLimiter(0, 0, slog.Default()))
+	client := &http.Client{Transport: transport}
+
+	for range 2 {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/v2/", nil)
+		require.NoError(t, err)
+		start := time.Now()
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			// The second request must wait for the rate limit window to reset.
+			assert.GreaterOrEqual(t, elapsed, 900*time.Millisecond)
+		}
+	}
+
+	assert.Equal(t, 0, transport.ThrottledRequests())
+}
+
+func TestRateLimiter_observeRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		headers       map[string]string
+		expectedDelay time.Duration
+	}{
+		{
+			name:          "requests remaining",
+			headers:       map[string]string{"RateLimit-Remaining": "76;w=21600"},
+			expectedDelay: 0,
+		},
+		{
+			name:          "reset",
+			headers:       map[string]string{"RateLimit-Remaining": "0", "RateLimit-Reset": "30"},
+			expectedDelay: 30 * time.Second,
+		},
+		{
+			name:          "retry after",
+			headers:       map[string]string{"RateLimit-Remaining": "0;w=21600", "Retry-After": "10"},
+			expectedDelay: 10 * time.Second,
+		},
+		{
+			name:          "window without reset",
+			headers:       map[string]string{"RateLimit-Remaining": "0;w=60"},
+			expectedDelay: 60 * time.Second,
+		},
+		{
+			name:          "window longer than the maximum delay",
+			headers:       map[string]string{"RateLimit-Remaining": "0;w=21600"},
+			expectedDelay: maxThrottleDelay,
+		},
+		{
+			name:          "no window and no reset",
+			headers:       map[string]string{"RateLimit-Remaining": "0"},
+			expectedDelay: baseThrottleDelay,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := NewRateLimiter(0, 0, slog.Default())
+			resp := &http.Response{
+				Header:  http.Header{},
+				Request: httptest.NewRequest(http.MethodGet, "https://registry.test/v2/", nil),
+			}
+			for key, value := range test.headers {
+				resp.Header.Set(key, value)
+			}
+
+			start := time.Now()
+			limiter.observeRateLimitHeaders(resp)
+
+			if test.expectedDelay == 0 {
+				assert.True(t, limiter.pausedUntil.IsZero())
+				return
+			}
+			assert.WithinDuration(t, start.Add(test.expectedDelay), limiter.pausedUntil, time.Second)
+		})
+	}
+}
+
+func Test_parseRateLimitWindow(t *testing.T) {
+	tests := []struct {
+		value         string
+		expected      time.Duration
+		expectedFound bool
+	}{
+		{value: "", expected: 0, expectedFound: false},
+		{value: "0", expected: 0, expectedFound: false},
+		{value: "0;w=21600", expected: 21600 * time.Second, expectedFound: true},
+		{value: "0; w=60", expected: 60 * time.Second, expectedFound: true},
+		{value: "0;w=invalid", expected: 0, expectedFound: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			window, found := parseRateLimitWindow(test.value)
+			assert.Equal(t, test.expected, window)
+			assert.Equal(t, test.expectedFound, found)
+		})
+	}
+}
+
+func TestRateLimitedTransport_RequestsPerSecond(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := NewRateLimitedTransport(http.DefaultTransport, NewRateLimiter(10, 1, slog.Default()))
+	client := &http.Client{Transport: transport}
+
+	start := time.Now()
+	for range 5 {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/v2/", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// The first request consumes the burst, the other 4 are spaced by 100ms.
+	assert.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+}
+
+func TestRateLimiters_Get(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	rateLimiters := NewRateLimiters(slog.Default())
+	limiter := rateLimiters.Get("default/registry", 10, 1)
+	assert.Same(t, limiter, rateLimiters.Get("default/registry", 10, 1))
+	assert.NotSame(t, limiter, rateLimiters.Get("other/registry", 10, 1))
+
+	// The transports created for different messages share the rate limit of the registry.
+	start := time.Now()
+	for range 5 {
+		client := &http.Client{Transport: NewRateLimitedTransport(http.DefaultTransport, rateLimiters.Get("default/registry", 10, 1))}
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/v2/", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+
+	// The rate limiter is replaced when the rate limit of the registry changes.
+	assert.NotSame(t, limiter, rateLimiters.Get("default/registry", 20, 1))
+}
+
+func Test_retryAfter(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      time.Duration
+		expectedFound bool
+	}{
+		{
+			name:          "missing header",
+			value:         "",
+			expected:      0,
+			expectedFound: false,
+		},
+		{
+			name:          "seconds",
+			value:         "30",
+			expected:      30 * time.Second,
+			expectedFound: true,
+		},
+		{
+			name:          "zero seconds",
+			value:         "0",
+			expected:      0,
+			expectedFound: true,
+		},
+		{
+			name:          "malformed",
+			value:         "soon",
+			expected:      0,
+			expectedFound: false,
+		},
+		{
+			name:          "date in the past",
+			value:         "Wed, 21 Oct 2015 07:28:00 GMT",
+			expected:      0,
+			expectedFound: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.value != "" {
+				header.Set("Retry-After", test.value)
+			}
+			delay, found := retryAfter(header)
+			assert.Equal(t, test.expected, delay)
+			assert.Equal(t, test.expectedFound, found)
+		})
+	}
+}
+
+func Test_parseRateLimitValue(t *testing.T) {
+	tests := []struct {
+		value         string
+		expected      int
+		expectedFound bool
+	}{
+		{value: "", expected: 0, expectedFound: false},
+		{value: "76", expected: 76, expectedFound: true},
+		{value: "76;w=21600", expected: 76, expectedFound: true},
+		{value: "invalid", expected: 0, expectedFound: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			value, found := parseRateLimitValue(test.value)
+			assert.Equal(t, test.expected, value)
+			assert.Equal(t, test.expectedFound, found)
+		})
+	}
+}
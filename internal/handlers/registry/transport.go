@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// ErrInvalidCABundle is returned when the CA bundle of a registry does not contain any PEM encoded certificate.
+var ErrInvalidCABundle = errors.New("the CA bundle does not contain any valid PEM encoded certificate")
+
+// RootCAs returns the system certificate pool extended with the certificates of the given CA bundle.
+// It returns nil when the CA bundle is empty, so that the system certificates are used.
+func RootCAs(caBundle string) (*x509.CertPool, error) {
+	if caBundle == "" {
+		return nil, nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, ErrInvalidCABundle
+	}
+
+	return rootCAs, nil
+}
+
+// NewTransport creates a new http.RoundTripper trusting the given root CAs, or the system ones when nil.
+// When insecure is true, the certificate of the registry is not verified.
+// The requests sent through the transport are counted in the registry metrics and traced.
+func NewTransport(insecure bool, rootCAs *x509.CertPool) (http.RoundTripper, error) {
+	transport, ok := remote.DefaultTransport.(*http.Transport)
+	if !ok {
+		// should not happen
+		return nil, errors.New("remote.DefaultTransport is not an *http.Transport")
+	}
+	transport = transport.Clone()
+
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: insecure, //nolint:gosec // this a user provided option
+		RootCAs:            rootCAs,
+	}
+
+	return &instrumentedTransport{base: transport}, nil
+}
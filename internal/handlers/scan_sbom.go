@@ -4,24 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
-	"os"
-	"path"
-
-	"go.yaml.in/yaml/v3"
-	_ "modernc.org/sqlite" // sqlite driver for RPM DB and Java DB
+	"slices"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-
-	vexrepo "github.com/aquasecurity/trivy/pkg/vex/repo"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
-	trivyCommands "github.com/aquasecurity/trivy/pkg/commands"
-	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
 	"github.com/kubewarden/sbomscanner/api"
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
@@ -29,39 +22,26 @@ import (
 	"github.com/kubewarden/sbomscanner/internal/messaging"
 )
 
-const (
-	// trivyVEXSubPath is the directory used by trivy to hold VEX repositories.
-	trivyVEXSubPath = ".trivy/vex"
-	// trivyVEXRepoFile is the file used by trivy to hold VEX repositories.
-	trivyVEXRepoFile = "repository.yaml"
-)
-
 // ScanSBOMHandler is responsible for handling SBOM scan requests.
 type ScanSBOMHandler struct {
-	k8sClient             client.Client
-	scheme                *runtime.Scheme
-	workDir               string
-	trivyDBRepository     string
-	trivyJavaDBRepository string
-	logger                *slog.Logger
+	k8sClient client.Client
+	scheme    *runtime.Scheme
+	scanner   Scanner
+	logger    *slog.Logger
 }
 
 // NewScanSBOMHandler creates a new instance of ScanSBOMHandler.
 func NewScanSBOMHandler(
 	k8sClient client.Client,
 	scheme *runtime.Scheme,
-	workDir string,
-	trivyDBRepository string,
-	trivyJavaDBRepository string,
+	scanner Scanner,
 	logger *slog.Logger,
 ) *ScanSBOMHandler {
 	return &ScanSBOMHandler{
-		k8sClient:             k8sClient,
-		scheme:                scheme,
-		workDir:               workDir,
-		trivyDBRepository:     trivyDBRepository,
-		trivyJavaDBRepository: trivyJavaDBRepository,
-		logger:                logger.With("handler", "scan_sbom_handler"),
+		k8sClient: k8sClient,
+		scheme:    scheme,
+		scanner:   scanner,
+		logger:    logger.With("handler", "scan_sbom_handler"),
 	}
 }
 
@@ -69,7 +49,7 @@ func NewScanSBOMHandler(
 func (h *ScanSBOMHandler) Handle(ctx context.Context, message messaging.Message) error { //nolint:funlen,gocognit
 	scanSBOMMessage := &ScanSBOMMessage{}
 	if err := json.Unmarshal(message.Data(), scanSBOMMessage); err != nil {
-		return fmt.Errorf("failed to unmarshal scan job message: %w", err)
+		return messaging.NewPermanentError(fmt.Errorf("failed to unmarshal scan job message: %w", err))
 	}
 
 	h.logger.InfoContext(ctx, "SBOM scan requested",
@@ -96,6 +76,12 @@ func (h *ScanSBOMHandler) Handle(ctx context.Context, message messaging.Message)
 		return nil
 	}
 
+	if scanSBOMMessage.IsStale(scanJob) {
+		h.logger.InfoContext(ctx, "Message published for an earlier generation of the ScanJob, stopping SBOM scan", "scanjob", scanJob.Name, "namespace", scanJob.Namespace,
+			"generation", scanSBOMMessage.Generation, "currentGeneration", scanJob.Generation)
+		return nil
+	}
+
 	h.logger.DebugContext(ctx, "ScanJob found", "scanjob", scanJob)
 
 	if scanJob.IsFailed() {
@@ -103,6 +89,11 @@ func (h *ScanSBOMHandler) Handle(ctx context.Context, message messaging.Message)
 		return nil
 	}
 
+	if scanJob.IsStopRequested() {
+		h.logger.InfoContext(ctx, "ScanJob is cancelled or suspended, stopping SBOM scan", "scanjob", scanJob.Name, "namespace", scanJob.Namespace)
+		return nil
+	}
+
 	sbom := &storagev1alpha1.SBOM{}
 	err = h.k8sClient.Get(ctx, client.ObjectKey{
 		Name:      scanSBOMMessage.SBOM.Name,
@@ -123,118 +114,48 @@ func (h *ScanSBOMHandler) Handle(ctx context.Context, message messaging.Message)
 		return fmt.Errorf("failed to list VEXHub: %w", err)
 	}
 
-	sbomFile, err := os.CreateTemp(h.workDir, "trivy.sbom.*.json")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary SBOM file: %w", err)
-	}
-	defer func() {
-		if err = sbomFile.Close(); err != nil {
-			h.logger.Error("failed to close temporary SBOM file", "error", err)
-		}
-
-		if err = os.Remove(sbomFile.Name()); err != nil {
-			h.logger.Error("failed to remove temporary SBOM file", "error", err)
-		}
-	}()
-
-	_, err = sbomFile.Write(sbom.SPDX.Raw)
-	if err != nil {
-		return fmt.Errorf("failed to write SBOM file: %w", err)
-	}
-	reportFile, err := os.CreateTemp(h.workDir, "trivy.report.*.json")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary report file: %w", err)
-	}
-	defer func() {
-		if err = reportFile.Close(); err != nil {
-			h.logger.Error("failed to close temporary report file", "error", err)
-		}
-
-		if err = os.Remove(reportFile.Name()); err != nil {
-			h.logger.Error("failed to remove temporary repoort file", "error", err)
-		}
-	}()
-
-	trivyArgs := []string{
-		"sbom",
-		"--skip-version-check",
-		"--disable-telemetry",
-		"--cache-dir", h.workDir,
-		"--format", "json",
-		"--db-repository", h.trivyDBRepository,
-		"--java-db-repository", h.trivyJavaDBRepository,
-		"--output", reportFile.Name(),
-	}
-	// Set XDG_DATA_HOME environment variable to /tmp because trivy expects
-	// the repository file in that location and there is no way to change it
-	// through input flags:
-	// https://trivy.dev/v0.64/docs/supply-chain/vex/repo/#default-configuration
-	// TODO(alegrey91): fix upstream
-	trivyHome, err := os.MkdirTemp("/tmp", "trivy-")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary trivy home: %w", err)
-	}
-	err = os.Setenv("XDG_DATA_HOME", trivyHome)
+	results, database, err := h.scanner.Scan(ctx, sbom.SPDX.Raw, vexHubList.Items)
 	if err != nil {
-		return fmt.Errorf("failed to set XDG_DATA_HOME to %s: %w", trivyHome, err)
-	}
-
-	if len(vexHubList.Items) > 0 {
-		trivyVEXPath := path.Join(trivyHome, trivyVEXSubPath)
-		vexRepoPath := path.Join(trivyVEXPath, trivyVEXRepoFile)
-		if err = h.setupVEXHubRepositories(vexHubList, trivyVEXPath, vexRepoPath); err != nil {
-			return fmt.Errorf("failed to setup VEX Hub repositories: %w", err)
-		}
-		// Clean up the trivy home directory after each handler execution to
-		// ensure VEX repositories are refreshed on every run.
-		defer func() {
-			h.logger.Debug("Removing trivy home")
-			if err = os.RemoveAll(trivyHome); err != nil {
-				h.logger.Error("failed to remove temporary trivy home", "error", err)
-			}
-		}()
-
-		// We explicitly set the `--vex` option only when needed
-		// (VEXHub resources are found). This is because trivy automatically
-		// fills the repository file with aquasecurity VEX files, when
-		// `--vex` is specificed.
-		trivyArgs = append(trivyArgs, "--vex", "repo", "--show-suppressed")
-	}
-
-	app := trivyCommands.NewApp()
-	// add SBOM file name at the end.
-	trivyArgs = append(trivyArgs, sbomFile.Name())
-	app.SetArgs(trivyArgs)
-
-	if err = app.ExecuteContext(ctx); err != nil {
-		return fmt.Errorf("failed to execute trivy: %w", err)
+		return fmt.Errorf("failed to scan SBOM: %w", err)
 	}
 
 	h.logger.InfoContext(ctx, "SBOM scanned",
 		"sbom", scanSBOMMessage.SBOM.Name,
 		"namespace", scanSBOMMessage.SBOM.Namespace,
+		"scanner", h.scanner.Name(),
 	)
 
 	if err = message.InProgress(); err != nil {
 		return fmt.Errorf("failed to ack message as in progress: %w", err)
 	}
 
-	reportBytes, err := io.ReadAll(reportFile)
-	if err != nil {
-		return fmt.Errorf("failed to read SBOM output: %w", err)
-	}
+	scannedAt := metav1.Now()
+	summary := vulnReport.ComputeSummary(results)
 
-	reportOrig := trivyTypes.Report{}
-	err = json.Unmarshal(reportBytes, &reportOrig)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal report: %w", err)
-	}
+	imageMetadata := sbom.GetImageMetadata()
 
-	results, err := vulnReport.NewFromTrivyResults(reportOrig)
-	if err != nil {
-		return fmt.Errorf("failed to convert from trivy results: %w", err)
+	// The SBOM is named after the image it was generated from.
+	var layers []storagev1alpha1.LayerReport
+	var baseImageUpgrades []storagev1alpha1.BaseImageUpgrade
+	image := &storagev1alpha1.Image{}
+	err = h.k8sClient.Get(ctx, client.ObjectKey{
+		Name:      sbom.Name,
+		Namespace: sbom.Namespace,
+	}, image)
+	switch {
+	case err == nil:
+		// The base image is detected after the SBOM is generated, when the base image is cataloged later.
+		imageMetadata.BaseImage = image.BaseImage
+		layers = vulnReport.ComputeLayers(results, image.Layers)
+		baseImageUpgrades, err = h.baseImageUpgrades(ctx, image, layers)
+		if err != nil {
+			return err
+		}
+	case apierrors.IsNotFound(err):
+		h.logger.InfoContext(ctx, "Image not found, vulnerabilities not grouped by layer", "image", sbom.Name, "namespace", sbom.Namespace)
+	default:
+		return fmt.Errorf("failed to get Image: %w", err)
 	}
-	summary := vulnReport.ComputeSummary(results)
 
 	vulnerabilityReport := &storagev1alpha1.VulnerabilityReport{
 		ObjectMeta: metav1.ObjectMeta{
@@ -246,58 +167,189 @@ func (h *ScanSBOMHandler) Handle(ctx context.Context, message messaging.Message)
 		return fmt.Errorf("failed to set owner reference: %w", err)
 	}
 
-	_, err = controllerutil.CreateOrUpdate(ctx, h.k8sClient, vulnerabilityReport, func() error {
-		vulnerabilityReport.Labels = map[string]string{
-			v1alpha1.LabelScanJobUIDKey: string(scanJob.UID),
-			api.LabelManagedByKey:       api.LabelManagedByValue,
-			api.LabelPartOfKey:          api.LabelPartOfValue,
-		}
+	err = traceStorageWrite(ctx, "createOrUpdate", vulnerabilityReport, func(ctx context.Context) error {
+		_, err := controllerutil.CreateOrUpdate(ctx, h.k8sClient, vulnerabilityReport, func() error {
+			// Reports are named after the image digest: the first report of a new digest
+			// follows the report of the previous digest of the tag.
+			previousReport := vulnerabilityReport
+			if vulnerabilityReport.ResourceVersion == "" {
+				var err error
+				previousReport, err = h.previousTagReport(ctx, vulnerabilityReport, imageMetadata, string(scanJob.UID))
+				if err != nil {
+					return err
+				}
+			}
 
-		vulnerabilityReport.ImageMetadata = sbom.GetImageMetadata()
-		vulnerabilityReport.Report = storagev1alpha1.Report{
-			Summary: summary,
-			Results: results,
-		}
-		return nil
+			var previousResults []storagev1alpha1.Result
+			if previousReport != nil {
+				previousResults = previousReport.Report.Results
+			}
+			vulnReport.TrackFirstSeen(results, previousResults, scannedAt)
+			delta := computeReportDelta(previousReport, string(scanJob.UID), results)
+
+			vulnerabilityReport.Labels = map[string]string{
+				v1alpha1.LabelScanJobUIDKey: string(scanJob.UID),
+				api.LabelManagedByKey:       api.LabelManagedByValue,
+				api.LabelPartOfKey:          api.LabelPartOfValue,
+			}
+
+			vulnerabilityReport.ImageMetadata = imageMetadata
+			vulnerabilityReport.Report = storagev1alpha1.Report{
+				Scanner:           h.scanner.Name(),
+				Database:          database,
+				Summary:           summary,
+				Results:           results,
+				Layers:            layers,
+				BaseImageUpgrades: baseImageUpgrades,
+				Delta:             delta,
+				ScannedAt:         &scannedAt,
+			}
+			return nil
+		})
+		return err //nolint:wrapcheck // wrapped by the caller
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create or update vulnerability report: %w", err)
 	}
 
+	return h.clearFailedImage(ctx, scanJob, imageMetadata)
+}
+
+// clearFailedImage removes the image from the failed images of the ScanJob once it is scanned,
+// e.g. when its dead-lettered message is replayed, and completes the ScanJob again with the remaining failures.
+// Only the failed images listed in the ScanJob status are cleared.
+func (h *ScanSBOMHandler) clearFailedImage(ctx context.Context, scanJob *v1alpha1.ScanJob, imageMetadata storagev1alpha1.ImageMetadata) error {
+	image := imageReference(imageMetadata)
+	if !slices.ContainsFunc(scanJob.Status.FailedImages, func(failedImage v1alpha1.FailedImage) bool {
+		return failedImage.Image == image && failedImage.Platform == imageMetadata.Platform
+	}) {
+		return nil
+	}
+
+	var cleared bool
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := h.k8sClient.Get(ctx, client.ObjectKeyFromObject(scanJob), scanJob); err != nil {
+			return fmt.Errorf("cannot get ScanJob %s/%s: %w", scanJob.Namespace, scanJob.Name, err)
+		}
+		cleared = scanJob.RemoveFailedImage(image, imageMetadata.Platform)
+		if !cleared {
+			return nil
+		}
+		if scanJob.IsComplete() {
+			scanJob.MarkImagesProcessed()
+		}
+
+		return h.k8sClient.Status().Update(ctx, scanJob)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear failed image %s of ScanJob %s/%s: %w", image, scanJob.Namespace, scanJob.Name, err)
+	}
+	if !cleared {
+		return nil
+	}
+
+	h.logger.InfoContext(ctx, "Failed image scanned, cleared from the ScanJob",
+		"scanjob", scanJob.Name,
+		"namespace", scanJob.Namespace,
+		"image", image,
+		"failedImagesCount", scanJob.Status.FailedImagesCount,
+	)
+
 	return nil
 }
 
-// setupVEXHubRepositories creates all the necessary files and directories
-// to use VEX Hub repositories.
-func (h *ScanSBOMHandler) setupVEXHubRepositories(vexHubList *v1alpha1.VEXHubList, trivyVEXPath, vexRepoPath string) error {
-	config := vexrepo.Config{}
-	var err error
-	for _, repo := range vexHubList.Items {
-		repo := vexrepo.Repository{
-			Name:    repo.Name,
-			URL:     repo.Spec.URL,
-			Enabled: repo.Spec.Enabled,
+// computeReportDelta computes the changes of the vulnerabilities since the previous scan,
+// from the current content of the vulnerability report, or from the report of the previous digest of the tag.
+// There is no delta on the first scan of the tag.
+// When the report was already written by the same ScanJob, e.g. when the message is redelivered,
+// the delta against the scan preceding the ScanJob is kept.
+func computeReportDelta(
+	previousReport *storagev1alpha1.VulnerabilityReport,
+	scanJobUID string,
+	results []storagev1alpha1.Result,
+) *storagev1alpha1.ReportDelta {
+	if previousReport == nil || previousReport.ResourceVersion == "" {
+		return nil
+	}
+
+	previousScanJobUID := previousReport.Labels[v1alpha1.LabelScanJobUIDKey]
+	if previousScanJobUID == scanJobUID {
+		return previousReport.Report.Delta
+	}
+
+	delta := vulnReport.ComputeDelta(previousReport.Report.Results, results)
+	delta.PreviousScanJobUID = previousScanJobUID
+
+	return delta
+}
+
+// previousTagReport returns the most recently scanned report of another digest of the same tag and platform,
+// or nil when the tag was never scanned. Images referenced by digest only have no previous report.
+// The reports written by the given ScanJob are ignored, as they are not a previous scan of the tag.
+func (h *ScanSBOMHandler) previousTagReport(
+	ctx context.Context,
+	vulnerabilityReport *storagev1alpha1.VulnerabilityReport,
+	imageMetadata storagev1alpha1.ImageMetadata,
+	scanJobUID string,
+) (*storagev1alpha1.VulnerabilityReport, error) {
+	if imageMetadata.Tag == "" {
+		return nil, nil
+	}
+
+	tagReports := &storagev1alpha1.VulnerabilityReportList{}
+	if err := h.k8sClient.List(ctx, tagReports,
+		client.InNamespace(vulnerabilityReport.Namespace),
+		client.MatchingFields{
+			storagev1alpha1.IndexImageMetadataRegistry:   imageMetadata.Registry,
+			storagev1alpha1.IndexImageMetadataRepository: imageMetadata.Repository,
+			storagev1alpha1.IndexImageMetadataTag:        imageMetadata.Tag,
+			storagev1alpha1.IndexImageMetadataPlatform:   imageMetadata.Platform,
+		},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list VulnerabilityReports of tag %s:%s: %w", imageMetadata.Repository, imageMetadata.Tag, err)
+	}
+
+	var previousReport *storagev1alpha1.VulnerabilityReport
+	for i := range tagReports.Items {
+		tagReport := &tagReports.Items[i]
+		if tagReport.Name == vulnerabilityReport.Name || tagReport.Labels[v1alpha1.LabelScanJobUIDKey] == scanJobUID {
+			continue
+		}
+		if previousReport == nil || reportScannedAt(tagReport).After(reportScannedAt(previousReport)) {
+			previousReport = tagReport
 		}
-		config.Repositories = append(config.Repositories, repo)
 	}
 
-	var repositories []byte
-	repositories, err = yaml.Marshal(config)
-	if err != nil {
-		return fmt.Errorf("failed to marshal struct: %w", err)
+	return previousReport, nil
+}
+
+// reportScannedAt returns when the report was last scanned.
+// Reports written before the scan time was recorded are dated by their creation.
+func reportScannedAt(vulnerabilityReport *storagev1alpha1.VulnerabilityReport) time.Time {
+	if vulnerabilityReport.Report.ScannedAt != nil {
+		return vulnerabilityReport.Report.ScannedAt.Time
 	}
+	return vulnerabilityReport.CreationTimestamp.Time
+}
 
-	h.logger.Debug("Creating VEX repository directory", "vexhub", trivyVEXPath)
-	err = os.MkdirAll(trivyVEXPath, 0o750)
-	if err != nil {
-		return fmt.Errorf("failed to create VEX configuration directory: %w", err)
+// baseImageUpgrades lists the newer tags of the base image repository of the image, found in the catalog,
+// fixing CVEs of the base image layers.
+func (h *ScanSBOMHandler) baseImageUpgrades(
+	ctx context.Context,
+	image *storagev1alpha1.Image,
+	layers []storagev1alpha1.LayerReport,
+) ([]storagev1alpha1.BaseImageUpgrade, error) {
+	if image.BaseImage == nil || image.BaseImage.Tag == "" {
+		return nil, nil
 	}
 
-	h.logger.Debug("Creating VEX repository file", "vexhub", vexRepoPath)
-	err = os.WriteFile(vexRepoPath, repositories, 0o600)
-	if err != nil {
-		return fmt.Errorf("failed to create VEX repository file: %w", err)
+	baseImageReports := &storagev1alpha1.VulnerabilityReportList{}
+	if err := h.k8sClient.List(ctx, baseImageReports,
+		client.InNamespace(image.Namespace),
+		client.MatchingFields{storagev1alpha1.IndexImageMetadataRepository: image.BaseImage.Repository},
+	); err != nil {
+		return nil, fmt.Errorf("failed to list VulnerabilityReports of base image repository %s: %w", image.BaseImage.Repository, err)
 	}
 
-	return nil
+	return vulnReport.ComputeBaseImageUpgrades(*image.BaseImage, image.Platform, layers, baseImageReports.Items), nil
 }
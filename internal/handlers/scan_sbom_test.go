@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"log/slog"
 	"net"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
@@ -17,6 +19,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -164,7 +167,7 @@ func testScanSBOM(t *testing.T, cacheDir, platform, sourceSBOMJSON, expectedRepo
 	err = json.Unmarshal(reportData, expectedReport)
 	require.NoError(t, err, "failed to unmarshal expected report file %s", expectedReportJSON)
 
-	handler := NewScanSBOMHandler(k8sClient, scheme, cacheDir, testTrivyDBRepository, testTrivyJavaDBRepository, slog.Default())
+	handler := NewScanSBOMHandler(k8sClient, scheme, newTestTrivyEngine(t, k8sClient, cacheDir), slog.Default())
 
 	message, err := json.Marshal(&ScanSBOMMessage{
 		BaseMessage: BaseMessage{
@@ -198,9 +201,27 @@ func testScanSBOM(t *testing.T, cacheDir, platform, sourceSBOMJSON, expectedRepo
 	report := &vulnerabilityReport.Report
 	require.NotEmpty(t, report)
 
+	// the database depends on the latest test trivy-db
+	require.NotNil(t, report.Database)
+	assert.Equal(t, "2", report.Database.Version)
+	assert.NotEmpty(t, report.Database.Digest)
+	report.Database = nil
+
 	// override report field since trivy uses the sbom name as Target,
 	// which changes at every test run.
 	report.Results[0].Target = expectedReport.Results[0].Target
+
+	// the vulnerabilities are first seen at the time of the scan
+	assert.Nil(t, report.Delta, "the first scan of an image must have no delta")
+	require.NotNil(t, report.ScannedAt)
+	for i := range report.Results {
+		for j := range report.Results[i].Vulnerabilities {
+			require.NotNil(t, report.Results[i].Vulnerabilities[j].FirstSeen)
+			assert.True(t, report.ScannedAt.Equal(report.Results[i].Vulnerabilities[j].FirstSeen))
+			report.Results[i].Vulnerabilities[j].FirstSeen = nil
+		}
+	}
+	report.ScannedAt = nil
 	assert.Equal(t, expectedReport, report)
 }
 
@@ -213,6 +234,247 @@ func fakeVEXHubRepository(t *testing.T) *httptest.Server {
 	return server
 }
 
+// fakeScanner is a Scanner returning the same results for every SBOM.
+type fakeScanner struct {
+	results []storagev1alpha1.Result
+}
+
+func (s *fakeScanner) Name() string {
+	return "fake"
+}
+
+func (s *fakeScanner) Scan(_ context.Context, _ []byte, _ []v1alpha1.VEXHub) ([]storagev1alpha1.Result, *storagev1alpha1.VulnerabilityDatabase, error) {
+	return s.results, nil, nil
+}
+
+func TestScanSBOMHandler_Handle_ImageLayers(t *testing.T) {
+	scanJob := &v1alpha1.ScanJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-scanjob",
+			Namespace: "default",
+			UID:       "test-scanjob-uid",
+		},
+	}
+	image := &storagev1alpha1.Image{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-image",
+			Namespace: "default",
+		},
+		Layers: []storagev1alpha1.ImageLayer{
+			{DiffID: "sha256:base", Command: base64.StdEncoding.EncodeToString([]byte("ADD rootfs.tar.gz /")), BaseImage: true},
+			{DiffID: "sha256:app", Command: base64.StdEncoding.EncodeToString([]byte("RUN apk add curl"))},
+		},
+	}
+	sbom := &storagev1alpha1.SBOM{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      image.Name,
+			Namespace: image.Namespace,
+		},
+	}
+	imageWithBaseImage := image.DeepCopy()
+	imageWithBaseImage.BaseImage = &storagev1alpha1.BaseImage{
+		RegistryURI: "index.docker.io",
+		Repository:  "library/alpine",
+		Tag:         "3.19",
+		Source:      storagev1alpha1.BaseImageSourceAnnotations,
+	}
+	baseImageReport := &storagev1alpha1.VulnerabilityReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "alpine-3.20",
+			Namespace: "default",
+		},
+		ImageMetadata: storagev1alpha1.ImageMetadata{
+			RegistryURI: "index.docker.io",
+			Repository:  "library/alpine",
+			Tag:         "3.20",
+			Digest:      "sha256:alpine-3.20",
+		},
+	}
+	scanner := &fakeScanner{results: []storagev1alpha1.Result{{
+		Vulnerabilities: []storagev1alpha1.Vulnerability{
+			{CVE: "CVE-2024-0001", Severity: "CRITICAL", DiffID: "sha256:base"},
+			{CVE: "CVE-2024-0002", Severity: "LOW", DiffID: "sha256:app"},
+		},
+	}}}
+
+	expectedLayers := []storagev1alpha1.LayerReport{
+		{
+			DiffID:    "sha256:base",
+			Command:   "ADD rootfs.tar.gz /",
+			BaseImage: true,
+			Summary:   storagev1alpha1.Summary{Critical: 1},
+			CVEs:      []string{"CVE-2024-0001"},
+		},
+		{
+			DiffID:  "sha256:app",
+			Command: "RUN apk add curl",
+			Summary: storagev1alpha1.Summary{Low: 1},
+			CVEs:    []string{"CVE-2024-0002"},
+		},
+	}
+
+	for _, test := range []struct {
+		name                      string
+		objects                   []runtime.Object
+		expectedLayers            []storagev1alpha1.LayerReport
+		expectedBaseImage         *storagev1alpha1.BaseImage
+		expectedBaseImageUpgrades []storagev1alpha1.BaseImageUpgrade
+	}{
+		{
+			name:           "image found",
+			objects:        []runtime.Object{scanJob, image, sbom},
+			expectedLayers: expectedLayers,
+		},
+		{
+			name:              "image with a base image",
+			objects:           []runtime.Object{scanJob, imageWithBaseImage, sbom, baseImageReport},
+			expectedLayers:    expectedLayers,
+			expectedBaseImage: imageWithBaseImage.BaseImage,
+			expectedBaseImageUpgrades: []storagev1alpha1.BaseImageUpgrade{
+				{Tag: "3.20", Digest: "sha256:alpine-3.20", FixedCVEs: []string{"CVE-2024-0001"}},
+			},
+		},
+		{
+			name:           "image not found",
+			objects:        []runtime.Object{scanJob, sbom},
+			expectedLayers: nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			scheme := scheme.Scheme
+			require.NoError(t, storagev1alpha1.AddToScheme(scheme))
+			require.NoError(t, v1alpha1.AddToScheme(scheme))
+			k8sClient := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithRuntimeObjects(test.objects...).
+				WithIndex(&storagev1alpha1.VulnerabilityReport{}, storagev1alpha1.IndexImageMetadataRepository, func(obj client.Object) []string {
+					vulnerabilityReport, ok := obj.(*storagev1alpha1.VulnerabilityReport)
+					if !ok {
+						return nil
+					}
+					return []string{vulnerabilityReport.GetImageMetadata().Repository}
+				}).
+				Build()
+
+			handler := NewScanSBOMHandler(k8sClient, scheme, scanner, slog.Default())
+
+			message, err := json.Marshal(&ScanSBOMMessage{
+				BaseMessage: BaseMessage{
+					ScanJob: ObjectRef{
+						Name:      scanJob.Name,
+						Namespace: scanJob.Namespace,
+						UID:       string(scanJob.UID),
+					},
+				},
+				SBOM: ObjectRef{
+					Name:      sbom.Name,
+					Namespace: sbom.Namespace,
+				},
+			})
+			require.NoError(t, err)
+			require.NoError(t, handler.Handle(t.Context(), &testMessage{data: message}))
+
+			vulnerabilityReport := &storagev1alpha1.VulnerabilityReport{}
+			require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKeyFromObject(sbom), vulnerabilityReport))
+			assert.Equal(t, storagev1alpha1.Summary{Critical: 1, Low: 1}, vulnerabilityReport.Report.Summary)
+			assert.Equal(t, test.expectedLayers, vulnerabilityReport.Report.Layers)
+			assert.Equal(t, test.expectedBaseImage, vulnerabilityReport.ImageMetadata.BaseImage)
+			assert.Equal(t, test.expectedBaseImageUpgrades, vulnerabilityReport.Report.BaseImageUpgrades)
+		})
+	}
+}
+
+func TestScanSBOMHandler_Handle_Delta(t *testing.T) {
+	scanJob := &v1alpha1.ScanJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-scanjob",
+			Namespace: "default",
+			UID:       "test-scanjob-uid",
+		},
+	}
+	sbom := &storagev1alpha1.SBOM{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-image",
+			Namespace: "default",
+		},
+	}
+	firstSeen := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	previousReport := &storagev1alpha1.VulnerabilityReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      sbom.Name,
+			Namespace: sbom.Namespace,
+			Labels:    map[string]string{v1alpha1.LabelScanJobUIDKey: "previous-scanjob-uid"},
+		},
+		Report: storagev1alpha1.Report{
+			Results: []storagev1alpha1.Result{{
+				Vulnerabilities: []storagev1alpha1.Vulnerability{
+					{CVE: "CVE-2024-0001", PURL: "pkg:apk/alpine/openssl@3.3.0", Severity: "HIGH", FirstSeen: &firstSeen},
+					{CVE: "CVE-2024-0002", PURL: "pkg:apk/alpine/curl@8.9.0", Severity: "MEDIUM", FirstSeen: &firstSeen},
+				},
+			}},
+		},
+	}
+	scanner := &fakeScanner{results: []storagev1alpha1.Result{{
+		Vulnerabilities: []storagev1alpha1.Vulnerability{
+			{CVE: "CVE-2024-0001", PURL: "pkg:apk/alpine/openssl@3.3.0", Severity: "CRITICAL"},
+			{CVE: "CVE-2024-0003", PURL: "pkg:apk/alpine/busybox@1.36.1", Severity: "CRITICAL"},
+		},
+	}}}
+
+	scheme := scheme.Scheme
+	require.NoError(t, storagev1alpha1.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRuntimeObjects(scanJob, sbom, previousReport).
+		Build()
+
+	handler := NewScanSBOMHandler(k8sClient, scheme, scanner, slog.Default())
+
+	message, err := json.Marshal(&ScanSBOMMessage{
+		BaseMessage: BaseMessage{
+			ScanJob: ObjectRef{
+				Name:      scanJob.Name,
+				Namespace: scanJob.Namespace,
+				UID:       string(scanJob.UID),
+			},
+		},
+		SBOM: ObjectRef{
+			Name:      sbom.Name,
+			Namespace: sbom.Namespace,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, handler.Handle(t.Context(), &testMessage{data: message}))
+
+	expectedDelta := &storagev1alpha1.ReportDelta{
+		PreviousScanJobUID: "previous-scanjob-uid",
+		Introduced: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0003", PURL: "pkg:apk/alpine/busybox@1.36.1", Severity: "CRITICAL"},
+		},
+		Fixed: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0002", PURL: "pkg:apk/alpine/curl@8.9.0", Severity: "MEDIUM"},
+		},
+		SeverityChanges: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0001", PURL: "pkg:apk/alpine/openssl@3.3.0", Severity: "CRITICAL", PreviousSeverity: "HIGH"},
+		},
+	}
+
+	vulnerabilityReport := &storagev1alpha1.VulnerabilityReport{}
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKeyFromObject(sbom), vulnerabilityReport))
+	assert.Equal(t, expectedDelta, vulnerabilityReport.Report.Delta)
+	vulnerabilities := vulnerabilityReport.Report.Results[0].Vulnerabilities
+	require.NotNil(t, vulnerabilities[0].FirstSeen)
+	assert.True(t, firstSeen.Equal(vulnerabilities[0].FirstSeen), "known vulnerabilities must keep their first-seen time")
+	require.NotNil(t, vulnerabilities[1].FirstSeen)
+	assert.True(t, vulnerabilities[1].FirstSeen.After(firstSeen.Time))
+
+	// a redelivered message must not compute the delta against the report of the same ScanJob
+	require.NoError(t, handler.Handle(t.Context(), &testMessage{data: message}))
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKeyFromObject(sbom), vulnerabilityReport))
+	assert.Equal(t, expectedDelta, vulnerabilityReport.Report.Delta)
+}
+
 func TestScanSBOMHandler_Handle_StopProcessing(t *testing.T) {
 	spdxData, err := os.ReadFile(filepath.Join("..", "..", "test", "fixtures", "golang-1.12-alpine-amd64.spdx.json"))
 	require.NoError(t, err)
@@ -245,10 +507,15 @@ func TestScanSBOMHandler_Handle_StopProcessing(t *testing.T) {
 	failedScanJob := scanJob.DeepCopy()
 	failedScanJob.MarkFailed(v1alpha1.ReasonInternalError, "kaboom")
 
+	// The ScanJob was suspended and resumed after the message was published.
+	resumedScanJob := scanJob.DeepCopy()
+	resumedScanJob.Generation = 3
+
 	tests := []struct {
-		name            string
-		scanJob         *v1alpha1.ScanJob
-		existingObjects []runtime.Object
+		name              string
+		scanJob           *v1alpha1.ScanJob
+		messageGeneration int64
+		existingObjects   []runtime.Object
 	}{
 		{
 			name:            "scanjob not found",
@@ -265,6 +532,12 @@ func TestScanSBOMHandler_Handle_StopProcessing(t *testing.T) {
 			scanJob:         failedScanJob,
 			existingObjects: []runtime.Object{failedScanJob, sbom, vexHubs},
 		},
+		{
+			name:              "message published for an earlier generation",
+			scanJob:           resumedScanJob,
+			messageGeneration: 1,
+			existingObjects:   []runtime.Object{resumedScanJob, sbom, vexHubs},
+		},
 		{
 			name:            "sbom not found",
 			scanJob:         scanJob,
@@ -286,7 +559,7 @@ func TestScanSBOMHandler_Handle_StopProcessing(t *testing.T) {
 				Build()
 
 			cacheDir := t.TempDir()
-			handler := NewScanSBOMHandler(k8sClient, scheme, cacheDir, testTrivyDBRepository, testTrivyJavaDBRepository, slog.Default())
+			handler := NewScanSBOMHandler(k8sClient, scheme, NewTrivyEngine(k8sClient, cacheDir, testTrivyDBRepository, testTrivyJavaDBRepository, nil, nil, slog.Default()), slog.Default())
 
 			message, err := json.Marshal(&ScanSBOMMessage{
 				BaseMessage: BaseMessage{
@@ -295,6 +568,7 @@ func TestScanSBOMHandler_Handle_StopProcessing(t *testing.T) {
 						Namespace: test.scanJob.Namespace,
 						UID:       string(test.scanJob.UID),
 					},
+					Generation: test.messageGeneration,
 				},
 				SBOM: ObjectRef{
 					Name:      sbom.Name,
@@ -317,3 +591,191 @@ func TestScanSBOMHandler_Handle_StopProcessing(t *testing.T) {
 		})
 	}
 }
+
+func TestScanSBOMHandler_Handle_PreviousDigest(t *testing.T) {
+	scanJob := &v1alpha1.ScanJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-scanjob",
+			Namespace: "default",
+			UID:       "test-scanjob-uid",
+		},
+	}
+	imageMetadata := storagev1alpha1.ImageMetadata{
+		Registry:    "test-registry",
+		RegistryURI: "registry.local",
+		Repository:  "alpine",
+		Tag:         "3.20",
+		Platform:    "linux/amd64",
+		Digest:      "sha256:new",
+	}
+	sbom := &storagev1alpha1.SBOM{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-image-new",
+			Namespace: "default",
+		},
+		ImageMetadata: imageMetadata,
+	}
+
+	firstSeen := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	newTagReport := func(name, platform, digest string, scannedAt time.Time) *storagev1alpha1.VulnerabilityReport {
+		reportMetadata := imageMetadata
+		reportMetadata.Platform = platform
+		reportMetadata.Digest = digest
+		return &storagev1alpha1.VulnerabilityReport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{v1alpha1.LabelScanJobUIDKey: "previous-scanjob-uid"},
+			},
+			ImageMetadata: reportMetadata,
+			Report: storagev1alpha1.Report{
+				ScannedAt: &metav1.Time{Time: scannedAt},
+				Results: []storagev1alpha1.Result{{
+					Vulnerabilities: []storagev1alpha1.Vulnerability{
+						{CVE: "CVE-2024-0001", PURL: "pkg:apk/alpine/openssl@3.3.0", Severity: "HIGH", FirstSeen: &firstSeen},
+					},
+				}},
+			},
+		}
+	}
+	scanner := &fakeScanner{results: []storagev1alpha1.Result{{
+		Vulnerabilities: []storagev1alpha1.Vulnerability{
+			{CVE: "CVE-2024-0001", PURL: "pkg:apk/alpine/openssl@3.3.0", Severity: "HIGH"},
+			{CVE: "CVE-2024-0003", PURL: "pkg:apk/alpine/busybox@1.36.1", Severity: "CRITICAL"},
+		},
+	}}}
+
+	scheme := scheme.Scheme
+	require.NoError(t, storagev1alpha1.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	builder := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRuntimeObjects(
+			scanJob,
+			sbom,
+			// the previous digest of the tag, scanned after an older one
+			newTagReport("test-image-old", "linux/amd64", "sha256:old", time.Now().Add(-time.Hour)),
+			newTagReport("test-image-older", "linux/amd64", "sha256:older", time.Now().Add(-48*time.Hour)),
+			// another platform of the tag
+			newTagReport("test-image-arm64", "linux/arm64", "sha256:arm64", time.Now()),
+		)
+	for field, value := range map[string]func(storagev1alpha1.ImageMetadata) string{
+		storagev1alpha1.IndexImageMetadataRegistry:   func(m storagev1alpha1.ImageMetadata) string { return m.Registry },
+		storagev1alpha1.IndexImageMetadataRepository: func(m storagev1alpha1.ImageMetadata) string { return m.Repository },
+		storagev1alpha1.IndexImageMetadataTag:        func(m storagev1alpha1.ImageMetadata) string { return m.Tag },
+		storagev1alpha1.IndexImageMetadataPlatform:   func(m storagev1alpha1.ImageMetadata) string { return m.Platform },
+	} {
+		builder = builder.WithIndex(&storagev1alpha1.VulnerabilityReport{}, field, func(obj client.Object) []string {
+			vulnerabilityReport, ok := obj.(*storagev1alpha1.VulnerabilityReport)
+			if !ok {
+				return nil
+			}
+			return []string{value(vulnerabilityReport.GetImageMetadata())}
+		})
+	}
+	k8sClient := builder.Build()
+
+	handler := NewScanSBOMHandler(k8sClient, scheme, scanner, slog.Default())
+
+	message, err := json.Marshal(&ScanSBOMMessage{
+		BaseMessage: BaseMessage{
+			ScanJob: ObjectRef{
+				Name:      scanJob.Name,
+				Namespace: scanJob.Namespace,
+				UID:       string(scanJob.UID),
+			},
+		},
+		SBOM: ObjectRef{
+			Name:      sbom.Name,
+			Namespace: sbom.Namespace,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, handler.Handle(t.Context(), &testMessage{data: message}))
+
+	vulnerabilityReport := &storagev1alpha1.VulnerabilityReport{}
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKeyFromObject(sbom), vulnerabilityReport))
+	assert.Equal(t, &storagev1alpha1.ReportDelta{
+		PreviousScanJobUID: "previous-scanjob-uid",
+		Introduced: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0003", PURL: "pkg:apk/alpine/busybox@1.36.1", Severity: "CRITICAL"},
+		},
+		Fixed:           []storagev1alpha1.VulnerabilityChange{},
+		SeverityChanges: []storagev1alpha1.VulnerabilityChange{},
+	}, vulnerabilityReport.Report.Delta, "the delta of a new digest must be computed against the previous digest of the tag")
+	vulnerabilities := vulnerabilityReport.Report.Results[0].Vulnerabilities
+	require.NotNil(t, vulnerabilities[0].FirstSeen)
+	assert.True(t, firstSeen.Equal(vulnerabilities[0].FirstSeen), "the first-seen time must be carried over from the previous digest")
+}
+
+func TestScanSBOMHandler_Handle_ReplayedFailedImage(t *testing.T) {
+	imageMetadata := storagev1alpha1.ImageMetadata{
+		Registry:    "test-registry",
+		RegistryURI: "registry.local",
+		Repository:  "alpine",
+		Platform:    "linux/amd64",
+		Digest:      "sha256:replayed",
+	}
+	sbom := &storagev1alpha1.SBOM{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-image-replayed",
+			Namespace: "default",
+		},
+		ImageMetadata: imageMetadata,
+	}
+
+	// The ScanJob completed with the image failed, before its dead-lettered message was replayed.
+	scanJob := &v1alpha1.ScanJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-scanjob",
+			Namespace: "default",
+			UID:       "test-scanjob-uid",
+		},
+		Status: v1alpha1.ScanJobStatus{
+			ImagesCount:        3,
+			ScannedImagesCount: 1,
+		},
+	}
+	scanJob.InitializeConditions()
+	scanJob.AddFailedImage(v1alpha1.FailedImage{Image: "registry.local/alpine@sha256:other", Platform: "linux/amd64", Error: "kaboom"})
+	scanJob.AddFailedImage(v1alpha1.FailedImage{Image: "registry.local/alpine@sha256:replayed", Platform: "linux/amd64", Error: "kaboom"})
+	scanJob.MarkImagesProcessed()
+
+	scheme := scheme.Scheme
+	require.NoError(t, storagev1alpha1.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRuntimeObjects(scanJob, sbom).
+		WithStatusSubresource(scanJob).
+		Build()
+
+	handler := NewScanSBOMHandler(k8sClient, scheme, &fakeScanner{}, slog.Default())
+
+	message, err := json.Marshal(&ScanSBOMMessage{
+		BaseMessage: BaseMessage{
+			ScanJob: ObjectRef{
+				Name:      scanJob.Name,
+				Namespace: scanJob.Namespace,
+				UID:       string(scanJob.UID),
+			},
+		},
+		SBOM: ObjectRef{
+			Name:      sbom.Name,
+			Namespace: sbom.Namespace,
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, handler.Handle(t.Context(), &testMessage{data: message}))
+
+	updatedScanJob := &v1alpha1.ScanJob{}
+	require.NoError(t, k8sClient.Get(t.Context(), client.ObjectKeyFromObject(scanJob), updatedScanJob))
+	assert.Equal(t, 1, updatedScanJob.Status.FailedImagesCount)
+	assert.Equal(t, []v1alpha1.FailedImage{
+		{Image: "registry.local/alpine@sha256:other", Platform: "linux/amd64", Error: "kaboom"},
+	}, updatedScanJob.Status.FailedImages)
+	completeCondition := meta.FindStatusCondition(updatedScanJob.Status.Conditions, v1alpha1.ConditionTypeComplete)
+	require.NotNil(t, completeCondition)
+	assert.Equal(t, v1alpha1.ReasonPartiallyFailed, completeCondition.Reason)
+	assert.Equal(t, "1 of 3 images could not be scanned", completeCondition.Message)
+}
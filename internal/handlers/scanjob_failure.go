@@ -3,67 +3,155 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"path"
+	"unicode/utf8"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	sbombasticv1alpha1 "github.com/kubewarden/sbomscanner/api/v1alpha1"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
 	"github.com/kubewarden/sbomscanner/internal/messaging"
 )
 
+// maxFailedImageErrorLength is the maximum length of the error recorded for a failed image,
+// to keep the ScanJob status size bounded.
+const maxFailedImageErrorLength = 1024
+
+// failureMessage contains the fields of the messages that identify the processed image, if any.
+type failureMessage struct {
+	BaseMessage
+	// Image is set by GenerateSBOMMessage.
+	Image *ObjectRef `json:"image,omitempty"`
+	// SBOM is set by ScanSBOMMessage.
+	SBOM *ObjectRef `json:"sbom,omitempty"`
+}
+
 // ScanJobFailureHandler handles failures for messages related to scan jobs.
 type ScanJobFailureHandler struct {
 	k8sClient client.Client
+	recorder  record.EventRecorder
 	logger    *slog.Logger
 }
 
 // NewScanJobFailureHandler creates a new instance of ScanJobFailureHandler.
 func NewScanJobFailureHandler(
 	k8sClient client.Client,
+	recorder record.EventRecorder,
 	logger *slog.Logger,
 ) *ScanJobFailureHandler {
 	return &ScanJobFailureHandler{
 		k8sClient: k8sClient,
+		recorder:  recorder,
 		logger:    logger.With("handler", "scanjob_failure_handler"),
 	}
 }
 
 // HandleFailure processes message failures and updates the associated ScanJob status.
-func (h *ScanJobFailureHandler) HandleFailure(ctx context.Context, message messaging.Message, errorMessage string) error {
-	baseMessage := &BaseMessage{}
-	if err := json.Unmarshal(message.Data(), baseMessage); err != nil {
+// Failures of messages processing a single image are recorded in the ScanJob status,
+// and the ScanJob keeps going with the other images.
+// Any other failure marks the whole ScanJob as failed.
+func (h *ScanJobFailureHandler) HandleFailure(ctx context.Context, message messaging.Message, failure error) error {
+	errorMessage := failure.Error()
+	msg := &failureMessage{}
+	if err := json.Unmarshal(message.Data(), msg); err != nil {
 		return fmt.Errorf("failed to unmarshal base message: %w", err)
 	}
 	h.logger.DebugContext(ctx, "Handling ScanJob failure",
-		"scanjob", baseMessage.ScanJob.Name,
-		"namespace", baseMessage.ScanJob.Namespace,
+		"scanjob", msg.ScanJob.Name,
+		"namespace", msg.ScanJob.Namespace,
 		"error", errorMessage,
 	)
 
+	var failedImage *sbombasticv1alpha1.FailedImage
+	if msg.Image != nil || msg.SBOM != nil {
+		failedImage = h.failedImage(ctx, msg, errorMessage)
+	}
+
 	scanJob := &sbombasticv1alpha1.ScanJob{}
+	var skipped bool
 
 	// It is possible that the controller is slow to set the status condition "Scheduled" to true,
 	// so we might encounter conflicts when setting the status conditions.
 	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		if err := h.k8sClient.Get(ctx, client.ObjectKey{
-			Name:      baseMessage.ScanJob.Name,
-			Namespace: baseMessage.ScanJob.Namespace,
+			Name:      msg.ScanJob.Name,
+			Namespace: msg.ScanJob.Namespace,
 		}, scanJob); err != nil {
-			return fmt.Errorf("cannot get scanjob %s/%s: %w", baseMessage.ScanJob.Namespace, baseMessage.ScanJob.Name, err)
+			return fmt.Errorf("cannot get scanjob %s/%s: %w", msg.ScanJob.Namespace, msg.ScanJob.Name, err)
+		}
+
+		skipped = msg.IsStale(scanJob)
+		if skipped {
+			h.logger.InfoContext(ctx, "Message published for an earlier generation of the ScanJob, skipping updating ScanJob status",
+				"scanjob", scanJob.Name,
+				"namespace", scanJob.Namespace,
+				"generation", msg.Generation,
+				"currentGeneration", scanJob.Generation,
+			)
+			return nil
+		}
+
+		skipped = scanJob.IsCancelled() || scanJob.IsStopRequested()
+		if skipped {
+			h.logger.InfoContext(ctx, "ScanJob is cancelled or suspended, skipping updating ScanJob status",
+				"scanjob", scanJob.Name,
+				"namespace", scanJob.Namespace,
+			)
+			return nil
+		}
+
+		if failedImage == nil {
+			scanJob.MarkFailed(failureReason(failure), errorMessage)
+			return h.k8sClient.Status().Update(ctx, scanJob)
 		}
 
-		scanJob.MarkFailed(sbombasticv1alpha1.ReasonInternalError, errorMessage)
+		skipped = scanJob.IsFailed() || scanJob.IsComplete()
+		if skipped {
+			h.logger.InfoContext(ctx, "ScanJob already finished, skipping recording failed image",
+				"scanjob", scanJob.Name,
+				"namespace", scanJob.Namespace,
+				"image", failedImage.Image,
+			)
+			return nil
+		}
+
+		scanJob.AddFailedImage(*failedImage)
+		if scanJob.AllImagesProcessed() {
+			scanJob.MarkImagesProcessed()
+		}
 		return h.k8sClient.Status().Update(ctx, scanJob)
 	})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			h.logger.InfoContext(ctx, "ScanJob not found, skipping updating ScanJob status to failed", "scanjob", baseMessage.ScanJob.Name, "namespace", baseMessage.ScanJob.Namespace)
+			h.logger.InfoContext(ctx, "ScanJob not found, skipping updating ScanJob status", "scanjob", msg.ScanJob.Name, "namespace", msg.ScanJob.Namespace)
 			return nil
 		}
-		return fmt.Errorf("failed to update ScanJob %s/%s status to failed: %w", scanJob.Namespace, scanJob.Name, err)
+		return fmt.Errorf("failed to update ScanJob %s/%s status: %w", msg.ScanJob.Namespace, msg.ScanJob.Name, err)
+	}
+	if skipped {
+		return nil
+	}
+
+	if failedImage != nil {
+		h.logger.DebugContext(ctx, "Failed image recorded in ScanJob",
+			"scanjob", scanJob.Name,
+			"namespace", scanJob.Namespace,
+			"image", failedImage.Image,
+			"failedImagesCount", scanJob.Status.FailedImagesCount,
+		)
+		h.recordFailedImage(ctx, msg, scanJob, failedImage)
+		if scanJob.IsComplete() {
+			RecordScanJobCompleted(h.recorder, scanJob)
+		}
+		return nil
 	}
 
 	h.logger.DebugContext(ctx, "ScanJob marked as failed",
@@ -71,5 +159,105 @@ func (h *ScanJobFailureHandler) HandleFailure(ctx context.Context, message messa
 		"namespace", scanJob.Namespace,
 		"error_message", errorMessage,
 	)
+	h.recorder.Event(scanJob, corev1.EventTypeWarning, EventReasonScanJobFailed, truncateErrorMessage(errorMessage))
 	return nil
 }
+
+// recordFailedImage records the failure of an image on the ScanJob and on the Image.
+// SBOMs are named after the Image they are generated from.
+// The event is recorded on the Image only if it exists, since the events are looked up by the UID of the object.
+func (h *ScanJobFailureHandler) recordFailedImage(ctx context.Context, msg *failureMessage, scanJob *sbombasticv1alpha1.ScanJob, failedImage *sbombasticv1alpha1.FailedImage) {
+	reason, ref := EventReasonSBOMGenerationFailed, msg.Image
+	if msg.Image == nil {
+		reason, ref = EventReasonImageScanFailed, msg.SBOM
+	}
+
+	h.recorder.Eventf(scanJob, corev1.EventTypeWarning, reason, "Image %s: %s", failedImage.Image, failedImage.Error)
+
+	image := &storagev1alpha1.Image{}
+	if err := h.k8sClient.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, image); err != nil {
+		h.logger.InfoContext(ctx, "Cannot get failed image, not recording the failure on it",
+			"name", ref.Name,
+			"namespace", ref.Namespace,
+			"error", err,
+		)
+		return
+	}
+	h.recorder.Eventf(image, corev1.EventTypeWarning, reason, "ScanJob %s: %s", scanJob.Name, failedImage.Error)
+}
+
+// failedImage builds the failed image entry of the given message.
+// The image reference is read from the Image or SBOM resource,
+// falling back to the resource name if it cannot be retrieved.
+func (h *ScanJobFailureHandler) failedImage(ctx context.Context, msg *failureMessage, errorMessage string) *sbombasticv1alpha1.FailedImage {
+	errorMessage = truncateErrorMessage(errorMessage)
+
+	var obj interface {
+		client.Object
+		storagev1alpha1.ImageMetadataAccessor
+	}
+	var ref *ObjectRef
+	if msg.Image != nil {
+		obj, ref = &storagev1alpha1.Image{}, msg.Image
+	} else {
+		obj, ref = &storagev1alpha1.SBOM{}, msg.SBOM
+	}
+
+	if err := h.k8sClient.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}, obj); err != nil {
+		h.logger.InfoContext(ctx, "Cannot get image metadata of failed image, using the resource name",
+			"name", ref.Name,
+			"namespace", ref.Namespace,
+			"error", err,
+		)
+		return &sbombasticv1alpha1.FailedImage{
+			Image: ref.Name,
+			Error: errorMessage,
+		}
+	}
+
+	imageMetadata := obj.GetImageMetadata()
+	return &sbombasticv1alpha1.FailedImage{
+		Image:    imageReference(imageMetadata),
+		Platform: imageMetadata.Platform,
+		Error:    errorMessage,
+	}
+}
+
+// imageReference returns the reference of the image, by tag,
+// or by digest for the images without a tag.
+func imageReference(imageMetadata storagev1alpha1.ImageMetadata) string {
+	repository := path.Join(imageMetadata.RegistryURI, imageMetadata.Repository)
+	if imageMetadata.Tag == "" {
+		return fmt.Sprintf("%s@%s", repository, imageMetadata.Digest)
+	}
+
+	return fmt.Sprintf("%s:%s", repository, imageMetadata.Tag)
+}
+
+// truncateErrorMessage truncates the error message to maxFailedImageErrorLength bytes,
+// without splitting a multi-byte character.
+func truncateErrorMessage(errorMessage string) string {
+	if len(errorMessage) <= maxFailedImageErrorLength {
+		return errorMessage
+	}
+
+	end := maxFailedImageErrorLength
+	for end > 0 && !utf8.RuneStart(errorMessage[end]) {
+		end--
+	}
+
+	return errorMessage[:end]
+}
+
+// failureReason returns the reason of the failure of a whole ScanJob.
+// The registry errors are recognized by the error they wrap.
+func failureReason(failure error) string {
+	switch {
+	case errors.Is(failure, registryclient.ErrAuthFailed):
+		return sbombasticv1alpha1.ReasonAuthFailed
+	case errors.Is(failure, registryclient.ErrUnreachable):
+		return sbombasticv1alpha1.ReasonRegistryUnreachable
+	default:
+		return sbombasticv1alpha1.ReasonInternalError
+	}
+}
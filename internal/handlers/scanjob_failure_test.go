@@ -2,72 +2,330 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
 	sbombasticv1alpha1 "github.com/kubewarden/sbomscanner/api/v1alpha1"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
 	"github.com/kubewarden/sbomscanner/pkg/generated/clientset/versioned/scheme"
 )
 
 func TestScanJobFailureHandler_HandleFailure(t *testing.T) {
 	scheme := scheme.Scheme
-	err := sbombasticv1alpha1.AddToScheme(scheme)
-	require.NoError(t, err)
+	require.NoError(t, sbombasticv1alpha1.AddToScheme(scheme))
+	require.NoError(t, storagev1alpha1.AddToScheme(scheme))
 
-	scanJob := &sbombasticv1alpha1.ScanJob{
+	image := &storagev1alpha1.Image{
 		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-image",
+			Namespace: "default",
+		},
+		ImageMetadata: storagev1alpha1.ImageMetadata{
+			Registry:    "test-registry",
+			RegistryURI: "registry.example.com",
+			Repository:  "library/nginx",
+			Tag:         "1.27",
+			Platform:    "linux/amd64",
+		},
+	}
+	baseMessage := BaseMessage{
+		ScanJob: ObjectRef{
 			Name:      "test-scanjob",
 			Namespace: "default",
 		},
-		Spec: sbombasticv1alpha1.ScanJobSpec{
-			Registry: "test-registry",
+	}
+
+	tests := []struct {
+		name                      string
+		message                   any
+		failure                   error
+		cancel                    bool
+		imagesCount               int
+		scannedImagesCount        int
+		expectedCancelled         bool
+		expectedFailed            bool
+		expectedComplete          bool
+		expectedReason            string
+		expectedFailedImagesCount int
+		expectedFailedImages      []sbombasticv1alpha1.FailedImage
+		expectedEvents            []string
+	}{
+		{
+			name:                      "catalog failure marks the ScanJob as failed",
+			message:                   &CreateCatalogMessage{BaseMessage: baseMessage},
+			failure:                   errors.New("catalog creation failed"),
+			expectedFailed:            true,
+			expectedReason:            sbombasticv1alpha1.ReasonInternalError,
+			expectedFailedImagesCount: 0,
+			expectedEvents:            []string{"Warning ScanJobFailed catalog creation failed"},
+		},
+		{
+			name:                      "catalog authentication failure marks the ScanJob as failed with the AuthFailed reason",
+			message:                   &CreateCatalogMessage{BaseMessage: baseMessage},
+			failure:                   fmt.Errorf("cannot discover images in registry test-registry: %w: UNAUTHORIZED", registryclient.ErrAuthFailed),
+			expectedFailed:            true,
+			expectedReason:            sbombasticv1alpha1.ReasonAuthFailed,
+			expectedFailedImagesCount: 0,
+			expectedEvents:            []string{"Warning ScanJobFailed cannot discover images in registry test-registry: registry authentication failed: UNAUTHORIZED"},
+		},
+		{
+			name:                      "catalog connection failure marks the ScanJob as failed with the RegistryUnreachable reason",
+			message:                   &CreateCatalogMessage{BaseMessage: baseMessage},
+			failure:                   fmt.Errorf("cannot discover images in registry test-registry: %w: dial tcp: connection refused", registryclient.ErrUnreachable),
+			expectedFailed:            true,
+			expectedReason:            sbombasticv1alpha1.ReasonRegistryUnreachable,
+			expectedFailedImagesCount: 0,
+			expectedEvents:            []string{"Warning ScanJobFailed cannot discover images in registry test-registry: registry unreachable: dial tcp: connection refused"},
+		},
+		{
+			name:                      "catalog failure mentioning an authentication failure is not classified by its message",
+			message:                   &CreateCatalogMessage{BaseMessage: baseMessage},
+			failure:                   errors.New("cannot parse config: registry authentication failed"),
+			expectedFailed:            true,
+			expectedReason:            sbombasticv1alpha1.ReasonInternalError,
+			expectedFailedImagesCount: 0,
+			expectedEvents:            []string{"Warning ScanJobFailed cannot parse config: registry authentication failed"},
+		},
+		{
+			name: "SBOM generation failure is recorded and the ScanJob keeps going",
+			message: &GenerateSBOMMessage{
+				BaseMessage: baseMessage,
+				Image:       ObjectRef{Name: image.Name, Namespace: image.Namespace},
+			},
+			failure:                   errors.New("SBOM generation failed"),
+			imagesCount:               3,
+			scannedImagesCount:        1,
+			expectedFailedImagesCount: 1,
+			expectedFailedImages: []sbombasticv1alpha1.FailedImage{
+				{
+					Image:    "registry.example.com/library/nginx:1.27",
+					Platform: "linux/amd64",
+					Error:    "SBOM generation failed",
+				},
+			},
+			expectedEvents: []string{
+				"Warning SBOMGenerationFailed Image registry.example.com/library/nginx:1.27: SBOM generation failed",
+				"Warning SBOMGenerationFailed ScanJob test-scanjob: SBOM generation failed",
+			},
+		},
+		{
+			name: "last image failure completes the ScanJob as partially failed",
+			message: &GenerateSBOMMessage{
+				BaseMessage: baseMessage,
+				Image:       ObjectRef{Name: image.Name, Namespace: image.Namespace},
+			},
+			failure:                   errors.New("SBOM generation failed"),
+			imagesCount:               3,
+			scannedImagesCount:        2,
+			expectedComplete:          true,
+			expectedReason:            sbombasticv1alpha1.ReasonPartiallyFailed,
+			expectedFailedImagesCount: 1,
+			expectedFailedImages: []sbombasticv1alpha1.FailedImage{
+				{
+					Image:    "registry.example.com/library/nginx:1.27",
+					Platform: "linux/amd64",
+					Error:    "SBOM generation failed",
+				},
+			},
+			expectedEvents: []string{
+				"Warning SBOMGenerationFailed Image registry.example.com/library/nginx:1.27: SBOM generation failed",
+				"Warning SBOMGenerationFailed ScanJob test-scanjob: SBOM generation failed",
+				"Warning ScanJobCompleted 1 of 3 images could not be scanned",
+			},
+		},
+		{
+			name: "SBOM scan failure of a missing SBOM falls back to the resource name",
+			message: &ScanSBOMMessage{
+				BaseMessage: baseMessage,
+				SBOM:        ObjectRef{Name: "missing-sbom", Namespace: "default"},
+			},
+			failure:                   errors.New(strings.Repeat("x", maxFailedImageErrorLength+1)),
+			imagesCount:               3,
+			expectedFailedImagesCount: 1,
+			expectedFailedImages: []sbombasticv1alpha1.FailedImage{
+				{
+					Image: "missing-sbom",
+					Error: strings.Repeat("x", maxFailedImageErrorLength),
+				},
+			},
+			expectedEvents: []string{
+				"Warning ImageScanFailed Image missing-sbom: " + strings.Repeat("x", maxFailedImageErrorLength),
+			},
+		},
+		{
+			name: "SBOM generation failure of a message published for another generation of the ScanJob is ignored",
+			message: &GenerateSBOMMessage{
+				BaseMessage: BaseMessage{ScanJob: baseMessage.ScanJob, Generation: 1},
+				Image:       ObjectRef{Name: image.Name, Namespace: image.Namespace},
+			},
+			failure:                   errors.New("SBOM generation failed"),
+			imagesCount:               3,
+			expectedFailedImagesCount: 0,
+		},
+		{
+			name:                      "catalog failure of a cancelled ScanJob is ignored",
+			message:                   &CreateCatalogMessage{BaseMessage: baseMessage},
+			failure:                   errors.New("catalog creation failed"),
+			cancel:                    true,
+			expectedCancelled:         true,
+			expectedFailedImagesCount: 0,
 		},
 	}
 
-	k8sClient := fake.NewClientBuilder().
-		WithScheme(scheme).
-		WithRuntimeObjects(scanJob).
-		WithStatusSubresource(scanJob).
-		Build()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scanJob := &sbombasticv1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      baseMessage.ScanJob.Name,
+					Namespace: baseMessage.ScanJob.Namespace,
+				},
+				Spec: sbombasticv1alpha1.ScanJobSpec{
+					Registry: "test-registry",
+					Cancel:   test.cancel,
+				},
+				Status: sbombasticv1alpha1.ScanJobStatus{
+					ImagesCount:        test.imagesCount,
+					ScannedImagesCount: test.scannedImagesCount,
+				},
+			}
+			scanJob.InitializeConditions()
+			scanJob.MarkInProgress(sbombasticv1alpha1.ReasonImageScanInProgress, "Image scan in progress")
+			if test.cancel {
+				scanJob.MarkCancelled(sbombasticv1alpha1.ReasonCancelled, "ScanJob has been cancelled by the user")
+			}
+
+			k8sClient := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithRuntimeObjects([]runtime.Object{scanJob, image.DeepCopy()}...).
+				WithStatusSubresource(scanJob).
+				Build()
+
+			recorder := record.NewFakeRecorder(10)
+			handler := NewScanJobFailureHandler(k8sClient, recorder, slog.Default())
 
-	handler := NewScanJobFailureHandler(k8sClient, slog.Default())
+			message, err := json.Marshal(test.message)
+			require.NoError(t, err)
 
-	message, err := json.Marshal(&GenerateSBOMMessage{
-		BaseMessage: BaseMessage{
-			ScanJob: ObjectRef{
+			err = handler.HandleFailure(t.Context(), &testMessage{data: message}, test.failure)
+			require.NoError(t, err)
+
+			updatedScanJob := &sbombasticv1alpha1.ScanJob{}
+			err = k8sClient.Get(t.Context(), types.NamespacedName{
 				Name:      scanJob.Name,
 				Namespace: scanJob.Namespace,
-			},
-		},
-		Image: ObjectRef{
+			}, updatedScanJob)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expectedFailed, updatedScanJob.IsFailed())
+			assert.Equal(t, test.expectedComplete, updatedScanJob.IsComplete())
+			assert.Equal(t, test.expectedCancelled, updatedScanJob.IsCancelled())
+			assert.Equal(t, test.expectedFailedImagesCount, updatedScanJob.Status.FailedImagesCount)
+			assert.Equal(t, test.expectedFailedImages, updatedScanJob.Status.FailedImages)
+			assert.Equal(t, test.expectedEvents, recordedEvents(recorder))
+
+			switch {
+			case test.expectedFailed:
+				failedCondition := meta.FindStatusCondition(updatedScanJob.Status.Conditions, sbombasticv1alpha1.ConditionTypeFailed)
+				require.NotNil(t, failedCondition)
+				assert.Equal(t, test.expectedReason, failedCondition.Reason)
+				assert.Equal(t, test.failure.Error(), failedCondition.Message)
+			case test.expectedComplete:
+				completeCondition := meta.FindStatusCondition(updatedScanJob.Status.Conditions, sbombasticv1alpha1.ConditionTypeComplete)
+				require.NotNil(t, completeCondition)
+				assert.Equal(t, test.expectedReason, completeCondition.Reason)
+			case test.expectedCancelled:
+				assert.False(t, updatedScanJob.IsInProgress())
+			default:
+				assert.True(t, updatedScanJob.IsInProgress())
+			}
+		})
+	}
+}
+
+func TestTruncateErrorMessage(t *testing.T) {
+	short := "short error"
+	assert.Equal(t, short, truncateErrorMessage(short))
+
+	// The multi-byte character crosses the limit, and must not be split.
+	long := strings.Repeat("x", maxFailedImageErrorLength-1) + "é" + "tail"
+	truncated := truncateErrorMessage(long)
+	assert.True(t, utf8.ValidString(truncated))
+	assert.Equal(t, strings.Repeat("x", maxFailedImageErrorLength-1), truncated)
+}
+
+func TestImageReference(t *testing.T) {
+	imageMetadata := storagev1alpha1.ImageMetadata{
+		RegistryURI: "registry.example.com",
+		Repository:  "library/nginx",
+		Tag:         "1.27",
+		Digest:      "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+	}
+	assert.Equal(t, "registry.example.com/library/nginx:1.27", imageReference(imageMetadata))
+
+	imageMetadata.Tag = ""
+	assert.Equal(t, "registry.example.com/library/nginx@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", imageReference(imageMetadata))
+}
+
+// objectRecorder records the objects the events are recorded on.
+type objectRecorder struct {
+	objects []runtime.Object
+}
+
+func (r *objectRecorder) Event(object runtime.Object, _, _, _ string) {
+	r.objects = append(r.objects, object)
+}
+
+func (r *objectRecorder) Eventf(object runtime.Object, _, _, _ string, _ ...any) {
+	r.objects = append(r.objects, object)
+}
+
+func (r *objectRecorder) AnnotatedEventf(object runtime.Object, _ map[string]string, _, _, _ string, _ ...any) {
+	r.objects = append(r.objects, object)
+}
+
+func TestScanJobFailureHandler_recordFailedImage(t *testing.T) {
+	scheme := scheme.Scheme
+	require.NoError(t, sbombasticv1alpha1.AddToScheme(scheme))
+	require.NoError(t, storagev1alpha1.AddToScheme(scheme))
+
+	image := &storagev1alpha1.Image{
+		ObjectMeta: metav1.ObjectMeta{
 			Name:      "test-image",
 			Namespace: "default",
+			UID:       "image-uid",
 		},
-	})
-	require.NoError(t, err)
-
-	errorMessage := "SBOM generation failed"
-	err = handler.HandleFailure(t.Context(), &testMessage{data: message}, errorMessage)
-	require.NoError(t, err)
-
-	updatedScanJob := &sbombasticv1alpha1.ScanJob{}
-	err = k8sClient.Get(t.Context(), types.NamespacedName{
-		Name:      scanJob.Name,
-		Namespace: scanJob.Namespace,
-	}, updatedScanJob)
-	require.NoError(t, err)
-
-	assert.True(t, updatedScanJob.IsFailed())
-	failedCondition := meta.FindStatusCondition(updatedScanJob.Status.Conditions, string(sbombasticv1alpha1.ConditionTypeFailed))
-	require.NotNil(t, failedCondition)
-	assert.Equal(t, sbombasticv1alpha1.ReasonInternalError, failedCondition.Reason)
-	assert.Equal(t, errorMessage, failedCondition.Message)
+	}
+	scanJob := &sbombasticv1alpha1.ScanJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-scanjob",
+			Namespace: "default",
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(image).Build()
+	recorder := &objectRecorder{}
+	handler := NewScanJobFailureHandler(k8sClient, recorder, slog.Default())
+
+	msg := &failureMessage{SBOM: &ObjectRef{Name: "test-image", Namespace: "default"}}
+	handler.recordFailedImage(t.Context(), msg, scanJob, &sbombasticv1alpha1.FailedImage{Image: "test-image", Error: "failed"})
+
+	require.Len(t, recorder.objects, 2)
+	assert.Equal(t, scanJob, recorder.objects[0])
+	recordedImage, ok := recorder.objects[1].(*storagev1alpha1.Image)
+	require.True(t, ok)
+	assert.Equal(t, types.UID("image-uid"), recordedImage.UID, "the event must be recorded on the existing Image")
 }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var tracer = otel.Tracer("github.com/kubewarden/sbomscanner/internal/handlers")
+
+// executeTrivy runs a Trivy command within a span and records its duration.
+func executeTrivy(ctx context.Context, command string, execute func(context.Context) error) error {
+	ctx, span := tracer.Start(ctx, "trivy "+command, trace.WithAttributes(
+		attribute.String("trivy.command", command),
+	))
+	defer span.End()
+
+	start := time.Now()
+	err := execute(ctx)
+	observeTrivyDuration(command, start, err)
+	recordSpanError(span, err)
+
+	return err
+}
+
+// traceStorageWrite runs a write of the object to the storage within a span.
+func traceStorageWrite(ctx context.Context, operation string, obj client.Object, write func(context.Context) error) error {
+	kind := reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	ctx, span := tracer.Start(ctx, "storage "+operation+" "+kind,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("k8s.namespace.name", obj.GetNamespace()),
+			attribute.String("sbomscanner.object.kind", kind),
+			attribute.String("sbomscanner.object.name", obj.GetName()),
+		),
+	)
+	defer span.End()
+
+	err := write(ctx)
+	recordSpanError(span, err)
+
+	return err
+}
+
+// recordSpanError marks the span as failed when err is not nil.
+func recordSpanError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	_ "modernc.org/sqlite" // sqlite driver for RPM DB and Java DB
+
+	trivyTypes "github.com/aquasecurity/trivy/pkg/types"
+	vexrepo "github.com/aquasecurity/trivy/pkg/vex/repo"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"go.yaml.in/yaml/v3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	vulnReport "github.com/kubewarden/sbomscanner/internal/handlers/vulnerabilityreport"
+	"github.com/kubewarden/sbomscanner/internal/messaging"
+)
+
+const (
+	// trivyVEXSubPath is the directory used by trivy to hold VEX repositories.
+	trivyVEXSubPath = ".trivy/vex"
+	// trivyVEXRepoFile is the file used by trivy to hold VEX repositories.
+	trivyVEXRepoFile = "repository.yaml"
+	// dockerConfigEnv is the environment variable pointing to the directory of the Docker configuration.
+	dockerConfigEnv = "DOCKER_CONFIG"
+)
+
+// TrivyEngine generates and scans SBOMs using Trivy.
+type TrivyEngine struct {
+	k8sClient             client.Client
+	workDir               string
+	trivyDBRepository     string
+	trivyJavaDBRepository string
+	// subprocess configures the execution of Trivy in a child process.
+	// Trivy runs in-process when nil.
+	subprocess *TrivySubprocessConfig
+	// offline configures the bundle holding the databases and VEX repositories.
+	// The databases are downloaded when nil.
+	offline *OfflineConfig
+	// dbLeases counts the executions using each version of the databases.
+	dbLeases trivyDBLeases
+	// dbReplicas holds the copies of the vulnerability database used by the child processes.
+	dbReplicas trivyDBReplicas
+	logger     *slog.Logger
+}
+
+// NewTrivyEngine creates a new instance of TrivyEngine.
+func NewTrivyEngine(
+	k8sClient client.Client,
+	workDir string,
+	trivyDBRepository string,
+	trivyJavaDBRepository string,
+	subprocess *TrivySubprocessConfig,
+	offline *OfflineConfig,
+	logger *slog.Logger,
+) *TrivyEngine {
+	return &TrivyEngine{
+		k8sClient:             k8sClient,
+		workDir:               workDir,
+		trivyDBRepository:     trivyDBRepository,
+		trivyJavaDBRepository: trivyJavaDBRepository,
+		subprocess:            subprocess,
+		offline:               offline,
+		logger:                logger.With("engine", EngineTrivy),
+	}
+}
+
+// Name returns the name of the engine.
+func (e *TrivyEngine) Name() string {
+	return EngineTrivy
+}
+
+// GenerateSBOM generates SPDX JSON content for an image using Trivy.
+func (e *TrivyEngine) GenerateSBOM(ctx context.Context, image *storagev1alpha1.Image, registry *v1alpha1.Registry) ([]byte, error) {
+	// The Java DB is needed to generate SBOMs for images containing Java components
+	// See: https://github.com/aquasecurity/trivy/discussions/9666
+	cacheDir, _, release, err := e.acquireDatabase()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get trivy databases: %w", err)
+	}
+	defer release()
+
+	sbomFile, err := os.CreateTemp(e.workDir, "trivy.sbom.*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary SBOM file: %w", err)
+	}
+	defer func() {
+		if err = sbomFile.Close(); err != nil {
+			e.logger.Error("failed to close temporary SBOM file", "error", err)
+		}
+		if err = os.Remove(sbomFile.Name()); err != nil {
+			e.logger.Error("failed to remove temporary SBOM file", "error", err)
+		}
+	}()
+
+	trivyArgs := []string{
+		"image",
+		"--skip-version-check",
+		"--disable-telemetry",
+		"--format", "spdx-json",
+		"--output", sbomFile.Name(),
+		"--cache-dir", cacheDir,
+		// The scan cache is kept in memory, since concurrent executions
+		// cannot share the on-disk one.
+		"--cache-backend", "memory",
+		"--skip-db-update",
+		"--skip-java-db-update",
+		fmt.Sprintf(
+			"%s/%s@%s",
+			image.GetImageMetadata().RegistryURI,
+			image.GetImageMetadata().Repository,
+			image.GetImageMetadata().Digest,
+		),
+	}
+	if e.offline != nil {
+		// Do not look up the Java packages missing from the Java DB in Maven Central.
+		trivyArgs = append(trivyArgs, "--offline-scan")
+	}
+
+	// Trivy sends the credentials of its configuration file to every registry, including the mirrors:
+	// they are passed through a Docker configuration scoped to the registry of the image instead,
+	// so that the mirrors are reached anonymously.
+	// Each SBOM generation uses its own Docker configuration, so that concurrent SBOM generations do not share it.
+	env := map[string]string{}
+	if registry.IsPrivate() {
+		var dockerConfigDir string
+		dockerConfigDir, err = e.writeDockerConfig(ctx, image, registry)
+		if err != nil {
+			return nil, fmt.Errorf("cannot setup trivy credentials for registry %s: %w", registry.Name, err)
+		}
+		defer func() {
+			if err = os.RemoveAll(dockerConfigDir); err != nil {
+				e.logger.Error("failed to remove temporary docker config directory", "error", err)
+			}
+		}()
+		env[dockerConfigEnv] = dockerConfigDir
+	}
+
+	// Trivy supports registry mirrors only through its configuration file.
+	registryConfig, err := trivyRegistryConfig(registry)
+	if err != nil {
+		return nil, fmt.Errorf("cannot setup trivy for registry %s: %w", registry.Name, err)
+	}
+	if len(registryConfig) > 0 {
+		var configFile string
+		configFile, err = e.writeTrivyConfig(map[string]any{"registry": registryConfig})
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err = os.Remove(configFile); err != nil {
+				e.logger.Error("failed to remove temporary trivy config file", "error", err)
+			}
+		}()
+		trivyArgs = append(trivyArgs, "--config", configFile)
+	}
+
+	if err = e.runTrivy(ctx, trivyArgs, env); err != nil {
+		return nil, fmt.Errorf("failed to execute trivy: %w", err)
+	}
+
+	e.logger.DebugContext(ctx, "SPDX generated", "image", image.Name, "namespace", image.Namespace)
+
+	spdxBytes, err := io.ReadAll(sbomFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM output: %w", err)
+	}
+
+	return spdxBytes, nil
+}
+
+// Scan scans the SPDX JSON content for vulnerabilities using Trivy.
+// The databases downloaded by UpdateDatabase are used, Trivy never downloads them while scanning.
+func (e *TrivyEngine) Scan(ctx context.Context, spdx []byte, vexHubs []v1alpha1.VEXHub) ([]storagev1alpha1.Result, *storagev1alpha1.VulnerabilityDatabase, error) {
+	cacheDir, database, release, err := e.acquireDatabase()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get trivy databases: %w", err)
+	}
+	defer release()
+	if e.subprocess != nil {
+		// Concurrent child processes cannot open the same vulnerability database.
+		versionDir := cacheDir
+		cacheDir, err = e.dbReplicas.acquire(versionDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot copy trivy-db: %w", err)
+		}
+		defer e.dbReplicas.release(versionDir, cacheDir)
+	}
+
+	sbomFile, err := os.CreateTemp(e.workDir, "trivy.sbom.*.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary SBOM file: %w", err)
+	}
+	defer func() {
+		if err = sbomFile.Close(); err != nil {
+			e.logger.Error("failed to close temporary SBOM file", "error", err)
+		}
+
+		if err = os.Remove(sbomFile.Name()); err != nil {
+			e.logger.Error("failed to remove temporary SBOM file", "error", err)
+		}
+	}()
+
+	_, err = sbomFile.Write(spdx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to write SBOM file: %w", err)
+	}
+	reportFile, err := os.CreateTemp(e.workDir, "trivy.report.*.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary report file: %w", err)
+	}
+	defer func() {
+		if err = reportFile.Close(); err != nil {
+			e.logger.Error("failed to close temporary report file", "error", err)
+		}
+
+		if err = os.Remove(reportFile.Name()); err != nil {
+			e.logger.Error("failed to remove temporary repoort file", "error", err)
+		}
+	}()
+
+	trivyArgs := []string{
+		"sbom",
+		"--skip-version-check",
+		"--disable-telemetry",
+		"--format", "json",
+		"--output", reportFile.Name(),
+		"--cache-dir", cacheDir,
+		"--cache-backend", "memory",
+		"--skip-db-update",
+		"--skip-java-db-update",
+	}
+	if e.offline != nil {
+		// The VEX repositories are loaded from the bundle.
+		trivyArgs = append(trivyArgs, "--offline-scan", "--skip-vex-repo-update")
+		if err = e.checkBundledVEXRepositories(vexHubs); err != nil {
+			return nil, nil, messaging.NewPermanentError(err)
+		}
+	}
+	var env map[string]string
+	if len(vexHubs) > 0 {
+		// Set XDG_DATA_HOME environment variable to /tmp because trivy expects
+		// the repository file in that location and there is no way to change it
+		// through input flags:
+		// https://trivy.dev/v0.64/docs/supply-chain/vex/repo/#default-configuration
+		// TODO(alegrey91): fix upstream
+		var trivyHome string
+		trivyHome, err = os.MkdirTemp("/tmp", "trivy-")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create temporary trivy home: %w", err)
+		}
+		env = map[string]string{"XDG_DATA_HOME": trivyHome}
+
+		trivyVEXPath := path.Join(trivyHome, trivyVEXSubPath)
+		vexRepoPath := path.Join(trivyVEXPath, trivyVEXRepoFile)
+		if err = e.setupVEXHubRepositories(vexHubs, trivyVEXPath, vexRepoPath); err != nil {
+			return nil, nil, fmt.Errorf("failed to setup VEX Hub repositories: %w", err)
+		}
+		// Clean up the trivy home directory after each scan to
+		// ensure VEX repositories are refreshed on every run.
+		defer func() {
+			e.logger.Debug("Removing trivy home")
+			if err = os.RemoveAll(trivyHome); err != nil {
+				e.logger.Error("failed to remove temporary trivy home", "error", err)
+			}
+		}()
+
+		// We explicitly set the `--vex` option only when needed
+		// (VEXHub resources are found). This is because trivy automatically
+		// fills the repository file with aquasecurity VEX files, when
+		// `--vex` is specificed.
+		trivyArgs = append(trivyArgs, "--vex", "repo", "--show-suppressed")
+	}
+
+	// add SBOM file name at the end.
+	trivyArgs = append(trivyArgs, sbomFile.Name())
+
+	if err = e.runTrivy(ctx, trivyArgs, env); err != nil {
+		return nil, nil, fmt.Errorf("failed to execute trivy: %w", err)
+	}
+
+	reportBytes, err := io.ReadAll(reportFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read SBOM output: %w", err)
+	}
+
+	reportOrig := trivyTypes.Report{}
+	err = json.Unmarshal(reportBytes, &reportOrig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal report: %w", err)
+	}
+
+	results, err := vulnReport.NewFromTrivyResults(reportOrig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert from trivy results: %w", err)
+	}
+
+	return results, database, nil
+}
+
+// checkBundledVEXRepositories returns an error if an enabled VEXHub has no repository in the bundle,
+// as Trivy would download it.
+func (e *TrivyEngine) checkBundledVEXRepositories(vexHubs []v1alpha1.VEXHub) error {
+	for _, vexHub := range vexHubs {
+		if !vexHub.Spec.Enabled {
+			continue
+		}
+		manifest := filepath.Join(e.offline.BundlePath, bundleVEXDir, vexHub.Name, "vex-repository.json")
+		if _, err := os.Stat(manifest); err != nil {
+			return fmt.Errorf("%w: VEX repository of VEXHub %s not found in the bundle: %w", ErrOffline, vexHub.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// trivyRegistryConfig returns the registry section of the Trivy configuration,
+// containing the mirrors of the registry.
+func trivyRegistryConfig(registry *v1alpha1.Registry) (map[string]any, error) {
+	registryConfig := map[string]any{}
+
+	if len(registry.Spec.Mirrors) > 0 {
+		reg, err := name.NewRegistry(registry.Spec.URI)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse registry URI %s: %w", registry.Spec.URI, err)
+		}
+		// Trivy looks up the mirrors using the registry of the image reference,
+		// e.g. "index.docker.io" for Docker Hub images.
+		registryConfig["mirrors"] = map[string][]string{
+			reg.RegistryStr(): registry.Spec.Mirrors,
+		}
+	}
+
+	return registryConfig, nil
+}
+
+// writeDockerConfig writes the credentials of the registry of the image to a temporary Docker configuration,
+// in a directory readable only by the worker.
+// Returns the path of the directory, to be set as DOCKER_CONFIG.
+func (e *TrivyEngine) writeDockerConfig(ctx context.Context, image *storagev1alpha1.Image, registry *v1alpha1.Registry) (string, error) {
+	authConfig, err := registryAuthConfig(ctx, e.k8sClient, image, registry)
+	if err != nil {
+		return "", err
+	}
+	reg, err := name.NewRegistry(image.GetImageMetadata().RegistryURI)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse registry %s: %w", image.GetImageMetadata().RegistryURI, err)
+	}
+
+	dockerConfigDir, err := os.MkdirTemp(e.workDir, "trivy.docker.*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary docker config directory: %w", err)
+	}
+	if err = writeDockerConfigFile(dockerConfigDir, reg, authConfig); err != nil {
+		if removeErr := os.RemoveAll(dockerConfigDir); removeErr != nil {
+			e.logger.Error("failed to remove temporary docker config directory", "error", removeErr)
+		}
+		return "", err
+	}
+
+	return dockerConfigDir, nil
+}
+
+// writeDockerConfigFile writes a Docker configuration file holding only the credentials of the given registry.
+func writeDockerConfigFile(dir string, reg name.Registry, authConfig *authn.AuthConfig) error {
+	// Docker Hub credentials are looked up with a legacy key.
+	key := reg.RegistryStr()
+	if key == name.DefaultRegistry {
+		key = authn.DefaultAuthKey
+	}
+
+	configBytes, err := json.Marshal(map[string]any{
+		"auths": map[string]*authn.AuthConfig{
+			key: authConfig,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal docker config: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "config.json"), configBytes, 0o600); err != nil {
+		return fmt.Errorf("failed to write docker config file: %w", err)
+	}
+
+	return nil
+}
+
+// writeTrivyConfig writes a temporary Trivy configuration file, readable only by the worker.
+// Returns the path of the configuration file.
+func (e *TrivyEngine) writeTrivyConfig(config map[string]any) (string, error) {
+	configBytes, err := yaml.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal trivy config: %w", err)
+	}
+
+	configFile, err := os.CreateTemp(e.workDir, "trivy.config.*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary trivy config file: %w", err)
+	}
+	defer func() {
+		if err = configFile.Close(); err != nil {
+			e.logger.Error("failed to close temporary trivy config file", "error", err)
+		}
+	}()
+
+	if _, err = configFile.Write(configBytes); err != nil {
+		return "", fmt.Errorf("failed to write trivy config file: %w", err)
+	}
+
+	return configFile.Name(), nil
+}
+
+// setupVEXHubRepositories creates all the necessary files and directories
+// to use VEX Hub repositories.
+func (e *TrivyEngine) setupVEXHubRepositories(vexHubs []v1alpha1.VEXHub, trivyVEXPath, vexRepoPath string) error {
+	config := vexrepo.Config{}
+	var err error
+	for _, repo := range vexHubs {
+		repo := vexrepo.Repository{
+			Name:    repo.Name,
+			URL:     repo.Spec.URL,
+			Enabled: repo.Spec.Enabled,
+		}
+		config.Repositories = append(config.Repositories, repo)
+	}
+
+	var repositories []byte
+	repositories, err = yaml.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal struct: %w", err)
+	}
+
+	e.logger.Debug("Creating VEX repository directory", "vexhub", trivyVEXPath)
+	err = os.MkdirAll(trivyVEXPath, 0o750)
+	if err != nil {
+		return fmt.Errorf("failed to create VEX configuration directory: %w", err)
+	}
+
+	e.logger.Debug("Creating VEX repository file", "vexhub", vexRepoPath)
+	err = os.WriteFile(vexRepoPath, repositories, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create VEX repository file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/aquasecurity/trivy-db/pkg/metadata"
+	trivyDB "github.com/aquasecurity/trivy/pkg/db"
+	"github.com/aquasecurity/trivy/pkg/javadb"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+const (
+	// trivyDBSubPath is the directory of the work directory holding the Trivy databases.
+	trivyDBSubPath = "trivy-db"
+	// trivyDBVersionsDir is the directory holding the downloaded versions of the Trivy databases.
+	trivyDBVersionsDir = "versions"
+	// trivyDBCurrentLink is the symbolic link to the version of the Trivy databases used by the executions.
+	trivyDBCurrentLink = "current"
+	// trivyDBInfoFile describes a version of the Trivy databases.
+	trivyDBInfoFile = "sbomscanner.json"
+)
+
+// errTrivyDBNotReady is returned when the Trivy databases have not been downloaded yet.
+var errTrivyDBNotReady = errors.New("trivy databases not downloaded yet")
+
+// trivyDBInfo describes a version of the Trivy databases downloaded by the worker.
+type trivyDBInfo struct {
+	// DBDigest and JavaDBDigest are empty when the databases are loaded from bundled files.
+	DBDigest     string `json:"dbDigest,omitempty"`
+	JavaDBDigest string `json:"javaDBDigest,omitempty"`
+}
+
+// trivyDBLeases counts the executions using each version of the Trivy databases,
+// so that the versions in use are not removed by the updates.
+type trivyDBLeases struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// trivyDBSource is where a version of the Trivy databases is fetched from.
+type trivyDBSource struct {
+	// revision identifies the content of the databases.
+	revision string
+	info     trivyDBInfo
+	// fetch writes the databases into the given cache directory.
+	fetch func(ctx context.Context, cacheDir string) error
+}
+
+// UpdateDatabase downloads the Trivy vulnerability database and Java database,
+// or loads them from the bundle in offline mode, when they differ from the ones in use.
+// The databases are fetched into a new directory, which replaces
+// the one used by the executions atomically.
+// The previous versions are removed once no execution uses them.
+func (e *TrivyEngine) UpdateDatabase(ctx context.Context) error {
+	var source *trivyDBSource
+	var err error
+	if e.offline != nil {
+		source, err = e.bundleDatabaseSource()
+	} else {
+		source, err = e.remoteDatabaseSource(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	versionsDir := filepath.Join(e.workDir, trivyDBSubPath, trivyDBVersionsDir)
+	versionHash := sha256.Sum256([]byte(source.revision))
+	version := hex.EncodeToString(versionHash[:])[:16]
+
+	currentVersion, err := e.currentDatabaseVersion()
+	if err != nil && !errors.Is(err, errTrivyDBNotReady) {
+		return err
+	}
+	if currentVersion == version {
+		e.logger.DebugContext(ctx, "Trivy databases up to date", "db", source.info.DBDigest, "javaDB", source.info.JavaDBDigest)
+		// Remove the versions released since the last update.
+		e.pruneDatabases(versionsDir, version)
+		return nil
+	}
+
+	if err = os.MkdirAll(versionsDir, 0o750); err != nil {
+		return fmt.Errorf("failed to create trivy databases directory: %w", err)
+	}
+	versionDir := filepath.Join(versionsDir, version)
+	if _, err = os.Stat(versionDir); errors.Is(err, os.ErrNotExist) {
+		if err = e.fetchDatabases(ctx, source, versionsDir, versionDir); err != nil {
+			return err
+		}
+	}
+
+	if err = e.swapDatabase(version); err != nil {
+		return err
+	}
+	e.logger.InfoContext(ctx, "Trivy databases updated", "db", source.info.DBDigest, "javaDB", source.info.JavaDBDigest)
+
+	e.pruneDatabases(versionsDir, version)
+
+	return nil
+}
+
+// DatabaseReady returns an error until the Trivy databases are downloaded.
+func (e *TrivyEngine) DatabaseReady() error {
+	_, err := e.currentDatabaseVersion()
+	return err
+}
+
+// remoteDatabaseSource resolves the Trivy databases of the configured repositories.
+// The databases are downloaded by digest, so that the downloaded content matches the resolved revision.
+func (e *TrivyEngine) remoteDatabaseSource(ctx context.Context) (*trivyDBSource, error) {
+	dbRef, err := resolveTrivyDBDigest(ctx, e.trivyDBRepository, trivyDB.SchemaVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve trivy-db digest: %w", err)
+	}
+	javaDBRef, err := resolveTrivyDBDigest(ctx, e.trivyJavaDBRepository, javadb.SchemaVersion)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve trivy-java-db digest: %w", err)
+	}
+
+	return &trivyDBSource{
+		revision: dbRef.DigestStr() + javaDBRef.DigestStr(),
+		info:     trivyDBInfo{DBDigest: dbRef.DigestStr(), JavaDBDigest: javaDBRef.DigestStr()},
+		// In-process, runTrivy serializes the downloads with the other executions,
+		// since Trivy holds the vulnerability database in a package-level handle.
+		fetch: func(ctx context.Context, cacheDir string) error {
+			for _, args := range [][]string{
+				{"--download-db-only", "--db-repository", dbRef.String()},
+				{"--download-java-db-only", "--java-db-repository", javaDBRef.String()},
+			} {
+				trivyArgs := append([]string{
+					"image",
+					"--skip-version-check",
+					"--disable-telemetry",
+					"--cache-dir", cacheDir,
+				}, args...)
+				if err := e.runTrivy(ctx, trivyArgs, nil); err != nil {
+					return fmt.Errorf("failed to download trivy databases: %w", err)
+				}
+			}
+			return nil
+		},
+	}, nil
+}
+
+// bundleDatabaseSource opens the Trivy databases of the offline bundle.
+// The VEX repositories of the bundle are linked into the cache directory,
+// where Trivy looks them up.
+func (e *TrivyEngine) bundleDatabaseSource() (*trivyDBSource, error) {
+	db, err := openBundleDB(filepath.Join(e.offline.BundlePath, bundleTrivyDBDir), trivyDBMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open bundled trivy-db: %w", err)
+	}
+	javaDB, err := openBundleDB(filepath.Join(e.offline.BundlePath, bundleTrivyJavaDBDir), trivyJavaDBMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open bundled trivy-java-db: %w", err)
+	}
+
+	return &trivyDBSource{
+		revision: db.revision + javaDB.revision,
+		info:     trivyDBInfo{DBDigest: db.digest, JavaDBDigest: javaDB.digest},
+		fetch: func(_ context.Context, cacheDir string) error {
+			if err := db.extract(trivyDB.Dir(cacheDir)); err != nil {
+				return fmt.Errorf("failed to load bundled trivy-db: %w", err)
+			}
+			if err := javaDB.extract(filepath.Join(cacheDir, "java-db")); err != nil {
+				return fmt.Errorf("failed to load bundled trivy-java-db: %w", err)
+			}
+
+			vexDir, err := filepath.Abs(filepath.Join(e.offline.BundlePath, bundleVEXDir))
+			if err != nil {
+				return fmt.Errorf("failed to resolve bundled VEX repositories: %w", err)
+			}
+			if err = os.MkdirAll(filepath.Join(cacheDir, "vex"), 0o750); err != nil {
+				return fmt.Errorf("failed to create VEX repositories directory: %w", err)
+			}
+			if err = os.Symlink(vexDir, filepath.Join(cacheDir, "vex", "repositories")); err != nil {
+				return fmt.Errorf("failed to link bundled VEX repositories: %w", err)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// fetchDatabases fetches the Trivy databases into a staging directory, renamed to versionDir when complete.
+func (e *TrivyEngine) fetchDatabases(ctx context.Context, source *trivyDBSource, versionsDir, versionDir string) error {
+	stagingDir, err := os.MkdirTemp(versionsDir, ".staging-*")
+	if err != nil {
+		return fmt.Errorf("failed to create trivy databases staging directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(stagingDir); err != nil {
+			e.logger.Error("failed to remove trivy databases staging directory", "error", err)
+		}
+	}()
+
+	if err = source.fetch(ctx, stagingDir); err != nil {
+		return err
+	}
+
+	// Make sure both databases were fetched before using them.
+	for _, dbDir := range []string{trivyDB.Dir(stagingDir), filepath.Join(stagingDir, "java-db")} {
+		if _, err = metadata.NewClient(dbDir).Get(); err != nil {
+			return fmt.Errorf("invalid trivy database in %s: %w", dbDir, err)
+		}
+	}
+
+	infoBytes, err := json.Marshal(source.info)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trivy databases info: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(stagingDir, trivyDBInfoFile), infoBytes, 0o600); err != nil {
+		return fmt.Errorf("failed to write trivy databases info: %w", err)
+	}
+
+	if err = os.Rename(stagingDir, versionDir); err != nil {
+		return fmt.Errorf("failed to move trivy databases: %w", err)
+	}
+
+	return nil
+}
+
+// swapDatabase points the current link to the given version, atomically.
+func (e *TrivyEngine) swapDatabase(version string) error {
+	currentLink := filepath.Join(e.workDir, trivyDBSubPath, trivyDBCurrentLink)
+	newLink := currentLink + ".new"
+	if err := os.Remove(newLink); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale trivy databases link: %w", err)
+	}
+	if err := os.Symlink(filepath.Join(trivyDBVersionsDir, version), newLink); err != nil {
+		return fmt.Errorf("failed to link trivy databases: %w", err)
+	}
+	if err := os.Rename(newLink, currentLink); err != nil {
+		return fmt.Errorf("failed to swap trivy databases: %w", err)
+	}
+
+	return nil
+}
+
+// pruneDatabases removes the versions of the Trivy databases other than the current one.
+// The versions leased by executions in flight are kept, and removed by a later update.
+func (e *TrivyEngine) pruneDatabases(versionsDir, current string) {
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		e.logger.Error("failed to list trivy databases", "error", err)
+		return
+	}
+
+	// The executions lease the current version only:
+	// an unleased previous version cannot be leased anymore.
+	var unused []string
+	e.dbLeases.mu.Lock()
+	for _, entry := range entries {
+		if entry.Name() == current || e.dbLeases.counts[entry.Name()] > 0 {
+			continue
+		}
+		unused = append(unused, entry.Name())
+	}
+	e.dbLeases.mu.Unlock()
+
+	for _, version := range unused {
+		versionDir := filepath.Join(versionsDir, version)
+		e.dbReplicas.forget(versionDir)
+		if err := os.RemoveAll(versionDir); err != nil {
+			e.logger.Error("failed to remove trivy databases", "version", version, "error", err)
+		}
+	}
+}
+
+// currentDatabaseVersion returns the version of the Trivy databases used by the executions.
+func (e *TrivyEngine) currentDatabaseVersion() (string, error) {
+	target, err := os.Readlink(filepath.Join(e.workDir, trivyDBSubPath, trivyDBCurrentLink))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", errTrivyDBNotReady
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read trivy databases link: %w", err)
+	}
+
+	return filepath.Base(target), nil
+}
+
+// acquireDatabase returns the cache directory holding the Trivy databases used by the executions,
+// and the description of the vulnerability database.
+// The directory is resolved once, so that an execution keeps using the same version
+// when the databases are updated concurrently.
+// The version is leased until the returned release function is called, so that it is not removed.
+func (e *TrivyEngine) acquireDatabase() (string, *storagev1alpha1.VulnerabilityDatabase, func(), error) {
+	e.dbLeases.mu.Lock()
+	version, err := e.currentDatabaseVersion()
+	if err != nil {
+		e.dbLeases.mu.Unlock()
+		return "", nil, nil, err
+	}
+	if e.dbLeases.counts == nil {
+		e.dbLeases.counts = map[string]int{}
+	}
+	e.dbLeases.counts[version]++
+	e.dbLeases.mu.Unlock()
+
+	release := func() {
+		e.dbLeases.mu.Lock()
+		defer e.dbLeases.mu.Unlock()
+
+		e.dbLeases.counts[version]--
+		if e.dbLeases.counts[version] == 0 {
+			delete(e.dbLeases.counts, version)
+		}
+	}
+
+	cacheDir, database, err := e.database(version)
+	if err != nil {
+		release()
+		return "", nil, nil, err
+	}
+
+	return cacheDir, database, release, nil
+}
+
+// database returns the cache directory holding the given version of the Trivy databases,
+// and the description of the vulnerability database.
+func (e *TrivyEngine) database(version string) (string, *storagev1alpha1.VulnerabilityDatabase, error) {
+	cacheDir := filepath.Join(e.workDir, trivyDBSubPath, trivyDBVersionsDir, version)
+
+	infoBytes, err := os.ReadFile(filepath.Join(cacheDir, trivyDBInfoFile))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read trivy databases info: %w", err)
+	}
+	info := trivyDBInfo{}
+	if err = json.Unmarshal(infoBytes, &info); err != nil {
+		return "", nil, fmt.Errorf("failed to unmarshal trivy databases info: %w", err)
+	}
+
+	meta, err := metadata.NewClient(trivyDB.Dir(cacheDir)).Get()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read trivy-db metadata: %w", err)
+	}
+
+	return cacheDir, &storagev1alpha1.VulnerabilityDatabase{
+		Version:   strconv.Itoa(meta.Version),
+		UpdatedAt: &metav1.Time{Time: meta.UpdatedAt},
+		Digest:    info.DBDigest,
+	}, nil
+}
+
+// resolveTrivyDBDigest returns the reference by digest of the database in the given repository.
+// As Trivy does, the schema version is used as tag when the repository has none.
+func resolveTrivyDBDigest(ctx context.Context, repository string, schemaVersion int) (name.Digest, error) {
+	ref, err := name.ParseReference(repository, name.WithDefaultTag(""))
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("cannot parse repository %s: %w", repository, err)
+	}
+	if digest, ok := ref.(name.Digest); ok {
+		return digest, nil
+	}
+	tag, _ := ref.(name.Tag)
+	if tag.TagStr() == "" {
+		tag = tag.Tag(strconv.Itoa(schemaVersion))
+	}
+
+	descriptor, err := remote.Head(tag, remote.WithContext(ctx), remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("cannot get manifest of %s: %w", tag, err)
+	}
+
+	return tag.Context().Digest(descriptor.Digest.String()), nil
+}
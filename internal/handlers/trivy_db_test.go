@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTrivyDownload is a Trivy child process writing the metadata of the database it is asked to download,
+// and logging its invocations.
+const fakeTrivyDownload = `#!/bin/sh
+echo "$@" >> "$0.log"
+while [ $# -gt 0 ]; do
+	case "$1" in
+		--cache-dir) cache_dir="$2"; shift ;;
+		--download-db-only) db_dir=db ;;
+		--download-java-db-only) db_dir=java-db ;;
+	esac
+	shift
+done
+mkdir -p "$cache_dir/$db_dir"
+echo '{"Version":2,"NextUpdate":"2025-01-02T00:00:00Z","UpdatedAt":"2025-01-01T00:00:00Z","DownloadedAt":"2025-01-01T01:00:00Z"}' > "$cache_dir/$db_dir/metadata.json"
+`
+
+// pushRandomImage pushes a random image to the given reference and returns its digest.
+func pushRandomImage(t *testing.T, reference string) string {
+	t.Helper()
+
+	ref, err := name.ParseReference(reference)
+	require.NoError(t, err)
+	image, err := random.Image(64, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, image, remote.WithContext(t.Context())))
+	digest, err := image.Digest()
+	require.NoError(t, err)
+
+	return digest.String()
+}
+
+func TestTrivyEngine_UpdateDatabase(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	dbRepository := serverURL.Host + "/trivy-db"
+	javaDBRepository := serverURL.Host + "/trivy-java-db"
+
+	dbDigest := pushRandomImage(t, dbRepository+":2")
+	pushRandomImage(t, javaDBRepository+":1")
+
+	trivyPath := filepath.Join(t.TempDir(), "trivy")
+	require.NoError(t, os.WriteFile(trivyPath, []byte(fakeTrivyDownload), 0o700)) //nolint:gosec // the script must be executable
+
+	workDir := t.TempDir()
+	engine := NewTrivyEngine(nil, workDir, dbRepository, javaDBRepository, &TrivySubprocessConfig{Executable: trivyPath}, nil, slog.Default())
+
+	require.ErrorIs(t, engine.DatabaseReady(), errTrivyDBNotReady)
+	_, _, err = engine.Scan(t.Context(), []byte("{}"), nil)
+	require.ErrorIs(t, err, errTrivyDBNotReady, "scans must not download the databases")
+
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	require.NoError(t, engine.DatabaseReady())
+
+	cacheDir, database, release, err := engine.acquireDatabase()
+	require.NoError(t, err)
+	assert.Equal(t, "2", database.Version)
+	assert.Equal(t, dbDigest, database.Digest)
+	assert.Equal(t, "2025-01-01T00:00:00Z", database.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z"))
+
+	invocations, err := os.ReadFile(trivyPath + ".log")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(invocations)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "--db-repository "+dbRepository+"@"+dbDigest)
+
+	// the databases are downloaded again only when their digest changes
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	invocations, err = os.ReadFile(trivyPath + ".log")
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(invocations)), "\n"), 2)
+
+	// the databases leased by an execution in flight are kept across updates
+	pushRandomImage(t, dbRepository+":2")
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	newCacheDir, _, newRelease, err := engine.acquireDatabase()
+	require.NoError(t, err)
+	newRelease()
+	assert.NotEqual(t, cacheDir, newCacheDir)
+	assert.DirExists(t, cacheDir, "the databases in use must be kept")
+
+	newDBDigest := pushRandomImage(t, dbRepository+":2")
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	_, database, newRelease, err = engine.acquireDatabase()
+	require.NoError(t, err)
+	newRelease()
+	assert.Equal(t, newDBDigest, database.Digest)
+	assert.DirExists(t, cacheDir, "the databases in use must be kept")
+	assert.NoDirExists(t, newCacheDir, "the unused databases must be removed")
+
+	// the released databases are removed by the next update
+	release()
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	assert.NoDirExists(t, cacheDir, "the released databases must be removed")
+
+	versions, err := os.ReadDir(filepath.Join(workDir, trivyDBSubPath, trivyDBVersionsDir))
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+func TestTrivyEngine_UpdateDatabase_DownloadFailure(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	dbRepository := serverURL.Host + "/trivy-db"
+	javaDBRepository := serverURL.Host + "/trivy-java-db"
+	pushRandomImage(t, dbRepository+":2")
+	pushRandomImage(t, javaDBRepository+":1")
+
+	trivyPath := filepath.Join(t.TempDir(), "trivy")
+	require.NoError(t, os.WriteFile(trivyPath, []byte("#!/bin/sh\necho 'download failed' >&2\nexit 1\n"), 0o700)) //nolint:gosec // the script must be executable
+
+	workDir := t.TempDir()
+	engine := NewTrivyEngine(nil, workDir, dbRepository, javaDBRepository, &TrivySubprocessConfig{Executable: trivyPath}, nil, slog.Default())
+
+	err = engine.UpdateDatabase(t.Context())
+	require.ErrorContains(t, err, "download failed")
+	require.ErrorIs(t, engine.DatabaseReady(), errTrivyDBNotReady)
+
+	versions, err := os.ReadDir(filepath.Join(workDir, trivyDBSubPath, trivyDBVersionsDir))
+	require.NoError(t, err)
+	assert.Empty(t, versions, "the staging directory must be removed")
+}
+
+func TestTrivyEngine_RunTrivy_InProcessDownload(t *testing.T) {
+	engine := NewTrivyEngine(nil, t.TempDir(), testTrivyDBRepository, testTrivyJavaDBRepository, nil, nil, slog.Default())
+
+	// In-process, the downloads wait for the executions in flight.
+	inProcessTrivyMu.Lock()
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.runTrivy(ctx, []string{
+			"image",
+			"--skip-version-check",
+			"--disable-telemetry",
+			"--cache-dir", t.TempDir(),
+			"--download-db-only",
+			"--db-repository", "127.0.0.1:1/trivy-db@sha256:" + strings.Repeat("0", 64),
+		}, nil)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("the download must wait for the executions in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	inProcessTrivyMu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("the download must run once the executions in flight complete")
+	}
+}
+
+func Test_resolveTrivyDBDigest(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	repository := serverURL.Host + "/trivy-db"
+
+	schemaDigest := pushRandomImage(t, repository+":2")
+	latestDigest := pushRandomImage(t, repository+":latest")
+	pinnedDigest := "sha256:" + strings.Repeat("a", 64)
+
+	tests := []struct {
+		name           string
+		repository     string
+		expectedDigest string
+		expectedError  string
+	}{
+		{
+			name:           "schema version used as tag",
+			repository:     repository,
+			expectedDigest: schemaDigest,
+		},
+		{
+			name:           "explicit tag",
+			repository:     repository + ":latest",
+			expectedDigest: latestDigest,
+		},
+		{
+			name:           "pinned digest not resolved",
+			repository:     repository + "@" + pinnedDigest,
+			expectedDigest: pinnedDigest,
+		},
+		{
+			name:          "missing tag",
+			repository:    repository + ":missing",
+			expectedError: "cannot get manifest",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			digest, err := resolveTrivyDBDigest(t.Context(), test.repository, 2)
+			if test.expectedError != "" {
+				require.ErrorContains(t, err, test.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedDigest, digest.DigestStr())
+			assert.Equal(t, repository, digest.Context().String())
+		})
+	}
+}
+
+func TestTrivyEngine_UpdateDatabase_DigestUnchanged(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	dbRepository := serverURL.Host + "/trivy-db"
+	javaDBRepository := serverURL.Host + "/trivy-java-db"
+
+	// The database is pinned by digest, so that pushing a new tag does not change it.
+	dbDigest := pushRandomImage(t, dbRepository+":2")
+	pushRandomImage(t, javaDBRepository+":1")
+
+	trivyPath := filepath.Join(t.TempDir(), "trivy")
+	require.NoError(t, os.WriteFile(trivyPath, []byte(fakeTrivyDownload), 0o700)) //nolint:gosec // the script must be executable
+
+	workDir := t.TempDir()
+	engine := NewTrivyEngine(nil, workDir, dbRepository+"@"+dbDigest, javaDBRepository, &TrivySubprocessConfig{Executable: trivyPath}, nil, slog.Default())
+
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+	version, err := engine.currentDatabaseVersion()
+	require.NoError(t, err)
+
+	pushRandomImage(t, dbRepository+":2")
+	require.NoError(t, engine.UpdateDatabase(t.Context()))
+
+	newVersion, err := engine.currentDatabaseVersion()
+	require.NoError(t, err)
+	assert.Equal(t, version, newVersion)
+
+	invocations, err := os.ReadFile(trivyPath + ".log")
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(invocations)), "\n"), 2, "the databases must not be downloaded again")
+}
@@ -0,0 +1,10 @@
+package handlers
+
+import "syscall"
+
+// setMemoryLimit limits the data segment of the current process, which includes
+// the memory allocated by the Go runtime, to the given number of bytes.
+// Allocations beyond the limit fail and make the process exit.
+func setMemoryLimit(limit uint64) error {
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit}) //nolint:wrapcheck // wrapped by the caller
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package handlers
+
+import "errors"
+
+// setMemoryLimit is only supported on Linux.
+func setMemoryLimit(uint64) error {
+	return errors.New("memory limit is only supported on Linux")
+}
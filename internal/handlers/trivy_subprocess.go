@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	trivyCommands "github.com/aquasecurity/trivy/pkg/commands"
+	trivyDB "github.com/aquasecurity/trivy/pkg/db"
+)
+
+const (
+	// TrivySubcommand is the first argument of the worker executable when it runs Trivy as a child process.
+	TrivySubcommand = "trivy"
+	// trivyMemoryLimitEnv holds the memory limit applied by the Trivy child process to itself, in bytes.
+	trivyMemoryLimitEnv = "SBOMSCANNER_TRIVY_MEMORY_LIMIT"
+	// trivyStderrTailSize is the size of the end of the Trivy child process stderr included in the errors.
+	trivyStderrTailSize = 1024
+	// trivyTerminationGracePeriod is the time given to the Trivy child process to exit after being interrupted.
+	trivyTerminationGracePeriod = 5 * time.Second
+	// trivyDBReplicasDir is the directory of a version of the Trivy databases holding the copies
+	// of the vulnerability database used by the Trivy child processes.
+	trivyDBReplicasDir = "replicas"
+)
+
+// inProcessTrivyMu serializes the executions of Trivy in the worker process.
+var inProcessTrivyMu sync.Mutex
+
+// ErrTrivyTimeout is returned when a Trivy execution exceeds the configured timeout.
+// It is not a permanent error, so the message is retried.
+var ErrTrivyTimeout = errors.New("trivy execution timed out")
+
+// TrivySubprocessConfig configures the execution of Trivy in a child process of the worker,
+// so that an execution exhausting the memory or hanging does not affect the other messages in flight.
+type TrivySubprocessConfig struct {
+	// Executable is the path to the worker executable, run with the TrivySubcommand argument.
+	Executable string
+	// MemoryLimit is the maximum memory of the child process, in bytes. Zero means no limit.
+	MemoryLimit int64
+	// MaxProcs is the GOMAXPROCS of the child process, the number of threads running Go code simultaneously.
+	// It is a scheduling setting, not a CPU quota. Zero means the Go runtime default.
+	MaxProcs int
+	// Timeout is the maximum duration of a Trivy execution. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// RunTrivy runs Trivy with the given arguments in the current process.
+// It is the entrypoint of the Trivy child process, which applies the memory limit set by the worker to itself.
+func RunTrivy(ctx context.Context, args []string) error {
+	if memoryLimit := os.Getenv(trivyMemoryLimitEnv); memoryLimit != "" {
+		limit, err := strconv.ParseUint(memoryLimit, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid memory limit %q: %w", memoryLimit, err)
+		}
+		if err = setMemoryLimit(limit); err != nil {
+			return fmt.Errorf("failed to set memory limit: %w", err)
+		}
+	}
+
+	app := trivyCommands.NewApp()
+	app.SetArgs(args)
+
+	return app.ExecuteContext(ctx) //nolint:wrapcheck // the error is printed by the child process
+}
+
+// runTrivy runs a Trivy command with the given arguments and additional environment variables,
+// either in-process or in a child process.
+func (e *TrivyEngine) runTrivy(ctx context.Context, args []string, env map[string]string) error {
+	command := args[0]
+
+	if e.subprocess != nil {
+		return executeTrivy(ctx, command, func(ctx context.Context) error {
+			return e.runTrivySubprocess(ctx, args, env)
+		})
+	}
+
+	// Trivy holds the vulnerability database in a package-level handle, opened with an exclusive lock
+	// and closed at the end of each execution, and the environment is shared by the whole process:
+	// the in-process executions are serialized, including the database downloads.
+	inProcessTrivyMu.Lock()
+	defer inProcessTrivyMu.Unlock()
+
+	restoreEnv, err := setEnv(env)
+	defer restoreEnv()
+	if err != nil {
+		return err
+	}
+
+	app := trivyCommands.NewApp()
+	app.SetArgs(args)
+
+	return executeTrivy(ctx, command, app.ExecuteContext)
+}
+
+// setEnv sets the given environment variables,
+// returning a function restoring their previous values.
+func setEnv(env map[string]string) (func(), error) {
+	previous := make(map[string]*string, len(env))
+	restore := func() {
+		for key, value := range previous {
+			if value == nil {
+				_ = os.Unsetenv(key)
+				continue
+			}
+			_ = os.Setenv(key, *value)
+		}
+	}
+
+	for key, value := range env {
+		if previousValue, found := os.LookupEnv(key); found {
+			previous[key] = &previousValue
+		} else {
+			previous[key] = nil
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return restore, fmt.Errorf("failed to set %s to %s: %w", key, value, err)
+		}
+	}
+
+	return restore, nil
+}
+
+// runTrivySubprocess runs Trivy in a child process, within the configured limits.
+// The child process uses a temporary directory as its working, home and temporary directory,
+// removed when the execution completes.
+func (e *TrivyEngine) runTrivySubprocess(ctx context.Context, args []string, env map[string]string) error {
+	sandboxDir, err := os.MkdirTemp(e.workDir, "trivy.sandbox.*")
+	if err != nil {
+		return fmt.Errorf("failed to create trivy sandbox directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(sandboxDir); err != nil {
+			e.logger.Error("failed to remove trivy sandbox directory", "error", err)
+		}
+	}()
+
+	if e.subprocess.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.subprocess.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, e.subprocess.Executable, append([]string{TrivySubcommand}, args...)...) //nolint:gosec // the executable is the worker itself
+	cmd.Dir = sandboxDir
+	cmd.Env = append(os.Environ(),
+		"HOME="+sandboxDir,
+		"TMPDIR="+sandboxDir,
+	)
+	if e.subprocess.MemoryLimit > 0 {
+		// The Go runtime of the child process collects garbage more aggressively
+		// when approaching the soft limit, before reaching the hard one.
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("GOMEMLIMIT=%d", e.subprocess.MemoryLimit*9/10),
+			fmt.Sprintf("%s=%d", trivyMemoryLimitEnv, e.subprocess.MemoryLimit),
+		)
+	}
+	if e.subprocess.MaxProcs > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GOMAXPROCS=%d", e.subprocess.MaxProcs))
+	}
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	// Give Trivy a chance to clean up before killing it.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = trivyTerminationGracePeriod
+
+	err = cmd.Run()
+	if e.subprocess.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrTrivyTimeout, e.subprocess.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("trivy child process failed: %w: %s", err, stderrTail(stderr.Bytes()))
+	}
+
+	return nil
+}
+
+// stderrTail returns the end of the stderr of a child process.
+func stderrTail(stderr []byte) string {
+	if len(stderr) > trivyStderrTailSize {
+		stderr = stderr[len(stderr)-trivyStderrTailSize:]
+	}
+	return strings.TrimSpace(string(stderr))
+}
+
+// trivyDBReplicas holds the copies of the vulnerability database used by the Trivy child processes.
+// Trivy opens the vulnerability database read-write with an exclusive lock,
+// so concurrent child processes cannot share it: each one scans with its own copy.
+// The copies are kept in the directory of their version of the databases, and reused by the next executions.
+type trivyDBReplicas struct {
+	mu sync.Mutex
+	// free lists the copies not in use, by cache directory.
+	free map[string][]string
+	// created counts the copies created, by cache directory.
+	created map[string]int
+}
+
+// acquire returns a cache directory holding a copy of the vulnerability database of the given cache directory,
+// and the other databases of the cache directory.
+// The copy must be released when the execution completes.
+func (r *trivyDBReplicas) acquire(cacheDir string) (string, error) {
+	r.mu.Lock()
+	if free := r.free[cacheDir]; len(free) > 0 {
+		replicaDir := free[len(free)-1]
+		r.free[cacheDir] = free[:len(free)-1]
+		r.mu.Unlock()
+		return replicaDir, nil
+	}
+	if r.created == nil {
+		r.created = map[string]int{}
+	}
+	replica := r.created[cacheDir]
+	r.created[cacheDir]++
+	r.mu.Unlock()
+
+	replicaDir := filepath.Join(cacheDir, trivyDBReplicasDir, strconv.Itoa(replica))
+	if err := createTrivyDBReplica(cacheDir, replicaDir); err != nil {
+		_ = os.RemoveAll(replicaDir)
+		return "", err
+	}
+
+	return replicaDir, nil
+}
+
+// release makes a copy returned by acquire available to the next executions.
+func (r *trivyDBReplicas) release(cacheDir, replicaDir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.free == nil {
+		r.free = map[string][]string{}
+	}
+	r.free[cacheDir] = append(r.free[cacheDir], replicaDir)
+}
+
+// forget drops the copies of a cache directory, when the directory is removed.
+func (r *trivyDBReplicas) forget(cacheDir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.free, cacheDir)
+	delete(r.created, cacheDir)
+}
+
+// createTrivyDBReplica copies the vulnerability database of cacheDir into replicaDir,
+// and links the other entries of cacheDir, read concurrently without locking, into replicaDir.
+func createTrivyDBReplica(cacheDir, replicaDir string) error {
+	cacheDir, err := filepath.Abs(cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve trivy databases directory: %w", err)
+	}
+	dbDir := trivyDB.Dir(cacheDir)
+	replicaDBDir := trivyDB.Dir(replicaDir)
+	if err = os.MkdirAll(replicaDBDir, 0o750); err != nil {
+		return fmt.Errorf("failed to create trivy database replica directory: %w", err)
+	}
+
+	if err = copyFiles(dbDir, replicaDBDir); err != nil {
+		return fmt.Errorf("failed to copy trivy-db: %w", err)
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to list trivy databases: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == filepath.Base(dbDir) || entry.Name() == trivyDBReplicasDir {
+			continue
+		}
+		if err = os.Symlink(filepath.Join(cacheDir, entry.Name()), filepath.Join(replicaDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to link trivy databases: %w", err)
+		}
+	}
+
+	return nil
+}
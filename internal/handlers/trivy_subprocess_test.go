@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kubewarden/sbomscanner/internal/messaging"
+)
+
+func TestTrivyEngine_RunTrivySubprocess(t *testing.T) {
+	workDir := t.TempDir()
+	outputFile := filepath.Join(t.TempDir(), "output")
+	executable := filepath.Join(t.TempDir(), "worker")
+	script := `#!/bin/sh
+{
+  echo "args=$*"
+  echo "pwd=$(pwd)"
+  echo "HOME=$HOME"
+  echo "TMPDIR=$TMPDIR"
+  echo "GOMAXPROCS=$GOMAXPROCS"
+  echo "GOMEMLIMIT=$GOMEMLIMIT"
+  echo "SBOMSCANNER_TRIVY_MEMORY_LIMIT=$SBOMSCANNER_TRIVY_MEMORY_LIMIT"
+  echo "XDG_DATA_HOME=$XDG_DATA_HOME"
+} > ` + outputFile + "\n"
+	require.NoError(t, os.WriteFile(executable, []byte(script), 0o700)) //nolint:gosec // the script must be executable
+
+	engine := NewTrivyEngine(nil, workDir, testTrivyDBRepository, testTrivyJavaDBRepository, &TrivySubprocessConfig{
+		Executable:  executable,
+		MemoryLimit: 1000,
+		MaxProcs:    2,
+		Timeout:     time.Minute,
+	}, nil, slog.Default())
+
+	err := engine.runTrivy(t.Context(), []string{"sbom", "--format", "json"}, map[string]string{"XDG_DATA_HOME": "/tmp/trivy-home"})
+	require.NoError(t, err)
+
+	outputData, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	output := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(outputData)), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		output[key] = value
+	}
+
+	assert.Equal(t, "trivy sbom --format json", output["args"])
+	sandboxDir := output["pwd"]
+	assert.Equal(t, workDir, filepath.Dir(sandboxDir))
+	assert.Equal(t, sandboxDir, output["HOME"])
+	assert.Equal(t, sandboxDir, output["TMPDIR"])
+	assert.Equal(t, "2", output["GOMAXPROCS"])
+	assert.Equal(t, "900", output["GOMEMLIMIT"])
+	assert.Equal(t, "1000", output["SBOMSCANNER_TRIVY_MEMORY_LIMIT"])
+	assert.Equal(t, "/tmp/trivy-home", output["XDG_DATA_HOME"])
+	assert.NoDirExists(t, sandboxDir, "the sandbox directory should be removed")
+}
+
+func TestTrivyEngine_RunTrivySubprocess_Timeout(t *testing.T) {
+	executable := filepath.Join(t.TempDir(), "worker")
+	require.NoError(t, os.WriteFile(executable, []byte("#!/bin/sh\nexec sleep 10\n"), 0o700)) //nolint:gosec // the script must be executable
+
+	engine := NewTrivyEngine(nil, t.TempDir(), testTrivyDBRepository, testTrivyJavaDBRepository, &TrivySubprocessConfig{
+		Executable: executable,
+		Timeout:    100 * time.Millisecond,
+	}, nil, slog.Default())
+
+	start := time.Now()
+	err := engine.runTrivy(t.Context(), []string{"image"}, nil)
+	require.ErrorIs(t, err, ErrTrivyTimeout)
+	assert.False(t, messaging.IsPermanent(err), "a timeout should be retried")
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestTrivyEngine_RunTrivySubprocess_Failure(t *testing.T) {
+	executable := filepath.Join(t.TempDir(), "worker")
+	require.NoError(t, os.WriteFile(executable, []byte("#!/bin/sh\necho 'fatal error: out of memory' >&2\nexit 2\n"), 0o700)) //nolint:gosec // the script must be executable
+
+	engine := NewTrivyEngine(nil, t.TempDir(), testTrivyDBRepository, testTrivyJavaDBRepository, &TrivySubprocessConfig{
+		Executable: executable,
+	}, nil, slog.Default())
+
+	err := engine.runTrivy(t.Context(), []string{"image"}, nil)
+	require.ErrorContains(t, err, "fatal error: out of memory")
+	assert.False(t, errors.Is(err, ErrTrivyTimeout))
+}
+
+func TestSetEnv(t *testing.T) {
+	t.Setenv("SBOMSCANNER_TEST_EXISTING", "previous")
+	// Registers the variable to be restored at the end of the test.
+	t.Setenv("SBOMSCANNER_TEST_NEW", "")
+	require.NoError(t, os.Unsetenv("SBOMSCANNER_TEST_NEW"))
+
+	restore, err := setEnv(map[string]string{
+		"SBOMSCANNER_TEST_EXISTING": "current",
+		"SBOMSCANNER_TEST_NEW":      "current",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "current", os.Getenv("SBOMSCANNER_TEST_EXISTING"))
+	assert.Equal(t, "current", os.Getenv("SBOMSCANNER_TEST_NEW"))
+
+	restore()
+	assert.Equal(t, "previous", os.Getenv("SBOMSCANNER_TEST_EXISTING"))
+	_, found := os.LookupEnv("SBOMSCANNER_TEST_NEW")
+	assert.False(t, found, "variables not set before must be unset")
+}
+
+func TestTrivyDBReplicas(t *testing.T) {
+	cacheDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "db"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "db", "trivy.db"), []byte("trivy-db"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "db", "metadata.json"), []byte("{}"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(cacheDir, "java-db"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, trivyDBInfoFile), []byte("{}"), 0o600))
+
+	replicas := &trivyDBReplicas{}
+	first, err := replicas.acquire(cacheDir)
+	require.NoError(t, err)
+	second, err := replicas.acquire(cacheDir)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second, "concurrent executions must use their own copy")
+
+	for _, replicaDir := range []string{first, second} {
+		info, err := os.Lstat(filepath.Join(replicaDir, "db", "trivy.db"))
+		require.NoError(t, err)
+		assert.True(t, info.Mode().IsRegular(), "the vulnerability database must be copied")
+		content, err := os.ReadFile(filepath.Join(replicaDir, "db", "trivy.db"))
+		require.NoError(t, err)
+		assert.Equal(t, "trivy-db", string(content))
+
+		target, err := os.Readlink(filepath.Join(replicaDir, "java-db"))
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(cacheDir, "java-db"), target)
+		assert.FileExists(t, filepath.Join(replicaDir, trivyDBInfoFile))
+	}
+
+	// released copies are reused
+	replicas.release(cacheDir, first)
+	reused, err := replicas.acquire(cacheDir)
+	require.NoError(t, err)
+	assert.Equal(t, first, reused)
+
+	// the copies of a removed version are not reused
+	replicas.release(cacheDir, reused)
+	replicas.forget(cacheDir)
+	require.NoError(t, os.RemoveAll(filepath.Join(cacheDir, trivyDBReplicasDir)))
+	recreated, err := replicas.acquire(cacheDir)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(recreated, "db", "trivy.db"))
+}
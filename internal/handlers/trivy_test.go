@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+func Test_writeDockerConfigFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		registryURI string
+	}{
+		{
+			name:        "registry",
+			registryURI: "registry.local:5000",
+		},
+		{
+			name:        "docker hub",
+			registryURI: "docker.io",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			reg, err := name.NewRegistry(test.registryURI)
+			require.NoError(t, err)
+
+			authConfig := &authn.AuthConfig{Username: "user", Password: "password"}
+			require.NoError(t, writeDockerConfigFile(dir, reg, authConfig))
+
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv(dockerConfigEnv, dir)
+
+			authenticator, err := authn.DefaultKeychain.Resolve(reg)
+			require.NoError(t, err)
+			resolved, err := authn.Authorization(t.Context(), authenticator)
+			require.NoError(t, err)
+			assert.Equal(t, "user", resolved.Username)
+			assert.Equal(t, "password", resolved.Password)
+
+			// The mirrors are reached anonymously.
+			mirror, err := name.NewRegistry("mirror.local:5000")
+			require.NoError(t, err)
+			authenticator, err = authn.DefaultKeychain.Resolve(mirror)
+			require.NoError(t, err)
+			assert.Equal(t, authn.Anonymous, authenticator)
+		})
+	}
+}
+
+func Test_trivyRegistryConfig(t *testing.T) {
+	registry := &v1alpha1.Registry{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-registry",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.RegistrySpec{
+			URI:        "docker.io",
+			AuthSecret: "test-registry-auth-secret",
+			Mirrors:    []string{"mirror.local:5000"},
+		},
+	}
+
+	registryConfig, err := trivyRegistryConfig(registry)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"mirrors": map[string][]string{
+			"index.docker.io": {"mirror.local:5000"},
+		},
+	}, registryConfig, "the credentials must not be sent to the mirrors")
+}
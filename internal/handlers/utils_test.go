@@ -3,14 +3,19 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"testing"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/crane"
 	"github.com/google/go-containerregistry/pkg/name"
 	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/registry"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -55,6 +60,19 @@ func (m *testMessage) InProgress() error {
 	return nil
 }
 
+// recordedEvents returns the events recorded so far by the fake recorder.
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
 // Custom keychain for the test registry
 type staticKeychain struct {
 	registry string
@@ -135,3 +153,13 @@ func imageFactory(registryURI, repository, tag, platform, digest string) *storag
 		},
 	}
 }
+
+// newTestTrivyEngine creates a TrivyEngine with the test databases downloaded.
+func newTestTrivyEngine(t *testing.T, k8sClient client.Client, workDir string) *TrivyEngine {
+	t.Helper()
+
+	engine := NewTrivyEngine(k8sClient, workDir, testTrivyDBRepository, testTrivyJavaDBRepository, nil, nil, slog.Default())
+	require.NoError(t, engine.UpdateDatabase(t.Context()), "failed to download the trivy databases")
+
+	return engine
+}
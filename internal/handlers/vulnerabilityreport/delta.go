@@ -0,0 +1,142 @@
+package vulnerabilityreport
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/package-url/packageurl-go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+// firstSeenKey identifies a vulnerability of a package installed at a given path, whatever the package version.
+type firstSeenKey struct {
+	cve         string
+	pkg         string
+	packagePath string
+}
+
+// deltaKey identifies a vulnerability of a package in the delta between two scans, whatever the package version.
+type deltaKey struct {
+	cve string
+	pkg string
+}
+
+// deltaVulnerability is a vulnerability of a package in the delta between two scans.
+type deltaVulnerability struct {
+	purl     string
+	severity string
+}
+
+// packageIdentity returns the package URL without the version, qualifiers and subpath,
+// so that a package keeps its identity when it is upgraded.
+// Package URLs that cannot be parsed are returned as is.
+func packageIdentity(purl string) string {
+	parsed, err := packageurl.FromString(purl)
+	if err != nil {
+		return purl
+	}
+
+	return packageurl.NewPackageURL(parsed.Type, parsed.Namespace, parsed.Name, "", nil, "").ToString()
+}
+
+// TrackFirstSeen sets when the vulnerabilities of the results were found in the image for the first time.
+// The vulnerabilities found by the previous scan keep their first-seen time, even when the package was upgraded,
+// the other ones are first seen at the time of the scan.
+func TrackFirstSeen(results, previous []storagev1alpha1.Result, scannedAt metav1.Time) {
+	firstSeen := make(map[firstSeenKey]*metav1.Time)
+	for _, result := range previous {
+		for _, vuln := range result.Vulnerabilities {
+			if vuln.FirstSeen != nil {
+				firstSeen[firstSeenKey{vuln.CVE, packageIdentity(vuln.PURL), vuln.PackagePath}] = vuln.FirstSeen
+			}
+		}
+	}
+
+	for i := range results {
+		for j := range results[i].Vulnerabilities {
+			vuln := &results[i].Vulnerabilities[j]
+			if seen, found := firstSeen[firstSeenKey{vuln.CVE, packageIdentity(vuln.PURL), vuln.PackagePath}]; found {
+				vuln.FirstSeen = seen.DeepCopy()
+			} else {
+				vuln.FirstSeen = scannedAt.DeepCopy()
+			}
+		}
+	}
+}
+
+// ComputeDelta computes the changes of the vulnerabilities between the previous and the current results.
+// Suppressed vulnerabilities are ignored, so suppressing a vulnerability reports it as fixed.
+// A vulnerability of a package upgraded to a version that is still vulnerable is not reported as a change.
+// The changes are ordered by CVE and PURL.
+func ComputeDelta(previous, current []storagev1alpha1.Result) *storagev1alpha1.ReportDelta {
+	previousVulnerabilities := deltaVulnerabilities(previous)
+	currentVulnerabilities := deltaVulnerabilities(current)
+
+	delta := &storagev1alpha1.ReportDelta{
+		Introduced:      []storagev1alpha1.VulnerabilityChange{},
+		Fixed:           []storagev1alpha1.VulnerabilityChange{},
+		SeverityChanges: []storagev1alpha1.VulnerabilityChange{},
+	}
+	for key, vuln := range currentVulnerabilities {
+		previousVuln, found := previousVulnerabilities[key]
+		switch {
+		case !found:
+			delta.Introduced = append(delta.Introduced, storagev1alpha1.VulnerabilityChange{
+				CVE:      key.cve,
+				PURL:     vuln.purl,
+				Severity: vuln.severity,
+			})
+		case previousVuln.severity != vuln.severity:
+			delta.SeverityChanges = append(delta.SeverityChanges, storagev1alpha1.VulnerabilityChange{
+				CVE:              key.cve,
+				PURL:             vuln.purl,
+				Severity:         vuln.severity,
+				PreviousSeverity: previousVuln.severity,
+			})
+		}
+	}
+	for key, vuln := range previousVulnerabilities {
+		if _, found := currentVulnerabilities[key]; !found {
+			delta.Fixed = append(delta.Fixed, storagev1alpha1.VulnerabilityChange{
+				CVE:      key.cve,
+				PURL:     vuln.purl,
+				Severity: vuln.severity,
+			})
+		}
+	}
+
+	slices.SortFunc(delta.Introduced, compareVulnerabilityChanges)
+	slices.SortFunc(delta.Fixed, compareVulnerabilityChanges)
+	slices.SortFunc(delta.SeverityChanges, compareVulnerabilityChanges)
+
+	return delta
+}
+
+// deltaVulnerabilities returns the vulnerabilities of the results, excluding the suppressed ones.
+// When a package is installed more than once, its first occurrence is used.
+func deltaVulnerabilities(results []storagev1alpha1.Result) map[deltaKey]deltaVulnerability {
+	vulnerabilities := make(map[deltaKey]deltaVulnerability)
+	for _, result := range results {
+		for _, vuln := range result.Vulnerabilities {
+			if vuln.Suppressed {
+				continue
+			}
+			key := deltaKey{vuln.CVE, packageIdentity(vuln.PURL)}
+			if _, found := vulnerabilities[key]; !found {
+				vulnerabilities[key] = deltaVulnerability{purl: vuln.PURL, severity: vuln.Severity}
+			}
+		}
+	}
+
+	return vulnerabilities
+}
+
+// compareVulnerabilityChanges orders the vulnerability changes by CVE and PURL.
+func compareVulnerabilityChanges(a, b storagev1alpha1.VulnerabilityChange) int {
+	return cmp.Or(
+		cmp.Compare(a.CVE, b.CVE),
+		cmp.Compare(a.PURL, b.PURL),
+	)
+}
@@ -0,0 +1,140 @@
+package vulnerabilityreport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+func TestTrackFirstSeen(t *testing.T) {
+	firstScan := metav1.NewTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	secondScan := metav1.NewTime(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	previous := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{CVE: "CVE-2024-0001", PURL: "pkg:golang/a@1.0.0", PackagePath: "/bin/a", FirstSeen: &firstScan},
+				{CVE: "CVE-2024-0003", PURL: "pkg:golang/c@1.0.0", PackagePath: "/bin/c", FirstSeen: &firstScan},
+			},
+		},
+	}
+	results := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{CVE: "CVE-2024-0001", PURL: "pkg:golang/a@1.0.0", PackagePath: "/bin/a"},
+				// the same package installed at another path
+				{CVE: "CVE-2024-0001", PURL: "pkg:golang/a@1.0.0", PackagePath: "/bin/b"},
+				{CVE: "CVE-2024-0002", PURL: "pkg:golang/a@1.0.0", PackagePath: "/bin/a"},
+				// the package was upgraded, without fixing the CVE
+				{CVE: "CVE-2024-0003", PURL: "pkg:golang/c@1.1.0", PackagePath: "/bin/c"},
+			},
+		},
+	}
+
+	TrackFirstSeen(results, previous, secondScan)
+
+	vulnerabilities := results[0].Vulnerabilities
+	require.NotNil(t, vulnerabilities[0].FirstSeen)
+	assert.True(t, firstScan.Equal(vulnerabilities[0].FirstSeen))
+	require.NotNil(t, vulnerabilities[1].FirstSeen)
+	assert.True(t, secondScan.Equal(vulnerabilities[1].FirstSeen))
+	require.NotNil(t, vulnerabilities[2].FirstSeen)
+	assert.True(t, secondScan.Equal(vulnerabilities[2].FirstSeen))
+	require.NotNil(t, vulnerabilities[3].FirstSeen)
+	assert.True(t, firstScan.Equal(vulnerabilities[3].FirstSeen))
+}
+
+func TestComputeDelta(t *testing.T) {
+	previous := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{CVE: "CVE-2024-0001", PURL: "pkg:golang/a@1.0.0", Severity: "HIGH"},
+				{CVE: "CVE-2024-0002", PURL: "pkg:golang/a@1.0.0", Severity: "MEDIUM"},
+				{CVE: "CVE-2024-0003", PURL: "pkg:golang/b@1.0.0", Severity: "LOW"},
+				{CVE: "CVE-2024-0004", PURL: "pkg:golang/b@1.0.0", Severity: "LOW"},
+				{CVE: "CVE-2024-0005", PURL: "pkg:golang/b@1.0.0", Severity: "HIGH", Suppressed: true},
+			},
+		},
+	}
+	current := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{CVE: "CVE-2024-0001", PURL: "pkg:golang/a@1.0.0", Severity: "CRITICAL"},
+				{CVE: "CVE-2024-0002", PURL: "pkg:golang/a@1.0.0", Severity: "MEDIUM"},
+				// the same package installed at another path
+				{CVE: "CVE-2024-0002", PURL: "pkg:golang/a@1.0.0", Severity: "MEDIUM"},
+				{CVE: "CVE-2024-0004", PURL: "pkg:golang/b@1.0.0", Severity: "LOW", Suppressed: true},
+				{CVE: "CVE-2024-0005", PURL: "pkg:golang/b@1.0.0", Severity: "HIGH"},
+				{CVE: "CVE-2024-0006", PURL: "pkg:golang/c@1.0.0", Severity: "CRITICAL"},
+			},
+		},
+	}
+
+	delta := ComputeDelta(previous, current)
+
+	expected := &storagev1alpha1.ReportDelta{
+		Introduced: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0005", PURL: "pkg:golang/b@1.0.0", Severity: "HIGH"},
+			{CVE: "CVE-2024-0006", PURL: "pkg:golang/c@1.0.0", Severity: "CRITICAL"},
+		},
+		Fixed: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0003", PURL: "pkg:golang/b@1.0.0", Severity: "LOW"},
+			{CVE: "CVE-2024-0004", PURL: "pkg:golang/b@1.0.0", Severity: "LOW"},
+		},
+		SeverityChanges: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0001", PURL: "pkg:golang/a@1.0.0", Severity: "CRITICAL", PreviousSeverity: "HIGH"},
+		},
+	}
+	assert.Equal(t, expected, delta)
+}
+
+func TestComputeDelta_PackageUpgradedStillVulnerable(t *testing.T) {
+	previous := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{CVE: "CVE-2024-0001", PURL: "pkg:deb/debian/openssl@3.0.1?arch=amd64", Severity: "HIGH"},
+				{CVE: "CVE-2024-0002", PURL: "pkg:deb/debian/openssl@3.0.1?arch=amd64", Severity: "LOW"},
+			},
+		},
+	}
+	current := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				// the package was upgraded, without fixing the CVE
+				{CVE: "CVE-2024-0001", PURL: "pkg:deb/debian/openssl@3.0.2?arch=amd64", Severity: "HIGH"},
+			},
+		},
+	}
+
+	delta := ComputeDelta(previous, current)
+
+	expected := &storagev1alpha1.ReportDelta{
+		Introduced: []storagev1alpha1.VulnerabilityChange{},
+		Fixed: []storagev1alpha1.VulnerabilityChange{
+			{CVE: "CVE-2024-0002", PURL: "pkg:deb/debian/openssl@3.0.1?arch=amd64", Severity: "LOW"},
+		},
+		SeverityChanges: []storagev1alpha1.VulnerabilityChange{},
+	}
+	assert.Equal(t, expected, delta)
+}
+
+func TestComputeDelta_NoChanges(t *testing.T) {
+	results := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{CVE: "CVE-2024-0001", PURL: "pkg:golang/a@1.0.0", Severity: "HIGH"},
+			},
+		},
+	}
+
+	delta := ComputeDelta(results, results)
+
+	assert.Empty(t, delta.Introduced)
+	assert.Empty(t, delta.Fixed)
+	assert.Empty(t, delta.SeverityChanges)
+}
@@ -0,0 +1,317 @@
+package vulnerabilityreport
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+// GrypeDocument is the subset of the Grype JSON output used to build a VulnerabilityReport.
+// See: https://github.com/anchore/grype/tree/main/grype/presenter/models
+type GrypeDocument struct {
+	Matches        []GrypeMatch `json:"matches"`
+	IgnoredMatches []GrypeMatch `json:"ignoredMatches"`
+	Distro         GrypeDistro  `json:"distro"`
+	Descriptor     struct {
+		DB struct {
+			Status GrypeDBStatus `json:"status"`
+		} `json:"db"`
+	} `json:"descriptor"`
+}
+
+// GrypeDBStatus describes the vulnerability database used by Grype.
+type GrypeDBStatus struct {
+	SchemaVersion string `json:"schemaVersion"`
+	Built         string `json:"built"`
+}
+
+// GrypeMatch is a vulnerability affecting a package.
+type GrypeMatch struct {
+	Vulnerability          GrypeVulnerability   `json:"vulnerability"`
+	RelatedVulnerabilities []GrypeVulnerability `json:"relatedVulnerabilities"`
+	Artifact               GrypePackage         `json:"artifact"`
+	AppliedIgnoreRules     []GrypeIgnoreRule    `json:"appliedIgnoreRules"`
+}
+
+// GrypeVulnerability contains the details of a vulnerability.
+type GrypeVulnerability struct {
+	ID          string      `json:"id"`
+	DataSource  string      `json:"dataSource"`
+	Severity    string      `json:"severity"`
+	URLs        []string    `json:"urls"`
+	Description string      `json:"description"`
+	CVSS        []GrypeCVSS `json:"cvss"`
+	Fix         GrypeFix    `json:"fix"`
+}
+
+// GrypeCVSS contains a CVSS score of a vulnerability.
+type GrypeCVSS struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	Vector  string `json:"vector"`
+	Metrics struct {
+		BaseScore float64 `json:"baseScore"`
+	} `json:"metrics"`
+}
+
+// GrypeFix contains the versions fixing a vulnerability.
+type GrypeFix struct {
+	Versions []string `json:"versions"`
+	State    string   `json:"state"`
+}
+
+// GrypePackage is a package found in the SBOM.
+type GrypePackage struct {
+	Name      string          `json:"name"`
+	Version   string          `json:"version"`
+	Type      string          `json:"type"`
+	PURL      string          `json:"purl"`
+	Locations []GrypeLocation `json:"locations"`
+}
+
+// GrypeLocation is the location of a package in the image.
+type GrypeLocation struct {
+	Path    string `json:"path"`
+	LayerID string `json:"layerID"`
+}
+
+// GrypeIgnoreRule is the rule that suppressed a match.
+type GrypeIgnoreRule struct {
+	Reason           string `json:"reason"`
+	VEXStatus        string `json:"vex-status"`
+	VEXJustification string `json:"vex-justification"`
+}
+
+// GrypeDistro is the Linux distribution of the image.
+type GrypeDistro struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// grypeOSPackageTypes are the Syft package types installed by the package manager of the distribution.
+var grypeOSPackageTypes = map[string]bool{
+	"alpm":    true,
+	"apk":     true,
+	"deb":     true,
+	"portage": true,
+	"rpm":     true,
+}
+
+// grypeBinaryPackageTypes maps the Syft package types found in compiled binaries to the Trivy ones.
+var grypeBinaryPackageTypes = map[string]string{
+	"go-module":  "gobinary",
+	"rust-crate": "rustbinary",
+}
+
+// grypeLangPackageTypes maps the Syft language package types to the Trivy ones, when they differ.
+var grypeLangPackageTypes = map[string]string{
+	"java-archive": "jar",
+	"python":       "python-pkg",
+	"gem":          "gemspec",
+	"dotnet":       "dotnet-core",
+	"php-composer": "composer",
+}
+
+// grypeCVSSSources maps the Grype CVSS sources to the Trivy ones.
+var grypeCVSSSources = map[string]string{
+	"nvd@nist.gov":                   "nvd",
+	"security-advisories@github.com": "ghsa",
+	"secalert@redhat.com":            "redhat",
+}
+
+// NewFromGrypeResults converts the results obtained by the Grype scan,
+// into the SBOMscanner VulnerabilityReport format.
+// The matches are grouped by target and class, as in the Trivy results:
+// the packages of the distribution form a single target, while the other packages
+// are grouped by the file they were found in.
+func NewFromGrypeResults(document GrypeDocument) ([]storagev1alpha1.Result, error) {
+	results := []storagev1alpha1.Result{}
+	resultIndexes := map[string]int{}
+
+	addMatch := func(match GrypeMatch, suppressed bool) error {
+		if match.Vulnerability.ID == "" {
+			return fmt.Errorf("grype match for package %s has no vulnerability ID", match.Artifact.Name)
+		}
+
+		result := newGrypeResult(match.Artifact, document.Distro)
+		key := result.Target + "/" + string(result.Class) + "/" + result.Type
+		index, ok := resultIndexes[key]
+		if !ok {
+			index = len(results)
+			resultIndexes[key] = index
+			results = append(results, result)
+		}
+
+		vuln := newGrypeVulnerability(match, result.Class)
+		vuln.Suppressed = suppressed
+		if suppressed {
+			vuln.VEXStatus = newGrypeVEXStatus(match.AppliedIgnoreRules)
+		}
+		results[index].Vulnerabilities = append(results[index].Vulnerabilities, vuln)
+
+		return nil
+	}
+
+	for _, match := range document.Matches {
+		if err := addMatch(match, false); err != nil {
+			return nil, err
+		}
+	}
+	// vulnerabilities suppressed by VEX documents or ignore rules
+	for _, match := range document.IgnoredMatches {
+		if err := addMatch(match, true); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func newGrypeResult(artifact GrypePackage, distro GrypeDistro) storagev1alpha1.Result {
+	if grypeOSPackageTypes[artifact.Type] {
+		target := strings.TrimSpace(distro.Name + " " + distro.Version)
+		if target == "" {
+			target = artifact.Type
+		}
+		return storagev1alpha1.Result{
+			Target: target,
+			Class:  storagev1alpha1.ClassOSPackages,
+			Type:   distroType(distro, artifact.Type),
+		}
+	}
+
+	if binaryType, ok := grypeBinaryPackageTypes[artifact.Type]; ok {
+		return storagev1alpha1.Result{
+			Target: grypePackagePath(artifact),
+			Class:  storagev1alpha1.ClassBinary,
+			Type:   binaryType,
+		}
+	}
+
+	langType, ok := grypeLangPackageTypes[artifact.Type]
+	if !ok {
+		langType = artifact.Type
+	}
+	return storagev1alpha1.Result{
+		Target: grypePackagePath(artifact),
+		Class:  storagev1alpha1.ClassLangPackages,
+		Type:   langType,
+	}
+}
+
+// newGrypeVulnerability sets the vulnerability values from grype
+func newGrypeVulnerability(match GrypeMatch, class storagev1alpha1.Class) storagev1alpha1.Vulnerability {
+	vuln := match.Vulnerability
+	// GitHub advisories carry the details of the related CVE, when there is one.
+	description := vuln.Description
+	cvss := vuln.CVSS
+	for _, related := range match.RelatedVulnerabilities {
+		if description == "" {
+			description = related.Description
+		}
+		if len(cvss) == 0 {
+			cvss = related.CVSS
+		}
+	}
+
+	references := vuln.URLs
+	if vuln.DataSource != "" && !slices.Contains(references, vuln.DataSource) {
+		references = append([]string{vuln.DataSource}, references...)
+	}
+
+	fixedVersions := vuln.Fix.Versions
+	if fixedVersions == nil {
+		fixedVersions = []string{}
+	}
+
+	var packagePath string
+	if class != storagev1alpha1.ClassOSPackages {
+		packagePath = grypePackagePath(match.Artifact)
+	}
+
+	var diffID string
+	if len(match.Artifact.Locations) > 0 {
+		diffID = match.Artifact.Locations[0].LayerID
+	}
+
+	return storagev1alpha1.Vulnerability{
+		CVE:              vuln.ID,
+		PackageName:      match.Artifact.Name,
+		PackagePath:      packagePath,
+		PURL:             match.Artifact.PURL,
+		InstalledVersion: match.Artifact.Version,
+		FixedVersions:    fixedVersions,
+		DiffID:           diffID,
+		Description:      description,
+		Severity:         grypeSeverity(vuln.Severity),
+		References:       references,
+		CVSS:             newGrypeCVSS(cvss),
+	}
+}
+
+func newGrypeCVSS(grypeCVSS []GrypeCVSS) map[string]storagev1alpha1.CVSS {
+	cvssMap := make(map[string]storagev1alpha1.CVSS, len(grypeCVSS))
+	for _, cvss := range grypeCVSS {
+		// Only CVSS v3 scores are reported.
+		if !strings.HasPrefix(cvss.Version, "3") {
+			continue
+		}
+		source, ok := grypeCVSSSources[cvss.Source]
+		if !ok {
+			source = cvss.Source
+		}
+		cvssMap[source] = storagev1alpha1.CVSS{
+			V3Score:  strconv.FormatFloat(cvss.Metrics.BaseScore, 'f', -1, 64),
+			V3Vector: cvss.Vector,
+		}
+	}
+	return cvssMap
+}
+
+func newGrypeVEXStatus(rules []GrypeIgnoreRule) *storagev1alpha1.VEXStatus {
+	for _, rule := range rules {
+		if rule.VEXStatus == "" {
+			continue
+		}
+		return &storagev1alpha1.VEXStatus{
+			Status:    rule.VEXStatus,
+			Statement: rule.VEXJustification,
+		}
+	}
+	return nil
+}
+
+// grypeSeverity converts the Grype severity to the Trivy one.
+// Trivy reports the "negligible" severity of the distributions as "LOW".
+func grypeSeverity(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL":
+		return "CRITICAL"
+	case "HIGH":
+		return "HIGH"
+	case "MEDIUM":
+		return "MEDIUM"
+	case "LOW", "NEGLIGIBLE":
+		return "LOW"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// distroType returns the Trivy type of the distribution, e.g. "alpine" or "debian".
+func distroType(distro GrypeDistro, packageType string) string {
+	if distro.Name != "" {
+		return strings.ToLower(distro.Name)
+	}
+	return packageType
+}
+
+func grypePackagePath(artifact GrypePackage) string {
+	if len(artifact.Locations) == 0 {
+		return ""
+	}
+	return fixPath(artifact.Locations[0].Path)
+}
@@ -0,0 +1,124 @@
+package vulnerabilityreport
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+func TestNewFromGrypeResults(t *testing.T) {
+	reportData, err := os.ReadFile(filepath.Join("..", "..", "..", "test", "fixtures", "vulnerabilityreport", "grype.report.json"))
+	require.NoError(t, err)
+
+	grypeDocument := GrypeDocument{}
+	err = json.Unmarshal(reportData, &grypeDocument)
+	require.NoError(t, err)
+
+	got, err := NewFromGrypeResults(grypeDocument)
+	require.NoError(t, err)
+
+	expected := []storagev1alpha1.Result{
+		{
+			Target: "/nginx-ingress-controller",
+			Class:  "binary",
+			Type:   "gobinary",
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{
+					CVE:              "CVE-2024-45336",
+					PackageName:      "stdlib",
+					PackagePath:      "/nginx-ingress-controller",
+					PURL:             "pkg:golang/stdlib@go1.23.4",
+					InstalledVersion: "go1.23.4",
+					FixedVersions:    []string{"1.22.11", "1.23.5"},
+					DiffID:           "sha256:d37a3e42d123ca619ceab4bbe3c1e9a96d0a837e5e0e3052b33dbd0e842c5661",
+					Description:      "Lorem ipsum",
+					Severity:         "MEDIUM",
+					References: []string{
+						"https://nvd.nist.gov/vuln/detail/CVE-2024-45336",
+						"https://go.dev/issue/70530",
+					},
+					CVSS: map[string]storagev1alpha1.CVSS{
+						"nvd": {
+							V3Vector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:N/A:N",
+							V3Score:  "5.9",
+						},
+					},
+				},
+				{
+					CVE:              "GHSA-qxp5-gwg8-xv66",
+					PackageName:      "golang.org/x/net",
+					PackagePath:      "/nginx-ingress-controller",
+					PURL:             "pkg:golang/golang.org/x/net@v0.33.0",
+					InstalledVersion: "v0.33.0",
+					FixedVersions:    []string{"0.36.0"},
+					DiffID:           "sha256:d37a3e42d123ca619ceab4bbe3c1e9a96d0a837e5e0e3052b33dbd0e842c5661",
+					Description:      "Lorem ipsum",
+					Severity:         "MEDIUM",
+					References: []string{
+						"https://github.com/advisories/GHSA-qxp5-gwg8-xv66",
+					},
+					CVSS: map[string]storagev1alpha1.CVSS{
+						"ghsa": {
+							V3Vector: "CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:L/I:N/A:L",
+							V3Score:  "4.4",
+						},
+					},
+				},
+			},
+		},
+		{
+			Target: "alpine 3.21.2",
+			Class:  "os-pkgs",
+			Type:   "alpine",
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{
+					CVE:              "CVE-2024-12797",
+					PackageName:      "libssl3",
+					PURL:             "pkg:apk/alpine/libssl3@3.3.2-r4?arch=x86_64&distro=alpine-3.21.2",
+					InstalledVersion: "3.3.2-r4",
+					FixedVersions:    []string{"3.3.3-r0"},
+					DiffID:           "sha256:a0904247e36a7726c03c71ee48f3e64462021c88dafeb13f37fdaf613b27f11c",
+					Severity:         "HIGH",
+					References: []string{
+						"https://security.alpinelinux.org/vuln/CVE-2024-12797",
+					},
+					CVSS: map[string]storagev1alpha1.CVSS{},
+				},
+				{
+					CVE:              "CVE-2024-13176",
+					PackageName:      "libssl3",
+					PURL:             "pkg:apk/alpine/libssl3@3.3.2-r4?arch=x86_64&distro=alpine-3.21.2",
+					InstalledVersion: "3.3.2-r4",
+					FixedVersions:    []string{},
+					DiffID:           "sha256:a0904247e36a7726c03c71ee48f3e64462021c88dafeb13f37fdaf613b27f11c",
+					Severity:         "LOW",
+					References: []string{
+						"https://security.alpinelinux.org/vuln/CVE-2024-13176",
+					},
+					CVSS:       map[string]storagev1alpha1.CVSS{},
+					Suppressed: true,
+					VEXStatus: &storagev1alpha1.VEXStatus{
+						Status:    "not_affected",
+						Statement: "vulnerable_code_not_in_execute_path",
+					},
+				},
+			},
+		},
+	}
+	require.Equal(t, expected, got)
+
+	summary := ComputeSummary(got)
+	require.Equal(t, storagev1alpha1.Summary{High: 1, Medium: 2, Suppressed: 1}, summary)
+}
+
+func TestNewFromGrypeResults_MissingVulnerabilityID(t *testing.T) {
+	_, err := NewFromGrypeResults(GrypeDocument{
+		Matches: []GrypeMatch{{Artifact: GrypePackage{Name: "stdlib", Type: "go-module"}}},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package vulnerabilityreport
+
+import (
+	"encoding/base64"
+	"slices"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+// ComputeLayers groups the vulnerabilities of the results by the image layer introducing them.
+// The layer reports follow the order of the image layers.
+// Vulnerabilities that cannot be attributed to a layer of the image are not part of any layer report.
+func ComputeLayers(results []storagev1alpha1.Result, imageLayers []storagev1alpha1.ImageLayer) []storagev1alpha1.LayerReport {
+	layers := make([]storagev1alpha1.LayerReport, 0, len(imageLayers))
+	layerIndexes := make(map[string]int, len(imageLayers))
+
+	for _, imageLayer := range imageLayers {
+		if _, found := layerIndexes[imageLayer.DiffID]; found {
+			// The same layer can be added more than once to an image, the vulnerabilities belong to the first one.
+			continue
+		}
+		layerIndexes[imageLayer.DiffID] = len(layers)
+		layers = append(layers, storagev1alpha1.LayerReport{
+			DiffID:    imageLayer.DiffID,
+			Digest:    imageLayer.Digest,
+			Command:   decodeCommand(imageLayer.Command),
+			BaseImage: imageLayer.BaseImage,
+			CVEs:      []string{},
+		})
+	}
+
+	for _, result := range results {
+		for _, vuln := range result.Vulnerabilities {
+			index, found := layerIndexes[vuln.DiffID]
+			if !found {
+				continue
+			}
+			layer := &layers[index]
+			addToSummary(&layer.Summary, vuln)
+			if !vuln.Suppressed && !slices.Contains(layer.CVEs, vuln.CVE) {
+				layer.CVEs = append(layer.CVEs, vuln.CVE)
+			}
+		}
+	}
+
+	for i := range layers {
+		slices.Sort(layers[i].CVEs)
+	}
+
+	return layers
+}
+
+// decodeCommand decodes the base64 encoded command of an image layer.
+// The command is returned as is when it is not base64 encoded.
+func decodeCommand(command string) string {
+	decoded, err := base64.StdEncoding.DecodeString(command)
+	if err != nil {
+		return command
+	}
+
+	return string(decoded)
+}
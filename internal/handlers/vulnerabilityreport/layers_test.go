@@ -0,0 +1,77 @@
+package vulnerabilityreport
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+func TestComputeLayers(t *testing.T) {
+	imageLayers := []storagev1alpha1.ImageLayer{
+		{
+			DiffID:    "sha256:base",
+			Digest:    "sha256:base-digest",
+			Command:   base64.StdEncoding.EncodeToString([]byte("ADD rootfs.tar.xz /")),
+			BaseImage: true,
+		},
+		{
+			DiffID:  "sha256:app",
+			Digest:  "sha256:app-digest",
+			Command: base64.StdEncoding.EncodeToString([]byte("RUN apt-get install -y curl")),
+		},
+		{
+			DiffID:  "sha256:config",
+			Digest:  "sha256:config-digest",
+			Command: base64.StdEncoding.EncodeToString([]byte("COPY config /etc/app")),
+		},
+	}
+	results := []storagev1alpha1.Result{
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				{CVE: "CVE-2024-0002", Severity: "CRITICAL", DiffID: "sha256:base"},
+				{CVE: "CVE-2024-0001", Severity: "HIGH", DiffID: "sha256:base"},
+				{CVE: "CVE-2024-0003", Severity: "LOW", DiffID: "sha256:app"},
+				{CVE: "CVE-2024-0004", Severity: "MEDIUM", DiffID: "sha256:app", Suppressed: true},
+				{CVE: "CVE-2024-0005", Severity: "HIGH", DiffID: "sha256:unknown"},
+			},
+		},
+		{
+			Vulnerabilities: []storagev1alpha1.Vulnerability{
+				// the same CVE affecting another package of the layer
+				{CVE: "CVE-2024-0003", Severity: "LOW", DiffID: "sha256:app"},
+				{CVE: "CVE-2024-0006", Severity: "UNKNOWN"},
+			},
+		},
+	}
+
+	layers := ComputeLayers(results, imageLayers)
+
+	expected := []storagev1alpha1.LayerReport{
+		{
+			DiffID:    "sha256:base",
+			Digest:    "sha256:base-digest",
+			Command:   "ADD rootfs.tar.xz /",
+			BaseImage: true,
+			Summary:   storagev1alpha1.Summary{Critical: 1, High: 1},
+			CVEs:      []string{"CVE-2024-0001", "CVE-2024-0002"},
+		},
+		{
+			DiffID:  "sha256:app",
+			Digest:  "sha256:app-digest",
+			Command: "RUN apt-get install -y curl",
+			Summary: storagev1alpha1.Summary{Low: 2, Suppressed: 1},
+			CVEs:    []string{"CVE-2024-0003"},
+		},
+		{
+			DiffID:  "sha256:config",
+			Digest:  "sha256:config-digest",
+			Command: "COPY config /etc/app",
+			CVEs:    []string{},
+		},
+	}
+
+	assert.Equal(t, expected, layers)
+}
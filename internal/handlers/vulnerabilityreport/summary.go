@@ -5,35 +5,33 @@ import (
 )
 
 func ComputeSummary(results []storagev1alpha1.Result) storagev1alpha1.Summary {
-	var critical, high, medium, low, unknown, suppressed int
+	summary := storagev1alpha1.Summary{}
 
 	for _, result := range results {
 		for _, vuln := range result.Vulnerabilities {
-			if vuln.Suppressed {
-				suppressed++
-				continue
-			}
-			switch vuln.Severity {
-			case "CRITICAL":
-				critical++
-			case "HIGH":
-				high++
-			case "MEDIUM":
-				medium++
-			case "LOW":
-				low++
-			case "UNKNOWN":
-				unknown++
-			}
+			addToSummary(&summary, vuln)
 		}
 	}
 
-	return storagev1alpha1.Summary{
-		Critical:   critical,
-		High:       high,
-		Medium:     medium,
-		Low:        low,
-		Unknown:    unknown,
-		Suppressed: suppressed,
+	return summary
+}
+
+// addToSummary counts the vulnerability in the summary.
+func addToSummary(summary *storagev1alpha1.Summary, vuln storagev1alpha1.Vulnerability) {
+	if vuln.Suppressed {
+		summary.Suppressed++
+		return
+	}
+	switch vuln.Severity {
+	case "CRITICAL":
+		summary.Critical++
+	case "HIGH":
+		summary.High++
+	case "MEDIUM":
+		summary.Medium++
+	case "LOW":
+		summary.Low++
+	case "UNKNOWN":
+		summary.Unknown++
 	}
 }
@@ -0,0 +1,81 @@
+package vulnerabilityreport
+
+import (
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+// ComputeBaseImageUpgrades lists the newer tags of the base image repository fixing CVEs of the base image layers.
+// The newer tags are the ones of the reports of the base image repository, for the same platform,
+// whose version is greater than the one of the base image and with the same variant (e.g. "1.24-alpine" for "1.23-alpine").
+// Tags that are not versions, like "latest", are ignored.
+// The upgrades are ordered by version.
+func ComputeBaseImageUpgrades(
+	baseImage storagev1alpha1.BaseImage,
+	platform string,
+	layers []storagev1alpha1.LayerReport,
+	baseImageReports []storagev1alpha1.VulnerabilityReport,
+) []storagev1alpha1.BaseImageUpgrade {
+	baseVersion, err := semver.NewVersion(baseImage.Tag)
+	if err != nil {
+		return nil
+	}
+
+	baseImageCVEs := sets.New[string]()
+	for _, layer := range layers {
+		if layer.BaseImage {
+			baseImageCVEs.Insert(layer.CVEs...)
+		}
+	}
+	if baseImageCVEs.Len() == 0 {
+		return nil
+	}
+
+	versions := make(map[string]*semver.Version)
+	var upgrades []storagev1alpha1.BaseImageUpgrade
+	for _, report := range baseImageReports {
+		metadata := report.GetImageMetadata()
+		if metadata.RegistryURI != baseImage.RegistryURI || metadata.Repository != baseImage.Repository || metadata.Platform != platform {
+			continue
+		}
+		version, err := semver.NewVersion(metadata.Tag)
+		if err != nil || version.Prerelease() != baseVersion.Prerelease() || !version.GreaterThan(baseVersion) {
+			continue
+		}
+
+		fixedCVEs := baseImageCVEs.Difference(reportCVEs(report.Report))
+		if fixedCVEs.Len() == 0 {
+			continue
+		}
+		versions[metadata.Tag] = version
+		upgrades = append(upgrades, storagev1alpha1.BaseImageUpgrade{
+			Tag:       metadata.Tag,
+			Digest:    metadata.Digest,
+			FixedCVEs: sets.List(fixedCVEs),
+		})
+	}
+
+	slices.SortFunc(upgrades, func(a, b storagev1alpha1.BaseImageUpgrade) int {
+		return versions[a.Tag].Compare(versions[b.Tag])
+	})
+
+	return upgrades
+}
+
+// reportCVEs returns the CVEs of the report, excluding the suppressed ones.
+func reportCVEs(report storagev1alpha1.Report) sets.Set[string] {
+	cves := sets.New[string]()
+	for _, result := range report.Results {
+		for _, vuln := range result.Vulnerabilities {
+			if !vuln.Suppressed {
+				cves.Insert(vuln.CVE)
+			}
+		}
+	}
+
+	return cves
+}
@@ -0,0 +1,86 @@
+package vulnerabilityreport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	storagev1alpha1 "github.com/kubewarden/sbomscanner/api/storage/v1alpha1"
+)
+
+// newBaseImageReport returns the report of a tag of the alpine repository affected by the given CVEs.
+func newBaseImageReport(tag, platform string, cves ...string) storagev1alpha1.VulnerabilityReport {
+	vulnerabilities := []storagev1alpha1.Vulnerability{}
+	for _, cve := range cves {
+		vulnerabilities = append(vulnerabilities, storagev1alpha1.Vulnerability{CVE: cve})
+	}
+
+	return storagev1alpha1.VulnerabilityReport{
+		ImageMetadata: storagev1alpha1.ImageMetadata{
+			RegistryURI: "index.docker.io",
+			Repository:  "library/alpine",
+			Tag:         tag,
+			Platform:    platform,
+			Digest:      "sha256:" + tag,
+		},
+		Report: storagev1alpha1.Report{
+			Results: []storagev1alpha1.Result{{Vulnerabilities: vulnerabilities}},
+		},
+	}
+}
+
+func TestComputeBaseImageUpgrades(t *testing.T) {
+	baseImage := storagev1alpha1.BaseImage{
+		RegistryURI: "index.docker.io",
+		Repository:  "library/alpine",
+		Tag:         "3.19",
+	}
+	layers := []storagev1alpha1.LayerReport{
+		{BaseImage: true, CVEs: []string{"CVE-2024-0001", "CVE-2024-0002", "CVE-2024-0003"}},
+		// CVEs of the application layers are not fixed by the base image
+		{CVEs: []string{"CVE-2024-0004"}},
+	}
+	baseImageReports := []storagev1alpha1.VulnerabilityReport{
+		newBaseImageReport("3.21", "linux/amd64"),
+		newBaseImageReport("3.20", "linux/amd64", "CVE-2024-0002", "CVE-2024-0003"),
+		newBaseImageReport("3.19", "linux/amd64", "CVE-2024-0001", "CVE-2024-0002", "CVE-2024-0003"),
+		newBaseImageReport("3.18", "linux/amd64"),
+		newBaseImageReport("3.22", "linux/arm64"),
+		newBaseImageReport("3.22-slim", "linux/amd64"),
+		newBaseImageReport("latest", "linux/amd64"),
+		// the tag fixes none of the CVEs
+		newBaseImageReport("3.19.1", "linux/amd64", "CVE-2024-0001", "CVE-2024-0002", "CVE-2024-0003"),
+	}
+
+	upgrades := ComputeBaseImageUpgrades(baseImage, "linux/amd64", layers, baseImageReports)
+
+	expected := []storagev1alpha1.BaseImageUpgrade{
+		{
+			Tag:       "3.20",
+			Digest:    "sha256:3.20",
+			FixedCVEs: []string{"CVE-2024-0001"},
+		},
+		{
+			Tag:       "3.21",
+			Digest:    "sha256:3.21",
+			FixedCVEs: []string{"CVE-2024-0001", "CVE-2024-0002", "CVE-2024-0003"},
+		},
+	}
+	assert.Equal(t, expected, upgrades)
+}
+
+func TestComputeBaseImageUpgrades_NotVersioned(t *testing.T) {
+	baseImage := storagev1alpha1.BaseImage{
+		RegistryURI: "index.docker.io",
+		Repository:  "library/alpine",
+		Tag:         "latest",
+	}
+	layers := []storagev1alpha1.LayerReport{
+		{BaseImage: true, CVEs: []string{"CVE-2024-0001"}},
+	}
+
+	upgrades := ComputeBaseImageUpgrades(baseImage, "linux/amd64", layers, []storagev1alpha1.VulnerabilityReport{
+		newBaseImageReport("3.21", "linux/amd64"),
+	})
+	assert.Empty(t, upgrades)
+}
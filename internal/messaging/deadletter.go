@@ -0,0 +1,242 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	deadLetterStreamName = "SBOMBASTIC_DLQ"
+	// deadLetterSubjectPrefix is prepended to the original subject of the dead-lettered messages.
+	// It must not overlap with the subjects of the main stream.
+	deadLetterSubjectPrefix = "sbomscanner-dlq."
+	// deadLetterMaxAge is how long dead-lettered messages are retained.
+	deadLetterMaxAge = 14 * 24 * time.Hour
+)
+
+// Headers set on the dead-lettered messages.
+const (
+	// HeaderError is the error returned by the handler on the last attempt.
+	HeaderError = "Sbomscanner-Error"
+	// HeaderAttempts is the number of times the message has been delivered.
+	HeaderAttempts = "Sbomscanner-Attempts"
+	// HeaderOriginalSubject is the subject the message was originally published to.
+	HeaderOriginalSubject = "Sbomscanner-Original-Subject"
+	// HeaderPublishedAt is the time the message was originally published, in RFC 3339 format.
+	HeaderPublishedAt = "Sbomscanner-Published-At"
+	// HeaderDeadLetteredAt is the time the message was dead-lettered, in RFC 3339 format.
+	HeaderDeadLetteredAt = "Sbomscanner-Dead-Lettered-At"
+)
+
+// DeadLetter is a message that exhausted its delivery attempts.
+type DeadLetter struct {
+	// Sequence is the sequence number of the message in the dead-letter stream.
+	Sequence uint64 `json:"sequence"`
+	// Subject is the subject the message was originally published to.
+	Subject string `json:"subject"`
+	// MessageID is the original message ID used for deduplication.
+	MessageID string `json:"messageID,omitempty"`
+	// Error is the error returned by the handler on the last attempt.
+	Error string `json:"error"`
+	// Attempts is the number of times the message has been delivered.
+	Attempts int `json:"attempts"`
+	// PublishedAt is the time the message was originally published.
+	PublishedAt time.Time `json:"publishedAt"`
+	// DeadLetteredAt is the time the message was dead-lettered.
+	DeadLetteredAt time.Time `json:"deadLetteredAt"`
+	// Data is the message payload.
+	Data []byte `json:"data"`
+}
+
+// ErrDeadLetterNotFound is returned when the requested dead-lettered message does not exist.
+var ErrDeadLetterNotFound = errors.New("dead-lettered message not found")
+
+// DeadLetterQueue lists and replays the messages that exhausted their delivery attempts.
+type DeadLetterQueue interface {
+	// List returns the dead-lettered messages, oldest first.
+	List(ctx context.Context) ([]DeadLetter, error)
+	// Replay publishes the dead-lettered message with the given sequence to its original subject,
+	// and removes it from the dead-letter queue.
+	Replay(ctx context.Context, sequence uint64) error
+}
+
+// NatsDeadLetterQueue is an implementation of the DeadLetterQueue interface backed by a NATS JetStream stream.
+type NatsDeadLetterQueue struct {
+	js     jetstream.JetStream
+	logger *slog.Logger
+}
+
+// NewNatsDeadLetterQueue creates a new NatsDeadLetterQueue instance with the provided NATS connection.
+func NewNatsDeadLetterQueue(ctx context.Context, nc *nats.Conn, logger *slog.Logger) (*NatsDeadLetterQueue, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+
+	if err := createDeadLetterStream(ctx, js); err != nil {
+		return nil, err
+	}
+
+	return &NatsDeadLetterQueue{
+		js:     js,
+		logger: logger.With("component", "dead_letter_queue"),
+	}, nil
+}
+
+// List returns the dead-lettered messages, oldest first.
+func (q *NatsDeadLetterQueue) List(ctx context.Context) ([]DeadLetter, error) {
+	stream, err := q.js.Stream(ctx, deadLetterStreamName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-letter stream: %w", err)
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-letter stream info: %w", err)
+	}
+
+	deadLetters := []DeadLetter{}
+	if info.State.Msgs == 0 {
+		return deadLetters, nil
+	}
+
+	for sequence := info.State.FirstSeq; sequence <= info.State.LastSeq; sequence++ {
+		msg, err := stream.GetMsg(ctx, sequence)
+		if err != nil {
+			// Replayed messages are deleted from the stream, leaving gaps in the sequence.
+			if errors.Is(err, jetstream.ErrMsgNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get dead-lettered message %d: %w", sequence, err)
+		}
+
+		deadLetters = append(deadLetters, deadLetterFromMsg(msg))
+	}
+
+	return deadLetters, nil
+}
+
+// Replay publishes the dead-lettered message with the given sequence to its original subject,
+// and removes it from the dead-letter queue.
+func (q *NatsDeadLetterQueue) Replay(ctx context.Context, sequence uint64) error {
+	stream, err := q.js.Stream(ctx, deadLetterStreamName)
+	if err != nil {
+		return fmt.Errorf("failed to get dead-letter stream: %w", err)
+	}
+
+	msg, err := stream.GetMsg(ctx, sequence)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			return ErrDeadLetterNotFound
+		}
+		return fmt.Errorf("failed to get dead-lettered message %d: %w", sequence, err)
+	}
+
+	deadLetter := deadLetterFromMsg(msg)
+	// Publishing and deleting the message are not atomic: if the deletion fails, the replay is retried.
+	// The message ID is derived from the dead-letter sequence, so that JetStream discards the message
+	// published again by a retry within the deduplication window.
+	// The original message ID may still be in the deduplication window, so it is not reused.
+	replayMsg := &nats.Msg{
+		Subject: deadLetter.Subject,
+		Data:    deadLetter.Data,
+		Header: nats.Header{
+			jetstream.MsgIDHeader: []string{replayMessageID(sequence)},
+		},
+	}
+	ack, err := q.js.PublishMsg(ctx, replayMsg)
+	if err != nil {
+		return fmt.Errorf("failed to replay message %d: %w", sequence, err)
+	}
+	if ack.Duplicate {
+		q.logger.InfoContext(ctx, "Dead-lettered message already replayed, removing it", "sequence", sequence, "subject", deadLetter.Subject)
+	}
+
+	// The message might have been removed by a concurrent replay.
+	if err := stream.DeleteMsg(ctx, sequence); err != nil && !errors.Is(err, jetstream.ErrMsgNotFound) {
+		return fmt.Errorf("failed to delete replayed message %d: %w", sequence, err)
+	}
+
+	q.logger.InfoContext(ctx, "Dead-lettered message replayed", "sequence", sequence, "subject", deadLetter.Subject)
+
+	return nil
+}
+
+// replayMessageID returns the message ID of the replay of the dead-lettered message with the given sequence.
+func replayMessageID(sequence uint64) string {
+	return fmt.Sprintf("%s-replay-%d", deadLetterStreamName, sequence)
+}
+
+// createDeadLetterStream creates the stream holding the dead-lettered messages.
+// CreateStream is an idempotent operation, if the stream already exists, it will succeed without error.
+func createDeadLetterStream(ctx context.Context, js jetstream.JetStream) error {
+	_, err := js.CreateStream(ctx, jetstream.StreamConfig{
+		Name:      deadLetterStreamName,
+		Retention: jetstream.LimitsPolicy,
+		Subjects:  []string{deadLetterSubjectPrefix + ">"},
+		MaxAge:    deadLetterMaxAge,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create dead-letter stream: %w", err)
+	}
+
+	return nil
+}
+
+// publishDeadLetter publishes the message to the dead-letter stream,
+// recording the processing error and the delivery metadata in the headers.
+func publishDeadLetter(ctx context.Context, js jetstream.JetStream, msg jetstream.Msg, metadata *jetstream.MsgMetadata, processingErr error) error {
+	header := nats.Header{
+		HeaderError:           []string{processingErr.Error()},
+		HeaderAttempts:        []string{strconv.FormatUint(metadata.NumDelivered, 10)},
+		HeaderOriginalSubject: []string{msg.Subject()},
+		HeaderPublishedAt:     []string{metadata.Timestamp.UTC().Format(time.RFC3339)},
+		HeaderDeadLetteredAt:  []string{time.Now().UTC().Format(time.RFC3339)},
+	}
+	if messageID := msg.Headers().Get(jetstream.MsgIDHeader); messageID != "" {
+		header.Set(jetstream.MsgIDHeader, messageID)
+	}
+
+	deadLetterMsg := &nats.Msg{
+		Subject: deadLetterSubjectPrefix + msg.Subject(),
+		Data:    msg.Data(),
+		Header:  header,
+	}
+	if _, err := js.PublishMsg(ctx, deadLetterMsg); err != nil {
+		return fmt.Errorf("failed to publish message to the dead-letter stream: %w", err)
+	}
+
+	return nil
+}
+
+// deadLetterFromMsg converts a message stored in the dead-letter stream to a DeadLetter.
+func deadLetterFromMsg(msg *jetstream.RawStreamMsg) DeadLetter {
+	// Malformed headers are ignored, they are only informative.
+	attempts, _ := strconv.Atoi(msg.Header.Get(HeaderAttempts))
+	publishedAt, _ := time.Parse(time.RFC3339, msg.Header.Get(HeaderPublishedAt))
+	deadLetteredAt, _ := time.Parse(time.RFC3339, msg.Header.Get(HeaderDeadLetteredAt))
+
+	subject := msg.Header.Get(HeaderOriginalSubject)
+	if subject == "" {
+		subject = strings.TrimPrefix(msg.Subject, deadLetterSubjectPrefix)
+	}
+
+	return DeadLetter{
+		Sequence:       msg.Sequence,
+		Subject:        subject,
+		MessageID:      msg.Header.Get(jetstream.MsgIDHeader),
+		Error:          msg.Header.Get(HeaderError),
+		Attempts:       attempts,
+		PublishedAt:    publishedAt,
+		DeadLetteredAt: deadLetteredAt,
+		Data:           msg.Data,
+	}
+}
@@ -0,0 +1,56 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// DeadLetterPath is the path of the endpoints used to manage the dead-lettered messages.
+const DeadLetterPath = "/dead-letters"
+
+// NewDeadLetterHTTPHandler creates an HTTP handler exposing the dead-letter queue:
+//
+//	GET  /dead-letters                    lists the dead-lettered messages
+//	POST /dead-letters/{sequence}/replay  replays a dead-lettered message
+func NewDeadLetterHTTPHandler(queue DeadLetterQueue, logger *slog.Logger) http.Handler {
+	logger = logger.With("component", "dead_letter_http_handler")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+DeadLetterPath, func(w http.ResponseWriter, r *http.Request) {
+		deadLetters, err := queue.List(r.Context())
+		if err != nil {
+			logger.ErrorContext(r.Context(), "Failed to list dead-lettered messages", "error", err)
+			http.Error(w, "failed to list dead-lettered messages", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(deadLetters); err != nil {
+			logger.ErrorContext(r.Context(), "Failed to encode dead-lettered messages", "error", err)
+		}
+	})
+	mux.HandleFunc("POST "+DeadLetterPath+"/{sequence}/replay", func(w http.ResponseWriter, r *http.Request) {
+		sequence, err := strconv.ParseUint(r.PathValue("sequence"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid sequence", http.StatusBadRequest)
+			return
+		}
+
+		if err := queue.Replay(r.Context(), sequence); err != nil {
+			if errors.Is(err, ErrDeadLetterNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			logger.ErrorContext(r.Context(), "Failed to replay dead-lettered message", "sequence", sequence, "error", err)
+			http.Error(w, "failed to replay dead-lettered message", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return mux
+}
@@ -0,0 +1,264 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriber_Run_DeadLetter(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	publisher, err := NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	failureHandled := make(chan struct{}, 1)
+	done := make(chan struct{})
+
+	handlers := HandlerRegistry{
+		testSubscriberSubject: &testHandler{handleFunc: func(_ Message) error {
+			return errors.New("broken auth secret")
+		}},
+	}
+	failureHandler := &testFailureHandler{handleFailureFunc: func(_ Message, _ string) error {
+		failureHandled <- struct{}{}
+		return nil
+	}}
+	retryConfig := &RetryConfig{
+		BaseDelay:   10 * time.Millisecond,
+		Jitter:      0,
+		MaxAttempts: 5,
+	}
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-dlq", handlers, failureHandler, RetryPolicies{testSubscriberSubject: retryConfig}, nil, slog.Default())
+	require.NoError(t, err, "failed to create subscriber")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	message := []byte(`{"data":"dead-letter-test"}`)
+	err = publisher.Publish(t.Context(), testSubscriberSubject, "dlq-id", message)
+	require.NoError(t, err, "failed to publish message")
+
+	go func() {
+		err = subscriber.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-failureHandled:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for failure handler after max retries")
+	}
+
+	cancel()
+	<-done
+	require.NoError(t, err, "unexpected subscriber error")
+
+	queue, err := NewNatsDeadLetterQueue(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	deadLetters, err := queue.List(t.Context())
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 1)
+
+	deadLetter := deadLetters[0]
+	require.Equal(t, testSubscriberSubject, deadLetter.Subject)
+	require.Equal(t, "dlq-id", deadLetter.MessageID)
+	require.Equal(t, message, deadLetter.Data)
+	require.Equal(t, retryConfig.MaxAttempts, deadLetter.Attempts)
+	require.Contains(t, deadLetter.Error, "broken auth secret")
+	require.False(t, deadLetter.PublishedAt.IsZero())
+	require.False(t, deadLetter.DeadLetteredAt.IsZero())
+	require.False(t, deadLetter.DeadLetteredAt.Before(deadLetter.PublishedAt))
+
+	// The message has been removed from the work queue
+	require.Equal(t, uint64(0), streamMessages(t, nc))
+}
+
+func TestNatsDeadLetterQueue_Replay(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	_, err = NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	queue, err := NewNatsDeadLetterQueue(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	message := []byte(`{"data":"replay-test"}`)
+	sequence := publishTestDeadLetter(t, nc, message)
+
+	err = queue.Replay(t.Context(), sequence)
+	require.NoError(t, err)
+
+	// The message is back in the work queue, and removed from the dead-letter stream
+	require.Equal(t, uint64(1), streamMessages(t, nc))
+	deadLetters, err := queue.List(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, deadLetters)
+
+	err = queue.Replay(t.Context(), sequence)
+	require.ErrorIs(t, err, ErrDeadLetterNotFound)
+}
+
+func TestNatsDeadLetterQueue_Replay_Retry(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	_, err = NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	queue, err := NewNatsDeadLetterQueue(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	message := []byte(`{"data":"replay-retry-test"}`)
+	sequence := publishTestDeadLetter(t, nc, message)
+
+	// A previous replay published the message, but failed to delete it from the dead-letter stream.
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+	_, err = js.PublishMsg(t.Context(), &nats.Msg{
+		Subject: testSubscriberSubject,
+		Data:    message,
+		Header:  nats.Header{jetstream.MsgIDHeader: []string{replayMessageID(sequence)}},
+	})
+	require.NoError(t, err)
+
+	err = queue.Replay(t.Context(), sequence)
+	require.NoError(t, err)
+
+	// The message is replayed only once
+	require.Equal(t, uint64(1), streamMessages(t, nc))
+	deadLetters, err := queue.List(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, deadLetters)
+}
+
+func TestDeadLetterHTTPHandler(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	_, err = NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	queue, err := NewNatsDeadLetterQueue(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	message := []byte(`{"data":"http-test"}`)
+	sequence := publishTestDeadLetter(t, nc, message)
+
+	server := httptest.NewServer(NewDeadLetterHTTPHandler(queue, slog.Default()))
+	defer server.Close()
+
+	request, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+DeadLetterPath, nil)
+	require.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	var deadLetters []DeadLetter
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&deadLetters))
+	require.Len(t, deadLetters, 1)
+	require.Equal(t, sequence, deadLetters[0].Sequence)
+	require.Equal(t, message, deadLetters[0].Data)
+
+	tests := []struct {
+		name           string
+		sequence       string
+		expectedStatus int
+	}{
+		{
+			name:           "replay",
+			sequence:       fmt.Sprint(sequence),
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "already replayed",
+			sequence:       fmt.Sprint(sequence),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "invalid sequence",
+			sequence:       "invalid",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL+DeadLetterPath+"/"+test.sequence+"/replay", nil)
+			require.NoError(t, err)
+			response, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+			defer response.Body.Close()
+
+			require.Equal(t, test.expectedStatus, response.StatusCode)
+		})
+	}
+}
+
+// publishTestDeadLetter publishes a message to the dead-letter stream and returns its sequence.
+func publishTestDeadLetter(t *testing.T, nc *nats.Conn, data []byte) uint64 {
+	t.Helper()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	ack, err := js.PublishMsg(&nats.Msg{
+		Subject: deadLetterSubjectPrefix + testSubscriberSubject,
+		Data:    data,
+		Header: nats.Header{
+			HeaderOriginalSubject: []string{testSubscriberSubject},
+			HeaderError:           []string{"test error"},
+			HeaderAttempts:        []string{"5"},
+		},
+	})
+	require.NoError(t, err)
+
+	return ack.Sequence
+}
@@ -16,6 +16,7 @@ type Handler interface {
 }
 
 // FailureHandler handles messages that failed processing after exhausting retries.
+// It receives the error returned by the handler, so that it can be inspected with errors.Is and errors.As.
 type FailureHandler interface {
-	HandleFailure(ctx context.Context, message Message, errorMessage string) error
+	HandleFailure(ctx context.Context, message Message, failure error) error
 }
@@ -0,0 +1,87 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "sbomscanner"
+
+var (
+	messagesProcessed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "messaging",
+			Name:      "messages_processed_total",
+			Help:      "Number of messages processed successfully, per subject.",
+		},
+		[]string{"subject"},
+	)
+	messagesFailed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "messaging",
+			Name:      "messages_failed_total",
+			Help:      "Number of message deliveries whose handler returned an error, per subject.",
+		},
+		[]string{"subject"},
+	)
+	messagesRetried = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "messaging",
+			Name:      "messages_retried_total",
+			Help:      "Number of failed messages scheduled for redelivery after a backoff delay, per subject.",
+		},
+		[]string{"subject"},
+	)
+	messagesDeadLettered = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "messaging",
+			Name:      "messages_dead_lettered_total",
+			Help:      "Number of messages moved to the dead-letter stream, per subject.",
+		},
+		[]string{"subject"},
+	)
+	handlerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "messaging",
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent by the handlers processing a message, per subject and result.",
+			// Catalogs of large registries and SBOM generations of large images can take several minutes.
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+		},
+		[]string{"subject", "result"},
+	)
+)
+
+// Results of the message processing, used as the "result" label of the metrics.
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+)
+
+// RegisterMetrics registers the metrics of the messaging layer with the given registerer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{
+		messagesProcessed,
+		messagesFailed,
+		messagesRetried,
+		messagesDeadLettered,
+		handlerDuration,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			var alreadyRegistered prometheus.AlreadyRegisteredError
+			if errors.As(err, &alreadyRegistered) {
+				continue
+			}
+			return fmt.Errorf("failed to register messaging metrics: %w", err)
+		}
+	}
+
+	return nil
+}
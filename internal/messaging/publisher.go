@@ -61,7 +61,12 @@ func NewNatsPublisher(ctx context.Context, nc *nats.Conn, logger *slog.Logger) (
 // The messageID is set as the "Nats-Msg-Id" header to enable deduplication by JetStream.
 // If a message with the same ID has already been published in, it will be ignored.
 // The default deduplication window is 2 minutes.
+// The trace context of ctx is propagated in the message headers, so that the processing
+// of the message is traced as a child of the span publishing it.
 func (p *NatsPublisher) Publish(ctx context.Context, subject string, messageID string, message []byte) error {
+	ctx, span := startPublishSpan(ctx, subject, messageID)
+	defer span.End()
+
 	msg := &nats.Msg{
 		Subject: subject,
 		Data:    message,
@@ -69,7 +74,9 @@ func (p *NatsPublisher) Publish(ctx context.Context, subject string, messageID s
 			jetstream.MsgIDHeader: []string{messageID},
 		},
 	}
+	injectTraceContext(ctx, msg.Header)
 	if _, err := p.js.PublishMsg(ctx, msg); err != nil {
+		recordSpanError(span, err)
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
 
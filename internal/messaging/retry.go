@@ -0,0 +1,96 @@
+package messaging
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultRetryConfig is the retry configuration used for the subjects without a retry policy.
+var DefaultRetryConfig = RetryConfig{
+	BaseDelay:   5 * time.Second,
+	MaxDelay:    5 * time.Minute,
+	Jitter:      0.2,
+	MaxAttempts: 5,
+}
+
+// RetryConfig defines retry behavior for message handling.
+type RetryConfig struct {
+	// BaseDelay is the base backoff delay.
+	// Subsequent retries will use an exponential backoff strategy based on this value.
+	BaseDelay time.Duration
+	// MaxDelay is the maximum backoff delay. Zero means no limit.
+	MaxDelay time.Duration
+	// Jitter is the jitter factor to apply to the backoff delay.
+	// For example, a jitter of 0.2 means the delay can vary by +/-20%.
+	Jitter float64
+	// MaxAttempts is the maximum number of attempts (including the first try).
+	MaxAttempts int
+}
+
+// backoffDelay calculates exponential backoff with jitter, capped to MaxDelay.
+func (c *RetryConfig) backoffDelay(attempt int) time.Duration {
+	base := float64(c.BaseDelay)
+	delay := base * math.Pow(2, float64(attempt-1)) // exponential
+
+	maxDelay := float64(c.MaxDelay)
+	if maxDelay > 0 && delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if c.Jitter > 0 {
+		// Using math/rand for backoff jitter is fine - this isn't security-sensitive
+		//nolint:gosec // G404: weak random source is acceptable for retry jitter
+		jitter := delay * (c.Jitter * (rand.Float64()*2 - 1))
+		delay += jitter
+		if delay < 0 {
+			delay = 0
+		}
+		if maxDelay > 0 && delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+
+	return time.Duration(delay)
+}
+
+// RetryPolicies is a map that associates subjects with their respective retry configuration.
+// Subjects that are not listed use the DefaultRetryConfig.
+type RetryPolicies map[string]*RetryConfig
+
+// retryConfig returns the retry configuration of the given subject.
+func (p RetryPolicies) retryConfig(subject string) *RetryConfig {
+	if retryConfig, found := p[subject]; found && retryConfig != nil {
+		return retryConfig
+	}
+
+	defaultRetryConfig := DefaultRetryConfig
+	return &defaultRetryConfig
+}
+
+// PermanentError is returned by handlers when processing a message cannot succeed by retrying,
+// for example because the message is malformed.
+// The message is not retried and the failure handler is invoked right away.
+type PermanentError struct {
+	Err error
+}
+
+// NewPermanentError wraps the given error into a PermanentError.
+func NewPermanentError(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent returns true if the error, or any error it wraps, is a PermanentError.
+func IsPermanent(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
@@ -0,0 +1,75 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryConfig_backoffDelay(t *testing.T) {
+	tests := []struct {
+		name          string
+		retryConfig   RetryConfig
+		attempt       int
+		expectedDelay time.Duration
+	}{
+		{
+			name:          "first attempt",
+			retryConfig:   RetryConfig{BaseDelay: time.Second},
+			attempt:       1,
+			expectedDelay: time.Second,
+		},
+		{
+			name:          "exponential backoff",
+			retryConfig:   RetryConfig{BaseDelay: time.Second},
+			attempt:       4,
+			expectedDelay: 8 * time.Second,
+		},
+		{
+			name:          "capped to max delay",
+			retryConfig:   RetryConfig{BaseDelay: time.Second, MaxDelay: 5 * time.Second},
+			attempt:       4,
+			expectedDelay: 5 * time.Second,
+		},
+		{
+			name:          "capped to max delay with jitter",
+			retryConfig:   RetryConfig{BaseDelay: time.Second, MaxDelay: 5 * time.Second, Jitter: 0.5},
+			attempt:       10,
+			expectedDelay: 5 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delay := test.retryConfig.backoffDelay(test.attempt)
+			if test.retryConfig.Jitter > 0 {
+				require.LessOrEqual(t, delay, test.expectedDelay)
+				require.GreaterOrEqual(t, delay, time.Duration(float64(test.expectedDelay)*(1-test.retryConfig.Jitter)))
+				return
+			}
+			require.Equal(t, test.expectedDelay, delay)
+		})
+	}
+}
+
+func TestRetryPolicies_retryConfig(t *testing.T) {
+	retryConfig := &RetryConfig{MaxAttempts: 2}
+	retryPolicies := RetryPolicies{testSubscriberSubject: retryConfig}
+
+	require.Equal(t, retryConfig, retryPolicies.retryConfig(testSubscriberSubject))
+	require.Equal(t, DefaultRetryConfig, *retryPolicies.retryConfig("unknown"))
+	require.Equal(t, DefaultRetryConfig, *RetryPolicies(nil).retryConfig(testSubscriberSubject))
+}
+
+func TestIsPermanent(t *testing.T) {
+	permanentErr := NewPermanentError(errors.New("malformed message"))
+
+	require.True(t, IsPermanent(permanentErr))
+	require.True(t, IsPermanent(fmt.Errorf("failed to handle message: %w", permanentErr)))
+	require.False(t, IsPermanent(errors.New("temporary failure")))
+	require.False(t, IsPermanent(nil))
+	require.Equal(t, "malformed message", permanentErr.Error())
+}
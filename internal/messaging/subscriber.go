@@ -2,49 +2,73 @@ package messaging
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math"
-	"math/rand/v2"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 )
 
-const maxDeliver = 5
-
-// RetryConfig defines retry behavior for message handling.
-type RetryConfig struct {
-	// BaseDelay is the base backoff delay.
-	// Subsequent retries will use an exponential backoff strategy based on this value.
-	BaseDelay time.Duration
-	// Jitter is the jitter factor to apply to the backoff delay.
-	// For example, a jitter of 0.2 means the delay can vary by +/-20%.
-	Jitter float64
-	// MaxAttempts is the maximum number of attempts (including the first try).
-	MaxAttempts int
+// ConcurrencyConfig defines how many messages are processed concurrently.
+type ConcurrencyConfig struct {
+	// MaxInFlight is the maximum number of messages processed concurrently for each subject.
+	// Subjects that are not listed process one message at a time.
+	MaxInFlight map[string]int
+	// DrainTimeout is how long the subscriber waits for the in-flight messages to be processed
+	// when shutting down, before cancelling their handlers.
+	DrainTimeout time.Duration
 }
 
+// maxInFlight returns the maximum number of messages processed concurrently for the given subject.
+func (c *ConcurrencyConfig) maxInFlight(subject string) int {
+	if c == nil || c.MaxInFlight[subject] < 1 {
+		return 1
+	}
+
+	return c.MaxInFlight[subject]
+}
+
+// drainTimeout returns how long to wait for the in-flight messages when shutting down.
+func (c *ConcurrencyConfig) drainTimeout() time.Duration {
+	if c == nil {
+		return 0
+	}
+
+	return c.DrainTimeout
+}
+
+// legacyConsumerSubjects identifies the legacy consumer filtering all the subjects.
+// Its messages are processed one at a time, as in previous versions.
+const legacyConsumerSubjects = "*"
+
 // HandlerRegistry is a map that associates subjects with their respective handlers.
 type HandlerRegistry map[string]Handler
 
 // NatsSubscriber is an implementation of a message subscriber that uses NATS JetStream to receive messages.
 type NatsSubscriber struct {
-	cons           jetstream.Consumer
-	handlers       HandlerRegistry
-	failureHandler FailureHandler
-	retryConfig    *RetryConfig
-	logger         *slog.Logger
+	js                jetstream.JetStream
+	consumers         map[string]jetstream.Consumer
+	handlers          HandlerRegistry
+	failureHandler    FailureHandler
+	retryPolicies     RetryPolicies
+	concurrencyConfig *ConcurrencyConfig
+	logger            *slog.Logger
 }
 
 // NewNatsSubscriber creates a new NatsSubscriber instance with the provided NATS connection and durable subscription name.
+// A durable consumer is created for each subject, so that the messages of a subject
+// are not held back by the messages of the other subjects.
 func NewNatsSubscriber(ctx context.Context,
 	nc *nats.Conn,
 	durable string,
 	handlers HandlerRegistry,
 	failureHandler FailureHandler,
-	retryConfig *RetryConfig,
+	retryPolicies RetryPolicies,
+	concurrencyConfig *ConcurrencyConfig,
 	logger *slog.Logger,
 ) (*NatsSubscriber, error) {
 	js, err := jetstream.New(nc)
@@ -52,88 +76,214 @@ func NewNatsSubscriber(ctx context.Context,
 		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
 	}
 
-	var subjects []string
-	for subject := range handlers {
-		subjects = append(subjects, subject)
-	}
-
-	cons, err := js.CreateOrUpdateConsumer(ctx,
-		streamName,
-		jetstream.ConsumerConfig{
-			FilterSubjects: subjects,
-			Durable:        durable,
-			// AckWait defines how long the server will wait for an acknowledgement
-			// before resending a message.
-			// We set it to a higher value than the default to allow for longer processing times.
-			// Handlers that are expected to take longer should use `InProgress` to extend the AckWait.
-			AckWait: 10 * time.Minute,
-			// We do not set MaxDeliver here because we want to handle retries manually
-			// to implement custom backoff and failure handling logic.
-		})
+	if err := createDeadLetterStream(ctx, js); err != nil {
+		return nil, err
+	}
+
+	consumers, err := createConsumers(ctx, js, durable, handlers, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create or update consumer: %w", err)
+		return nil, err
 	}
 
 	subscriber := &NatsSubscriber{
-		cons:           cons,
-		handlers:       handlers,
-		failureHandler: failureHandler,
-		retryConfig:    retryConfig,
-		logger:         logger.With("component", "subscriber"),
+		js:                js,
+		consumers:         consumers,
+		handlers:          handlers,
+		failureHandler:    failureHandler,
+		retryPolicies:     retryPolicies,
+		concurrencyConfig: concurrencyConfig,
+		logger:            logger.With("component", "subscriber"),
 	}
 
 	return subscriber, nil
 }
 
-// Run starts the subscriber and processes messages in a loop until the context is done.
+// createConsumers creates a durable consumer for each subject.
+// Previous versions used a single consumer filtering all the subjects, and work queue streams
+// do not allow consumers with overlapping subjects: while the legacy consumer exists,
+// for example during a rolling upgrade, the messages of all the subjects are consumed from it.
+// The legacy consumer must be removed once all the workers are upgraded, and the workers restarted.
+func createConsumers(
+	ctx context.Context,
+	js jetstream.JetStream,
+	durable string,
+	handlers HandlerRegistry,
+	logger *slog.Logger,
+) (map[string]jetstream.Consumer, error) {
+	legacyConsumer, err := js.Consumer(ctx, streamName, durable)
+	switch {
+	case err == nil:
+		logger.WarnContext(ctx, "Consuming all the subjects from the legacy consumer, remove it once all the workers are upgraded",
+			"consumer", durable)
+		return map[string]jetstream.Consumer{legacyConsumerSubjects: legacyConsumer}, nil
+	case !errors.Is(err, jetstream.ErrConsumerNotFound):
+		return nil, fmt.Errorf("failed to get legacy consumer %s: %w", durable, err)
+	}
+
+	consumers := make(map[string]jetstream.Consumer, len(handlers))
+	for subject := range handlers {
+		cons, err := js.CreateOrUpdateConsumer(ctx,
+			streamName,
+			jetstream.ConsumerConfig{
+				FilterSubject: subject,
+				Durable:       consumerName(durable, subject),
+				// AckWait defines how long the server will wait for an acknowledgement
+				// before resending a message.
+				// We set it to a higher value than the default to allow for longer processing times.
+				// Handlers that are expected to take longer should use `InProgress` to extend the AckWait.
+				AckWait: 10 * time.Minute,
+				// We do not set MaxDeliver here because we want to handle retries manually
+				// to implement custom backoff and failure handling logic.
+			})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create or update consumer for subject %s: %w", subject, err)
+		}
+		consumers[subject] = cons
+	}
+
+	return consumers, nil
+}
+
+// consumerName returns the durable consumer name of the given subject.
+// Consumer names cannot contain dots, so they are replaced with dashes.
+func consumerName(durable, subject string) string {
+	return durable + "-" + strings.ReplaceAll(subject, ".", "-")
+}
+
+// Run starts the subscriber and processes messages until the context is done.
+// Each subject is processed by a pool of at most MaxInFlight concurrent handlers.
+// When the context is done, the subscriber stops fetching new messages and waits for
+// the in-flight ones to be processed, up to the DrainTimeout.
 func (s *NatsSubscriber) Run(ctx context.Context) error {
-	consContext, err := s.cons.Consume(
-		func(msg jetstream.Msg) {
-			s.logger.DebugContext(ctx, "Processing message", "subject", msg.Subject())
+	// Handlers run with a context that is not cancelled when the subscriber shuts down,
+	// so that in-flight messages can be processed before exiting.
+	handlerCtx, cancelHandlers := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelHandlers()
 
-			metadata, err := msg.Metadata()
-			if err != nil {
-				s.logger.ErrorContext(ctx, "Failed to get message metadata",
-					"subject", msg.Subject(),
-					"error", err,
-				)
-				// Can't determine delivery count, NAK without delay
-				if err := msg.Nak(); err != nil {
-					s.logger.ErrorContext(ctx, "Failed to nak message",
-						"subject", msg.Subject(),
-						"error", err,
-					)
+	var wg sync.WaitGroup
+	consContexts := make([]jetstream.ConsumeContext, 0, len(s.consumers))
+	for subject, cons := range s.consumers {
+		maxInFlight := s.concurrencyConfig.maxInFlight(subject)
+		slots := make(chan struct{}, maxInFlight)
+
+		consContext, err := cons.Consume(
+			func(msg jetstream.Msg) {
+				// Wait for a free slot in the pool.
+				// Messages that are still buffered when shutting down are nak'ed,
+				// so that they can be redelivered to another worker right away.
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					s.nak(ctx, msg)
+					return
 				}
-				return
-			}
 
-			if err := s.handleMessage(ctx, msg.Subject(), msg); err != nil {
-				s.handleFailure(ctx, msg, metadata, err)
-				return
-			}
+				wg.Add(1)
+				go func() {
+					defer func() {
+						<-slots
+						wg.Done()
+					}()
 
-			if err := msg.Ack(); err != nil {
-				s.logger.ErrorContext(ctx, "Failed to ack message",
-					"subject", msg.Subject(),
-					"error", err,
-				)
+					s.processMessage(handlerCtx, msg)
+				}()
+			},
+			// Do not buffer more messages than the pool can process,
+			// so that the other workers can pick them up.
+			jetstream.PullMaxMessages(maxInFlight),
+		)
+		if err != nil {
+			for _, consContext := range consContexts {
+				consContext.Stop()
 			}
-		},
-	)
-	if err != nil {
-		return fmt.Errorf("failed to start consuming: %w", err)
+			return fmt.Errorf("failed to start consuming subject %s: %w", subject, err)
+		}
+		consContexts = append(consContexts, consContext)
+
+		s.logger.DebugContext(ctx, "Consuming subject", "subject", subject, "maxInFlight", maxInFlight)
 	}
 
 	s.logger.InfoContext(ctx, "Subscriber started, waiting for messages...")
 
 	<-ctx.Done()
 
-	s.logger.InfoContext(ctx, "Subscriber shutting down...")
-	consContext.Stop()
+	s.logger.InfoContext(ctx, "Subscriber shutting down, draining in-flight messages...")
+	for _, consContext := range consContexts {
+		consContext.Drain()
+	}
+	for _, consContext := range consContexts {
+		<-consContext.Closed()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(s.concurrencyConfig.drainTimeout()):
+		s.logger.WarnContext(ctx, "Drain timeout reached, cancelling in-flight messages")
+		cancelHandlers()
+		<-drained
+	}
+
+	s.logger.InfoContext(ctx, "Subscriber stopped")
 
 	return nil
 }
 
+// processMessage processes a single message and acknowledges it, or schedules its redelivery on failure.
+func (s *NatsSubscriber) processMessage(ctx context.Context, msg jetstream.Msg) {
+	s.logger.DebugContext(ctx, "Processing message", "subject", msg.Subject())
+
+	metadata, err := msg.Metadata()
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Failed to get message metadata",
+			"subject", msg.Subject(),
+			"error", err,
+		)
+		// Can't determine delivery count, NAK without delay
+		s.nak(ctx, msg)
+		return
+	}
+
+	ctx, span := startProcessSpan(ctx, msg.Subject(), msg.Headers(), metadata.NumDelivered)
+	defer span.End()
+
+	if err := s.handleMessage(ctx, msg.Subject(), msg); err != nil {
+		recordSpanError(span, err)
+		// The handler was interrupted because the subscriber is shutting down,
+		// let another worker process the message right away.
+		if ctx.Err() != nil {
+			s.nak(ctx, msg)
+			return
+		}
+
+		s.handleFailure(ctx, msg, metadata, err)
+		return
+	}
+	messagesProcessed.WithLabelValues(msg.Subject()).Inc()
+
+	if err := msg.Ack(); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to ack message",
+			"subject", msg.Subject(),
+			"error", err,
+		)
+	}
+}
+
+// nak negatively acknowledges the message without delay.
+func (s *NatsSubscriber) nak(ctx context.Context, msg jetstream.Msg) {
+	if err := msg.Nak(); err != nil {
+		s.logger.ErrorContext(ctx, "Failed to nak message",
+			"subject", msg.Subject(),
+			"error", err,
+		)
+	}
+}
+
 // handleMessage handles individual message processing.
 func (s *NatsSubscriber) handleMessage(ctx context.Context, subject string, message Message) error {
 	handler, found := s.handlers[subject]
@@ -141,7 +291,14 @@ func (s *NatsSubscriber) handleMessage(ctx context.Context, subject string, mess
 		return fmt.Errorf("no handler found for subject: %s", subject)
 	}
 
-	if err := handler.Handle(ctx, message); err != nil {
+	start := time.Now()
+	err := handler.Handle(ctx, message)
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	handlerDuration.WithLabelValues(subject, result).Observe(time.Since(start).Seconds())
+	if err != nil {
 		return fmt.Errorf("failed to handle message on subject %s: %w", subject, err)
 	}
 
@@ -149,28 +306,54 @@ func (s *NatsSubscriber) handleMessage(ctx context.Context, subject string, mess
 }
 
 // handleFailure handles message processing failures, either by retrying with backoff
-// or by invoking the failure handler if max retries have been exceeded.
+// or, if max attempts have been reached or the error is permanent, by moving the message
+// to the dead-letter stream and invoking the failure handler.
 func (s *NatsSubscriber) handleFailure(ctx context.Context, msg jetstream.Msg, metadata *jetstream.MsgMetadata, processingErr error) {
 	s.logger.ErrorContext(ctx, "Failed to process message",
 		"subject", msg.Subject(),
 		"headers", msg.Headers(),
 		"error", processingErr,
 	)
+	messagesFailed.WithLabelValues(msg.Subject()).Inc()
+
+	retryConfig := s.retryPolicies.retryConfig(msg.Subject())
+	attempt := int(metadata.NumDelivered) //nolint:gosec // the delivery count cannot realistically overflow an int
 
-	if metadata.NumDelivered >= maxDeliver {
-		s.logger.InfoContext(ctx, "Max delivery attempts reached, invoking failure handler",
+	permanent := IsPermanent(processingErr)
+	if permanent || attempt >= retryConfig.MaxAttempts {
+		s.logger.InfoContext(ctx, "Not retrying message, moving it to the dead-letter stream",
 			"subject", msg.Subject(),
 			"deliveryCount", metadata.NumDelivered,
-			"maxAttempts", s.retryConfig.MaxAttempts,
+			"maxAttempts", retryConfig.MaxAttempts,
+			"permanent", permanent,
 		)
 
-		if err := s.failureHandler.HandleFailure(ctx, msg, processingErr.Error()); err != nil {
-			s.logger.ErrorContext(ctx, "Failed to handle failure",
+		// Keep the message in the stream if it cannot be dead-lettered, so that it is not lost.
+		if err := publishDeadLetter(ctx, s.js, msg, metadata, processingErr); err != nil {
+			s.logger.ErrorContext(ctx, "Failed to dead-letter message",
 				"subject", msg.Subject(),
 				"error", err,
 			)
+			if err := msg.NakWithDelay(retryConfig.backoffDelay(attempt)); err != nil {
+				s.logger.ErrorContext(ctx, "Failed to nak message with delay",
+					"subject", msg.Subject(),
+					"error", err,
+				)
+			}
+
+			return
 		}
-		// Ack the message to remove it from the stream after failure handling
+		messagesDeadLettered.WithLabelValues(msg.Subject()).Inc()
+
+		if s.failureHandler != nil {
+			if err := s.failureHandler.HandleFailure(ctx, msg, processingErr); err != nil {
+				s.logger.ErrorContext(ctx, "Failed to handle failure",
+					"subject", msg.Subject(),
+					"error", err,
+				)
+			}
+		}
+		// Ack the message to remove it from the stream, it is now held by the dead-letter stream
 		if err := msg.Ack(); err != nil {
 			s.logger.ErrorContext(ctx, "Failed to ack message after failure handling",
 				"subject", msg.Subject(),
@@ -181,11 +364,11 @@ func (s *NatsSubscriber) handleFailure(ctx context.Context, msg jetstream.Msg, m
 		return
 	}
 
-	delay := s.backoffDelay(int(metadata.NumDelivered))
+	delay := retryConfig.backoffDelay(attempt)
 	s.logger.InfoContext(ctx, "Retrying failed message after delay",
 		"subject", msg.Subject(),
 		"deliveryCount", metadata.NumDelivered,
-		"maxAttempts", s.retryConfig.MaxAttempts,
+		"maxAttempts", retryConfig.MaxAttempts,
 		"delay", delay,
 	)
 	if err := msg.NakWithDelay(delay); err != nil {
@@ -193,23 +376,7 @@ func (s *NatsSubscriber) handleFailure(ctx context.Context, msg jetstream.Msg, m
 			"subject", msg.Subject(),
 			"error", err,
 		)
+		return
 	}
-}
-
-// backoffDelay calculates exponential backoff with jitter
-func (s *NatsSubscriber) backoffDelay(attempt int) time.Duration {
-	base := float64(s.retryConfig.BaseDelay)
-	delay := base * math.Pow(2, float64(attempt-1)) // exponential
-
-	if s.retryConfig.Jitter > 0 {
-		// Using math/rand for backoff jitter is fine - this isn't security-sensitive
-		//nolint:gosec // G404: weak random source is acceptable for retry jitter
-		jitter := delay * (s.retryConfig.Jitter * (rand.Float64()*2 - 1))
-		delay += jitter
-		if delay < 0 {
-			delay = 0
-		}
-	}
-
-	return time.Duration(delay)
+	messagesRetried.WithLabelValues(msg.Subject()).Inc()
 }
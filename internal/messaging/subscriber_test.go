@@ -11,10 +11,22 @@ import (
 
 	natstest "github.com/nats-io/nats-server/v2/test"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
-const testSubscriberSubject = "sbomscanner.subscriber.test"
+const (
+	testSubscriberSubject      = "sbomscanner.subscriber.test"
+	testSubscriberOtherSubject = "sbomscanner.subscriber.other"
+)
 
 type testMessage struct {
 	data []byte
@@ -36,12 +48,20 @@ func (h *testHandler) Handle(_ context.Context, message Message) error {
 	return h.handleFunc(message)
 }
 
+type testHandlerWithContext struct {
+	handleFunc func(context.Context, Message) error
+}
+
+func (h *testHandlerWithContext) Handle(ctx context.Context, message Message) error {
+	return h.handleFunc(ctx, message)
+}
+
 type testFailureHandler struct {
 	handleFailureFunc func(message Message, errorMessage string) error
 }
 
-func (h *testFailureHandler) HandleFailure(_ context.Context, message Message, errorMessage string) error {
-	return h.handleFailureFunc(message, errorMessage)
+func (h *testFailureHandler) HandleFailure(_ context.Context, message Message, failure error) error {
+	return h.handleFailureFunc(message, failure.Error())
 }
 
 func TestSubscriber_Run(t *testing.T) {
@@ -71,12 +91,14 @@ func TestSubscriber_Run(t *testing.T) {
 	handlers := HandlerRegistry{
 		testSubscriberSubject: testHandler,
 	}
-	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable", handlers, nil, nil, slog.Default())
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable", handlers, nil, nil, nil, slog.Default())
 	require.NoError(t, err, "failed to create subscriber")
 
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
 
+	processedBefore := testutil.ToFloat64(messagesProcessed.WithLabelValues(testSubscriberSubject))
+
 	message := []byte(`{"data":"test data"}`)
 	err = publisher.Publish(t.Context(), testSubscriberSubject, "id", message)
 	require.NoError(t, err, "failed to publish message")
@@ -97,6 +119,81 @@ func TestSubscriber_Run(t *testing.T) {
 	<-done
 
 	require.NoError(t, err, "unexpected subscriber error")
+	require.InDelta(t, 1, testutil.ToFloat64(messagesProcessed.WithLabelValues(testSubscriberSubject))-processedBefore, 0)
+}
+
+func TestSubscriber_Run_TraceContext(t *testing.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	publisher, err := NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	processed := make(chan trace.SpanContext, 1)
+	done := make(chan struct{})
+
+	handlers := HandlerRegistry{
+		testSubscriberSubject: &testHandlerWithContext{handleFunc: func(ctx context.Context, _ Message) error {
+			processed <- trace.SpanContextFromContext(ctx)
+			return nil
+		}},
+	}
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable", handlers, nil, nil, nil, slog.Default())
+	require.NoError(t, err, "failed to create subscriber")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	publishCtx, rootSpan := tracerProvider.Tracer("test").Start(t.Context(), "root")
+	err = publisher.Publish(publishCtx, testSubscriberSubject, "id", []byte(`{"data":"test data"}`))
+	require.NoError(t, err, "failed to publish message")
+	rootSpan.End()
+
+	go func() {
+		err = subscriber.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case spanContext := <-processed:
+		require.True(t, spanContext.IsValid(), "handler context has no span")
+		require.Equal(t, rootSpan.SpanContext().TraceID(), spanContext.TraceID(), "handler span is not part of the publisher trace")
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for message to be processed")
+	}
+
+	cancel()
+	<-done
+	require.NoError(t, err, "unexpected subscriber error")
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range spanRecorder.Ended() {
+		spans[span.Name()] = span
+	}
+	require.Contains(t, spans, "send "+testSubscriberSubject)
+	require.Contains(t, spans, "process "+testSubscriberSubject)
+	sendSpan := spans["send "+testSubscriberSubject]
+	processSpan := spans["process "+testSubscriberSubject]
+	require.Equal(t, rootSpan.SpanContext().SpanID(), sendSpan.Parent().SpanID())
+	require.Equal(t, sendSpan.SpanContext().SpanID(), processSpan.Parent().SpanID())
+	require.Equal(t, trace.SpanKindConsumer, processSpan.SpanKind())
 }
 
 func TestSubscriber_Run_WithRetry(t *testing.T) {
@@ -137,7 +234,7 @@ func TestSubscriber_Run_WithRetry(t *testing.T) {
 		Jitter:      0,
 		MaxAttempts: 5,
 	}
-	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-retry", handlers, nil, retryConfig, slog.Default())
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-retry", handlers, nil, RetryPolicies{testSubscriberSubject: retryConfig}, nil, slog.Default())
 	require.NoError(t, err, "failed to create subscriber")
 
 	ctx, cancel := context.WithCancel(t.Context())
@@ -210,9 +307,13 @@ func TestSubscriber_Run_WithMaxRetriesExceeded(t *testing.T) {
 		Jitter:      0,
 		MaxAttempts: 5,
 	}
-	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-max-retry", handlers, testFailureHandler, retryConfig, slog.Default())
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-max-retry", handlers, testFailureHandler, RetryPolicies{testSubscriberSubject: retryConfig}, nil, slog.Default())
 	require.NoError(t, err, "failed to create subscriber")
 
+	failedBefore := testutil.ToFloat64(messagesFailed.WithLabelValues(testSubscriberSubject))
+	retriedBefore := testutil.ToFloat64(messagesRetried.WithLabelValues(testSubscriberSubject))
+	deadLetteredBefore := testutil.ToFloat64(messagesDeadLettered.WithLabelValues(testSubscriberSubject))
+
 	ctx, cancel := context.WithCancel(t.Context())
 	defer cancel()
 
@@ -235,6 +336,432 @@ func TestSubscriber_Run_WithMaxRetriesExceeded(t *testing.T) {
 	cancel()
 	<-done
 	require.NoError(t, err, "unexpected subscriber error")
+
+	require.InDelta(t, 5, testutil.ToFloat64(messagesFailed.WithLabelValues(testSubscriberSubject))-failedBefore, 0)
+	require.InDelta(t, 4, testutil.ToFloat64(messagesRetried.WithLabelValues(testSubscriberSubject))-retriedBefore, 0)
+	require.InDelta(t, 1, testutil.ToFloat64(messagesDeadLettered.WithLabelValues(testSubscriberSubject))-deadLetteredBefore, 0)
+}
+
+func TestSubscriber_Run_WithRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		handlerErr       error
+		maxAttempts      int
+		expectedAttempts int32
+	}{
+		{
+			name:             "max attempts from the subject retry policy",
+			handlerErr:       errors.New("processing failed"),
+			maxAttempts:      2,
+			expectedAttempts: 2,
+		},
+		{
+			name:             "permanent error is not retried",
+			handlerErr:       fmt.Errorf("wrapped: %w", NewPermanentError(errors.New("malformed message"))),
+			maxAttempts:      5,
+			expectedAttempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := natstest.DefaultTestOptions
+			opts.Port = -1 // Use a random port
+			opts.JetStream = true
+			opts.StoreDir = t.TempDir()
+			ns := natstest.RunServer(&opts)
+			defer ns.Shutdown()
+
+			nc, err := nats.Connect(ns.ClientURL())
+			require.NoError(t, err)
+			defer nc.Close()
+
+			publisher, err := NewNatsPublisher(t.Context(), nc, slog.Default())
+			require.NoError(t, err)
+
+			var attemptCount atomic.Int32
+			failureHandled := make(chan string, 1)
+			done := make(chan struct{})
+
+			handlers := HandlerRegistry{
+				testSubscriberSubject: &testHandler{handleFunc: func(_ Message) error {
+					attemptCount.Add(1)
+					return test.handlerErr
+				}},
+			}
+			failureHandler := &testFailureHandler{handleFailureFunc: func(_ Message, errorMessage string) error {
+				failureHandled <- errorMessage
+				return nil
+			}}
+			retryPolicies := RetryPolicies{
+				testSubscriberSubject: {
+					BaseDelay:   10 * time.Millisecond,
+					MaxDelay:    50 * time.Millisecond,
+					MaxAttempts: test.maxAttempts,
+				},
+			}
+			subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-retry-policy", handlers, failureHandler, retryPolicies, nil, slog.Default())
+			require.NoError(t, err, "failed to create subscriber")
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			err = publisher.Publish(t.Context(), testSubscriberSubject, "id", []byte(`{"data":"retry-policy-test"}`))
+			require.NoError(t, err, "failed to publish message")
+
+			go func() {
+				err = subscriber.Run(ctx)
+				close(done)
+			}()
+
+			select {
+			case errorMessage := <-failureHandled:
+				require.Contains(t, errorMessage, test.handlerErr.Error())
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "timed out waiting for failure handler")
+			}
+
+			cancel()
+			<-done
+			require.NoError(t, err, "unexpected subscriber error")
+			require.Equal(t, test.expectedAttempts, attemptCount.Load(), "unexpected number of attempts")
+		})
+	}
+}
+
+func TestSubscriber_Run_WithConcurrency(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	publisher, err := NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	const maxInFlight = 3
+	const messageCount = 6
+
+	var inFlight, maxObservedInFlight atomic.Int32
+	started := make(chan struct{}, messageCount)
+	release := make(chan struct{})
+	processed := make(chan struct{}, messageCount)
+	otherProcessed := make(chan struct{}, 1)
+	done := make(chan struct{})
+
+	// Handler that blocks until released, tracking the number of concurrent executions
+	handleFunc := func(_ Message) error {
+		current := inFlight.Add(1)
+		for {
+			observed := maxObservedInFlight.Load()
+			if current <= observed || maxObservedInFlight.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		started <- struct{}{}
+		<-release
+		inFlight.Add(-1)
+		processed <- struct{}{}
+		return nil
+	}
+
+	handlers := HandlerRegistry{
+		testSubscriberSubject: &testHandler{handleFunc: handleFunc},
+		testSubscriberOtherSubject: &testHandler{handleFunc: func(_ Message) error {
+			otherProcessed <- struct{}{}
+			return nil
+		}},
+	}
+	concurrencyConfig := &ConcurrencyConfig{
+		MaxInFlight: map[string]int{
+			testSubscriberSubject: maxInFlight,
+		},
+	}
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-concurrency", handlers, nil, nil, concurrencyConfig, slog.Default())
+	require.NoError(t, err, "failed to create subscriber")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	for i := range messageCount {
+		err = publisher.Publish(t.Context(), testSubscriberSubject, fmt.Sprintf("id-%d", i), []byte(`{"data":"concurrency-test"}`))
+		require.NoError(t, err, "failed to publish message")
+	}
+
+	go func() {
+		err = subscriber.Run(ctx)
+		close(done)
+	}()
+
+	for range maxInFlight {
+		select {
+		case <-started:
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for messages to be processed concurrently")
+		}
+	}
+
+	// The pool is full, no other message of the subject must be processed
+	select {
+	case <-started:
+		require.Fail(t, "more messages than the configured maximum are in flight")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Other subjects must not be blocked by the full pool
+	err = publisher.Publish(t.Context(), testSubscriberOtherSubject, "other-id", []byte(`{"data":"other"}`))
+	require.NoError(t, err, "failed to publish message")
+	select {
+	case <-otherProcessed:
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for message of another subject to be processed")
+	}
+
+	close(release)
+	for range messageCount {
+		select {
+		case <-processed:
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for all messages to be processed")
+		}
+	}
+	require.Equal(t, int32(maxInFlight), maxObservedInFlight.Load(), "unexpected number of concurrent messages")
+
+	cancel()
+	<-done
+	require.NoError(t, err, "unexpected subscriber error")
+}
+
+func TestSubscriber_Run_GracefulDrain(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	publisher, err := NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	handlers := HandlerRegistry{
+		testSubscriberSubject: &testHandler{handleFunc: func(_ Message) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}},
+	}
+	concurrencyConfig := &ConcurrencyConfig{
+		DrainTimeout: 5 * time.Second,
+	}
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-drain", handlers, nil, nil, concurrencyConfig, slog.Default())
+	require.NoError(t, err, "failed to create subscriber")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	err = publisher.Publish(t.Context(), testSubscriberSubject, "id", []byte(`{"data":"drain-test"}`))
+	require.NoError(t, err, "failed to publish message")
+
+	go func() {
+		err = subscriber.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for message to be processed")
+	}
+
+	cancel()
+
+	// The subscriber must wait for the in-flight message
+	select {
+	case <-done:
+		require.Fail(t, "subscriber stopped before the in-flight message was processed")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for subscriber to stop")
+	}
+	require.NoError(t, err, "unexpected subscriber error")
+
+	// The message has been acked and removed from the work queue
+	require.Equal(t, uint64(0), streamMessages(t, nc), "expected the message to be acked")
+}
+
+func TestSubscriber_Run_DrainTimeout(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	publisher, err := NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	started := make(chan struct{}, 1)
+	done := make(chan struct{})
+
+	handlers := HandlerRegistry{
+		testSubscriberSubject: &testHandlerWithContext{handleFunc: func(ctx context.Context, _ Message) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+	concurrencyConfig := &ConcurrencyConfig{
+		DrainTimeout: 100 * time.Millisecond,
+	}
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-drain-timeout", handlers, nil, nil, concurrencyConfig, slog.Default())
+	require.NoError(t, err, "failed to create subscriber")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	err = publisher.Publish(t.Context(), testSubscriberSubject, "id", []byte(`{"data":"drain-timeout-test"}`))
+	require.NoError(t, err, "failed to publish message")
+
+	go func() {
+		err = subscriber.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for message to be processed")
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "timed out waiting for subscriber to stop after the drain timeout")
+	}
+	require.NoError(t, err, "unexpected subscriber error")
+
+	// The interrupted message has been nak'ed and is still in the work queue
+	require.Equal(t, uint64(1), streamMessages(t, nc), "expected the message to be redelivered")
+}
+
+func TestNewNatsSubscriber_LegacyConsumer(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // Use a random port
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	ns := natstest.RunServer(&opts)
+	defer ns.Shutdown()
+
+	nc, err := nats.Connect(ns.ClientURL())
+	require.NoError(t, err)
+	defer nc.Close()
+
+	publisher, err := NewNatsPublisher(t.Context(), nc, slog.Default())
+	require.NoError(t, err)
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	// Consumer filtering all the subjects, as created by previous versions
+	_, err = js.CreateOrUpdateConsumer(t.Context(), streamName, jetstream.ConsumerConfig{
+		Durable:        "test-durable-legacy",
+		FilterSubjects: []string{testSubscriberSubject, testSubscriberOtherSubject},
+	})
+	require.NoError(t, err)
+
+	processed := make(chan string, 2)
+	handleFunc := func(m Message) error {
+		processed <- string(m.Data())
+		return nil
+	}
+	handlers := HandlerRegistry{
+		testSubscriberSubject:      &testHandler{handleFunc: handleFunc},
+		testSubscriberOtherSubject: &testHandler{handleFunc: handleFunc},
+	}
+	subscriber, err := NewNatsSubscriber(t.Context(), nc, "test-durable-legacy", handlers, nil, nil, nil, slog.Default())
+	require.NoError(t, err, "failed to create subscriber")
+
+	// The legacy consumer is kept, since the workers of the previous version might still be consuming from it.
+	_, err = js.Consumer(t.Context(), streamName, "test-durable-legacy")
+	require.NoError(t, err)
+	for subject := range handlers {
+		_, err = js.Consumer(t.Context(), streamName, consumerName("test-durable-legacy", subject))
+		require.ErrorIs(t, err, jetstream.ErrConsumerNotFound, "unexpected consumer for subject %s", subject)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, subscriber.Run(ctx))
+		close(done)
+	}()
+
+	require.NoError(t, publisher.Publish(t.Context(), testSubscriberSubject, "id1", []byte("first")))
+	require.NoError(t, publisher.Publish(t.Context(), testSubscriberOtherSubject, "id2", []byte("second")))
+
+	var messages []string
+	for range 2 {
+		select {
+		case message := <-processed:
+			messages = append(messages, message)
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "timed out waiting for message to be processed")
+		}
+	}
+	assert.ElementsMatch(t, []string{"first", "second"}, messages)
+
+	cancel()
+	<-done
+
+	// Once the legacy consumer is removed, a consumer is created for each subject.
+	require.NoError(t, js.DeleteConsumer(t.Context(), streamName, "test-durable-legacy"))
+	_, err = NewNatsSubscriber(t.Context(), nc, "test-durable-legacy", handlers, nil, nil, nil, slog.Default())
+	require.NoError(t, err, "failed to create subscriber")
+	for subject := range handlers {
+		_, err = js.Consumer(t.Context(), streamName, consumerName("test-durable-legacy", subject))
+		require.NoError(t, err, "expected consumer for subject %s", subject)
+	}
+}
+
+// streamMessages returns the number of messages stored in the stream.
+func streamMessages(t *testing.T, nc *nats.Conn) uint64 {
+	t.Helper()
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+
+	stream, err := js.Stream(t.Context(), streamName)
+	require.NoError(t, err)
+
+	info, err := stream.Info(t.Context())
+	require.NoError(t, err)
+
+	return info.State.Msgs
 }
 
 func TestSubscriber_handleMessage(t *testing.T) {
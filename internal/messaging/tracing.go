@@ -0,0 +1,93 @@
+package messaging
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/kubewarden/sbomscanner/internal/messaging")
+
+// messagingSystemNATS identifies NATS as the messaging system of the spans.
+var messagingSystemNATS = semconv.MessagingSystemKey.String("nats")
+
+// headerCarrier adapts the headers of a NATS message to propagation.TextMapCarrier.
+// NATS headers are case-sensitive, while the NATS server may rewrite the case of the
+// trace context headers, so the keys are matched case-insensitively.
+type headerCarrier nats.Header
+
+// Get returns the value associated with the passed key.
+func (c headerCarrier) Get(key string) string {
+	for k, values := range c {
+		if strings.EqualFold(k, key) && len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+// Set stores the key-value pair.
+func (c headerCarrier) Set(key, value string) {
+	nats.Header(c).Set(key, value)
+}
+
+// Keys lists the keys stored in this carrier.
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// injectTraceContext sets the W3C trace context of ctx in the headers of a message.
+func injectTraceContext(ctx context.Context, header nats.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(header))
+}
+
+// extractTraceContext returns a copy of ctx holding the W3C trace context found in the headers of a message.
+func extractTraceContext(ctx context.Context, header nats.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier(header))
+}
+
+// startPublishSpan starts the span of the publication of a message.
+func startPublishSpan(ctx context.Context, subject, messageID string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "send "+subject,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			messagingSystemNATS,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(subject),
+			semconv.MessagingMessageID(messageID),
+		),
+	)
+}
+
+// startProcessSpan starts the span of the processing of a delivered message,
+// as a child of the span that published it.
+func startProcessSpan(ctx context.Context, subject string, header nats.Header, attempt uint64) (context.Context, trace.Span) {
+	return tracer.Start(extractTraceContext(ctx, header), "process "+subject,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			messagingSystemNATS,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(subject),
+			semconv.MessagingMessageID(header.Get(nats.MsgIdHdr)),
+			attribute.Int64("messaging.nats.delivery_count", int64(attempt)), //nolint:gosec // the delivery count cannot realistically overflow an int64
+		),
+	)
+}
+
+// recordSpanError marks the span as failed with the given error.
+func recordSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
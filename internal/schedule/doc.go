@@ -0,0 +1,2 @@
+// Package schedule computes when registries are scanned automatically.
+package schedule
@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+// maxBlackoutWindowSkips bounds the search of a time outside of the blackout windows,
+// since overlapping windows could cover all the time.
+const maxBlackoutWindowSkips = 1000
+
+// ErrNoTimeOutsideBlackoutWindows is returned when the blackout windows leave no time to start a scan.
+var ErrNoTimeOutsideBlackoutWindows = errors.New("no time found outside of the blackout windows")
+
+// Schedule is the automatic scan schedule of a Registry.
+type Schedule struct {
+	cron            cron.Schedule
+	interval        time.Duration
+	blackoutWindows []blackoutWindow
+	location        *time.Location
+}
+
+type blackoutWindow struct {
+	start    cron.Schedule
+	duration time.Duration
+}
+
+// ParseCron parses a standard cron expression with five fields, or a descriptor such as "@daily".
+// The time zone cannot be set in the expression, since it is configured separately.
+func ParseCron(expr string) (cron.Schedule, error) {
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, errors.New("the time zone cannot be set in the cron expression, use timeZone instead")
+	}
+
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	return schedule, nil
+}
+
+// LoadLocation returns the location of the given IANA time zone.
+// An empty time zone means UTC.
+func LoadLocation(timeZone string) (*time.Location, error) {
+	if timeZone == "" {
+		return time.UTC, nil
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+
+	return location, nil
+}
+
+// ForRegistry returns the automatic scan schedule of the given Registry.
+// The Registry must have either a ScanInterval or a Schedule.
+func ForRegistry(registry *v1alpha1.Registry) (*Schedule, error) {
+	location, err := LoadLocation(registry.Spec.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &Schedule{
+		location: location,
+	}
+
+	switch {
+	case registry.Spec.Schedule != "":
+		schedule.cron, err = ParseCron(registry.Spec.Schedule)
+		if err != nil {
+			return nil, err
+		}
+	case registry.Spec.ScanInterval != nil && registry.Spec.ScanInterval.Duration > 0:
+		schedule.interval = registry.Spec.ScanInterval.Duration
+	default:
+		return nil, fmt.Errorf("registry %s/%s is not scanned automatically", registry.Namespace, registry.Name)
+	}
+
+	for _, window := range registry.Spec.BlackoutWindows {
+		start, err := ParseCron(window.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blackout window start: %w", err)
+		}
+		if window.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("blackout window %q must have a positive duration", window.Start)
+		}
+
+		schedule.blackoutWindows = append(schedule.blackoutWindows, blackoutWindow{
+			start:    start,
+			duration: window.Duration.Duration,
+		})
+	}
+
+	return schedule, nil
+}
+
+// IsCron returns true if the scans are scheduled by a cron expression,
+// false if they are scheduled at a fixed interval.
+func (s *Schedule) IsCron() bool {
+	return s.cron != nil
+}
+
+// Next returns when the scan following the given time is due, outside of the blackout windows.
+// For cron schedules, the given time is when the last scan started.
+// For interval schedules, it is when the last scan completed.
+func (s *Schedule) Next(last time.Time) (time.Time, error) {
+	var next time.Time
+	if s.cron != nil {
+		next = s.cron.Next(last.In(s.location))
+	} else {
+		next = last.Add(s.interval).In(s.location)
+	}
+
+	return s.SkipBlackoutWindows(next)
+}
+
+// SkipBlackoutWindows returns the given time if it is outside of the blackout windows,
+// otherwise the end of the blackout windows containing it.
+func (s *Schedule) SkipBlackoutWindows(t time.Time) (time.Time, error) {
+	t = t.In(s.location)
+
+	for range maxBlackoutWindowSkips {
+		end, ok := s.blackoutWindowEnd(t)
+		if !ok {
+			return t, nil
+		}
+		t = end
+	}
+
+	return time.Time{}, ErrNoTimeOutsideBlackoutWindows
+}
+
+// blackoutWindowEnd returns the end of the blackout window containing the given time, if any.
+func (s *Schedule) blackoutWindowEnd(t time.Time) (time.Time, bool) {
+	for _, window := range s.blackoutWindows {
+		// The first start after t-duration is the only one whose window can contain t.
+		start := window.start.Next(t.Add(-window.duration))
+		if !start.After(t) {
+			return start.Add(window.duration), true
+		}
+	}
+
+	return time.Time{}, false
+}
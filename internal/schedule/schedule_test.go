@@ -0,0 +1,199 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		spec         v1alpha1.RegistrySpec
+		last         time.Time
+		expectedNext time.Time
+	}{
+		{
+			name: "interval",
+			spec: v1alpha1.RegistrySpec{
+				ScanInterval: &metav1.Duration{Duration: 6 * time.Hour},
+			},
+			last:         time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 6, 2, 16, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "cron in UTC",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 2 * * *",
+			},
+			last:         time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 6, 3, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "cron with time zone",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 2 * * *",
+				TimeZone: "Europe/Berlin",
+			},
+			last:         time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 6, 3, 2, 0, 0, 0, berlin),
+		},
+		{
+			name: "cron descriptor",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "@weekly",
+			},
+			// Monday
+			last:         time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 6, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "interval ending in a blackout window",
+			spec: v1alpha1.RegistrySpec{
+				ScanInterval: &metav1.Duration{Duration: time.Hour},
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					// Business hours
+					{Start: "0 9 * * 1-5", Duration: metav1.Duration{Duration: 8 * time.Hour}},
+				},
+			},
+			// Monday
+			last:         time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 6, 2, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "interval ending outside of the blackout windows",
+			spec: v1alpha1.RegistrySpec{
+				ScanInterval: &metav1.Duration{Duration: time.Hour},
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "0 9 * * 1-5", Duration: metav1.Duration{Duration: 8 * time.Hour}},
+				},
+			},
+			// Saturday
+			last:         time.Date(2025, 6, 7, 10, 30, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 6, 7, 11, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "cron in consecutive blackout windows",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 12 * * *",
+				TimeZone: "Europe/Berlin",
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "0 9 * * *", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+					{Start: "0 13 * * *", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+				},
+			},
+			last:         time.Date(2025, 6, 2, 10, 30, 0, 0, berlin),
+			expectedNext: time.Date(2025, 6, 2, 15, 0, 0, 0, berlin),
+		},
+		{
+			name: "cron at the end of a blackout window",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 17 * * *",
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "0 9 * * *", Duration: metav1.Duration{Duration: 8 * time.Hour}},
+				},
+			},
+			last:         time.Date(2025, 6, 2, 10, 30, 0, 0, time.UTC),
+			expectedNext: time.Date(2025, 6, 2, 17, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ForRegistry(&v1alpha1.Registry{Spec: test.spec})
+			require.NoError(t, err)
+
+			next, err := schedule.Next(test.last)
+			require.NoError(t, err)
+			assert.True(t, test.expectedNext.Equal(next), "expected %s, got %s", test.expectedNext, next)
+		})
+	}
+}
+
+func TestSchedule_SkipBlackoutWindows_AlwaysBlackedOut(t *testing.T) {
+	schedule, err := ForRegistry(&v1alpha1.Registry{
+		Spec: v1alpha1.RegistrySpec{
+			Schedule: "@daily",
+			BlackoutWindows: []v1alpha1.BlackoutWindow{
+				{Start: "0 * * * *", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = schedule.SkipBlackoutWindows(time.Now())
+	require.ErrorIs(t, err, ErrNoTimeOutsideBlackoutWindows)
+}
+
+func TestForRegistry_Invalid(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          v1alpha1.RegistrySpec
+		expectedError string
+	}{
+		{
+			name:          "no schedule",
+			spec:          v1alpha1.RegistrySpec{},
+			expectedError: "is not scanned automatically",
+		},
+		{
+			name: "invalid cron expression",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 2 * *",
+			},
+			expectedError: "invalid cron expression",
+		},
+		{
+			name: "time zone in the cron expression",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "CRON_TZ=Europe/Berlin 0 2 * * *",
+			},
+			expectedError: "use timeZone instead",
+		},
+		{
+			name: "invalid time zone",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 2 * * *",
+				TimeZone: "Mars/Olympus_Mons",
+			},
+			expectedError: "invalid time zone",
+		},
+		{
+			name: "invalid blackout window start",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 2 * * *",
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "invalid", Duration: metav1.Duration{Duration: time.Hour}},
+				},
+			},
+			expectedError: "invalid blackout window start",
+		},
+		{
+			name: "blackout window without duration",
+			spec: v1alpha1.RegistrySpec{
+				Schedule: "0 2 * * *",
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "0 9 * * *"},
+				},
+			},
+			expectedError: "must have a positive duration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ForRegistry(&v1alpha1.Registry{Spec: test.spec})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedError)
+		})
+	}
+}
@@ -4,19 +4,27 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
+	"github.com/kubewarden/sbomscanner/internal/handlers/credentialprovider"
+	"github.com/kubewarden/sbomscanner/internal/handlers/dockerauth"
+	registryclient "github.com/kubewarden/sbomscanner/internal/handlers/registry"
+	"github.com/kubewarden/sbomscanner/internal/schedule"
 )
 
 const (
@@ -25,10 +33,13 @@ const (
 
 var availableCatalogTypes = []string{v1alpha1.CatalogTypeNoCatalog, v1alpha1.CatalogTypeOCIDistribution}
 
+var availableCredentialProviders = []string{v1alpha1.CredentialProviderAWS, v1alpha1.CredentialProviderGCP, v1alpha1.CredentialProviderAzure}
+
 // SetupRegistryWebhookWithManager registers the webhook for Registry in the manager.
 func SetupRegistryWebhookWithManager(mgr ctrl.Manager) error {
 	err := ctrl.NewWebhookManagedBy(mgr).For(&v1alpha1.Registry{}).
 		WithValidator(&RegistryCustomValidator{
+			client: mgr.GetAPIReader(),
 			logger: mgr.GetLogger().WithName("registry_validator"),
 		}).
 		WithDefaulter(&RegistryCustomDefaulter{
@@ -68,13 +79,15 @@ func (d *RegistryCustomDefaulter) Default(_ context.Context, obj runtime.Object)
 // +kubebuilder:webhook:path=/validate-sbomscanner-kubewarden-io-v1alpha1-registry,mutating=false,failurePolicy=fail,sideEffects=None,groups=sbomscanner.kubewarden.io,resources=registries,verbs=create;update,versions=v1alpha1,name=vregistry.sbomscanner.kubewarden.io,admissionReviewVersions=v1
 
 type RegistryCustomValidator struct {
+	// client reads the auth Secrets directly from the API server, so that Secrets are not cached.
+	client client.Reader
 	logger logr.Logger
 }
 
 var _ webhook.CustomValidator = &RegistryCustomValidator{}
 
 // ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Registry.
-func (v *RegistryCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+func (v *RegistryCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
 	registry, ok := obj.(*v1alpha1.Registry)
 	if !ok {
 		return nil, fmt.Errorf("expected a Registry object but got %T", obj)
@@ -91,11 +104,11 @@ func (v *RegistryCustomValidator) ValidateCreate(_ context.Context, obj runtime.
 		)
 	}
 
-	return nil, nil
+	return v.registryWarnings(ctx, registry), nil
 }
 
 // ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Registry.
-func (v *RegistryCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+func (v *RegistryCustomValidator) ValidateUpdate(ctx context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
 	registry, ok := newObj.(*v1alpha1.Registry)
 	if !ok {
 		return nil, fmt.Errorf("expected a Registry object for the newObj but got %T", newObj)
@@ -112,7 +125,7 @@ func (v *RegistryCustomValidator) ValidateUpdate(_ context.Context, _, newObj ru
 		)
 	}
 
-	return nil, nil
+	return v.registryWarnings(ctx, registry), nil
 }
 
 // ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Registry.
@@ -126,6 +139,25 @@ func (v *RegistryCustomValidator) ValidateDelete(_ context.Context, obj runtime.
 	return nil, nil
 }
 
+// registryWarnings returns warnings about the configuration of the registry that cannot be rejected,
+// because it might be fixed later, like an auth Secret created after the Registry.
+// The connectivity to the registry is checked asynchronously by the controller, see the Validated condition.
+func (v *RegistryCustomValidator) registryWarnings(ctx context.Context, registry *v1alpha1.Registry) admission.Warnings {
+	var warnings admission.Warnings
+
+	if _, err := registryclient.RootCAs(registry.Spec.CABundle); err != nil {
+		warnings = append(warnings, fmt.Sprintf("spec.caBundle: %s", err))
+	}
+
+	if registry.Spec.AuthSecret != "" {
+		if _, err := dockerauth.KeychainForRegistry(ctx, v.client, registry); err != nil {
+			warnings = append(warnings, fmt.Sprintf("spec.authSecret: %s", err))
+		}
+	}
+
+	return warnings
+}
+
 func validateScanInterval(registry *v1alpha1.Registry) error {
 	if registry.Spec.ScanInterval == nil {
 		return nil
@@ -137,6 +169,54 @@ func validateScanInterval(registry *v1alpha1.Registry) error {
 	return nil
 }
 
+func validateSchedule(registry *v1alpha1.Registry) error {
+	if registry.Spec.Schedule == "" {
+		return nil
+	}
+	if registry.Spec.ScanInterval != nil && registry.Spec.ScanInterval.Duration != 0 {
+		return errors.New("schedule cannot be used together with scanInterval")
+	}
+	if _, err := schedule.ParseCron(registry.Spec.Schedule); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateTimeZone(registry *v1alpha1.Registry) error {
+	if _, err := schedule.LoadLocation(registry.Spec.TimeZone); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func validateBlackoutWindows(registry *v1alpha1.Registry) error {
+	if len(registry.Spec.BlackoutWindows) == 0 {
+		return nil
+	}
+	for _, window := range registry.Spec.BlackoutWindows {
+		if _, err := schedule.ParseCron(window.Start); err != nil {
+			return err
+		}
+		if window.Duration.Duration <= 0 {
+			return fmt.Errorf("blackout window %q must have a positive duration", window.Start)
+		}
+	}
+
+	// An invalid schedule is reported by the other validations.
+	if !registry.IsScanScheduled() {
+		return nil
+	}
+	if scanSchedule, err := schedule.ForRegistry(registry); err == nil {
+		if _, err = scanSchedule.SkipBlackoutWindows(time.Now()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func validateCatalogType(registry *v1alpha1.Registry) error {
 	// If the catalog type is empty, the Defaulter will set it to the default catalog type.
 	if registry.Spec.CatalogType == "" {
@@ -168,6 +248,83 @@ func validatePlatforms(registry *v1alpha1.Registry) error {
 	return nil
 }
 
+func validateCredentialProvider(registry *v1alpha1.Registry) error {
+	if registry.Spec.CredentialProvider == "" {
+		return nil
+	}
+	if !slices.Contains(availableCredentialProviders, registry.Spec.CredentialProvider) {
+		return fmt.Errorf("%s is not a valid CredentialProvider", registry.Spec.CredentialProvider)
+	}
+	if registry.Spec.AuthSecret != "" {
+		return errors.New("credentialProvider cannot be used together with authSecret")
+	}
+
+	// The credentials are requested to the provider for the registry and its mirrors.
+	hosts := []string{registry.Spec.URI}
+	for _, mirror := range registry.Spec.Mirrors {
+		host, _, _ := strings.Cut(mirror, "/")
+		hosts = append(hosts, host)
+	}
+	for _, host := range hosts {
+		supported, err := credentialprovider.SupportsRegistry(registry.Spec.CredentialProvider, host)
+		if err != nil {
+			return fmt.Errorf("cannot validate credentialProvider: %w", err)
+		}
+		if !supported {
+			return fmt.Errorf("registry %s is not hosted by %s", host, registry.Spec.CredentialProvider)
+		}
+	}
+
+	return nil
+}
+
+func validateMirrors(registry *v1alpha1.Registry) error {
+	for _, mirror := range registry.Spec.Mirrors {
+		if strings.Contains(mirror, "://") {
+			return fmt.Errorf("mirror %s must not contain a scheme", mirror)
+		}
+		// A mirror is valid if it can be used as the prefix of a repository name.
+		if _, err := name.NewRepository(path.Join(mirror, "library")); err != nil {
+			return fmt.Errorf("%s is not a valid mirror: %w", mirror, err)
+		}
+	}
+
+	return nil
+}
+
+func validateRateLimit(registry *v1alpha1.Registry) error {
+	if registry.Spec.RateLimit == nil {
+		return nil
+	}
+	if registry.Spec.RateLimit.RequestsPerSecond < 1 {
+		return errors.New("requestsPerSecond must be at least 1")
+	}
+	if registry.Spec.RateLimit.Burst < 0 {
+		return errors.New("burst must not be negative")
+	}
+
+	return nil
+}
+
+func validateScanJobsHistoryLimit(limit *int32) error {
+	if limit != nil && *limit < 0 {
+		return errors.New("history limit must not be negative")
+	}
+
+	return nil
+}
+
+func validateScanJobsHistoryMaxAge(registry *v1alpha1.Registry) error {
+	if registry.Spec.ScanJobsHistoryMaxAge == nil {
+		return nil
+	}
+	if registry.Spec.ScanJobsHistoryMaxAge.Duration <= 0 {
+		return errors.New("scanJobsHistoryMaxAge must be greater than 0")
+	}
+
+	return nil
+}
+
 func validateRegistry(registry *v1alpha1.Registry) field.ErrorList {
 	var allErrs field.ErrorList
 
@@ -176,6 +333,21 @@ func validateRegistry(registry *v1alpha1.Registry) field.ErrorList {
 		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.ScanInterval, err.Error()))
 	}
 
+	if err := validateSchedule(registry); err != nil {
+		fieldPath := field.NewPath("spec").Child("schedule")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.Schedule, err.Error()))
+	}
+
+	if err := validateTimeZone(registry); err != nil {
+		fieldPath := field.NewPath("spec").Child("timeZone")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.TimeZone, err.Error()))
+	}
+
+	if err := validateBlackoutWindows(registry); err != nil {
+		fieldPath := field.NewPath("spec").Child("blackoutWindows")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.BlackoutWindows, err.Error()))
+	}
+
 	if err := validateCatalogType(registry); err != nil {
 		fieldPath := field.NewPath("spec").Child("catalogType")
 		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.CatalogType, err.Error()))
@@ -189,6 +361,30 @@ func validateRegistry(registry *v1alpha1.Registry) field.ErrorList {
 		filepath := field.NewPath("spec").Child("platforms")
 		allErrs = append(allErrs, field.Invalid(filepath, registry.Spec.Platforms, err.Error()))
 	}
+	if err := validateCredentialProvider(registry); err != nil {
+		fieldPath := field.NewPath("spec").Child("credentialProvider")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.CredentialProvider, err.Error()))
+	}
+	if err := validateMirrors(registry); err != nil {
+		fieldPath := field.NewPath("spec").Child("mirrors")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.Mirrors, err.Error()))
+	}
+	if err := validateRateLimit(registry); err != nil {
+		fieldPath := field.NewPath("spec").Child("rateLimit")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.RateLimit, err.Error()))
+	}
+	if err := validateScanJobsHistoryLimit(registry.Spec.SuccessfulScanJobsHistoryLimit); err != nil {
+		fieldPath := field.NewPath("spec").Child("successfulScanJobsHistoryLimit")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.SuccessfulScanJobsHistoryLimit, err.Error()))
+	}
+	if err := validateScanJobsHistoryLimit(registry.Spec.FailedScanJobsHistoryLimit); err != nil {
+		fieldPath := field.NewPath("spec").Child("failedScanJobsHistoryLimit")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.FailedScanJobsHistoryLimit, err.Error()))
+	}
+	if err := validateScanJobsHistoryMaxAge(registry); err != nil {
+		fieldPath := field.NewPath("spec").Child("scanJobsHistoryMaxAge")
+		allErrs = append(allErrs, field.Invalid(fieldPath, registry.Spec.ScanJobsHistoryMaxAge, err.Error()))
+	}
 
 	return allErrs
 }
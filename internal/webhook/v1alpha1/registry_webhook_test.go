@@ -8,16 +8,23 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"github.com/kubewarden/sbomscanner/api/v1alpha1"
 )
 
 type registryTestCase struct {
-	name          string
-	registry      *v1alpha1.Registry
-	expectedError string
-	expectedField string
+	name             string
+	registry         *v1alpha1.Registry
+	expectedError    string
+	expectedField    string
+	expectedWarnings admission.Warnings
 }
 
 func TestRegistryDefaulter_Default(t *testing.T) {
@@ -201,12 +208,428 @@ var registryTestCases = []registryTestCase{
 		expectedField: "spec.platforms",
 		expectedError: "is not an allowed platform",
 	},
+	{
+		name: "should allow creation when credentialProvider is valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+				CredentialProvider: v1alpha1.CredentialProviderAWS,
+			},
+		},
+	},
+	{
+		name: "should deny creation when credentialProvider is not valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                "registry.test.local",
+				CredentialProvider: "OnPrem",
+			},
+		},
+		expectedField: "spec.credentialProvider",
+		expectedError: "OnPrem is not a valid CredentialProvider",
+	},
+	{
+		name: "should deny creation when credentialProvider is used together with authSecret",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                "myregistry.azurecr.io",
+				AuthSecret:         "my-secret",
+				CredentialProvider: v1alpha1.CredentialProviderAzure,
+			},
+		},
+		expectedField: "spec.credentialProvider",
+		expectedError: "credentialProvider cannot be used together with authSecret",
+	},
+	{
+		name: "should deny creation when the registry is not hosted by the credentialProvider",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                "ghcr.io",
+				CredentialProvider: v1alpha1.CredentialProviderGCP,
+			},
+		},
+		expectedField: "spec.credentialProvider",
+		expectedError: "registry ghcr.io is not hosted by GCP",
+	},
+	{
+		name: "should deny creation when a mirror is not hosted by the credentialProvider",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                "myregistry.azurecr.io",
+				CredentialProvider: v1alpha1.CredentialProviderAzure,
+				Mirrors:            []string{"mirror.example.com:5000/azure"},
+			},
+		},
+		expectedField: "spec.credentialProvider",
+		expectedError: "registry mirror.example.com:5000 is not hosted by Azure",
+	},
+	{
+		name: "should allow creation when mirrors are valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:     "docker.io",
+				Mirrors: []string{"mirror.test.local:5000", "mirror.test.local/docker.io"},
+			},
+		},
+	},
+	{
+		name: "should deny creation when a mirror contains a scheme",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:     "docker.io",
+				Mirrors: []string{"https://mirror.test.local"},
+			},
+		},
+		expectedField: "spec.mirrors",
+		expectedError: "must not contain a scheme",
+	},
+	{
+		name: "should deny creation when a mirror is not valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:     "docker.io",
+				Mirrors: []string{"mirror.test.local/Invalid Path"},
+			},
+		},
+		expectedField: "spec.mirrors",
+		expectedError: "is not a valid mirror",
+	},
+	{
+		name: "should allow creation when rateLimit is valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI: "registry.test.local",
+				RateLimit: &v1alpha1.RateLimit{
+					RequestsPerSecond: 5,
+					Burst:             10,
+				},
+			},
+		},
+	},
+	{
+		name: "should deny creation when rateLimit requestsPerSecond is less than 1",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI: "registry.test.local",
+				RateLimit: &v1alpha1.RateLimit{
+					RequestsPerSecond: 0,
+				},
+			},
+		},
+		expectedField: "spec.rateLimit",
+		expectedError: "requestsPerSecond must be at least 1",
+	},
+	{
+		name: "should deny creation when rateLimit burst is negative",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI: "registry.test.local",
+				RateLimit: &v1alpha1.RateLimit{
+					RequestsPerSecond: 1,
+					Burst:             -1,
+				},
+			},
+		},
+		expectedField: "spec.rateLimit",
+		expectedError: "burst must not be negative",
+	},
+	{
+		name: "should allow creation when authSecret is a dockerconfigjson Secret",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:        "registry.test.local",
+				AuthSecret: "registry-credentials",
+			},
+		},
+	},
+	{
+		name: "should warn when authSecret does not exist",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:        "registry.test.local",
+				AuthSecret: "missing-secret",
+			},
+		},
+		expectedWarnings: admission.Warnings{`spec.authSecret: cannot get Secret missing-secret: secrets "missing-secret" not found`},
+	},
+	{
+		name: "should warn when authSecret is not a dockerconfigjson Secret",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:        "registry.test.local",
+				AuthSecret: "opaque-secret",
+			},
+		},
+		expectedWarnings: admission.Warnings{"spec.authSecret: secret is not of type kubernetes.io/dockerconfigjson"},
+	},
+	{
+		name: "should warn when caBundle does not contain any certificate",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:      "registry.test.local",
+				CABundle: "not a certificate",
+			},
+		},
+		expectedWarnings: admission.Warnings{"spec.caBundle: the CA bundle does not contain any valid PEM encoded certificate"},
+	},
+	{
+		name: "should allow creation when scanJobs history limits are valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                            "registry.test.local",
+				SuccessfulScanJobsHistoryLimit: ptr.To[int32](3),
+				FailedScanJobsHistoryLimit:     ptr.To[int32](0),
+				ScanJobsHistoryMaxAge:          &metav1.Duration{Duration: 720 * time.Hour},
+			},
+		},
+	},
+	{
+		name: "should deny creation when successfulScanJobsHistoryLimit is negative",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                            "registry.test.local",
+				SuccessfulScanJobsHistoryLimit: ptr.To[int32](-1),
+			},
+		},
+		expectedField: "spec.successfulScanJobsHistoryLimit",
+		expectedError: "history limit must not be negative",
+	},
+	{
+		name: "should deny creation when failedScanJobsHistoryLimit is negative",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                        "registry.test.local",
+				FailedScanJobsHistoryLimit: ptr.To[int32](-1),
+			},
+		},
+		expectedField: "spec.failedScanJobsHistoryLimit",
+		expectedError: "history limit must not be negative",
+	},
+	{
+		name: "should deny creation when scanJobsHistoryMaxAge is not positive",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:                   "registry.test.local",
+				ScanJobsHistoryMaxAge: &metav1.Duration{Duration: 0},
+			},
+		},
+		expectedField: "spec.scanJobsHistoryMaxAge",
+		expectedError: "scanJobsHistoryMaxAge must be greater than 0",
+	},
+	{
+		name: "should allow creation when schedule, timeZone and blackoutWindows are valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:      "registry.test.local",
+				Schedule: "0 2 * * *",
+				TimeZone: "Europe/Berlin",
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "0 9 * * 1-5", Duration: metav1.Duration{Duration: 8 * time.Hour}},
+				},
+			},
+		},
+	},
+	{
+		name: "should deny creation when schedule is not a valid cron expression",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:      "registry.test.local",
+				Schedule: "0 2 * *",
+			},
+		},
+		expectedField: "spec.schedule",
+		expectedError: "invalid cron expression",
+	},
+	{
+		name: "should deny creation when schedule is used together with scanInterval",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:          "registry.test.local",
+				Schedule:     "0 2 * * *",
+				ScanInterval: &metav1.Duration{Duration: time.Hour},
+			},
+		},
+		expectedField: "spec.schedule",
+		expectedError: "cannot be used together with scanInterval",
+	},
+	{
+		name: "should deny creation when timeZone is not valid",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:      "registry.test.local",
+				Schedule: "0 2 * * *",
+				TimeZone: "Mars/Olympus_Mons",
+			},
+		},
+		expectedField: "spec.timeZone",
+		expectedError: "invalid time zone",
+	},
+	{
+		name: "should deny creation when a blackout window has no duration",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:      "registry.test.local",
+				Schedule: "0 2 * * *",
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "0 9 * * 1-5"},
+				},
+			},
+		},
+		expectedField: "spec.blackoutWindows",
+		expectedError: "must have a positive duration",
+	},
+	{
+		name: "should deny creation when blackoutWindows cover all the time",
+		registry: &v1alpha1.Registry{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-registry",
+				Namespace: "default",
+			},
+			Spec: v1alpha1.RegistrySpec{
+				URI:          "registry.test.local",
+				ScanInterval: &metav1.Duration{Duration: time.Hour},
+				BlackoutWindows: []v1alpha1.BlackoutWindow{
+					{Start: "0 * * * *", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+				},
+			},
+		},
+		expectedField: "spec.blackoutWindows",
+		expectedError: "no time found outside of the blackout windows",
+	},
+}
+
+// newRegistrySecretsClient returns a client holding a dockerconfigjson Secret and an opaque Secret.
+func newRegistrySecretsClient(t *testing.T) client.Reader {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "registry-credentials",
+					Namespace: "default",
+				},
+				Type: corev1.SecretTypeDockerConfigJson,
+				Data: map[string][]byte{
+					corev1.DockerConfigJsonKey: []byte(`{"auths":{"registry.test.local":{"auth":"dXNlcjpwYXNzd29yZA=="}}}`),
+				},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "opaque-secret",
+					Namespace: "default",
+				},
+				Type: corev1.SecretTypeOpaque,
+			},
+		).
+		Build()
 }
 
 func TestRegistryCustomValidator_ValidateCreate(t *testing.T) {
 	for _, test := range registryTestCases {
 		t.Run(test.name, func(t *testing.T) {
 			validator := &RegistryCustomValidator{
+				client: newRegistrySecretsClient(t),
 				logger: logr.Discard(),
 			}
 			warnings, err := validator.ValidateCreate(t.Context(), test.registry)
@@ -224,7 +647,7 @@ func TestRegistryCustomValidator_ValidateCreate(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			assert.Empty(t, warnings)
+			assert.Equal(t, test.expectedWarnings, warnings)
 		})
 	}
 }
@@ -233,6 +656,7 @@ func TestRegistryCustomValidator_ValidateUpdate(t *testing.T) {
 	for _, test := range registryTestCases {
 		t.Run(test.name, func(t *testing.T) {
 			validator := &RegistryCustomValidator{
+				client: newRegistrySecretsClient(t),
 				logger: logr.Discard(),
 			}
 
@@ -251,7 +675,7 @@ func TestRegistryCustomValidator_ValidateUpdate(t *testing.T) {
 				require.NoError(t, err)
 			}
 
-			assert.Empty(t, warnings)
+			assert.Equal(t, test.expectedWarnings, warnings)
 		})
 	}
 }
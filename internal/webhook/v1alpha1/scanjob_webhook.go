@@ -3,9 +3,11 @@ package v1alpha1
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/google/go-containerregistry/pkg/name"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
@@ -78,7 +80,7 @@ func (v *ScanJobCustomValidator) ValidateCreate(ctx context.Context, obj runtime
 	}
 	v.logger.Info("Validation for ScanJob upon creation", "name", scanJob.GetName())
 
-	var allErrs field.ErrorList
+	allErrs := validateScanJobScope(scanJob)
 
 	scanJobList := &v1alpha1.ScanJobList{}
 
@@ -88,9 +90,19 @@ func (v *ScanJobCustomValidator) ValidateCreate(ctx context.Context, obj runtime
 		return nil, apierrors.NewInternalError(fmt.Errorf("listing ScanJobs: %w", err))
 	}
 
+	nameOpts, err := v.registryNameOptions(ctx, scanJob)
+	if err != nil {
+		return nil, apierrors.NewInternalError(err)
+	}
+	selectors := scanJobSelectors(scanJob, nameOpts...)
 	for _, existingScanJob := range scanJobList.Items {
-		// Check if the a ScanJob with the same registry is already running
-		if !existingScanJob.IsComplete() && !existingScanJob.IsFailed() {
+		if existingScanJob.IsComplete() || existingScanJob.IsFailed() || existingScanJob.IsCancelled() {
+			continue
+		}
+
+		// ScanJobs scanning the same images would record their progress on the same VulnerabilityReports,
+		// so only the scoped ScanJobs scanning different images can run together.
+		if selectorsOverlap(selectors, scanJobSelectors(&existingScanJob, nameOpts...)) {
 			fieldPath := field.NewPath("spec").Child("registry")
 			allErrs = append(allErrs, field.Forbidden(fieldPath, fmt.Sprintf("a ScanJob for the registry %q is already running", scanJob.Spec.Registry)))
 			break
@@ -125,6 +137,26 @@ func (v *ScanJobCustomValidator) ValidateUpdate(_ context.Context, oldObj, newOb
 		allErrs = append(allErrs, field.Invalid(fieldPath, newJob.Spec.Registry, "field is immutable"))
 	}
 
+	if !slices.Equal(oldJob.Spec.Repositories, newJob.Spec.Repositories) {
+		fieldPath := field.NewPath("spec").Child("repositories")
+		allErrs = append(allErrs, field.Invalid(fieldPath, newJob.Spec.Repositories, "field is immutable"))
+	}
+
+	if !slices.Equal(oldJob.Spec.Tags, newJob.Spec.Tags) {
+		fieldPath := field.NewPath("spec").Child("tags")
+		allErrs = append(allErrs, field.Invalid(fieldPath, newJob.Spec.Tags, "field is immutable"))
+	}
+
+	if !slices.Equal(oldJob.Spec.Images, newJob.Spec.Images) {
+		fieldPath := field.NewPath("spec").Child("images")
+		allErrs = append(allErrs, field.Invalid(fieldPath, newJob.Spec.Images, "field is immutable"))
+	}
+
+	if oldJob.Spec.Cancel && !newJob.Spec.Cancel {
+		fieldPath := field.NewPath("spec").Child("cancel")
+		allErrs = append(allErrs, field.Invalid(fieldPath, newJob.Spec.Cancel, "a cancelled ScanJob cannot be resumed"))
+	}
+
 	if len(allErrs) > 0 {
 		return nil, apierrors.NewInvalid(
 			v1alpha1.GroupVersion.WithKind("ScanJob").GroupKind(),
@@ -135,6 +167,111 @@ func (v *ScanJobCustomValidator) ValidateUpdate(_ context.Context, oldObj, newOb
 	return nil, nil
 }
 
+// validateScanJobScope validates the repositories, tags and images the ScanJob is restricted to.
+// registryNameOptions returns the options to parse the repositories and images of the ScanJob,
+// relative to the registry.
+func (v *ScanJobCustomValidator) registryNameOptions(ctx context.Context, scanJob *v1alpha1.ScanJob) ([]name.Option, error) {
+	registry := &v1alpha1.Registry{}
+	err := v.client.Get(ctx, client.ObjectKey{Name: scanJob.Spec.Registry, Namespace: scanJob.Namespace}, registry)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting Registry: %w", err)
+	}
+
+	reg, err := name.NewRegistry(registry.Spec.URI)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Registry URI: %w", err)
+	}
+
+	return []name.Option{name.WithDefaultRegistry(reg.Name())}, nil
+}
+
+// imageSelector selects the images of a repository with the given identifier, a tag or a digest.
+// Empty fields select all the repositories or identifiers.
+type imageSelector struct {
+	repository string
+	identifier string
+}
+
+// scanJobSelectors returns the selectors of the images scanned by the ScanJob.
+// Invalid references are ignored, they are reported by validateScanJobScope.
+func scanJobSelectors(scanJob *v1alpha1.ScanJob, opts ...name.Option) []imageSelector {
+	if len(scanJob.Spec.Images) > 0 {
+		selectors := make([]imageSelector, 0, len(scanJob.Spec.Images))
+		for _, image := range scanJob.Spec.Images {
+			ref, err := name.ParseReference(image, opts...)
+			if err != nil {
+				continue
+			}
+			selectors = append(selectors, imageSelector{repository: ref.Context().RepositoryStr(), identifier: ref.Identifier()})
+		}
+		return selectors
+	}
+
+	repositories := []string{""}
+	if len(scanJob.Spec.Repositories) > 0 {
+		repositories = nil
+		for _, repository := range scanJob.Spec.Repositories {
+			repo, err := name.NewRepository(repository, opts...)
+			if err != nil {
+				continue
+			}
+			repositories = append(repositories, repo.RepositoryStr())
+		}
+	}
+	identifiers := []string{""}
+	if len(scanJob.Spec.Tags) > 0 {
+		identifiers = scanJob.Spec.Tags
+	}
+
+	selectors := make([]imageSelector, 0, len(repositories)*len(identifiers))
+	for _, repository := range repositories {
+		for _, identifier := range identifiers {
+			selectors = append(selectors, imageSelector{repository: repository, identifier: identifier})
+		}
+	}
+	return selectors
+}
+
+// selectorsOverlap returns true if an image can be selected by both lists of selectors.
+func selectorsOverlap(selectors, otherSelectors []imageSelector) bool {
+	for _, selector := range selectors {
+		for _, other := range otherSelectors {
+			if (selector.repository == "" || other.repository == "" || selector.repository == other.repository) &&
+				(selector.identifier == "" || other.identifier == "" || selector.identifier == other.identifier) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateScanJobScope(scanJob *v1alpha1.ScanJob) field.ErrorList {
+	var allErrs field.ErrorList
+
+	imagesPath := field.NewPath("spec").Child("images")
+	if len(scanJob.Spec.Images) > 0 && (len(scanJob.Spec.Repositories) > 0 || len(scanJob.Spec.Tags) > 0) {
+		allErrs = append(allErrs, field.Forbidden(imagesPath, "images cannot be combined with repositories or tags"))
+	}
+
+	for i, image := range scanJob.Spec.Images {
+		if _, err := name.ParseReference(image); err != nil {
+			allErrs = append(allErrs, field.Invalid(imagesPath.Index(i), image, fmt.Sprintf("invalid image reference: %s", err)))
+		}
+	}
+
+	tagsPath := field.NewPath("spec").Child("tags")
+	for i, tag := range scanJob.Spec.Tags {
+		if _, err := name.NewTag("repository:" + tag); err != nil {
+			allErrs = append(allErrs, field.Invalid(tagsPath.Index(i), tag, fmt.Sprintf("invalid tag: %s", err)))
+		}
+	}
+
+	return allErrs
+}
+
 // ValidateDelete validates the object on deletion.
 func (v *ScanJobCustomValidator) ValidateDelete(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
 	scanJob, ok := obj.(*v1alpha1.ScanJob)
@@ -167,6 +167,224 @@ func TestScanJobCustomValidator_ValidateCreate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "should admit creation when existing job with same registry was cancelled",
+			existingScanJob: func() *v1alpha1.ScanJob {
+				job := &v1alpha1.ScanJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "existing-job",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.ScanJobSpec{
+						Registry: "registry.example.com",
+						Cancel:   true,
+					},
+				}
+				job.InitializeConditions()
+				job.MarkCancelled(v1alpha1.ReasonCancelled, "Cancelled")
+				return job
+			}(),
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+				},
+			},
+		},
+		{
+			name: "should deny creation when existing job with same registry is suspended",
+			existingScanJob: func() *v1alpha1.ScanJob {
+				job := &v1alpha1.ScanJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "existing-job",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.ScanJobSpec{
+						Registry: "registry.example.com",
+						Suspend:  true,
+					},
+				}
+				job.MarkSuspended()
+				return job
+			}(),
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+				},
+			},
+			expectedError: "is already running",
+			expectedField: "spec.registry",
+		},
+		{
+			name: "should deny creation of a scoped job when existing job with same registry is in progress",
+			existingScanJob: func() *v1alpha1.ScanJob {
+				job := &v1alpha1.ScanJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "existing-job",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.ScanJobSpec{
+						Registry: "registry.example.com",
+					},
+				}
+				job.InitializeConditions()
+				job.MarkInProgress(v1alpha1.ReasonInProgress, "In progress")
+				return job
+			}(),
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+					Images:   []string{"myrepo:1.2.3"},
+				},
+			},
+			expectedError: "is already running",
+			expectedField: "spec.registry",
+		},
+		{
+			name: "should deny creation when existing scoped job with same registry is in progress",
+			existingScanJob: func() *v1alpha1.ScanJob {
+				job := &v1alpha1.ScanJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "existing-job",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.ScanJobSpec{
+						Registry:     "registry.example.com",
+						Repositories: []string{"myrepo"},
+					},
+				}
+				job.InitializeConditions()
+				job.MarkInProgress(v1alpha1.ReasonInProgress, "In progress")
+				return job
+			}(),
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+				},
+			},
+			expectedError: "is already running",
+			expectedField: "spec.registry",
+		},
+		{
+			name: "should deny creation of a scoped job scanning the images of an existing scoped job in progress",
+			existingScanJob: func() *v1alpha1.ScanJob {
+				job := &v1alpha1.ScanJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "existing-job",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.ScanJobSpec{
+						Registry:     "registry.example.com",
+						Repositories: []string{"myrepo"},
+						Tags:         []string{"1.2.3", "1.2.4"},
+					},
+				}
+				job.InitializeConditions()
+				job.MarkInProgress(v1alpha1.ReasonInProgress, "In progress")
+				return job
+			}(),
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+					Images:   []string{"registry.example.com/myrepo:1.2.4"},
+				},
+			},
+			expectedError: "is already running",
+			expectedField: "spec.registry",
+		},
+		{
+			name: "should admit creation of a scoped job scanning other images than an existing scoped job in progress",
+			existingScanJob: func() *v1alpha1.ScanJob {
+				job := &v1alpha1.ScanJob{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "existing-job",
+						Namespace: "default",
+					},
+					Spec: v1alpha1.ScanJobSpec{
+						Registry:     "registry.example.com",
+						Repositories: []string{"myrepo"},
+					},
+				}
+				job.InitializeConditions()
+				job.MarkInProgress(v1alpha1.ReasonInProgress, "In progress")
+				return job
+			}(),
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+					Images:   []string{"otherrepo:1.2.3"},
+				},
+			},
+		},
+		{
+			name: "should deny creation when images are combined with tags",
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+					Tags:     []string{"1.2.3"},
+					Images:   []string{"myrepo:1.2.3"},
+				},
+			},
+			expectedError: "cannot be combined",
+			expectedField: "spec.images",
+		},
+		{
+			name: "should deny creation when an image reference is invalid",
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+					Images:   []string{"myrepo@sha256:invalid"},
+				},
+			},
+			expectedError: "invalid image reference",
+			expectedField: "spec.images[0]",
+		},
+		{
+			name: "should deny creation when a tag is invalid",
+			scanJob: &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+					Tags:     []string{"invalid/tag"},
+				},
+			},
+			expectedError: "invalid tag",
+			expectedField: "spec.tags[0]",
+		},
 	}
 
 	for _, test := range tests {
@@ -187,6 +405,17 @@ func TestScanJobCustomValidator_ValidateCreate(t *testing.T) {
 				Build()
 			validator := ScanJobCustomValidator{client: client}
 
+			registry := &v1alpha1.Registry{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "registry.example.com",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.RegistrySpec{
+					URI: "registry.example.com",
+				},
+			}
+			require.NoError(t, client.Create(t.Context(), registry))
+
 			if test.existingScanJob != nil {
 				require.NoError(t, client.Create(t.Context(), test.existingScanJob))
 			}
@@ -250,3 +479,63 @@ func TestScanJobCustomValidator_ValidateUpdate(t *testing.T) {
 
 	assert.Empty(t, warnings)
 }
+
+func TestScanJobCustomValidator_ValidateUpdate_Cancel(t *testing.T) {
+	tests := []struct {
+		name          string
+		oldCancel     bool
+		newCancel     bool
+		expectedError string
+	}{
+		{
+			name:      "should admit cancelling a ScanJob",
+			oldCancel: false,
+			newCancel: true,
+		},
+		{
+			name:          "should deny resuming a cancelled ScanJob",
+			oldCancel:     true,
+			newCancel:     false,
+			expectedError: "cannot be resumed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oldObj := &v1alpha1.ScanJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-scan-job",
+					Namespace: "default",
+				},
+				Spec: v1alpha1.ScanJobSpec{
+					Registry: "registry.example.com",
+					Cancel:   test.oldCancel,
+				},
+			}
+			newObj := oldObj.DeepCopy()
+			newObj.Spec.Cancel = test.newCancel
+
+			scheme := runtime.NewScheme()
+			require.NoError(t, v1alpha1.AddToScheme(scheme))
+			client := fake.NewClientBuilder().WithScheme(scheme).Build()
+			validator := ScanJobCustomValidator{client: client}
+
+			warnings, err := validator.ValidateUpdate(t.Context(), oldObj, newObj)
+
+			if test.expectedError != "" {
+				require.Error(t, err)
+				statusErr, ok := err.(interface{ Status() metav1.Status })
+				require.True(t, ok)
+				details := statusErr.Status().Details
+				require.NotNil(t, details)
+				require.Len(t, details.Causes, 1)
+				assert.Equal(t, "spec.cancel", details.Causes[0].Field)
+				assert.Contains(t, details.Causes[0].Message, test.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Empty(t, warnings)
+		})
+	}
+}
@@ -241,6 +241,15 @@ func (b *ImageApplyConfiguration) WithDigest(value string) *ImageApplyConfigurat
 	return b
 }
 
+// WithBaseImage sets the BaseImage field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the BaseImage field is set to the value of the last call.
+func (b *ImageApplyConfiguration) WithBaseImage(value *BaseImageApplyConfiguration) *ImageApplyConfiguration {
+	b.ensureImageMetadataApplyConfigurationExists()
+	b.ImageMetadataApplyConfiguration.BaseImage = value
+	return b
+}
+
 func (b *ImageApplyConfiguration) ensureImageMetadataApplyConfigurationExists() {
 	if b.ImageMetadataApplyConfiguration == nil {
 		b.ImageMetadataApplyConfiguration = &ImageMetadataApplyConfiguration{}
@@ -5,9 +5,10 @@ package v1alpha1
 // ImageLayerApplyConfiguration represents a declarative configuration of the ImageLayer type for use
 // with apply.
 type ImageLayerApplyConfiguration struct {
-	Command *string `json:"command,omitempty"`
-	Digest  *string `json:"digest,omitempty"`
-	DiffID  *string `json:"diffID,omitempty"`
+	Command   *string `json:"command,omitempty"`
+	Digest    *string `json:"digest,omitempty"`
+	DiffID    *string `json:"diffID,omitempty"`
+	BaseImage *bool   `json:"baseImage,omitempty"`
 }
 
 // ImageLayerApplyConfiguration constructs a declarative configuration of the ImageLayer type for use with
@@ -39,3 +40,11 @@ func (b *ImageLayerApplyConfiguration) WithDiffID(value string) *ImageLayerApply
 	b.DiffID = &value
 	return b
 }
+
+// WithBaseImage sets the BaseImage field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the BaseImage field is set to the value of the last call.
+func (b *ImageLayerApplyConfiguration) WithBaseImage(value bool) *ImageLayerApplyConfiguration {
+	b.BaseImage = &value
+	return b
+}
@@ -5,12 +5,13 @@ package v1alpha1
 // ImageMetadataApplyConfiguration represents a declarative configuration of the ImageMetadata type for use
 // with apply.
 type ImageMetadataApplyConfiguration struct {
-	Registry    *string `json:"registry,omitempty"`
-	RegistryURI *string `json:"registryURI,omitempty"`
-	Repository  *string `json:"repository,omitempty"`
-	Tag         *string `json:"tag,omitempty"`
-	Platform    *string `json:"platform,omitempty"`
-	Digest      *string `json:"digest,omitempty"`
+	Registry    *string                      `json:"registry,omitempty"`
+	RegistryURI *string                      `json:"registryURI,omitempty"`
+	Repository  *string                      `json:"repository,omitempty"`
+	Tag         *string                      `json:"tag,omitempty"`
+	Platform    *string                      `json:"platform,omitempty"`
+	Digest      *string                      `json:"digest,omitempty"`
+	BaseImage   *BaseImageApplyConfiguration `json:"baseImage,omitempty"`
 }
 
 // ImageMetadataApplyConfiguration constructs a declarative configuration of the ImageMetadata type for use with
@@ -66,3 +67,11 @@ func (b *ImageMetadataApplyConfiguration) WithDigest(value string) *ImageMetadat
 	b.Digest = &value
 	return b
 }
+
+// WithBaseImage sets the BaseImage field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the BaseImage field is set to the value of the last call.
+func (b *ImageMetadataApplyConfiguration) WithBaseImage(value *BaseImageApplyConfiguration) *ImageMetadataApplyConfiguration {
+	b.BaseImage = value
+	return b
+}
@@ -2,11 +2,21 @@
 
 package v1alpha1
 
+import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // ReportApplyConfiguration represents a declarative configuration of the Report type for use
 // with apply.
 type ReportApplyConfiguration struct {
-	Summary *SummaryApplyConfiguration `json:"summary,omitempty"`
-	Results []ResultApplyConfiguration `json:"results,omitempty"`
+	Summary           *SummaryApplyConfiguration               `json:"summary,omitempty"`
+	Results           []ResultApplyConfiguration               `json:"results,omitempty"`
+	Scanner           *string                                  `json:"scanner,omitempty"`
+	Database          *VulnerabilityDatabaseApplyConfiguration `json:"database,omitempty"`
+	Layers            []LayerReportApplyConfiguration          `json:"layers,omitempty"`
+	BaseImageUpgrades []BaseImageUpgradeApplyConfiguration     `json:"baseImageUpgrades,omitempty"`
+	Delta             *ReportDeltaApplyConfiguration           `json:"delta,omitempty"`
+	ScannedAt         *v1.Time                                 `json:"scannedAt,omitempty"`
 }
 
 // ReportApplyConfiguration constructs a declarative configuration of the Report type for use with
@@ -35,3 +45,61 @@ func (b *ReportApplyConfiguration) WithResults(values ...*ResultApplyConfigurati
 	}
 	return b
 }
+
+// WithScanner sets the Scanner field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Scanner field is set to the value of the last call.
+func (b *ReportApplyConfiguration) WithScanner(value string) *ReportApplyConfiguration {
+	b.Scanner = &value
+	return b
+}
+
+// WithDatabase sets the Database field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Database field is set to the value of the last call.
+func (b *ReportApplyConfiguration) WithDatabase(value *VulnerabilityDatabaseApplyConfiguration) *ReportApplyConfiguration {
+	b.Database = value
+	return b
+}
+
+// WithLayers adds the given value to the Layers field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the Layers field.
+func (b *ReportApplyConfiguration) WithLayers(values ...*LayerReportApplyConfiguration) *ReportApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithLayers")
+		}
+		b.Layers = append(b.Layers, *values[i])
+	}
+	return b
+}
+
+// WithBaseImageUpgrades adds the given value to the BaseImageUpgrades field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the BaseImageUpgrades field.
+func (b *ReportApplyConfiguration) WithBaseImageUpgrades(values ...*BaseImageUpgradeApplyConfiguration) *ReportApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithBaseImageUpgrades")
+		}
+		b.BaseImageUpgrades = append(b.BaseImageUpgrades, *values[i])
+	}
+	return b
+}
+
+// WithDelta sets the Delta field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Delta field is set to the value of the last call.
+func (b *ReportApplyConfiguration) WithDelta(value *ReportDeltaApplyConfiguration) *ReportApplyConfiguration {
+	b.Delta = value
+	return b
+}
+
+// WithScannedAt sets the ScannedAt field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ScannedAt field is set to the value of the last call.
+func (b *ReportApplyConfiguration) WithScannedAt(value v1.Time) *ReportApplyConfiguration {
+	b.ScannedAt = &value
+	return b
+}
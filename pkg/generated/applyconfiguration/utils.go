@@ -16,6 +16,10 @@ import (
 func ForKind(kind schema.GroupVersionKind) interface{} {
 	switch kind {
 	// Group=storage.sbomscanner.kubewarden.io, Version=v1alpha1
+	case v1alpha1.SchemeGroupVersion.WithKind("BaseImage"):
+		return &storagev1alpha1.BaseImageApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("BaseImageUpgrade"):
+		return &storagev1alpha1.BaseImageUpgradeApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("CVSS"):
 		return &storagev1alpha1.CVSSApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("Image"):
@@ -24,8 +28,12 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 		return &storagev1alpha1.ImageLayerApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("ImageMetadata"):
 		return &storagev1alpha1.ImageMetadataApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("LayerReport"):
+		return &storagev1alpha1.LayerReportApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("Report"):
 		return &storagev1alpha1.ReportApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("ReportDelta"):
+		return &storagev1alpha1.ReportDeltaApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("Result"):
 		return &storagev1alpha1.ResultApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("SBOM"):
@@ -36,6 +44,10 @@ func ForKind(kind schema.GroupVersionKind) interface{} {
 		return &storagev1alpha1.VEXStatusApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("Vulnerability"):
 		return &storagev1alpha1.VulnerabilityApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("VulnerabilityChange"):
+		return &storagev1alpha1.VulnerabilityChangeApplyConfiguration{}
+	case v1alpha1.SchemeGroupVersion.WithKind("VulnerabilityDatabase"):
+		return &storagev1alpha1.VulnerabilityDatabaseApplyConfiguration{}
 	case v1alpha1.SchemeGroupVersion.WithKind("VulnerabilityReport"):
 		return &storagev1alpha1.VulnerabilityReportApplyConfiguration{}
 
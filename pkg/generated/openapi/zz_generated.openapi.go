@@ -13,18 +13,24 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
+		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.BaseImage":               schema_sbomscanner_api_storage_v1alpha1_BaseImage(ref),
+		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.BaseImageUpgrade":        schema_sbomscanner_api_storage_v1alpha1_BaseImageUpgrade(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.CVSS":                    schema_sbomscanner_api_storage_v1alpha1_CVSS(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Image":                   schema_sbomscanner_api_storage_v1alpha1_Image(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.ImageLayer":              schema_sbomscanner_api_storage_v1alpha1_ImageLayer(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.ImageList":               schema_sbomscanner_api_storage_v1alpha1_ImageList(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.ImageMetadata":           schema_sbomscanner_api_storage_v1alpha1_ImageMetadata(ref),
+		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.LayerReport":             schema_sbomscanner_api_storage_v1alpha1_LayerReport(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Report":                  schema_sbomscanner_api_storage_v1alpha1_Report(ref),
+		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.ReportDelta":             schema_sbomscanner_api_storage_v1alpha1_ReportDelta(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Result":                  schema_sbomscanner_api_storage_v1alpha1_Result(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.SBOM":                    schema_sbomscanner_api_storage_v1alpha1_SBOM(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.SBOMList":                schema_sbomscanner_api_storage_v1alpha1_SBOMList(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Summary":                 schema_sbomscanner_api_storage_v1alpha1_Summary(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VEXStatus":               schema_sbomscanner_api_storage_v1alpha1_VEXStatus(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Vulnerability":           schema_sbomscanner_api_storage_v1alpha1_Vulnerability(ref),
+		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityChange":     schema_sbomscanner_api_storage_v1alpha1_VulnerabilityChange(ref),
+		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityDatabase":   schema_sbomscanner_api_storage_v1alpha1_VulnerabilityDatabase(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityReport":     schema_sbomscanner_api_storage_v1alpha1_VulnerabilityReport(ref),
 		"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityReportList": schema_sbomscanner_api_storage_v1alpha1_VulnerabilityReportList(ref),
 		"k8s.io/apimachinery/pkg/apis/meta/v1.APIGroup":                                  schema_pkg_apis_meta_v1_APIGroup(ref),
@@ -83,6 +89,103 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 	}
 }
 
+func schema_sbomscanner_api_storage_v1alpha1_BaseImage(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BaseImage contains the reference of the image another image was built from.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"registryURI": {
+						SchemaProps: spec.SchemaProps{
+							Description: "RegistryURI specifies the URI of the registry where the base image is stored. Example: \"index.docker.io\".",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"repository": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Repository specifies the repository path of the base image. Example: \"library/alpine\".",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"tag": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Tag specifies the tag of the base image, when known. Example: \"3.20\".",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"digest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Digest specifies the sha256 digest of the base image, when known.",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"source": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Source specifies how the base image was detected. Either \"Annotations\" or \"Layers\".",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"registryURI", "repository", "source"},
+			},
+		},
+	}
+}
+
+func schema_sbomscanner_api_storage_v1alpha1_BaseImageUpgrade(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "BaseImageUpgrade contains the CVEs fixed by moving to a newer tag of the base image repository.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"tag": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Tag of the base image repository",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"digest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Digest of the image the tag points to",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"fixedCVEs": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FixedCVEs are the CVEs of the base image layers not affecting the tag",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"tag", "digest", "fixedCVEs"},
+			},
+		},
+	}
+}
+
 func schema_sbomscanner_api_storage_v1alpha1_CVSS(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -201,6 +304,13 @@ func schema_sbomscanner_api_storage_v1alpha1_ImageLayer(ref common.ReferenceCall
 							Format:      "",
 						},
 					},
+					"baseImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "baseImage tells if the layer belongs to the base image, rather than being created by the image build",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
 				},
 				Required: []string{"command", "digest", "diffID"},
 			},
@@ -312,10 +422,86 @@ func schema_sbomscanner_api_storage_v1alpha1_ImageMetadata(ref common.ReferenceC
 							Format:      "",
 						},
 					},
+					"baseImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BaseImage specifies the image the image was built from, when it could be detected.",
+							Ref:         ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.BaseImage"),
+						},
+					},
 				},
 				Required: []string{"registry", "registryURI", "repository", "tag", "platform", "digest"},
 			},
 		},
+		Dependencies: []string{
+			"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.BaseImage"},
+	}
+}
+
+func schema_sbomscanner_api_storage_v1alpha1_LayerReport(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "LayerReport contains the vulnerabilities introduced by an image layer.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"diffID": {
+						SchemaProps: spec.SchemaProps{
+							Description: "DiffID is the Hash of the uncompressed layer",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"digest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Digest is the Hash of the compressed layer",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"command": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Command is the command that led to the creation of the layer",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"baseImage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BaseImage tells if the layer belongs to the base image. Vulnerabilities of base image layers are fixed by updating the base image, the other ones by changing the image build.",
+							Default:     false,
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"summary": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Summary of the vulnerabilities introduced by the layer",
+							Default:     map[string]interface{}{},
+							Ref:         ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Summary"),
+						},
+					},
+					"cves": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CVEs introduced by the layer, excluding the suppressed ones",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: "",
+										Type:    []string{"string"},
+										Format:  "",
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"diffID", "baseImage", "summary", "cves"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Summary"},
 	}
 }
 
@@ -347,12 +533,130 @@ func schema_sbomscanner_api_storage_v1alpha1_Report(ref common.ReferenceCallback
 							},
 						},
 					},
+					"scanner": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Scanner is the engine that produced the report (e.g., \"trivy\", \"grype\")",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"database": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Database identifies the vulnerability database used by the scanner",
+							Ref:         ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityDatabase"),
+						},
+					},
+					"layers": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Layers groups the vulnerabilities by the image layer introducing them",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.LayerReport"),
+									},
+								},
+							},
+						},
+					},
+					"baseImageUpgrades": {
+						SchemaProps: spec.SchemaProps{
+							Description: "BaseImageUpgrades lists the newer tags of the base image repository, with the CVEs they fix",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.BaseImageUpgrade"),
+									},
+								},
+							},
+						},
+					},
+					"delta": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Delta contains the changes since the previous scan of the image (empty on the first scan)",
+							Ref:         ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.ReportDelta"),
+						},
+					},
+					"scannedAt": {
+						SchemaProps: spec.SchemaProps{
+							Description: "ScannedAt is when the image was last scanned",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
 				},
 				Required: []string{"summary", "results"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Result", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Summary"},
+			"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.BaseImageUpgrade", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.LayerReport", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.ReportDelta", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Result", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.Summary", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityDatabase", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_sbomscanner_api_storage_v1alpha1_ReportDelta(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ReportDelta contains the changes of the vulnerabilities between two successive scans of an image. Suppressed vulnerabilities are ignored.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"previousScanJobUID": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PreviousScanJobUID is the UID of the ScanJob of the previous scan",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"introduced": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Introduced lists the vulnerabilities not found by the previous scan",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityChange"),
+									},
+								},
+							},
+						},
+					},
+					"fixed": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Fixed lists the vulnerabilities found by the previous scan only",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityChange"),
+									},
+								},
+							},
+						},
+					},
+					"severityChanges": {
+						SchemaProps: spec.SchemaProps{
+							Description: "SeverityChanges lists the vulnerabilities whose severity changed since the previous scan",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Default: map[string]interface{}{},
+										Ref:     ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityChange"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"introduced", "fixed", "severityChanges"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VulnerabilityChange"},
 	}
 }
 
@@ -741,12 +1045,100 @@ func schema_sbomscanner_api_storage_v1alpha1_Vulnerability(ref common.ReferenceC
 							Ref:         ref("github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VEXStatus"),
 						},
 					},
+					"firstSeen": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FirstSeen is when the vulnerability was found in the image for the first time",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
 				},
 				Required: []string{"cve", "purl", "installedVersion", "diffID", "severity", "suppressed"},
 			},
 		},
 		Dependencies: []string{
-			"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.CVSS", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VEXStatus"},
+			"github.com/kubewarden/sbomscanner/api/storage/v1alpha1.CVSS", "github.com/kubewarden/sbomscanner/api/storage/v1alpha1.VEXStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
+func schema_sbomscanner_api_storage_v1alpha1_VulnerabilityChange(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "VulnerabilityChange identifies a vulnerability of a package that changed between two scans.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"cve": {
+						SchemaProps: spec.SchemaProps{
+							Description: "CVE identifier",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"purl": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PURL (Package URL) of the vulnerable package",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"severity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Severity rating of the vulnerability",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"previousSeverity": {
+						SchemaProps: spec.SchemaProps{
+							Description: "PreviousSeverity is the severity rating of the vulnerability in the previous scan (set for severity changes only)",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"cve", "purl", "severity"},
+			},
+		},
+	}
+}
+
+func schema_sbomscanner_api_storage_v1alpha1_VulnerabilityDatabase(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "VulnerabilityDatabase identifies a version of a vulnerability database.",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"version": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Version is the schema version of the database",
+							Default:     "",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"updatedAt": {
+						SchemaProps: spec.SchemaProps{
+							Description: "UpdatedAt is the time the database was built",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+					"digest": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Digest of the database OCI artifact, when downloaded from a registry",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+				},
+				Required: []string{"version"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
 	}
 }
 